@@ -1,5 +1,5 @@
 import SafeComponent from "components/SafeComponent"
-import { AdminChallengeConfig, ChallengeCategory, FlagType, JudgeType } from "utils/A1API";
+import { AdminChallengeConfig, ChallengeCategory, FlagMatchMode, FlagType, JudgeType } from "utils/A1API";
 import { EditChallengeView } from "components/admin/EditChallengeView";
 
 export default function Home() {
@@ -10,7 +10,10 @@ export default function Home() {
         judge_config: {
             judge_type: JudgeType.DYNAMIC,
             judge_script: null,
-            flag_template: ""
+            flag_template: "",
+            flag_match_mode: FlagMatchMode.EXACT,
+            trim_space: false,
+            accepted_flags: []
         },
         container_config: [],
         attachments: [],
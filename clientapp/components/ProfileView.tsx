@@ -5,11 +5,12 @@ import { MacScrollbar } from "mac-scrollbar";
 import { useEffect, useState } from "react";
 import { useNavigate, useParams } from "react-router";
 import { useTheme } from "next-themes";
-import { Accessibility, Ellipsis, KeyRound, Mail, UserRoundPen } from "lucide-react";
+import { Accessibility, Ellipsis, KeyRound, Link2, Mail, UserRoundPen } from "lucide-react";
 import UserBaiscInfo from "./user/profile/UserBaiscInfo";
 import { PasswordView } from "./user/profile/PasswordView";
 import EmailSettings from "./user/profile/EmailSettings";
 import DeleteAccount from "./user/profile/DeleteAccount";
+import SsoAccounts from "./user/profile/SsoAccounts";
 
 import {
     DropdownMenu,
@@ -43,6 +44,11 @@ export function ProfileView() {
             name: t("email_setting"),
             icon: <Mail className="h-4 w-4" />
         },
+        {
+            id: "sso",
+            name: t("sso_accounts"),
+            icon: <Link2 className="h-4 w-4" />
+        },
         {
             id: "mambaout",
             name: t("rm_rf"),
@@ -140,6 +146,10 @@ export function ProfileView() {
                                 <EmailSettings />
                             )}
 
+                            {activeModule == "sso" && (
+                                <SsoAccounts />
+                            )}
+
                             {activeModule == "mambaout" && (
                                 <DeleteAccount />
                             )}
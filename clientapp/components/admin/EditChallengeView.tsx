@@ -40,6 +40,7 @@ import { Switch } from "components/ui/switch";
 import { useTheme } from "next-themes";
 import LazyThemedEditor from "components/modules/LazyThemedEditor";
 import { useTranslation, Trans } from "react-i18next";
+import { FlagMatchConfigFields, cleanAcceptedFlags } from "components/admin/FlagMatchConfigFields";
 
 interface ContainerFormProps {
     control: any;
@@ -494,6 +495,9 @@ export function EditChallengeView({ challenge_info, isCreate = false }: { challe
             }),
             judge_script: z.string().optional(),
             flag_template: z.string().optional(),
+            flag_match_mode: z.enum(["EXACT", "CASE_INSENSITIVE", "REGEX"]).optional(),
+            trim_space: z.boolean().optional(),
+            accepted_flags: z.array(z.string()).optional(),
         }),
         allow_wan: z.boolean(),
         allow_dns: z.boolean(),
@@ -564,7 +568,10 @@ export function EditChallengeView({ challenge_info, isCreate = false }: { challe
             judge_config: {
                 judge_type: challenge_info.judge_config.judge_type,
                 judge_script: challenge_info.judge_config.judge_script || "",
-                flag_template: challenge_info.judge_config.flag_template
+                flag_template: challenge_info.judge_config.flag_template,
+                flag_match_mode: challenge_info.judge_config.flag_match_mode || "EXACT",
+                trim_space: challenge_info.judge_config.trim_space ?? false,
+                accepted_flags: challenge_info.judge_config.accepted_flags ?? [],
             },
             flag_type: challenge_info.flag_type,
             container_config: challenge_info.container_config.map((e) => ({
@@ -632,7 +639,7 @@ export function EditChallengeView({ challenge_info, isCreate = false }: { challe
             })),
             create_time: challenge_info.create_time,
             description: values.description,
-            judge_config: values.judge_config,
+            judge_config: cleanAcceptedFlags(values.judge_config),
             name: values.name,
             flag_type: values.flag_type
         };
@@ -891,6 +898,7 @@ export function EditChallengeView({ challenge_info, isCreate = false }: { challe
                                         </FormItem>
                                     )}
                                 />
+                                <FlagMatchConfigFields control={form.control} />
                             </>
                         )}
 
@@ -145,7 +145,13 @@ export function EditGameView({ game_info }: { game_info: AdminFullGameInfo }) {
             group_invite_code_enable: values.group_invite_code_enable,
             first_blood_reward: values.first_blood_reward,
             second_blood_reward: values.second_blood_reward,
-            third_blood_reward: values.third_blood_reward
+            third_blood_reward: values.third_blood_reward,
+            // 表单里没有编辑的配置原样传回去
+            blood_reward_config: game_info.blood_reward_config,
+            game_mode: game_info.game_mode,
+            attack_defense_config: game_info.attack_defense_config,
+            container_quota: game_info.container_quota,
+            container_lifetime: game_info.container_lifetime
         };
 
         if (!formEdited) return
@@ -0,0 +1,96 @@
+import {
+    FormControl,
+    FormDescription,
+    FormField,
+    FormItem,
+    FormLabel,
+    FormMessage,
+} from "components/ui/form";
+import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from "components/ui/select";
+import { Switch } from "components/ui/switch";
+import { Textarea } from "components/ui/textarea";
+import { useTranslation } from "react-i18next";
+
+/**
+ * Flag 匹配方式相关的表单项, 题目编辑和比赛题目设置共用
+ * 字段挂在 judge_config 下: flag_match_mode / trim_space / accepted_flags
+ */
+export function FlagMatchConfigFields({ control }: { control: any }) {
+    const { t } = useTranslation("challenge_edit")
+
+    return (
+        <>
+            <FormField
+                control={control}
+                name="judge_config.flag_match_mode"
+                render={({ field }) => (
+                    <FormItem>
+                        <div className="flex items-center h-[20px]">
+                            <FormLabel>{t("form.match.label")}</FormLabel>
+                            <div className="flex-1" />
+                            <FormMessage className="text-[14px]" />
+                        </div>
+                        <Select onValueChange={field.onChange} value={field.value || "EXACT"}>
+                            <FormControl>
+                                <SelectTrigger>
+                                    <SelectValue />
+                                </SelectTrigger>
+                            </FormControl>
+                            <SelectContent>
+                                <SelectItem value="EXACT">{t("form.match.exact")}</SelectItem>
+                                <SelectItem value="CASE_INSENSITIVE">{t("form.match.case_insensitive")}</SelectItem>
+                                <SelectItem value="REGEX">{t("form.match.regex")}</SelectItem>
+                            </SelectContent>
+                        </Select>
+                        <FormDescription>{t("form.match.description")}</FormDescription>
+                    </FormItem>
+                )}
+            />
+            <FormField
+                control={control}
+                name="judge_config.trim_space"
+                render={({ field }) => (
+                    <FormItem className="flex flex-row items-center justify-between rounded-xl border border-border/50 p-4">
+                        <div className="space-y-0.5">
+                            <FormLabel>{t("form.match.trim_space")}</FormLabel>
+                            <FormDescription>{t("form.match.trim_space_description")}</FormDescription>
+                        </div>
+                        <FormControl>
+                            <Switch checked={field.value ?? false} onCheckedChange={field.onChange} />
+                        </FormControl>
+                    </FormItem>
+                )}
+            />
+            <FormField
+                control={control}
+                name="judge_config.accepted_flags"
+                render={({ field }) => (
+                    <FormItem>
+                        <div className="flex items-center h-[20px]">
+                            <FormLabel>{t("form.match.accepted_flags")}</FormLabel>
+                            <div className="flex-1" />
+                            <FormMessage className="text-[14px]" />
+                        </div>
+                        <FormControl>
+                            {/* 保留空行方便输入, 提交时再过滤 */}
+                            <Textarea
+                                value={(field.value ?? []).join("\n")}
+                                onChange={(e) => field.onChange(e.target.value ? e.target.value.split("\n") : [])}
+                                className="h-[100px] font-mono"
+                            />
+                        </FormControl>
+                        <FormDescription>{t("form.match.accepted_flags_description")}</FormDescription>
+                    </FormItem>
+                )}
+            />
+        </>
+    )
+}
+
+/** 去掉 accepted_flags 里的空行 */
+export function cleanAcceptedFlags<T extends { accepted_flags?: string[] }>(judgeConfig: T): T {
+    return {
+        ...judgeConfig,
+        accepted_flags: (judgeConfig.accepted_flags ?? []).filter((flag) => flag.trim() != ""),
+    }
+}
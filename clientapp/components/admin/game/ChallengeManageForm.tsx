@@ -36,6 +36,7 @@ import { UploadFileDialog } from "components/dialogs/UploadFileDialog";
 import { Switch } from "components/ui/switch";
 import LazyThemedEditor from "components/modules/LazyThemedEditor";
 import useSWR from "swr";
+import { cleanAcceptedFlags } from "components/admin/FlagMatchConfigFields";
 
 interface ContainerFormProps {
     control: any;
@@ -491,6 +492,9 @@ export function ChallengeManageForm({ challengeInfo }: { challengeInfo: AdminCha
             }),
             judge_script: z.string().optional(),
             flag_template: z.string().optional(),
+            flag_match_mode: z.enum(["EXACT", "CASE_INSENSITIVE", "REGEX"]).optional(),
+            trim_space: z.boolean().optional(),
+            accepted_flags: z.array(z.string()).optional(),
         }),
         allow_wan: z.boolean(),
         allow_dns: z.boolean(),
@@ -563,7 +567,10 @@ export function ChallengeManageForm({ challengeInfo }: { challengeInfo: AdminCha
             judge_config: {
                 judge_type: challengeInfo?.judge_config.judge_type,
                 judge_script: challengeInfo?.judge_config.judge_script || "",
-                flag_template: challengeInfo?.judge_config.flag_template
+                flag_template: challengeInfo?.judge_config.flag_template,
+                flag_match_mode: challengeInfo?.judge_config.flag_match_mode || "EXACT",
+                trim_space: challengeInfo?.judge_config.trim_space ?? false,
+                accepted_flags: challengeInfo?.judge_config.accepted_flags ?? [],
             },
             flag_type: challengeInfo?.flag_type,
             container_config: challengeInfo?.container_config.map((e) => ({
@@ -631,7 +638,7 @@ export function ChallengeManageForm({ challengeInfo }: { challengeInfo: AdminCha
             })),
             create_time: challengeInfo?.create_time,
             description: values.description,
-            judge_config: values.judge_config,
+            judge_config: cleanAcceptedFlags(values.judge_config),
             name: values.name,
             flag_type: values.flag_type
         };
@@ -10,6 +10,7 @@ import {
 import { MacScrollbar } from "mac-scrollbar"
 import { ReactNode, useEffect, useState } from "react"
 import { JudgeConfigForm } from "./JudgeConfigForm"
+import { cleanAcceptedFlags } from "components/admin/FlagMatchConfigFields"
 import { useTheme } from "next-themes"
 import { useForm } from "react-hook-form"
 import { Form } from 'components/ui/form';
@@ -72,7 +73,8 @@ export default function ChallengeManageSheet(
     }, [isOpen])
 
     const handleSubmit = (values: z.infer<typeof GameChallengeSchema>) => {
-        api.admin.updateGameChallenge(gameID, challengeID, values as any as AdminDetailGameChallenge).then(() => {
+        const payload = { ...values, judge_config: cleanAcceptedFlags(values.judge_config) }
+        api.admin.updateGameChallenge(gameID, challengeID, payload as any as AdminDetailGameChallenge).then(() => {
             toast.success(t("update_success"))
         })
     }
@@ -34,6 +34,22 @@ export const GameChallengeSchema = z.object({
     difficulty: z.coerce.number().min(1, '请输入一个有效的数字'),
     minimal_score: z.coerce.number().min(0, '请输入一个有效的数字'),
     enable_blood_reward: z.boolean(),
+    // 旧题目的计分模式为空, 按指数衰减处理
+    scoring_mode: z.enum(['', 'STATIC', 'EXP_DECAY', 'LOGARITHMIC', 'LINEAR', 'TABLE']).optional(),
+    scoring_config: z.object({
+        decay: z.coerce.number().int('请输入一个整数').optional(),
+        score_table: z.array(z.number().min(0, '分数不能为负数')).optional(),
+    }).nullable().optional(),
+    // 旧题目的类型为空, 按普通题目处理
+    challenge_kind: z.enum(['', 'STANDARD', 'KOTH']).optional(),
+    koth_config: z.object({
+        tick_interval: z.coerce.number().int('请输入一个整数').min(5, '检查间隔不能小于 5 秒'),
+        points_per_tick: z.coerce.number().min(0, '分数不能为负数'),
+        token_source: z.enum(['FILE', 'HTTP']),
+        token_path: z.string().min(1, '请输入占领标记的路径'),
+        token_container: z.string().optional(),
+        token_port: z.string().optional(),
+    }).nullable().optional(),
     hints: z.array(
         z.object({
             content: z.string().optional(),
@@ -49,6 +65,9 @@ export const GameChallengeSchema = z.object({
         }),
         judge_script: z.string().optional(),
         flag_template: z.string().optional(),
+        flag_match_mode: z.enum(['EXACT', 'CASE_INSENSITIVE', 'REGEX']).optional(),
+        trim_space: z.boolean().optional(),
+        accepted_flags: z.array(z.string()).optional(),
     }),
 })
 
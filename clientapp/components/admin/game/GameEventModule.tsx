@@ -51,6 +51,16 @@ export function GameEventModule(
                 return "text-orange-600 border-orange-200 bg-orange-50 dark:text-orange-400 dark:border-orange-800 dark:bg-orange-950"
             case "SubmitWithoutStartContainer":
                 return "text-yellow-600 border-yellow-200 bg-yellow-50 dark:text-yellow-400 dark:border-yellow-800 dark:bg-yellow-950"
+            case "SharedIP":
+            case "SharedSubnet":
+            case "SharedAccount":
+                return "text-purple-600 border-purple-200 bg-purple-50 dark:text-purple-400 dark:border-purple-800 dark:bg-purple-950"
+            case "SubmitHoneypotFlag":
+            case "SubmitCanaryFlag":
+                return "text-rose-600 border-rose-200 bg-rose-50 dark:text-rose-400 dark:border-rose-800 dark:bg-rose-950"
+            case "SyncedSolves":
+            case "SameWrongFlag":
+                return "text-blue-600 border-blue-200 bg-blue-50 dark:text-blue-400 dark:border-blue-800 dark:bg-blue-950"
             default:
                 return "text-gray-600 border-gray-200 bg-gray-50 dark:text-gray-400 dark:border-gray-800 dark:bg-gray-950"
         }
@@ -64,6 +74,17 @@ export function GameEventModule(
                 return <TriangleAlert className="w-3 h-3" />
             case "SubmitWithoutStartContainer":
                 return <AlertCircle className="w-3 h-3" />
+            case "SharedIP":
+            case "SharedSubnet":
+                return <MapPin className="w-3 h-3" />
+            case "SharedAccount":
+                return <User className="w-3 h-3" />
+            case "SyncedSolves":
+                return <Clock className="w-3 h-3" />
+            case "SameWrongFlag":
+            case "SubmitHoneypotFlag":
+            case "SubmitCanaryFlag":
+                return <Flag className="w-3 h-3" />
             default:
                 return <Shield className="w-3 h-3" />
         }
@@ -77,6 +98,20 @@ export function GameEventModule(
                 return t("events.cheat.attachment")
             case "SubmitWithoutStartContainer":
                 return t("events.cheat.container")
+            case "SharedIP":
+                return t("events.cheat.shared_ip")
+            case "SharedSubnet":
+                return t("events.cheat.shared_subnet")
+            case "SyncedSolves":
+                return t("events.cheat.synced_solves")
+            case "SameWrongFlag":
+                return t("events.cheat.same_wrong_flag")
+            case "SharedAccount":
+                return t("events.cheat.shared_account")
+            case "SubmitHoneypotFlag":
+                return t("events.cheat.honeypot")
+            case "SubmitCanaryFlag":
+                return t("events.cheat.canary")
             default:
                 return type
         }
@@ -117,9 +152,9 @@ export function GameEventModule(
     const [cheatsTeamNames, setCheatsTeamNames] = useState<string[]>([])
     const [cheatsChallengeIds, setCheatsChallengeIds] = useState<number[]>([])
     const [cheatsTeamIds, setCheatsTeamIds] = useState<number[]>([])
-    type CheatType = "SubmitSomeonesFlag" | "SubmitWithoutDownloadAttachments" | "SubmitWithoutStartContainer"
+    type CheatType = "SubmitSomeonesFlag" | "SubmitWithoutDownloadAttachments" | "SubmitWithoutStartContainer" | "SharedIP" | "SharedSubnet" | "SyncedSolves" | "SameWrongFlag" | "SharedAccount" | "SubmitHoneypotFlag" | "SubmitCanaryFlag"
     const [cheatTypes, setCheatTypes] = useState<CheatType[]>([])
-    const cheatTypeOptions: CheatType[] = ["SubmitSomeonesFlag", "SubmitWithoutDownloadAttachments", "SubmitWithoutStartContainer"]
+    const cheatTypeOptions: CheatType[] = ["SubmitSomeonesFlag", "SubmitWithoutDownloadAttachments", "SubmitWithoutStartContainer", "SharedIP", "SharedSubnet", "SyncedSolves", "SameWrongFlag", "SharedAccount", "SubmitHoneypotFlag", "SubmitCanaryFlag"]
 
     const [curChoicedCategory, setCurChoicedCategory] = useState<string>("teamName")
 
@@ -724,24 +759,27 @@ export function GameEventModule(
                                         <div className="flex items-center flex-[2] gap-1 min-w-0" title={cheat.challenge_name}>
                                             <Trophy className="w-4 h-4 flex-shrink-0" />
                                             <span className="truncate">{cheat.challenge_name}</span>
-                                            <Badge
-                                                variant="outline"
-                                                className="text-xs select-none hover:bg-blue/10 hover:border-blue/30 cursor-pointer transition-all duration-200 rounded-md px-2 py-1 font-mono"
-                                                onClick={() => {
-                                                    gotoChallenge(cheat.challenge_id)
-                                                }}
-                                            >
-                                                #{cheat.challenge_id}
-                                            </Badge>
+                                            {cheat.challenge_id && (
+                                                <Badge
+                                                    variant="outline"
+                                                    className="text-xs select-none hover:bg-blue/10 hover:border-blue/30 cursor-pointer transition-all duration-200 rounded-md px-2 py-1 font-mono"
+                                                    onClick={() => {
+                                                        gotoChallenge(cheat.challenge_id!)
+                                                    }}
+                                                >
+                                                    #{cheat.challenge_id}
+                                                </Badge>
+                                            )}
                                         </div>
                                         <div className="flex items-center flex-[2] gap-1 min-w-0">
                                             {(() => {
-                                                if (cheat.cheat_type === "SubmitSomeonesFlag" && cheat.extra_data && typeof cheat.extra_data === 'object') {
+                                                if (cheat.cheat_type !== "SubmitWithoutDownloadAttachments" && cheat.cheat_type !== "SubmitWithoutStartContainer" && cheat.extra_data && typeof cheat.extra_data === 'object') {
                                                     const extraData = cheat.extra_data as any;
                                                     if (extraData.relevant_team && extraData.relevant_teamname) {
+                                                        const evidence = JSON.stringify(extraData.evidence || {})
                                                         return (
-                                                            <div className="flex items-center gap-1"
-                                                                data-tooltip-content={t("events.cheat.flag")}
+                                                            <div className="flex items-center gap-1 min-w-0"
+                                                                data-tooltip-content={cheat.cheat_type === "SubmitSomeonesFlag" ? t("events.cheat.flag") : evidence}
                                                                 data-tooltip-id="my-tooltip"
                                                                 data-tooltip-place="bottom"
                                                             >
@@ -756,6 +794,17 @@ export function GameEventModule(
                                                                 >
                                                                     #{extraData.relevant_team}
                                                                 </Badge>
+                                                                {typeof extraData.confidence === 'number' && (
+                                                                    <Badge
+                                                                        variant="outline"
+                                                                        className="text-xs select-none cursor-pointer transition-all duration-200 rounded-md px-2 py-1 font-mono"
+                                                                        onClick={() => {
+                                                                            copyWithResult(evidence, t("events.cheat.evidence"))
+                                                                        }}
+                                                                    >
+                                                                        {Math.round(extraData.confidence * 100)}%
+                                                                    </Badge>
+                                                                )}
                                                             </div>
                                                         );
                                                     }
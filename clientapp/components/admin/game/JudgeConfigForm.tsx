@@ -11,6 +11,7 @@ import {
 import { Select, SelectContent, SelectItem, SelectTrigger, SelectValue } from 'components/ui/select';
 import { Switch } from 'components/ui/switch';
 import { useFieldArray, useWatch } from 'react-hook-form';
+import { useState } from 'react';
 import CodeEditor from '@uiw/react-textarea-code-editor';
 import dayjs from 'dayjs';
 import { ScanBarcode, FileCode, ClockArrowUp, PlusCircle, Trash2, AppWindowMac, PencilRulerIcon } from 'lucide-react';
@@ -18,6 +19,7 @@ import { Button } from 'components/ui/button';
 import EditorDialog from 'components/modules/EditorDialog';
 import AlertConformer from 'components/modules/AlertConformer';
 import ChallengeScoreGraph from 'components/modules/ChallengeScoreGraph';
+import { FlagMatchConfigFields } from 'components/admin/FlagMatchConfigFields';
 
 interface JudgeConfigFormProps {
     /** react-hook-form control 对象 */
@@ -50,14 +52,160 @@ export function JudgeConfigForm({ control, form }: JudgeConfigFormProps) {
     const [
         difficulty,
         total_score,
-        minimal_score
+        minimal_score,
+        scoring_mode,
+        challenge_kind,
+        koth_token_source
     ] = useWatch({
         control,
-        name: ['difficulty', 'total_score', 'minimal_score'],
+        name: ['difficulty', 'total_score', 'minimal_score', 'scoring_mode', 'challenge_kind', 'koth_config.token_source'],
     })
 
     return (
         <>
+            {/* 题目类型 */}
+            <FormField
+                control={form.control}
+                name={`challenge_kind`}
+                render={({ field }) => (
+                    <FormItem className="select-none">
+                        <div className="flex items-center h-[20px]">
+                            <FormLabel>题目类型</FormLabel>
+                            <div className="flex-1" />
+                            <FormMessage className="text-[14px]" />
+                        </div>
+                        <Select
+                            onValueChange={(value) => {
+                                field.onChange(value)
+                                // 第一次切换到 KOTH 时填上和后端一致的默认配置
+                                if (value === 'KOTH' && !form.getValues('koth_config')) {
+                                    form.setValue('koth_config', {
+                                        tick_interval: 60,
+                                        points_per_tick: 10,
+                                        token_source: 'FILE',
+                                        token_path: '/king',
+                                    })
+                                }
+                            }}
+                            value={field.value || "STANDARD"}
+                        >
+                            <FormControl>
+                                <SelectTrigger>
+                                    <SelectValue />
+                                </SelectTrigger>
+                            </FormControl>
+                            <SelectContent>
+                                <SelectItem value="STANDARD">普通题目</SelectItem>
+                                <SelectItem value="KOTH">King of the Hill</SelectItem>
+                            </SelectContent>
+                        </Select>
+                        <FormDescription>KOTH 题目必须使用静态容器, 所有队伍争夺同一个靶机, 按占领时长计分, 没有 flag</FormDescription>
+                    </FormItem>
+                )}
+            />
+
+            {challenge_kind === 'KOTH' && (
+                <>
+                    <div className="flex gap-6">
+                        <FormField
+                            control={form.control}
+                            name={`koth_config.tick_interval`}
+                            render={({ field }) => (
+                                <FormItem className="select-none flex-1">
+                                    <div className="flex items-center h-[20px]">
+                                        <FormLabel>检查间隔 (秒)</FormLabel>
+                                        <div className="flex-1" />
+                                        <FormMessage className="text-[14px]" />
+                                    </div>
+                                    <FormControl>
+                                        <Input {...field} value={field.value ?? ''} />
+                                    </FormControl>
+                                </FormItem>
+                            )}
+                        />
+                        <FormField
+                            control={form.control}
+                            name={`koth_config.points_per_tick`}
+                            render={({ field }) => (
+                                <FormItem className="select-none flex-1">
+                                    <div className="flex items-center h-[20px]">
+                                        <FormLabel>每次检查的分数</FormLabel>
+                                        <div className="flex-1" />
+                                        <FormMessage className="text-[14px]" />
+                                    </div>
+                                    <FormControl>
+                                        <Input {...field} value={field.value ?? ''} />
+                                    </FormControl>
+                                </FormItem>
+                            )}
+                        />
+                    </div>
+
+                    <div className="flex gap-6">
+                        <FormField
+                            control={form.control}
+                            name={`koth_config.token_source`}
+                            render={({ field }) => (
+                                <FormItem className="select-none w-[35%]">
+                                    <div className="flex items-center h-[20px]">
+                                        <FormLabel>占领标记来源</FormLabel>
+                                        <div className="flex-1" />
+                                        <FormMessage className="text-[14px]" />
+                                    </div>
+                                    <Select onValueChange={field.onChange} value={field.value}>
+                                        <FormControl>
+                                            <SelectTrigger>
+                                                <SelectValue />
+                                            </SelectTrigger>
+                                        </FormControl>
+                                        <SelectContent>
+                                            <SelectItem value="FILE">容器内文件</SelectItem>
+                                            <SelectItem value="HTTP">HTTP 接口</SelectItem>
+                                        </SelectContent>
+                                    </Select>
+                                </FormItem>
+                            )}
+                        />
+                        <FormField
+                            control={form.control}
+                            name={`koth_config.token_path`}
+                            render={({ field }) => (
+                                <FormItem className="select-none flex-1">
+                                    <div className="flex items-center h-[20px]">
+                                        <FormLabel>占领标记路径</FormLabel>
+                                        <div className="flex-1" />
+                                        <FormMessage className="text-[14px]" />
+                                    </div>
+                                    <FormControl>
+                                        <Input {...field} value={field.value ?? ''} />
+                                    </FormControl>
+                                </FormItem>
+                            )}
+                        />
+                        <FormField
+                            key={koth_token_source}
+                            control={form.control}
+                            name={koth_token_source === 'HTTP' ? `koth_config.token_port` : `koth_config.token_container`}
+                            render={({ field }) => (
+                                <FormItem className="select-none flex-1">
+                                    <div className="flex items-center h-[20px]">
+                                        <FormLabel>{koth_token_source === 'HTTP' ? '端口名' : '容器名'}</FormLabel>
+                                        <div className="flex-1" />
+                                        <FormMessage className="text-[14px]" />
+                                    </div>
+                                    <FormControl>
+                                        <Input {...field} value={field.value ?? ''} />
+                                    </FormControl>
+                                </FormItem>
+                            )}
+                        />
+                    </div>
+                    <span className="text-[12px] text-foreground/60 mt-[-10px]">
+                        靶机里的占领标记写入队伍的 team_hash 即算占领, 文件来源不填容器名时读取第一个容器, HTTP 来源的路径需要以 / 开头, 不填端口名时使用第一个暴露的端口
+                    </span>
+                </>
+            )}
+
             {/* 评测模式选择 */}
             <FormField
                 control={form.control}
@@ -175,6 +323,8 @@ export function JudgeConfigForm({ control, form }: JudgeConfigFormProps) {
                 />
             )}
 
+            {attachType !== 'SCRIPT' && <FlagMatchConfigFields control={control} />}
+
             {/* 所属阶段 */}
             {/* <FormField
                 control={form.control}
@@ -190,6 +340,79 @@ export function JudgeConfigForm({ control, form }: JudgeConfigFormProps) {
                 )}
             /> */}
 
+            {/* 计分模式 */}
+            <FormField
+                control={form.control}
+                name={`scoring_mode`}
+                render={({ field }) => (
+                    <FormItem className="select-none">
+                        <div className="flex items-center h-[20px]">
+                            <FormLabel>计分模式</FormLabel>
+                            <div className="flex-1" />
+                            <FormMessage className="text-[14px]" />
+                        </div>
+                        <Select
+                            onValueChange={field.onChange}
+                            value={field.value || "EXP_DECAY"}
+                        >
+                            <FormControl>
+                                <SelectTrigger>
+                                    <SelectValue />
+                                </SelectTrigger>
+                            </FormControl>
+                            <SelectContent>
+                                <SelectItem value="EXP_DECAY">指数衰减</SelectItem>
+                                <SelectItem value="LOGARITHMIC">抛物线衰减 (CTFd logarithmic)</SelectItem>
+                                <SelectItem value="LINEAR">线性衰减</SelectItem>
+                                <SelectItem value="TABLE">按解题人数查表</SelectItem>
+                                <SelectItem value="STATIC">固定分数</SelectItem>
+                            </SelectContent>
+                        </Select>
+                        <FormDescription>分数统一向下取整, 第一个解出的队伍拿满分</FormDescription>
+                    </FormItem>
+                )}
+            />
+
+            {(scoring_mode === 'LOGARITHMIC' || scoring_mode === 'LINEAR') && (
+                <FormField
+                    control={form.control}
+                    name={`scoring_config.decay`}
+                    render={({ field }) => (
+                        <FormItem className="select-none">
+                            <div className="flex items-center h-[20px]">
+                                <FormLabel>衰减人数</FormLabel>
+                                <div className="flex-1" />
+                                <FormMessage className="text-[14px]" />
+                            </div>
+                            <FormControl>
+                                <Input {...field} value={field.value ?? ''} />
+                            </FormControl>
+                            <FormDescription>第几个解之后降到最低分</FormDescription>
+                        </FormItem>
+                    )}
+                />
+            )}
+
+            {scoring_mode === 'TABLE' && (
+                <FormField
+                    control={form.control}
+                    name={`scoring_config.score_table`}
+                    render={({ field }) => (
+                        <FormItem className="select-none">
+                            <div className="flex items-center h-[20px]">
+                                <FormLabel>分数表</FormLabel>
+                                <div className="flex-1" />
+                                <FormMessage className="text-[14px]" />
+                            </div>
+                            <FormControl>
+                                <ScoreTableInput value={field.value} onChange={field.onChange} />
+                            </FormControl>
+                            <FormDescription>用逗号分隔, 第 n 项是第 n 个解出之后的分数, 超出部分使用最后一项, 不受最低分限制</FormDescription>
+                        </FormItem>
+                    )}
+                />
+            )}
+
             <div className="flex gap-6">
                 <div className="flex flex-col gap-8 w-[35%]">
                     {/* 题目总分 */}
@@ -373,4 +596,22 @@ export function JudgeConfigForm({ control, form }: JudgeConfigFormProps) {
             )}
         </>
     );
-} 
\ No newline at end of file
+}
+
+/**
+ * 分数表输入框, 输入过程中保留原始文本, 只把能解析的数字写回表单
+ */
+function ScoreTableInput({ value, onChange }: { value?: number[], onChange: (value: number[]) => void }) {
+    const [text, setText] = useState((value ?? []).join(', '))
+
+    return (
+        <Input
+            value={text}
+            placeholder="500, 450, 400, 350"
+            onChange={(e) => {
+                setText(e.target.value)
+                onChange(e.target.value.split(/[,，\s]+/).filter((item) => item !== '').map(Number).filter((item) => !isNaN(item)))
+            }}
+        />
+    )
+}
@@ -1,7 +1,7 @@
 import { Button } from "components/ui/button"
 import { Input } from "components/ui/input"
 import { Label } from "components/ui/label"
-import { useState } from "react";
+import { useEffect, useState } from "react";
 import { CapWidget, CapWidgetElement } from '@pitininja/cap-react-widget';
 import { AxiosError } from 'axios';
 
@@ -23,10 +23,11 @@ import { api, createSkipGlobalErrorConfig } from "utils/ApiHelper";
 
 import { toast } from 'react-toastify/unstyled';
 import { useGlobalVariableContext } from "contexts/GlobalVariableContext";
-import { useNavigate } from "react-router";
+import { useNavigate, useSearchParams } from "react-router";
 import { useTranslation } from "react-i18next";
 
 import { useNavigateFrom } from "hooks/NavigateFrom";
+import { KeyRound, School } from "lucide-react";
 
 export function LoginForm() {
     const { t } = useTranslation("login_form");
@@ -46,6 +47,33 @@ export function LoginForm() {
 
     const [_navigateFrom, getNavigateFrom] = useNavigateFrom()
 
+    const [searchParams] = useSearchParams()
+    const ssoProviders = clientConfig.ssoProviders ?? []
+
+    // 单点登录完成后回到登录页面，加载用户资料之后再跳转
+    useEffect(() => {
+        if (searchParams.get("sso_login")) {
+            updateProfile(() => {
+                router(getNavigateFrom() ?? "/")
+
+                setTimeout(() => {
+                    toast.success(t("login_successful"))
+                }, 300)
+            })
+            return
+        }
+
+        const ssoError = searchParams.get("sso_error")
+        if (ssoError) {
+            toast.error(t(`sso_error_${ssoError}`, { defaultValue: t("sso_error_server_error") }))
+        }
+    }, [])
+
+    const loginWithSSO = (providerID: string) => {
+        const redirect = encodeURIComponent(getNavigateFrom() ?? "/")
+        window.location.href = `/api/sso/${providerID}/login?redirect=${redirect}`
+    }
+
     const formSchema = z.object({
         userName: z.string().nonempty(t("username_not_null")),
         password: z.string().nonempty(t("password_not_null"))
@@ -175,22 +203,30 @@ export function LoginForm() {
                         {t("sign_up_title")}
                     </a>
                 </div>
-                <div className="relative text-center text-sm after:absolute after:inset-0 after:top-1/2 after:z-0 after:flex after:items-center after:border-t after:border-border transition-[border-color] duration-300">
-                    <span className="relative z-10 bg-background px-2 text-muted-foreground transition-all duration-300">
-                        {t("or_continue_with")}
-                    </span>
-                </div>
-                <div className="flex flex-col gap-2 w-full">
-                    <Button variant="outline" className="w-full" disabled>
-                        <svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24">
-                            <path
-                                d="M12 .297c-6.63 0-12 5.373-12 12 0 5.303 3.438 9.8 8.205 11.385.6.113.82-.258.82-.577 0-.285-.01-1.04-.015-2.04-3.338.724-4.042-1.61-4.042-1.61C4.422 18.07 3.633 17.7 3.633 17.7c-1.087-.744.084-.729.084-.729 1.205.084 1.838 1.236 1.838 1.236 1.07 1.835 2.809 1.305 3.495.998.108-.776.417-1.305.76-1.605-2.665-.3-5.466-1.332-5.466-5.93 0-1.31.465-2.38 1.235-3.22-.135-.303-.54-1.523.105-3.176 0 0 1.005-.322 3.3 1.23.96-.267 1.98-.399 3-.405 1.02.006 2.04.138 3 .405 2.28-1.552 3.285-1.23 3.285-1.23.645 1.653.24 2.873.12 3.176.765.84 1.23 1.91 1.23 3.22 0 4.61-2.805 5.625-5.475 5.92.42.36.81 1.096.81 2.22 0 1.606-.015 2.896-.015 3.286 0 .315.21.69.825.57C20.565 22.092 24 17.592 24 12.297c0-6.627-5.373-12-12-12"
-                                fill="currentColor"
-                            />
-                        </svg>
-                        {t("login_with_github")}
-                    </Button>
-                </div>
+                {ssoProviders.length > 0 && (
+                    <>
+                        <div className="relative text-center text-sm after:absolute after:inset-0 after:top-1/2 after:z-0 after:flex after:items-center after:border-t after:border-border transition-[border-color] duration-300">
+                            <span className="relative z-10 bg-background px-2 text-muted-foreground transition-all duration-300">
+                                {t("or_continue_with")}
+                            </span>
+                        </div>
+                        <div className="flex flex-col gap-2 w-full">
+                            {ssoProviders.map((provider) => (
+                                <Button key={provider.id} variant="outline" className="w-full" onClick={() => loginWithSSO(provider.id)}>
+                                    {provider.type == "github" ? (
+                                        <svg xmlns="http://www.w3.org/2000/svg" viewBox="0 0 24 24">
+                                            <path
+                                                d="M12 .297c-6.63 0-12 5.373-12 12 0 5.303 3.438 9.8 8.205 11.385.6.113.82-.258.82-.577 0-.285-.01-1.04-.015-2.04-3.338.724-4.042-1.61-4.042-1.61C4.422 18.07 3.633 17.7 3.633 17.7c-1.087-.744.084-.729.084-.729 1.205.084 1.838 1.236 1.838 1.236 1.07 1.835 2.809 1.305 3.495.998.108-.776.417-1.305.76-1.605-2.665-.3-5.466-1.332-5.466-5.93 0-1.31.465-2.38 1.235-3.22-.135-.303-.54-1.523.105-3.176 0 0 1.005-.322 3.3 1.23.96-.267 1.98-.399 3-.405 1.02.006 2.04.138 3 .405 2.28-1.552 3.285-1.23 3.285-1.23.645 1.653.24 2.873.12 3.176.765.84 1.23 1.91 1.23 3.22 0 4.61-2.805 5.625-5.475 5.92.42.36.81 1.096.81 2.22 0 1.606-.015 2.896-.015 3.286 0 .315.21.69.825.57C20.565 22.092 24 17.592 24 12.297c0-6.627-5.373-12-12-12"
+                                                fill="currentColor"
+                                            />
+                                        </svg>
+                                    ) : provider.type == "cas" ? <School /> : <KeyRound />}
+                                    {t("login_with", { name: provider.name })}
+                                </Button>
+                            ))}
+                        </div>
+                    </>
+                )}
             </div>
         </Form>
     )
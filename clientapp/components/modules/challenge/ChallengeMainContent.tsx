@@ -42,6 +42,8 @@ export default function ChallengeMainContent(
 ) {
 
     const [containerLaunching, setContainerLaunching] = useState(false)
+    // 容器配额不足时在比赛的启动队列里的位置
+    const [queuePosition, setQueuePosition] = useState<number | null>(null)
 
     const [containerInfo, setContainerInfo] = useState<ExposePortInfo[]>([])
     const [containerRunningTrigger, setContainerRunningTrigger] = useState(false);
@@ -136,6 +138,10 @@ export default function ChallengeMainContent(
         if (refreshContainerTrigger == true) {
             const inter = setInterval(() => {
                 api.user.userGetContainerInfoForAChallenge(gameID, curChallenge?.challenge_id ?? 0).then((res) => {
+                    setQueuePosition(res.data.data.container_status == ContainerStatus.ContainerQueueing
+                        ? res.data.data.queue_position ?? null
+                        : null)
+
                     if (res.data.data.container_status == ContainerStatus.ContainerRunning) {
                         setContainerInfo(res.data.data.containers)
                         setContainerLaunching(false)
@@ -160,6 +166,7 @@ export default function ChallengeMainContent(
                         setRefreshContainerTrigger(false)
                     }
                 }).catch(() => {
+                    setQueuePosition(null)
                     setContainerLaunching(false)
                     setContainerRunningTrigger(false)
 
@@ -177,6 +184,7 @@ export default function ChallengeMainContent(
 
 
     useEffect(() => {
+        setQueuePosition(null)
         setContainerInfo(curChallenge?.containers ?? [])
         setContainerExpireTime(curChallenge?.container_expiretime
             ? dayjs(curChallenge.container_expiretime)
@@ -260,7 +268,9 @@ export default function ChallengeMainContent(
                                             {containerLaunching ? (
                                                 <>
                                                     <Loader2 className="animate-spin" />
-                                                    <span className="font-bold text-[1.125em]">{t("launching")}</span>
+                                                    <span className="font-bold text-[1.125em]">
+                                                        {queuePosition ? t("queueing", { position: queuePosition }) : t("launching")}
+                                                    </span>
                                                 </>
                                             ) : (
                                                 <>
@@ -300,23 +310,37 @@ export default function ChallengeMainContent(
                                             <Network />
                                             {container.container_ports?.length ? (
                                                 <div className="flex gap-2">
-                                                    {container.container_ports.map((port, j) => (
-                                                        <div key={j} className="flex gap-2 items-center">
-                                                            <span className="text-sm font-bold">{port.port_name}:</span>
-                                                            <div className="border-2 border-foreground px-2 rounded-md flex items-center justify-center hover:bg-foreground/30 transition-colors duration-300"
-                                                                onClick={() => {
-                                                                    const status = copy(`${port.ip}:${port.port}`)
-                                                                    if (status) {
-                                                                        toast.success(t("copied"))
-                                                                    } else {
-                                                                        toast.success(t("fail_copy"))
-                                                                    }
-                                                                }}
-                                                            >
-                                                                <span className="font-bold text-sm">{port.ip}:{port.port}</span>
+                                                    {container.container_ports.map((port, j) => {
+                                                        // 子域名路由的端口显示 URL，网关端口显示网关地址和连接用的 Token
+                                                        const address = port.type === "URL" && port.url ? port.url : `${port.ip}:${port.port}`
+                                                        const copyText = (text: string) => {
+                                                            const status = copy(text)
+                                                            if (status) {
+                                                                toast.success(t("copied"))
+                                                            } else {
+                                                                toast.success(t("fail_copy"))
+                                                            }
+                                                        }
+
+                                                        return (
+                                                            <div key={j} className="flex gap-2 items-center">
+                                                                <span className="text-sm font-bold">{port.port_name}:</span>
+                                                                <div className="border-2 border-foreground px-2 rounded-md flex items-center justify-center hover:bg-foreground/30 transition-colors duration-300"
+                                                                    onClick={() => copyText(address)}
+                                                                >
+                                                                    <span className="font-bold text-sm">{address}</span>
+                                                                </div>
+                                                                {port.type === "GATEWAY" && port.token ? (
+                                                                    <div className="border-2 border-foreground px-2 rounded-md flex items-center justify-center hover:bg-foreground/30 transition-colors duration-300"
+                                                                        onClick={() => copyText(port.token ?? "")}
+                                                                        title={t("gateway_token_hint")}
+                                                                    >
+                                                                        <span className="font-bold text-sm">{t("gateway_token")}: {port.token}</span>
+                                                                    </div>
+                                                                ) : <></>}
                                                             </div>
-                                                        </div>
-                                                    ))}
+                                                        )
+                                                    })}
                                                 </div>
                                             ) : (
                                                 <span className="font-bold">{t("wait_launch")}</span>
@@ -336,7 +360,7 @@ export default function ChallengeMainContent(
                             </div>
                             <div className="flex gap-6 mt-4 flex-col lg:flex-row">
                                 {curChallenge?.attachments?.map((attach, attach_index) => (
-                                    <FileDownloader key={attach_index} attach={attach} setRedirectURL={setRedirectURL} />
+                                    <FileDownloader key={attach_index} gameID={gameID} challengeID={curChallenge?.challenge_id ?? 0} attach={attach} setRedirectURL={setRedirectURL} />
                                 ))}
                             </div>
                         </div>
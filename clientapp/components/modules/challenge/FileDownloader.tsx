@@ -1,8 +1,9 @@
-import { File, Pickaxe, ArrowDownUp, FileDown } from "lucide-react";
+import { File, Pickaxe, ArrowDownUp, FileDown, FileCog, Loader2, CircleX } from "lucide-react";
 import { Button } from "components/ui/button";
 import { Progress } from "components/ui/progress";
-import { AttachmentType, UserAttachmentConfig } from "utils/A1API";
-import { useState } from "react";
+import { AttachmentType, TeamAttachmentStatus, UserAttachmentConfig } from "utils/A1API";
+import { useEffect, useState } from "react";
+import { api } from "utils/ApiHelper";
 import dayjs from "dayjs";
 import { useTranslation } from "react-i18next";
 
@@ -12,14 +13,50 @@ interface DownloadInfo {
     speed: string;
 };
 
+// 动态附件生成中时轮询状态的间隔
+const GENERATE_POLL_INTERVAL = 3000
+
 const FileDownloader = (
-    { attach, setRedirectURL }: { attach: UserAttachmentConfig, setRedirectURL: React.Dispatch<React.SetStateAction<string>> },
+    { gameID, challengeID, attach: initialAttach, setRedirectURL }: {
+        gameID: number,
+        challengeID: number,
+        attach: UserAttachmentConfig,
+        setRedirectURL: React.Dispatch<React.SetStateAction<string>>
+    },
 ) => {
 
     const [downloadSpeed, setDownloadSpeed] = useState<DownloadInfo>();
     const [downloading, setDownloading] = useState(false);
+    // 动态附件的生成状态会变化, 拷贝一份到本地
+    const [attach, setAttach] = useState<UserAttachmentConfig>(initialAttach);
+    const [requesting, setRequesting] = useState(false);
     const { t } = useTranslation("challenge_view")
 
+    useEffect(() => {
+        setAttach(initialAttach)
+    }, [initialAttach])
+
+    const isDynamic = attach.attach_type == AttachmentType.DYNAMICFILE
+    const generateStatus = attach.generate_status
+    const dynamicReady = isDynamic && generateStatus == TeamAttachmentStatus.READY && !!attach.attach_hash
+
+    // 第一次请求时开始生成, 失败后再请求会重新生成, 其他情况只返回当前状态
+    const requestGenerate = () => {
+        setRequesting(true)
+        api.user.userGenerateAttachmentForAChallenge(gameID, challengeID, { attach_name: attach.attach_name }).then((res) => {
+            setAttach(res.data.data)
+        }).finally(() => {
+            setRequesting(false)
+        })
+    }
+
+    useEffect(() => {
+        if (!isDynamic || generateStatus != TeamAttachmentStatus.PENDING) return
+
+        const timer = setTimeout(requestGenerate, GENERATE_POLL_INTERVAL)
+        return () => clearTimeout(timer)
+    }, [attach])
+
     const formatFileSize = (bytes: number): string => {
         if (bytes === 0) return '0 Bytes';
 
@@ -42,8 +79,12 @@ const FileDownloader = (
 
     const handleDownload = (attach: UserAttachmentConfig) => {
 
+        if (isDynamic && !dynamicReady) {
+            if (generateStatus != TeamAttachmentStatus.PENDING) requestGenerate()
+            return
+        }
 
-        if (attach.attach_type == AttachmentType.STATICFILE || attach.attach_type == AttachmentType.REMOTEFILE) {
+        if (attach.attach_type == AttachmentType.STATICFILE || attach.attach_type == AttachmentType.REMOTEFILE || dynamicReady) {
             const fileName = attach.attach_name
 
             setDownloadSpeed({
@@ -123,7 +164,7 @@ const FileDownloader = (
                 }
             };
 
-            if (attach.attach_type == AttachmentType.STATICFILE) {
+            if (attach.attach_type == AttachmentType.STATICFILE || dynamicReady) {
                 fetchFile(`/api/file/download/${attach.attach_hash}`)
             } else {
                 fetchFile(attach.attach_url ?? "");
@@ -133,10 +174,36 @@ const FileDownloader = (
         }
     };
 
+    // 动态附件还没生成好时显示生成状态
+    const renderGenerateStatus = () => {
+        if (requesting || generateStatus == TeamAttachmentStatus.PENDING) {
+            return (
+                <>
+                    <Loader2 className="animate-spin" />
+                    <span>{t("attachment_generating")}</span>
+                </>
+            )
+        }
+        if (generateStatus == TeamAttachmentStatus.FAILED) {
+            return (
+                <>
+                    <CircleX />
+                    <span>{t("attachment_generate_failed")}</span>
+                </>
+            )
+        }
+        return (
+            <>
+                <FileCog />
+                <span>{t("attachment_generate")}</span>
+            </>
+        )
+    }
+
     return (
         <Button variant="secondary" onClick={() => handleDownload(attach)}
             className="p-0 w-full lg:w-[300px] h-[105px] text-md [&_svg]:size-5 transition-all duration-300 hover:bg-foreground/20 select-none disabled:opacity-100"
-            disabled={downloading}
+            disabled={downloading || requesting || (isDynamic && generateStatus == TeamAttachmentStatus.PENDING)}
             title={attach.attach_name}
         >
             <div className={`flex flex-col p-4 w-full h-full ${!downloading ? "justify-center gap-2" : "justify-between"}`}>
@@ -167,8 +234,12 @@ const FileDownloader = (
                             <span className="font-bold text-nowrap text-ellipsis">{attach.attach_name}</span>
                         </div>
                         <div className="flex gap-2 items-center">
-                            <FileDown />
-                            <span>{t("download")}</span>
+                            {isDynamic && !dynamicReady ? renderGenerateStatus() : (
+                                <>
+                                    <FileDown />
+                                    <span>{t("download")}</span>
+                                </>
+                            )}
                         </div>
                     </>
                 )}
@@ -1,4 +1,4 @@
-import { Dispatch, SetStateAction, useEffect, useState } from "react";
+import { Dispatch, MutableRefObject, SetStateAction, useEffect, useRef, useState } from "react";
 import { Button } from "components/ui/button";
 import { Loader2, Mail, SendHorizonal, X } from "lucide-react";
 
@@ -12,7 +12,21 @@ import { toast } from 'react-toastify/unstyled';
 import { ChallengeSolveStatus } from "components/user/game/ChallengesView";
 import { useTranslation } from "react-i18next";
 
-const SubmitFlagView = ({ curChallenge, gameID, setChallengeSolved, challengeSolveStatusList: _challengeSolveStatusList, visible, setVisible }: { curChallenge: UserDetailGameChallenge | undefined, gameID: number, setChallengeSolved: (id: number) => void, challengeSolveStatusList: Record<number, ChallengeSolveStatus>, visible: boolean, setVisible: Dispatch<SetStateAction<boolean>> }) => {
+// 实时通知服务推送的判题结果
+export interface JudgeResultMessage {
+    judge_id: string;
+    challenge_id: number;
+    ingame_id: number;
+    judge_status: string;
+    judge_result: string;
+}
+
+export type JudgeResultListener = (message: JudgeResultMessage) => void
+
+// 推送丢失或者 WebSocket 断开时的兜底轮询间隔
+const JUDGE_POLL_INTERVAL = 3000
+
+const SubmitFlagView = ({ curChallenge, gameID, setChallengeSolved, challengeSolveStatusList: _challengeSolveStatusList, visible, setVisible, judgeResultListenerRef }: { curChallenge: UserDetailGameChallenge | undefined, gameID: number, setChallengeSolved: (id: number) => void, challengeSolveStatusList: Record<number, ChallengeSolveStatus>, visible: boolean, setVisible: Dispatch<SetStateAction<boolean>>, judgeResultListenerRef: MutableRefObject<JudgeResultListener | null> }) => {
 
     const { t } = useTranslation("challenge_view");
     const [flag, setFlag] = useState<string>("");
@@ -42,41 +56,67 @@ const SubmitFlagView = ({ curChallenge, gameID, setChallengeSolved, challengeSol
         setFlag("")
     }, [visible])
 
+    // 当前等待中的判题, 推送和轮询谁先拿到结果谁处理
+    const pendingJudge = useRef<{ judgeID: string, timer: NodeJS.Timeout } | null>(null)
+
+    const stopWaiting = () => {
+        if (pendingJudge.current) {
+            clearInterval(pendingJudge.current.timer)
+            pendingJudge.current = null
+        }
+        judgeResultListenerRef.current = null
+    }
+
+    useEffect(() => stopWaiting, [])
+
+    const handleJudgeResult = (judgeID: string, judgeStatus: string, judgeResult: string | undefined) => {
+        if (pendingJudge.current?.judgeID != judgeID) return
+
+        if (judgeStatus == "JudgeAC") {
+            stopWaiting()
+            setVisible(false)
+            setJudgeing(false)
+
+            setTimeout(() => {
+                setChallengeSolved(curChallenge?.challenge_id || 0)
+            }, 200)
+        } else if (judgeStatus == "JudgeWA") {
+            stopWaiting()
+            toast.error(judgeResult || t("flag_error"));
+            setBorderRed(true)
+            setJudgeing(false)
+        } else if (judgeStatus == "JudgeError" || judgeStatus == "JudgeTimeout") {
+            stopWaiting()
+            toast.error(t("judge_error"));
+            setJudgeing(false)
+        }
+    }
+
     const handleSubmitFlag = () => {
         setJudgeing(true)
         api.user.userGameSubmitFlag(gameID, curChallenge?.challenge_id ?? 0, { flag: flag })
             .then((res) => {
                 if (res.status == 200) {
-                    const judgeingInter = setInterval(() => {
-                        api.user.userGameJudgeResult(gameID, res.data.data.judge_id)
+                    const judgeID = res.data.data.judge_id
+                    stopWaiting()
+
+                    const timer = setInterval(() => {
+                        api.user.userGameJudgeResult(gameID, judgeID)
                             .then((res2) => {
                                 if (res2.status == 200) {
-                                    if (res2.data.data.judge_status == "JudgeAC") {
-                                        setVisible(false)
-                                        setJudgeing(false)
-                                        clearInterval(judgeingInter)
-
-                                        setTimeout(() => {
-                                            setChallengeSolved(curChallenge?.challenge_id || 0)
-                                        }, 200)
-
-                                    } else if (res2.data.data.judge_status == "JudgeWA") {
-                                        toast.error(t("flag_error"));
-                                        setBorderRed(true)
-                                        setJudgeing(false)
-                                        clearInterval(judgeingInter)
-                                    } else if (res2.data.data.judge_status == "JudgeError" || res2.data.data.judge_status == "JudgeTimeout") {
-                                        toast.error(t("judge_error"));
-                                        setJudgeing(false)
-                                        clearInterval(judgeingInter)
-                                    }
-                                } else {
+                                    handleJudgeResult(judgeID, res2.data.data.judge_status, res2.data.data.judge_result)
+                                } else if (pendingJudge.current?.judgeID == judgeID) {
+                                    stopWaiting()
                                     toast.error(t("unknow_error"));
                                     setJudgeing(false)
-                                    clearInterval(judgeingInter)
                                 }
                             })
-                    }, 1000)
+                    }, JUDGE_POLL_INTERVAL)
+
+                    pendingJudge.current = { judgeID, timer }
+                    judgeResultListenerRef.current = (message) => {
+                        handleJudgeResult(message.judge_id, message.judge_status, message.judge_result)
+                    }
                 }
             }
             ).catch(() => {
@@ -25,7 +25,7 @@ import { useGlobalVariableContext } from "contexts/GlobalVariableContext";
 
 import { SolvedAnimation } from "components/SolvedAnimation";
 import ChallengesViewHeader from "components/modules/challenge/ChallengeViewHeader";
-import SubmitFlagView from "components/modules/challenge/SubmitFlagView";
+import SubmitFlagView, { JudgeResultListener } from "components/modules/challenge/SubmitFlagView";
 
 import GameStatusMask from "components/modules/game/GameStatusMask";
 import ChallengeHintPage from "components/modules/challenge/ChallengeHintPage";
@@ -101,6 +101,8 @@ export function ChallengesView({
     const [showHintsWindowVisible, setShowHintsWindowVisible] = useState(false)
 
     const wsRef = useRef<WebSocket | null>(null)
+    // 正在等待判题结果的提交框, 收到 JudgeResult 推送时回调
+    const judgeResultListenerRef = useRef<JudgeResultListener | null>(null)
     const [wsStatus, setWsStatus] = useState<"connecting" | "connected" | "disconnected" | "ingore">("ingore")
 
     const [searchParams, setSearchParams] = useSearchParams()
@@ -229,6 +231,10 @@ export function ChallengesView({
                     socket.onmessage = (event) => {
                         try {
                             const data = JSON.parse(event.data)
+
+                            if (data.type === 'JudgeResult') {
+                                judgeResultListenerRef.current?.(data.message)
+                            }
                             
                             if (data.type === 'Notice') {
                                 const message: GameNotice = data.message
@@ -398,7 +404,7 @@ export function ChallengesView({
             {/* 抢血动画 */}
             <SolvedAnimation blood={blood} setBlood={setBlood} bloodMessage={bloodMessage} />
             {/* 提交 Flag 组件 */}
-            <SubmitFlagView curChallenge={curChallenge} gameID={gameID} setChallengeSolved={setChallengeSolved} challengeSolveStatusList={challengeSolveStatusList} visible={submitFlagWindowVisible} setVisible={setSubmitFlagWindowVisible} />
+            <SubmitFlagView curChallenge={curChallenge} gameID={gameID} setChallengeSolved={setChallengeSolved} challengeSolveStatusList={challengeSolveStatusList} visible={submitFlagWindowVisible} setVisible={setSubmitFlagWindowVisible} judgeResultListenerRef={judgeResultListenerRef} />
 
             {/* Hint 列表 */}
             <ChallengeHintPage curChallenge={curChallenge} visible={showHintsWindowVisible} setVisible={setShowHintsWindowVisible} />
@@ -0,0 +1,102 @@
+import { Button } from "components/ui/button";
+import { useGlobalVariableContext } from "contexts/GlobalVariableContext";
+import dayjs from "dayjs";
+import { Link2, Unlink } from "lucide-react";
+import { useEffect } from "react";
+import { useTranslation } from "react-i18next";
+import { useSearchParams } from "react-router";
+import { toast } from 'react-toastify/unstyled';
+import { api } from "utils/ApiHelper";
+
+export default function SsoAccounts() {
+
+    const { curProfile, clientConfig, updateProfile } = useGlobalVariableContext()
+    const { t } = useTranslation("sso_accounts")
+
+    const [searchParams, setSearchParams] = useSearchParams()
+
+    const ssoProviders = clientConfig.ssoProviders ?? []
+    const identities = curProfile.sso_identities ?? []
+
+    // 从提供方绑定回来时带着结果
+    useEffect(() => {
+        const linked = searchParams.get("sso_linked")
+        const ssoError = searchParams.get("sso_error")
+        if (!linked && !ssoError) return
+
+        if (linked) {
+            toast.success(t("link_success"))
+            updateProfile()
+        } else if (ssoError) {
+            toast.error(t(`error_${ssoError}`, { defaultValue: t("error_server_error") }))
+        }
+        setSearchParams({}, { replace: true })
+    }, [])
+
+    function link(providerID: string) {
+        api.user.ssoLink(providerID).then((res) => {
+            window.location.href = res.data.data.url
+        })
+    }
+
+    function unlink(providerID: string) {
+        api.user.ssoUnlink(providerID).then(() => {
+            toast.success(t("unlink_success"))
+            updateProfile()
+        })
+    }
+
+    // 已经从配置里删除的提供方也显示出来，方便解绑
+    const rows = [
+        ...ssoProviders.map((provider) => ({ id: provider.id, name: provider.name })),
+        ...identities
+            .filter((identity) => !ssoProviders.some((provider) => provider.id == identity.provider))
+            .map((identity) => ({ id: identity.provider, name: identity.provider })),
+    ]
+
+    return (
+        <div className="space-y-4">
+            <div className="h-[20px] flex items-center">
+                <span className="text-sm font-bold">{t("title")}</span>
+            </div>
+            <span className="text-muted-foreground text-sm">{t("description")}</span>
+            {rows.length == 0 ? (
+                <div className="text-muted-foreground text-sm py-4">{t("no_providers")}</div>
+            ) : (
+                <div className="flex flex-col gap-3 mt-4">
+                    {rows.map((row) => {
+                        const identity = identities.find((item) => item.provider == row.id)
+                        const available = ssoProviders.some((provider) => provider.id == row.id)
+                        return (
+                            <div key={row.id} className="flex items-center gap-4 rounded-md border px-4 py-3">
+                                <div className="flex flex-col flex-1 overflow-hidden">
+                                    <span className="font-medium">{row.name}</span>
+                                    {identity ? (
+                                        <span className="text-muted-foreground text-sm truncate">
+                                            {t("linked_as", { name: identity.username || identity.email || "-" })}
+                                            {" · "}
+                                            {t("linked_at", { time: dayjs(identity.linked_at).format("YYYY-MM-DD HH:mm") })}
+                                        </span>
+                                    ) : (
+                                        <span className="text-muted-foreground text-sm">{t("not_linked")}</span>
+                                    )}
+                                </div>
+                                {identity ? (
+                                    <Button variant="outline" onClick={() => unlink(row.id)}>
+                                        <Unlink />
+                                        {t("unlink")}
+                                    </Button>
+                                ) : available && (
+                                    <Button variant="outline" onClick={() => link(row.id)}>
+                                        <Link2 />
+                                        {t("link")}
+                                    </Button>
+                                )}
+                            </div>
+                        )
+                    })}
+                </div>
+            )}
+        </div>
+    )
+}
@@ -44,6 +44,15 @@ interface ClientConfig {
 
     // 全局比赛模式
     gameActivityMode: string | undefined;
+
+    // 单点登录
+    ssoProviders: SsoProvider[];
+}
+
+export interface SsoProvider {
+    id: string;
+    name: string;
+    type: "oidc" | "github" | "cas";
 }
 
 interface GlobalVariableContextType {
@@ -118,6 +127,8 @@ export const GlobalVariableProvider: React.FC<{ children: ReactNode }> = ({ chil
         fancyBackGroundIconHeight: 122.39,
 
         gameActivityMode: undefined,
+
+        ssoProviders: [],
     }
 
     const [clientConfig, setClientConfig] = useState<ClientConfig>({} as ClientConfig)
@@ -149,6 +149,12 @@ export enum JudgeType {
   SCRIPT = "SCRIPT",
 }
 
+export enum FlagMatchMode {
+  EXACT = "EXACT",
+  CASE_INSENSITIVE = "CASE_INSENSITIVE",
+  REGEX = "REGEX",
+}
+
 /**
  * Type of the attachment:
  * - STATICFILE: Static file stored on server
@@ -194,6 +200,8 @@ export interface Attachment {
 export interface ExposePort {
   name: string;
   port: number;
+  /** 开启子域名路由后通过 URL 访问 */
+  http?: boolean;
 }
 
 export interface Container {
@@ -205,12 +213,77 @@ export interface Container {
   cpu_limit?: number;
   memory_limit?: number;
   storage_limit?: number;
+  flag_config?: ContainerFlagConfig;
+  /** init 容器按顺序运行完成后才启动其他容器，不能暴露端口 */
+  init?: boolean;
+  volumes?: ContainerVolumeMount[];
+  readiness_probe?: ContainerProbe;
+  security_context?: ContainerSecurityContext;
+  /** 关闭 allow_wan 时额外放行的出站地址，同一个 Pod 的容器共用网络，对整个 Pod 生效 */
+  egress_allow?: ContainerEgressRule[];
+}
+
+/** 同一个 Pod 里的容器按名字共享的 emptyDir 卷 */
+export interface ContainerVolumeMount {
+  name: string;
+  mount_path: string;
+  read_only?: boolean;
+  /** 单位 MB，为 0 时不限制 */
+  size_limit?: number;
+}
+
+export interface ContainerProbe {
+  /** Docker 后端只支持 exec */
+  type: "tcp" | "http" | "exec";
+  port?: number;
+  path?: string;
+  command?: string[];
+  initial_delay_seconds?: number;
+  period_seconds?: number;
+  timeout_seconds?: number;
+  failure_threshold?: number;
+}
+
+export interface ContainerSecurityContext {
+  read_only_root_filesystem?: boolean;
+  run_as_user?: number | null;
+  run_as_group?: number | null;
+  run_as_non_root?: boolean;
+  allow_privilege_escalation?: boolean | null;
+  drop_capabilities?: string[];
+  add_capabilities?: string[];
+  seccomp?: "" | "RuntimeDefault" | "Unconfined";
+}
+
+export interface ContainerEgressRule {
+  cidr: string;
+  ports?: number[];
+  protocol?: "TCP" | "UDP";
+}
+
+/** flag 保存在 Secret 中，通过 A1CTF_FLAG 环境变量或文件传入容器 */
+export interface ContainerFlagConfig {
+  /** 挂载为文件的绝对路径，为空时不挂载 */
+  path?: string;
+  uid?: number | null;
+  gid?: number | null;
+  /**
+   * 八进制的文件权限，默认 0444
+   * @example "0400"
+   */
+  mode?: string;
+  disable_env?: boolean;
 }
 
 export interface JudgeConfig {
   flag_template: string;
   judge_script?: string | null;
   judge_type: JudgeType;
+  flag_match_mode?: FlagMatchMode;
+  /** 比较前去掉提交内容首尾的空白 */
+  trim_space?: boolean;
+  /** 除 flag_template 外同样判为正确的静态 flag，正则模式下为表达式 */
+  accepted_flags?: string[];
 }
 
 export interface EnvironmentItem {
@@ -277,6 +350,66 @@ export interface AdminDetailGameChallenge {
   /** @format double */
   difficulty?: number;
   enable_blood_reward?: boolean;
+  /** 计分模式，为空时按 EXP_DECAY 处理 */
+  scoring_mode?: ScoringMode;
+  scoring_config?: ScoringConfig;
+  /** 题目类型，为空时按 STANDARD 处理，KOTH 题目按占领靶机的时长计分 */
+  challenge_kind?: GameChallengeKind;
+  /** KOTH 题目的配置，靶机里的占领标记是占领队伍的 team_hash */
+  koth_config?: KothConfig;
+}
+
+/** 计分模式，为空时按 EXP_DECAY 处理 */
+export enum ScoringMode {
+  STATIC = "STATIC",
+  EXP_DECAY = "EXP_DECAY",
+  LOGARITHMIC = "LOGARITHMIC",
+  LINEAR = "LINEAR",
+  TABLE = "TABLE",
+}
+
+export interface ScoringConfig {
+  /**
+   * LOGARITHMIC 和 LINEAR 下降到最低分需要的解题人数
+   * @format int32
+   */
+  decay?: number;
+  /** TABLE 模式下第 n 个解之后的分数，超出表长度时使用最后一项 */
+  score_table?: number[];
+}
+
+/** 题目类型，为空时按 STANDARD 处理，KOTH 题目按占领靶机的时长计分 */
+export enum GameChallengeKind {
+  STANDARD = "STANDARD",
+  KOTH = "KOTH",
+}
+
+/** 占领标记的读取方式，FILE 读取容器内的文件，HTTP 请求靶机的接口 */
+export enum KothTokenSource {
+  FILE = "FILE",
+  HTTP = "HTTP",
+}
+
+/** KOTH 题目的配置，靶机里的占领标记是占领队伍的 team_hash */
+export interface KothConfig {
+  /**
+   * 检查间隔，单位秒，不小于 5
+   * @format int64
+   */
+  tick_interval: number;
+  /**
+   * 每次检查占领队伍获得的分数
+   * @format double
+   */
+  points_per_tick: number;
+  /** 占领标记的读取方式，FILE 读取容器内的文件，HTTP 请求靶机的接口 */
+  token_source: KothTokenSource;
+  /** FILE 为容器内的文件路径，HTTP 为以 / 开头的请求路径 */
+  token_path: string;
+  /** FILE 读取的容器名，为空时使用第一个容器 */
+  token_container?: string;
+  /** HTTP 请求的端口名，为空时使用第一个暴露的端口 */
+  token_port?: string;
 }
 
 export interface AddGameChallengePayload {
@@ -310,10 +443,68 @@ export interface AdminFullGameInfo {
   first_blood_reward?: number;
   second_blood_reward?: number;
   third_blood_reward?: number;
+  blood_reward_config?: BloodRewardConfig;
+  game_mode?: "JEOPARDY" | "ATTACK_DEFENSE";
+  attack_defense_config?: AttackDefenseConfig;
+  container_quota?: ContainerQuotaConfig;
+  container_lifetime?: ContainerLifetimeConfig;
   group_invite_code_enable?: boolean;
   challenges?: AdminDetailGameChallenge[];
 }
 
+export interface BloodRewardRule {
+  type: "PERCENT" | "FIXED";
+  value: number;
+}
+
+/** the i-th rule rewards the (i+1)-th solver, rewards decay linearly to decay_min_ratio within decay_duration seconds after the game starts. When updating a game, omit it to keep the current config or send empty ranks to clear it and fall back to first/second/third_blood_reward */
+export interface BloodRewardConfig {
+  ranks: BloodRewardRule[];
+  /** @format int64 */
+  decay_duration?: number;
+  decay_min_ratio?: number;
+}
+
+/** round timing in seconds and per-round scores of the attack-defense mode */
+export interface AttackDefenseConfig {
+  /** @format int64 */
+  round_duration: number;
+  /** @format int64 */
+  check_delay?: number;
+  flag_lifetime: number;
+  attack_score: number;
+  defense_score: number;
+  sla_score: number;
+}
+
+/** container resource limits, cpu in millicores and memory in MiB, 0 for unlimited */
+export interface ContainerQuotaConfig {
+  /** @format int64 */
+  team_cpu_limit: number;
+  /** @format int64 */
+  team_memory_limit: number;
+  /** @format int64 */
+  game_cpu_limit: number;
+  /** @format int64 */
+  game_memory_limit: number;
+}
+
+/** default container lifetime of the game in seconds, challenges can override it, omitted fields use the server defaults */
+export interface ContainerLifetimeConfig {
+  /** @format int64 */
+  initial_ttl?: number;
+  /** @format int64 */
+  extend_duration?: number;
+  /** @format int64 */
+  extend_window?: number;
+  /** @format int64 */
+  max_extensions?: number;
+  /** @format int64 */
+  max_lifetime?: number;
+  /** @format int64 */
+  idle_timeout?: number;
+}
+
 export interface UserGameSimpleInfo {
   /** @format int64 */
   game_id: number;
@@ -352,6 +543,8 @@ export interface UserSimpleGameChallenge {
   visible?: boolean;
   category?: ChallengeCategory;
   belong_stage?: string;
+  /** 题目类型，为空时按 STANDARD 处理，KOTH 题目按占领靶机的时长计分 */
+  challenge_kind?: GameChallengeKind;
 }
 
 export interface UserSimpleGameSolvedChallenge {
@@ -390,6 +583,15 @@ export interface UserAttachmentConfig {
    * @example "d4e5f6a1b2c3"
    */
   download_hash?: string | null;
+  /** generate status of the team's dynamic attachment, omitted before the first generate request */
+  generate_status?: TeamAttachmentStatus;
+}
+
+/** generate status of the team's dynamic attachment, omitted before the first generate request */
+export enum TeamAttachmentStatus {
+  PENDING = "PENDING",
+  READY = "READY",
+  FAILED = "FAILED",
 }
 
 export interface UserDetailGameChallenge {
@@ -425,7 +627,41 @@ export interface UserDetailGameChallenge {
   /** @format date-time */
   container_expiretime?: string;
   containers?: ExposePortInfo[];
+  container_lifetime?: UserContainerLifetime;
+  container_extend_count?: number;
   attachments?: UserAttachmentConfig[];
+  /** 题目类型，为空时按 STANDARD 处理，KOTH 题目按占领靶机的时长计分 */
+  challenge_kind?: GameChallengeKind;
+  /** KOTH 题目的占领状态，只有 KOTH 题目返回 */
+  koth?: UserKothStatus;
+}
+
+/** KOTH 题目的占领状态，只有 KOTH 题目返回 */
+export interface UserKothStatus {
+  /** @format int64 */
+  tick_interval: number;
+  /** @format double */
+  points_per_tick: number;
+  /** @format int64 */
+  holder_team_id: number | null;
+  holder_team_name: string | null;
+  /** @format date-time */
+  last_tick_time: string | null;
+  /**
+   * 当前队伍在这道题上累计获得的分数
+   * @format double
+   */
+  team_points: number;
+}
+
+/** durations in seconds, 0 means unlimited for max_extensions / max_lifetime and disabled for idle_timeout */
+export interface UserContainerLifetime {
+  initial_ttl: number;
+  extend_duration: number;
+  extend_window: number;
+  max_extensions: number;
+  max_lifetime: number;
+  idle_timeout: number;
 }
 
 export interface UserTeamInfo {
@@ -523,6 +759,9 @@ export interface ExposePortInfo {
     port_name: string;
     port: number;
     ip: string;
+    type?: "PORT" | "URL" | "GATEWAY";
+    url?: string;
+    token?: string;
   }[];
 }
 
@@ -765,6 +1004,9 @@ export interface AdminContainerItem {
     port_name: string;
     port: number;
     ip: string;
+    type?: "PORT" | "URL" | "GATEWAY";
+    url?: string;
+    token?: string;
   }[];
   team_name: string;
   game_name: string;
@@ -813,10 +1055,21 @@ export interface UserProfile {
   /** @format date-time */
   last_login_time: string;
   last_login_ip: string | null;
+  sso_identities?: UserSsoIdentity[];
   /** @format date-time */
   client_config_version?: string;
 }
 
+export interface UserSsoIdentity {
+  provider: string;
+  username: string;
+  email: string;
+  /** @format date-time */
+  linked_at: string;
+  /** @format date-time */
+  last_login_at: string;
+}
+
 export interface UserProfileUpdatePayload {
   phone?: string | null;
   student_number?: string | null;
@@ -1126,6 +1379,8 @@ export interface AdminListSubmitsPayload {
     | "JudgeQueueing"
     | "JudgeRunning"
   )[];
+  /** 关联分析记录的最低置信度（可选，不影响其他类型的记录） */
+  min_confidence?: number;
   /**
    * 开始时间（可选）
    * @format date-time
@@ -1186,7 +1441,16 @@ export interface AdminListCheatsPayload {
     | "SubmitSomeonesFlag"
     | "SubmitWithoutDownloadAttachments"
     | "SubmitWithoutStartContainer"
+    | "SharedIP"
+    | "SharedSubnet"
+    | "SyncedSolves"
+    | "SameWrongFlag"
+    | "SharedAccount"
+    | "SubmitHoneypotFlag"
+    | "SubmitCanaryFlag"
   )[];
+  /** 关联分析记录的最低置信度（可选，不影响其他类型的记录） */
+  min_confidence?: number;
   /**
    * 开始时间（可选）
    * @format date-time
@@ -1206,23 +1470,29 @@ export interface AdminCheatItem {
   cheat_type:
     | "SubmitSomeonesFlag"
     | "SubmitWithoutDownloadAttachments"
-    | "SubmitWithoutStartContainer";
+    | "SubmitWithoutStartContainer"
+    | "SharedIP"
+    | "SharedSubnet"
+    | "SyncedSolves"
+    | "SameWrongFlag"
+    | "SharedAccount"
+    | "SubmitHoneypotFlag"
+    | "SubmitCanaryFlag";
   /** 作弊者用户名 */
   username: string;
   /** 作弊者队伍名 */
   team_name: string;
   /** 队伍ID */
   team_id: number;
-  /** 题目ID */
-  challenge_id: number;
+  /** 题目ID，关联分析的记录可能为空 */
+  challenge_id: number | null;
   /** 题目名称 */
   challenge_name: string;
-  /** 相关判题ID */
-  judge_id: string;
+  /** 相关判题ID，关联分析的记录可能为空 */
+  judge_id: string | null;
   /** 相关FLAG ID */
   flag_id?: number | null;
-  /** 额外数据 */
-  extra_data: object;
+  extra_data: CheatExtraData;
   /**
    * 作弊时间
    * @format date-time
@@ -1232,6 +1502,85 @@ export interface AdminCheatItem {
   submiter_ip?: string | null;
 }
 
+/** 额外数据 */
+export interface CheatExtraData {
+  /** 相关队伍ID */
+  relevant_team?: number;
+  /** 相关队伍名 */
+  relevant_teamname?: string;
+  /** 关联分析的置信度，0 到 1 */
+  confidence?: number;
+  /** 关联分析的证据，诱饵 flag 记录诱饵的来源 source，内容随作弊类型变化 */
+  evidence?: Record<string, any>;
+}
+
+/** 作弊处罚规则 */
+export interface EnforcementRule {
+  /** 规则名，不能重复 */
+  name: string;
+  /** 匹配的作弊类型，为空时匹配所有类型 */
+  cheat_types?: (
+    | "SubmitSomeonesFlag"
+    | "SubmitWithoutDownloadAttachments"
+    | "SubmitWithoutStartContainer"
+    | "SharedIP"
+    | "SharedSubnet"
+    | "SyncedSolves"
+    | "SameWrongFlag"
+    | "SharedAccount"
+    | "SubmitHoneypotFlag"
+    | "SubmitCanaryFlag"
+  )[];
+  /** 关联分析记录的最低置信度，没有置信度的记录总是匹配 */
+  min_confidence?: number;
+  /** 队伍累计匹配的记录达到这个数量时执行一次，0 和 1 表示每条记录都执行 */
+  threshold?: number;
+  action:
+    | "InvalidateSolve"
+    | "ScorePenalty"
+    | "SetPending"
+    | "Ban"
+    | "NotifyAdmins";
+  /** 同时处罚记录里的相关队伍，比如 flag 的所有者 */
+  include_relevant_team?: boolean;
+  /** ScorePenalty 扣除的分数 */
+  penalty?: number;
+}
+
+export interface EnforcementPolicy {
+  rules: EnforcementRule[];
+  /** 最后修改规则的管理员 */
+  updated_by?: string;
+  /** @format date-time */
+  updated_at?: string;
+}
+
+export interface AdminEnforcementItem {
+  enforcement_id: number;
+  cheat_id: string;
+  cheat_type: string;
+  team_id: number;
+  team_name: string;
+  rule_name: string;
+  action:
+    | "InvalidateSolve"
+    | "ScorePenalty"
+    | "SetPending"
+    | "Ban"
+    | "NotifyAdmins";
+  /** 执行时的规则和撤销需要的数据 */
+  details: Record<string, any>;
+  status: "Applied" | "Undone" | "Failed";
+  error_message?: string | null;
+  /** 执行的次数，失败的处罚会自动重试到 anti-cheat.enforcement.max-attempts 次 */
+  attempts: number;
+  /** @format date-time */
+  create_time: string;
+  /** @format date-time */
+  undo_time?: string | null;
+  undone_by?: string | null;
+}
+
 /** 系统设置完整结构体 */
 export interface SystemSettings {
   /**
@@ -1776,6 +2125,44 @@ export class Api<
         type: ContentType.Json,
         ...params,
       }),
+
+    /**
+     * @description Redirect to the provider's login page, the browser comes back to `redirect` after login
+     *
+     * @tags auth
+     * @name SsoLogin
+     * @summary Start single sign-on
+     * @request GET:/api/sso/{provider}/login
+     */
+    ssoLogin: (
+      provider: string,
+      query?: {
+        /** Relative frontend path opened after login */
+        redirect?: string;
+      },
+      params: RequestParams = {},
+    ) =>
+      this.request<any, void>({
+        path: `/api/sso/${provider}/login`,
+        method: "GET",
+        query: query,
+        ...params,
+      }),
+
+    /**
+     * @description Called by the provider. Logs in, links or registers the user and redirects back to the frontend, a successful login lands on /login?sso_login=<provider>&from=<base64 of redirect>. Failures redirect to /login (or /profile/sso when linking) with `sso_error` set to one of invalid_state, provider_error, not_linked, already_linked, provider_linked, unknown_provider, server_error
+     *
+     * @tags auth
+     * @name SsoCallback
+     * @summary Single sign-on callback
+     * @request GET:/api/sso/{provider}/callback
+     */
+    ssoCallback: (provider: string, params: RequestParams = {}) =>
+      this.request<any, void>({
+        path: `/api/sso/${provider}/callback`,
+        method: "GET",
+        ...params,
+      }),
   };
   user = {
     /**
@@ -1876,6 +2263,51 @@ export class Api<
         ...params,
       }),
 
+    /**
+     * @description Returns the provider's login page, the identity is linked to the current user after login
+     *
+     * @tags user
+     * @name SsoLink
+     * @summary Link a single sign-on identity
+     * @request POST:/api/account/sso/{provider}/link
+     */
+    ssoLink: (provider: string, params: RequestParams = {}) =>
+      this.request<
+        {
+          code: number;
+          data: {
+            url: string;
+          };
+        },
+        void
+      >({
+        path: `/api/account/sso/${provider}/link`,
+        method: "POST",
+        format: "json",
+        ...params,
+      }),
+
+    /**
+     * No description
+     *
+     * @tags user
+     * @name SsoUnlink
+     * @summary Unlink a single sign-on identity
+     * @request POST:/api/account/sso/{provider}/unlink
+     */
+    ssoUnlink: (provider: string, params: RequestParams = {}) =>
+      this.request<
+        {
+          code: number;
+        },
+        void
+      >({
+        path: `/api/account/sso/${provider}/unlink`,
+        method: "POST",
+        format: "json",
+        ...params,
+      }),
+
     /**
      * @description Verify email code
      *
@@ -2193,6 +2625,8 @@ export class Api<
               | "JudgeWA"
               | "JudgeAC"
               | "JudgeTimeout";
+            /** 判题脚本返回的消息，没有时为空 */
+            judge_result: string;
           };
         },
         void | ErrorMessage
@@ -2203,6 +2637,37 @@ export class Api<
         ...params,
       }),
 
+    /**
+     * @description Request the team's copy of a DYNAMICFILE attachment. The first request starts the generation and a failed one is generated again, other requests just return the current status
+     *
+     * @tags user
+     * @name UserGenerateAttachmentForAChallenge
+     * @summary Generate a dynamic attachment for the team
+     * @request POST:/api/game/{game_id}/attachment/{challenge_id}
+     */
+    userGenerateAttachmentForAChallenge: (
+      gameId: number,
+      challengeId: number,
+      data: {
+        attach_name: string;
+      },
+      params: RequestParams = {},
+    ) =>
+      this.request<
+        {
+          code: number;
+          data: UserAttachmentConfig;
+        },
+        ErrorMessage
+      >({
+        path: `/api/game/${gameId}/attachment/${challengeId}`,
+        method: "POST",
+        body: data,
+        type: ContentType.Json,
+        format: "json",
+        ...params,
+      }),
+
     /**
      * @description Create a container for a challenge
      *
@@ -2301,6 +2766,15 @@ export class Api<
             /** @format date-time */
             container_expiretime?: string;
             containers: ExposePortInfo[];
+            lifetime?: UserContainerLifetime;
+            extend_count?: number;
+            /** @format date-time */
+            max_expire_time?: string | null;
+            /**
+             * position of the container in the game's start queue, starting from 1, only returned while ContainerQueueing
+             * @format int64
+             */
+            queue_position?: number;
           };
         },
         void | ErrorMessage
@@ -3779,6 +4253,148 @@ export class Api<
         format: "json",
         ...params,
       }),
+
+    /**
+     * @description 分析不同队伍之间共用的 IP、同步的解题顺序、相同的错误 flag 和共用的账号，结果写入作弊记录
+     *
+     * @tags admin
+     * @name AdminCorrelateGameCheats
+     * @summary 立即对比赛做关联分析
+     * @request POST:/api/admin/game/{game_id}/cheats/correlate
+     */
+    adminCorrelateGameCheats: (gameId: number, params: RequestParams = {}) =>
+      this.request<
+        {
+          code: number;
+          data: {
+            /** 这次分析得到的记录数 */
+            findings: number;
+          };
+        },
+        void
+      >({
+        path: `/api/admin/game/${gameId}/cheats/correlate`,
+        method: "POST",
+        format: "json",
+        ...params,
+      }),
+    /**
+     * No description
+     *
+     * @tags admin
+     * @name AdminGetEnforcementPolicy
+     * @summary 获取比赛的作弊处罚规则
+     * @request GET:/api/admin/game/{game_id}/enforcement-policy
+     */
+    adminGetEnforcementPolicy: (gameId: number, params: RequestParams = {}) =>
+      this.request<
+        {
+          code: number;
+          data: EnforcementPolicy;
+        },
+        any
+      >({
+        path: `/api/admin/game/${gameId}/enforcement-policy`,
+        method: "GET",
+        format: "json",
+        ...params,
+      }),
+
+    /**
+     * @description 只对之后的作弊记录生效，自动扣分记在修改规则的管理员名下，修改过的规则会当作新的规则重新执行
+     *
+     * @tags admin
+     * @name AdminUpdateEnforcementPolicy
+     * @summary 修改比赛的作弊处罚规则
+     * @request PUT:/api/admin/game/{game_id}/enforcement-policy
+     */
+    adminUpdateEnforcementPolicy: (
+      gameId: number,
+      data: {
+        rules: EnforcementRule[];
+      },
+      params: RequestParams = {},
+    ) =>
+      this.request<void, void>({
+        path: `/api/admin/game/${gameId}/enforcement-policy`,
+        method: "PUT",
+        body: data,
+        type: ContentType.Json,
+        ...params,
+      }),
+
+    /**
+     * No description
+     *
+     * @tags admin
+     * @name AdminListEnforcements
+     * @summary 获取比赛的处罚记录
+     * @request POST:/api/admin/game/{game_id}/enforcements
+     */
+    adminListEnforcements: (
+      gameId: number,
+      data: {
+        size?: number;
+        offset?: number;
+        team_ids?: number[];
+        cheat_id?: string;
+        statuses?: ("Applied" | "Undone" | "Failed")[];
+      },
+      params: RequestParams = {},
+    ) =>
+      this.request<
+        {
+          code: number;
+          data: AdminEnforcementItem[];
+          total: number;
+        },
+        any
+      >({
+        path: `/api/admin/game/${gameId}/enforcements`,
+        method: "POST",
+        body: data,
+        type: ContentType.Json,
+        format: "json",
+        ...params,
+      }),
+
+    /**
+     * @description 恢复作废的解题、删除扣分记录、恢复队伍状态，之后被管理员修改过的数据保持不变，已经发出的邮件无法撤回
+     *
+     * @tags admin
+     * @name AdminUndoEnforcement
+     * @summary 撤销一次处罚
+     * @request POST:/api/admin/game/{game_id}/enforcements/{enforcement_id}/undo
+     */
+    adminUndoEnforcement: (
+      gameId: number,
+      enforcementId: number,
+      params: RequestParams = {},
+    ) =>
+      this.request<void, void>({
+        path: `/api/admin/game/${gameId}/enforcements/${enforcementId}/undo`,
+        method: "POST",
+        ...params,
+      }),
+
+    /**
+     * @description 失败的处罚会自动重试到 anti-cheat.enforcement.max-attempts 次，之后需要管理员手动重试；规则已经不适用时失败的记录会被删除
+     *
+     * @tags admin
+     * @name AdminRetryEnforcement
+     * @summary 重试一次失败的处罚
+     * @request POST:/api/admin/game/{game_id}/enforcements/{enforcement_id}/retry
+     */
+    adminRetryEnforcement: (
+      gameId: number,
+      enforcementId: number,
+      params: RequestParams = {},
+    ) =>
+      this.request<void, void>({
+        path: `/api/admin/game/${gameId}/enforcements/${enforcementId}/retry`,
+        method: "POST",
+        ...params,
+      }),
   };
   file = {
     /**
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE game_challenges ADD COLUMN scoring_mode jsonb NOT NULL DEFAULT '"EXP_DECAY"'::jsonb;
+ALTER TABLE game_challenges ADD COLUMN scoring_config jsonb;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE game_challenges DROP COLUMN scoring_config;
+ALTER TABLE game_challenges DROP COLUMN scoring_mode;
+-- +goose StatementEnd
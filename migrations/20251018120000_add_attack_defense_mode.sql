@@ -0,0 +1,117 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE games ADD COLUMN game_mode jsonb NOT NULL DEFAULT '"JEOPARDY"'::jsonb;
+ALTER TABLE games ADD COLUMN attack_defense_config jsonb;
+ALTER TABLE game_challenges ADD COLUMN ad_service_config jsonb;
+
+-- 攻防模式每轮生成新的 flag，解题模式固定为 0
+ALTER TABLE team_flags ADD COLUMN round integer NOT NULL DEFAULT 0;
+CREATE INDEX idx_team_flags_round ON team_flags(game_id, challenge_id, team_id, round);
+
+CREATE TABLE "ad_rounds" (
+    "game_id" bigint NOT NULL,
+    "round" integer NOT NULL,
+    "start_time" timestamp NOT NULL,
+    "end_time" timestamp NOT NULL,
+    "checked" boolean NOT NULL DEFAULT false,
+    "scored" boolean NOT NULL DEFAULT false,
+    PRIMARY KEY (game_id, round),
+    CONSTRAINT ad_rounds_game_id_fkey FOREIGN KEY (game_id)
+        REFERENCES games(game_id) ON DELETE CASCADE
+);
+
+CREATE TABLE "ad_services" (
+    "service_id" BIGSERIAL NOT NULL,
+    "game_id" bigint NOT NULL,
+    "team_id" bigint NOT NULL,
+    "challenge_id" bigint NOT NULL,
+    "ingame_id" bigint NOT NULL,
+    "container_id" text,
+    "flag_round" integer NOT NULL DEFAULT 0,
+    "status" jsonb NOT NULL DEFAULT '"PENDING"'::jsonb,
+    "message" text NOT NULL DEFAULT '',
+    "check_time" timestamp,
+    PRIMARY KEY (service_id),
+    CONSTRAINT unique_ad_service UNIQUE (game_id, team_id, challenge_id),
+    CONSTRAINT ad_services_game_id_fkey FOREIGN KEY (game_id)
+        REFERENCES games(game_id) ON DELETE CASCADE,
+    CONSTRAINT ad_services_team_id_fkey FOREIGN KEY (team_id)
+        REFERENCES teams(team_id) ON DELETE CASCADE,
+    CONSTRAINT ad_services_ingame_id_fkey FOREIGN KEY (ingame_id)
+        REFERENCES game_challenges(ingame_id) ON DELETE CASCADE
+);
+
+CREATE TABLE "ad_service_checks" (
+    "game_id" bigint NOT NULL,
+    "round" integer NOT NULL,
+    "team_id" bigint NOT NULL,
+    "challenge_id" bigint NOT NULL,
+    "status" jsonb NOT NULL,
+    "message" text NOT NULL DEFAULT '',
+    "check_time" timestamp NOT NULL,
+    PRIMARY KEY (game_id, round, team_id, challenge_id),
+    CONSTRAINT ad_service_checks_game_id_fkey FOREIGN KEY (game_id)
+        REFERENCES games(game_id) ON DELETE CASCADE,
+    CONSTRAINT ad_service_checks_team_id_fkey FOREIGN KEY (team_id)
+        REFERENCES teams(team_id) ON DELETE CASCADE
+);
+
+CREATE TABLE "ad_attacks" (
+    "attack_id" BIGSERIAL NOT NULL,
+    "game_id" bigint NOT NULL,
+    "round" integer NOT NULL,
+    "attacker_team_id" bigint NOT NULL,
+    "victim_team_id" bigint NOT NULL,
+    "challenge_id" bigint NOT NULL,
+    "flag_id" bigint NOT NULL,
+    "flag_round" integer NOT NULL,
+    "submiter_id" uuid NOT NULL,
+    "submiter_ip" text,
+    "submit_time" timestamp NOT NULL,
+    PRIMARY KEY (attack_id),
+    CONSTRAINT unique_ad_attack_per_flag UNIQUE (attacker_team_id, flag_id),
+    CONSTRAINT ad_attacks_game_id_fkey FOREIGN KEY (game_id)
+        REFERENCES games(game_id) ON DELETE CASCADE,
+    CONSTRAINT ad_attacks_attacker_team_id_fkey FOREIGN KEY (attacker_team_id)
+        REFERENCES teams(team_id) ON DELETE CASCADE,
+    CONSTRAINT ad_attacks_victim_team_id_fkey FOREIGN KEY (victim_team_id)
+        REFERENCES teams(team_id) ON DELETE CASCADE,
+    CONSTRAINT ad_attacks_flag_id_fkey FOREIGN KEY (flag_id)
+        REFERENCES team_flags(flag_id) ON DELETE CASCADE,
+    CONSTRAINT ad_attacks_submiter_id_fkey FOREIGN KEY (submiter_id)
+        REFERENCES users(user_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_ad_attacks_game_round ON ad_attacks(game_id, round);
+
+CREATE TABLE "ad_round_scores" (
+    "game_id" bigint NOT NULL,
+    "round" integer NOT NULL,
+    "team_id" bigint NOT NULL,
+    "challenge_id" bigint NOT NULL,
+    "attack_score" double precision NOT NULL DEFAULT 0,
+    "defense_score" double precision NOT NULL DEFAULT 0,
+    "sla_score" double precision NOT NULL DEFAULT 0,
+    PRIMARY KEY (game_id, round, team_id, challenge_id),
+    CONSTRAINT ad_round_scores_game_id_fkey FOREIGN KEY (game_id)
+        REFERENCES games(game_id) ON DELETE CASCADE,
+    CONSTRAINT ad_round_scores_team_id_fkey FOREIGN KEY (team_id)
+        REFERENCES teams(team_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_ad_round_scores_team ON ad_round_scores(game_id, team_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS ad_round_scores;
+DROP TABLE IF EXISTS ad_attacks;
+DROP TABLE IF EXISTS ad_service_checks;
+DROP TABLE IF EXISTS ad_services;
+DROP TABLE IF EXISTS ad_rounds;
+DROP INDEX IF EXISTS idx_team_flags_round;
+ALTER TABLE team_flags DROP COLUMN round;
+ALTER TABLE game_challenges DROP COLUMN ad_service_config;
+ALTER TABLE games DROP COLUMN attack_defense_config;
+ALTER TABLE games DROP COLUMN game_mode;
+-- +goose StatementEnd
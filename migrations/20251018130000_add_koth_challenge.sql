@@ -0,0 +1,34 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE game_challenges ADD COLUMN challenge_kind jsonb NOT NULL DEFAULT '"STANDARD"'::jsonb;
+ALTER TABLE game_challenges ADD COLUMN koth_config jsonb;
+
+CREATE TABLE "koth_ticks" (
+    "tick_id" BIGSERIAL NOT NULL,
+    "game_id" bigint NOT NULL,
+    "challenge_id" bigint NOT NULL,
+    "ingame_id" bigint NOT NULL,
+    "tick" bigint NOT NULL,
+    "team_id" bigint,
+    "points" double precision NOT NULL DEFAULT 0,
+    "message" text NOT NULL DEFAULT '',
+    "tick_time" timestamp NOT NULL,
+    PRIMARY KEY (tick_id),
+    CONSTRAINT unique_koth_tick UNIQUE (ingame_id, tick),
+    CONSTRAINT koth_ticks_game_id_fkey FOREIGN KEY (game_id)
+        REFERENCES games(game_id) ON DELETE CASCADE,
+    CONSTRAINT koth_ticks_ingame_id_fkey FOREIGN KEY (ingame_id)
+        REFERENCES game_challenges(ingame_id) ON DELETE CASCADE,
+    CONSTRAINT koth_ticks_team_id_fkey FOREIGN KEY (team_id)
+        REFERENCES teams(team_id) ON DELETE CASCADE
+);
+
+CREATE INDEX idx_koth_ticks_team ON koth_ticks(game_id, team_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS koth_ticks;
+ALTER TABLE game_challenges DROP COLUMN koth_config;
+ALTER TABLE game_challenges DROP COLUMN challenge_kind;
+-- +goose StatementEnd
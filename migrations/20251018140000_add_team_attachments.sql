@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "team_attachments" (
+    "attachment_id" BIGSERIAL NOT NULL,
+    "game_id" bigint NOT NULL,
+    "challenge_id" bigint NOT NULL,
+    "team_id" bigint NOT NULL,
+    "attach_name" text NOT NULL,
+    "file_id" uuid,
+    "status" jsonb NOT NULL,
+    "message" text NOT NULL DEFAULT '',
+    "create_time" timestamp NOT NULL,
+    "update_time" timestamp NOT NULL,
+    PRIMARY KEY (attachment_id),
+    CONSTRAINT unique_team_attachment UNIQUE (game_id, challenge_id, team_id, attach_name),
+    CONSTRAINT team_attachments_game_id_fkey FOREIGN KEY (game_id)
+        REFERENCES games(game_id) ON DELETE CASCADE,
+    CONSTRAINT team_attachments_challenge_id_fkey FOREIGN KEY (challenge_id)
+        REFERENCES challenges(challenge_id) ON DELETE CASCADE,
+    CONSTRAINT team_attachments_team_id_fkey FOREIGN KEY (team_id)
+        REFERENCES teams(team_id) ON DELETE CASCADE,
+    CONSTRAINT team_attachments_file_id_fkey FOREIGN KEY (file_id)
+        REFERENCES uploads(file_id) ON DELETE SET NULL
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS team_attachments;
+-- +goose StatementEnd
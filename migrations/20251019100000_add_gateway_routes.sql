@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "gateway_routes" (
+    "token" varchar(64) NOT NULL,
+    "container_id" uuid NOT NULL,
+    "game_id" bigint NOT NULL,
+    "team_id" bigint NOT NULL,
+    "container_name" text NOT NULL,
+    "port_name" text NOT NULL,
+    "target_host" text NOT NULL,
+    "target_port" integer NOT NULL,
+    "create_time" timestamp NOT NULL,
+    PRIMARY KEY (token),
+    CONSTRAINT gateway_routes_container_id_fkey FOREIGN KEY (container_id)
+        REFERENCES containers(container_id) ON DELETE CASCADE
+);
+CREATE INDEX idx_gateway_routes_container ON gateway_routes(container_id);
+
+CREATE TABLE "gateway_connections" (
+    "connection_id" BIGSERIAL NOT NULL,
+    "token" varchar(64) NOT NULL,
+    "container_id" uuid NOT NULL,
+    "game_id" bigint NOT NULL,
+    "team_id" bigint NOT NULL,
+    "port_name" text NOT NULL,
+    "client_addr" text NOT NULL,
+    "mode" text NOT NULL,
+    "start_time" timestamp NOT NULL,
+    "end_time" timestamp,
+    "bytes_in" bigint NOT NULL DEFAULT 0,
+    "bytes_out" bigint NOT NULL DEFAULT 0,
+    "close_reason" text NOT NULL DEFAULT '',
+    PRIMARY KEY (connection_id),
+    CONSTRAINT gateway_connections_container_id_fkey FOREIGN KEY (container_id)
+        REFERENCES containers(container_id) ON DELETE CASCADE
+);
+CREATE INDEX idx_gateway_connections_container ON gateway_connections(container_id);
+CREATE INDEX idx_gateway_connections_game_team ON gateway_connections(game_id, team_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS gateway_connections;
+DROP TABLE IF EXISTS gateway_routes;
+-- +goose StatementEnd
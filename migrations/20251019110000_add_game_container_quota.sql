@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE games ADD COLUMN container_quota jsonb;
+CREATE INDEX idx_containers_game_status ON containers(game_id, container_status);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_containers_game_status;
+ALTER TABLE games DROP COLUMN container_quota;
+-- +goose StatementEnd
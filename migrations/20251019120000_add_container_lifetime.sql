@@ -0,0 +1,15 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE games ADD COLUMN container_lifetime jsonb;
+ALTER TABLE game_challenges ADD COLUMN container_lifetime jsonb;
+ALTER TABLE containers ADD COLUMN extend_count bigint NOT NULL DEFAULT 0;
+ALTER TABLE containers ADD COLUMN last_active_time timestamp;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE containers DROP COLUMN last_active_time;
+ALTER TABLE containers DROP COLUMN extend_count;
+ALTER TABLE game_challenges DROP COLUMN container_lifetime;
+ALTER TABLE games DROP COLUMN container_lifetime;
+-- +goose StatementEnd
@@ -0,0 +1,11 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE game_challenges ADD COLUMN warm_pool jsonb;
+ALTER TABLE containers ADD COLUMN pod_name text;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE containers DROP COLUMN pod_name;
+ALTER TABLE game_challenges DROP COLUMN warm_pool;
+-- +goose StatementEnd
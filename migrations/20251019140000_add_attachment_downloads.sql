@@ -0,0 +1,30 @@
+-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE "attachment_downloads" (
+    "download_id" BIGSERIAL NOT NULL,
+    "game_id" bigint NOT NULL,
+    "challenge_id" bigint NOT NULL,
+    "team_id" bigint NOT NULL,
+    "user_id" uuid NOT NULL,
+    "file_id" uuid NOT NULL,
+    "download_count" bigint NOT NULL DEFAULT 1,
+    "first_download_time" timestamp NOT NULL,
+    "last_download_time" timestamp NOT NULL,
+    "last_download_ip" text,
+    PRIMARY KEY (download_id),
+    CONSTRAINT unique_attachment_download UNIQUE (game_id, challenge_id, team_id, user_id, file_id),
+    CONSTRAINT attachment_downloads_game_id_fkey FOREIGN KEY (game_id)
+        REFERENCES games(game_id) ON DELETE CASCADE,
+    CONSTRAINT attachment_downloads_challenge_id_fkey FOREIGN KEY (challenge_id)
+        REFERENCES challenges(challenge_id) ON DELETE CASCADE,
+    CONSTRAINT attachment_downloads_team_id_fkey FOREIGN KEY (team_id)
+        REFERENCES teams(team_id) ON DELETE CASCADE
+);
+CREATE INDEX idx_team_attachments_file_id ON team_attachments (file_id);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_team_attachments_file_id;
+DROP TABLE IF EXISTS attachment_downloads;
+-- +goose StatementEnd
@@ -0,0 +1,25 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 关联分析的记录不一定对应某次提交或者某道题目
+ALTER TABLE "cheats" ALTER COLUMN "ingame_id" DROP NOT NULL;
+ALTER TABLE "cheats" ALTER COLUMN "ingame_id" DROP DEFAULT;
+ALTER TABLE "cheats" ALTER COLUMN "challenge_id" DROP NOT NULL;
+ALTER TABLE "cheats" ALTER COLUMN "challenge_id" DROP DEFAULT;
+ALTER TABLE "cheats" ALTER COLUMN "judge_id" DROP NOT NULL;
+ALTER TABLE "cheats" ALTER COLUMN "submiter_id" DROP NOT NULL;
+
+-- 关联分析重复执行时按指纹更新同一条记录
+ALTER TABLE "cheats" ADD COLUMN "fingerprint" text;
+CREATE UNIQUE INDEX idx_cheats_fingerprint ON cheats (fingerprint);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_cheats_fingerprint;
+DELETE FROM "cheats" WHERE "fingerprint" IS NOT NULL;
+ALTER TABLE "cheats" DROP COLUMN "fingerprint";
+ALTER TABLE "cheats" ALTER COLUMN "submiter_id" SET NOT NULL;
+ALTER TABLE "cheats" ALTER COLUMN "judge_id" SET NOT NULL;
+ALTER TABLE "cheats" ALTER COLUMN "challenge_id" SET NOT NULL;
+ALTER TABLE "cheats" ALTER COLUMN "ingame_id" SET NOT NULL;
+-- +goose StatementEnd
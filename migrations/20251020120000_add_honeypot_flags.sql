@@ -0,0 +1,31 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "game_challenges" ADD COLUMN "honeypot" jsonb;
+
+CREATE TABLE "team_canaries" (
+    "canary_id" BIGSERIAL NOT NULL,
+    "game_id" bigint NOT NULL,
+    "ingame_id" bigint NOT NULL,
+    "challenge_id" bigint NOT NULL,
+    "team_id" bigint NOT NULL,
+    "canary" text NOT NULL,
+    "create_time" timestamp NOT NULL,
+    PRIMARY KEY (canary_id),
+    CONSTRAINT unique_team_canary UNIQUE (ingame_id, team_id),
+    CONSTRAINT unique_canary_content UNIQUE (ingame_id, canary),
+    CONSTRAINT team_canaries_game_id_fkey FOREIGN KEY (game_id)
+        REFERENCES games(game_id) ON DELETE CASCADE,
+    CONSTRAINT team_canaries_ingame_id_fkey FOREIGN KEY (ingame_id)
+        REFERENCES game_challenges(ingame_id) ON DELETE CASCADE,
+    CONSTRAINT team_canaries_challenge_id_fkey FOREIGN KEY (challenge_id)
+        REFERENCES challenges(challenge_id) ON DELETE CASCADE,
+    CONSTRAINT team_canaries_team_id_fkey FOREIGN KEY (team_id)
+        REFERENCES teams(team_id) ON DELETE CASCADE
+);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS team_canaries;
+ALTER TABLE "game_challenges" DROP COLUMN IF EXISTS "honeypot";
+-- +goose StatementEnd
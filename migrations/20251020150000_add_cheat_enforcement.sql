@@ -0,0 +1,45 @@
+-- +goose Up
+-- +goose StatementBegin
+ALTER TABLE "games" ADD COLUMN "enforcement_policy" jsonb;
+
+-- 已有的作弊记录不再执行处罚
+ALTER TABLE "cheats" ADD COLUMN "enforced_at" timestamp;
+UPDATE "cheats" SET "enforced_at" = "cheat_time";
+CREATE INDEX idx_cheats_unenforced ON cheats (cheat_time) WHERE enforced_at IS NULL;
+
+CREATE TABLE "enforcements" (
+    "enforcement_id" BIGSERIAL NOT NULL,
+    "enforcement_key" text NOT NULL,
+    "game_id" bigint NOT NULL,
+    "cheat_id" uuid NOT NULL,
+    "team_id" bigint NOT NULL,
+    "rule_name" text NOT NULL,
+    "action" text NOT NULL,
+    "details" jsonb NOT NULL,
+    "status" text NOT NULL,
+    "error_message" text,
+    "create_time" timestamp NOT NULL,
+    "undo_time" timestamp,
+    "undone_by" uuid,
+    PRIMARY KEY (enforcement_id),
+    CONSTRAINT unique_enforcement_key UNIQUE (enforcement_key),
+    CONSTRAINT enforcements_game_id_fkey FOREIGN KEY (game_id)
+        REFERENCES games(game_id) ON DELETE CASCADE,
+    CONSTRAINT enforcements_cheat_id_fkey FOREIGN KEY (cheat_id)
+        REFERENCES cheats(cheat_id) ON DELETE CASCADE,
+    CONSTRAINT enforcements_team_id_fkey FOREIGN KEY (team_id)
+        REFERENCES teams(team_id) ON DELETE CASCADE,
+    CONSTRAINT enforcements_undone_by_fkey FOREIGN KEY (undone_by)
+        REFERENCES users(user_id) ON DELETE SET NULL
+);
+
+CREATE INDEX idx_enforcements_game_id ON enforcements (game_id, create_time);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP TABLE IF EXISTS enforcements;
+DROP INDEX IF EXISTS idx_cheats_unenforced;
+ALTER TABLE "cheats" DROP COLUMN IF EXISTS "enforced_at";
+ALTER TABLE "games" DROP COLUMN IF EXISTS "enforcement_policy";
+-- +goose StatementEnd
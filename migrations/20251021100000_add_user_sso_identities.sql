@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 按提供方保存绑定的单点登录身份，{"<provider>": {"subject": "...", ...}}
+ALTER TABLE "users" ALTER COLUMN "sso_data" TYPE jsonb USING NULLIF("sso_data", '')::jsonb;
+CREATE INDEX idx_users_sso_data ON users USING gin (sso_data jsonb_path_ops);
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_users_sso_data;
+ALTER TABLE "users" ALTER COLUMN "sso_data" TYPE text USING "sso_data"::text;
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- worker 抢到判题的时间，用来判断判题是不是卡住了
+ALTER TABLE "judges" ADD COLUMN "judge_started_at" timestamptz;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+ALTER TABLE "judges" DROP COLUMN IF EXISTS "judge_started_at";
+-- +goose StatementEnd
@@ -0,0 +1,12 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 失败的处罚按执行次数自动重试
+ALTER TABLE "enforcements" ADD COLUMN "attempts" integer NOT NULL DEFAULT 1;
+CREATE INDEX idx_enforcements_failed ON enforcements (create_time) WHERE status = 'Failed';
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_enforcements_failed;
+ALTER TABLE "enforcements" DROP COLUMN IF EXISTS "attempts";
+-- +goose StatementEnd
@@ -0,0 +1,10 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 忽略大小写的 flag 反查走 LOWER(flag_content)，普通索引用不上
+CREATE INDEX idx_team_flags_content_lower ON team_flags (LOWER(flag_content));
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_team_flags_content_lower;
+-- +goose StatementEnd
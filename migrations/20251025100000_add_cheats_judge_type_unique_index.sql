@@ -0,0 +1,17 @@
+-- +goose Up
+-- +goose StatementBegin
+-- 反作弊任务重试时会再次检查同一次提交，先清理已经重复写入的记录，保留已经处罚过的那条
+DELETE FROM cheats a USING cheats b
+WHERE a.fingerprint IS NULL AND b.fingerprint IS NULL
+    AND a.judge_id = b.judge_id AND a.cheat_type = b.cheat_type
+    AND (a.enforced_at IS NULL, a.cheat_id) > (b.enforced_at IS NULL, b.cheat_id);
+
+-- 关联分析的记录按 fingerprint 去重，同一次提交可能对应多个队伍，不受这个限制
+CREATE UNIQUE INDEX idx_cheats_judge_cheat_type ON cheats (judge_id, cheat_type)
+    WHERE fingerprint IS NULL AND judge_id IS NOT NULL;
+-- +goose StatementEnd
+
+-- +goose Down
+-- +goose StatementBegin
+DROP INDEX IF EXISTS idx_cheats_judge_cheat_type;
+-- +goose StatementEnd
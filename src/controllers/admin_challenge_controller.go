@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	"errors"
 	"log"
 	"net/http"
 	"sort"
@@ -13,7 +14,9 @@ import (
 	"gorm.io/gorm"
 
 	"a1ctf/src/db/models"
+	scriptjudge "a1ctf/src/modules/script_judge"
 	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/utils/general"
 	i18ntool "a1ctf/src/utils/i18n_tool"
 	k8stool "a1ctf/src/utils/k8s_tool"
 	"a1ctf/src/utils/ristretto_tool"
@@ -34,6 +37,17 @@ type ChallengeSearchPayload struct {
 	Keyword string `json:"keyword"`
 }
 
+// 脚本判题的题目需要先检查脚本能否运行
+func validateJudgeScript(judgeConfig *models.JudgeConfig) error {
+	if judgeConfig == nil || judgeConfig.JudgeType != models.JudgeTypeScript {
+		return nil
+	}
+	if judgeConfig.JudgeScript == nil || *judgeConfig.JudgeScript == "" {
+		return errors.New("judge script is empty")
+	}
+	return scriptjudge.Validate(*judgeConfig.JudgeScript)
+}
+
 func AdminListChallenges(c *gin.Context) {
 	var payload ListChallengePayload
 	if err := c.ShouldBindJSON(&payload); err != nil {
@@ -130,6 +144,15 @@ func AdminCreateChallenge(c *gin.Context) {
 		return
 	}
 
+	// 共享容器所有队伍连同一个容器，只能放一份 flag
+	if payload.ContainerType == models.STATIC_CONTAINER && payload.FlagType == models.FlagTypeDynamic {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SharedContainerRequiresStaticFlag"}),
+		})
+		return
+	}
+
 	if payload.ContainerConfig != nil {
 		if err := k8stool.ValidContainerConfig(*payload.ContainerConfig); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -140,6 +163,22 @@ func AdminCreateChallenge(c *gin.Context) {
 		}
 	}
 
+	if err := validateJudgeScript(payload.JudgeConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidJudgeScript", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+		})
+		return
+	}
+
+	if err := general.ValidateFlagMatchConfig(payload.JudgeConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidFlagMatchConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+		})
+		return
+	}
+
 	payload.CreateTime = time.Now().UTC()
 	payload.ChallengeID = nil
 
@@ -223,6 +262,15 @@ func AdminUpdateChallenge(c *gin.Context) {
 		return
 	}
 
+	// 共享容器所有队伍连同一个容器，只能放一份 flag
+	if payload.ContainerType == models.STATIC_CONTAINER && payload.FlagType == models.FlagTypeDynamic {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SharedContainerRequiresStaticFlag"}),
+		})
+		return
+	}
+
 	if payload.ContainerConfig != nil {
 		if err := k8stool.ValidContainerConfig(*payload.ContainerConfig); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{
@@ -233,6 +281,22 @@ func AdminUpdateChallenge(c *gin.Context) {
 		}
 	}
 
+	if err := validateJudgeScript(payload.JudgeConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidJudgeScript", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+		})
+		return
+	}
+
+	if err := general.ValidateFlagMatchConfig(payload.JudgeConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidFlagMatchConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+		})
+		return
+	}
+
 	var existingChallenge models.Challenge
 	if err := dbtool.DB().Where("challenge_id = ?", payload.ChallengeID).First(&existingChallenge).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
@@ -126,7 +126,7 @@ func AdminListContainers(c *gin.Context) {
 			containerPorts = append(containerPorts, exposeInfo.ExposePorts...)
 		}
 
-		podID := fmt.Sprintf("cl-%d-%s", container.InGameID, container.TeamHash)
+		podID := container.PodID()
 
 		containerNameList := make([]string, 0)
 		for _, exposeInfo := range container.ContainerConfig {
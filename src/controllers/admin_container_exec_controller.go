@@ -1,6 +1,7 @@
 package controllers
 
 import (
+	containerbackend "a1ctf/src/utils/container_backend"
 	i18ntool "a1ctf/src/utils/i18n_tool"
 	k8stool "a1ctf/src/utils/k8s_tool"
 	"context"
@@ -73,6 +74,12 @@ func AdminHandleContainerExec(c *gin.Context) {
 
 	var writeMu sync.Mutex
 
+	// 交互式终端依赖 k8s 的 exec 接口
+	if containerbackend.Get().Driver() != containerbackend.DriverKubernetes {
+		sendErrorMessage(ws, &writeMu, fmt.Sprintf("Interactive exec is not supported by the %s container backend", containerbackend.Get().Driver()))
+		return
+	}
+
 	clientset, err := k8stool.GetClient()
 	if err != nil {
 		sendErrorMessage(ws, &writeMu, fmt.Sprintf("Failed to get k8s client: %v", err))
@@ -0,0 +1,323 @@
+package controllers
+
+import (
+	"a1ctf/src/db/models"
+	"a1ctf/src/modules/enforcement"
+	"a1ctf/src/tasks"
+	dbtool "a1ctf/src/utils/db_tool"
+	i18ntool "a1ctf/src/utils/i18n_tool"
+	"a1ctf/src/utils/zaphelper"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// AdminGetEnforcementPolicy 获取比赛的作弊处罚规则
+func AdminGetEnforcementPolicy(c *gin.Context) {
+	game := c.MustGet("game").(models.Game)
+
+	policy := game.EnforcementPolicy
+	if policy == nil {
+		policy = &models.EnforcementPolicy{Rules: make([]models.EnforcementRule, 0)}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": policy,
+	})
+}
+
+// AdminUpdateEnforcementPolicy 修改比赛的作弊处罚规则，只对之后的作弊记录生效
+func AdminUpdateEnforcementPolicy(c *gin.Context) {
+	game := c.MustGet("game").(models.Game)
+
+	var payload struct {
+		Rules []models.EnforcementRule `json:"rules"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidRequestPayload"}),
+		})
+		return
+	}
+
+	// 自动扣分记在最后修改规则的管理员名下
+	users, _ := c.Get("UserID")
+	userClaims := users.(*models.JWTUser)
+
+	var policy *models.EnforcementPolicy
+	if len(payload.Rules) > 0 {
+		policy = &models.EnforcementPolicy{
+			Rules:     payload.Rules,
+			UpdatedBy: userClaims.UserID,
+			UpdatedAt: time.Now().UTC(),
+		}
+	}
+
+	if err := enforcement.ValidatePolicy(policy); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidEnforcementPolicy", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+		})
+		return
+	}
+
+	if err := dbtool.DB().Model(&models.Game{}).Where("game_id = ?", game.GameID).Update("enforcement_policy", policy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToSaveEnforcementPolicy"}),
+		})
+		return
+	}
+
+	gameIDStr := strconv.FormatInt(game.GameID, 10)
+	tasks.LogAdminOperation(c, models.ActionUpdate, models.ResourceTypeGame, &gameIDStr, map[string]interface{}{
+		"game_id":            game.GameID,
+		"enforcement_policy": policy,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+	})
+}
+
+// AdminListEnforcements 获取比赛的处罚记录
+func AdminListEnforcements(c *gin.Context) {
+	game := c.MustGet("game").(models.Game)
+
+	var payload struct {
+		Size     int                        `json:"size" binding:"min=0"`
+		Offset   int                        `json:"offset"`
+		TeamIDs  []int64                    `json:"team_ids"`
+		CheatID  *string                    `json:"cheat_id"`
+		Statuses []models.EnforcementStatus `json:"statuses"`
+	}
+	if err := c.ShouldBindJSON(&payload); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidRequestPayload"}),
+		})
+		return
+	}
+
+	baseQuery := dbtool.DB().Model(&models.Enforcement{}).Where("game_id = ?", game.GameID)
+	if len(payload.TeamIDs) > 0 {
+		baseQuery = baseQuery.Where("team_id IN ?", payload.TeamIDs)
+	}
+	if payload.CheatID != nil && *payload.CheatID != "" {
+		baseQuery = baseQuery.Where("cheat_id = ?", *payload.CheatID)
+	}
+	if len(payload.Statuses) > 0 {
+		baseQuery = baseQuery.Where("status IN ?", payload.Statuses)
+	}
+
+	var total int64
+	if err := baseQuery.Count(&total).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToLoadEnforcements"}),
+		})
+		return
+	}
+
+	var enforcements []models.Enforcement
+	query := baseQuery.Preload("Team").Preload("Cheat").Order("create_time DESC").Offset(payload.Offset)
+	if payload.Size > 0 {
+		query = query.Limit(payload.Size)
+	}
+	if err := query.Find(&enforcements).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToLoadEnforcements"}),
+		})
+		return
+	}
+
+	data := make([]gin.H, 0, len(enforcements))
+	for _, item := range enforcements {
+		data = append(data, gin.H{
+			"enforcement_id": item.EnforcementID,
+			"cheat_id":       item.CheatID,
+			"cheat_type":     item.Cheat.CheatType,
+			"team_id":        item.TeamID,
+			"team_name":      item.Team.TeamName,
+			"rule_name":      item.RuleName,
+			"action":         item.Action,
+			"details":        item.Details,
+			"status":         item.Status,
+			"error_message":  item.ErrorMessage,
+			"attempts":       item.Attempts,
+			"create_time":    item.CreateTime,
+			"undo_time":      item.UndoTime,
+			"undone_by":      item.UndoneBy,
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code":  200,
+		"data":  data,
+		"total": total,
+	})
+}
+
+// AdminUndoEnforcement 撤销一次处罚，撤销后同一条规则不会再对这条记录执行
+func AdminUndoEnforcement(c *gin.Context) {
+	game := c.MustGet("game").(models.Game)
+
+	enforcementID, err := strconv.ParseInt(c.Param("enforcement_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidEnforcementID"}),
+		})
+		return
+	}
+
+	var existing models.Enforcement
+	if err := dbtool.DB().Where("enforcement_id = ? AND game_id = ?", enforcementID, game.GameID).First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "EnforcementNotFound"}),
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToLoadEnforcements"}),
+			})
+		}
+		return
+	}
+
+	users, _ := c.Get("UserID")
+	userClaims := users.(*models.JWTUser)
+
+	undone, err := enforcement.Undo(existing.EnforcementID, userClaims.UserID)
+	if err != nil {
+		if errors.Is(err, enforcement.ErrNotApplied) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "EnforcementNotApplied"}),
+			})
+			return
+		}
+
+		tasks.LogAdminOperationWithError(c, models.ActionUndoEnforce, models.ResourceTypeCheat, &existing.CheatID, map[string]interface{}{
+			"game_id":        existing.GameID,
+			"team_id":        existing.TeamID,
+			"enforcement_id": existing.EnforcementID,
+			"action":         existing.Action,
+		}, err)
+
+		zaphelper.Logger.Error("Failed to undo enforcement", zap.Error(err), zap.Int64("enforcement_id", existing.EnforcementID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToUndoEnforcement"}),
+		})
+		return
+	}
+
+	tasks.LogAdminOperation(c, models.ActionUndoEnforce, models.ResourceTypeCheat, &undone.CheatID, map[string]interface{}{
+		"game_id":        undone.GameID,
+		"team_id":        undone.TeamID,
+		"enforcement_id": undone.EnforcementID,
+		"rule_name":      undone.RuleName,
+		"action":         undone.Action,
+		"details":        undone.Details,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": undone,
+	})
+}
+
+// AdminRetryEnforcement 重新执行一次失败的处罚，超过自动重试次数的处罚需要管理员手动重试
+func AdminRetryEnforcement(c *gin.Context) {
+	game := c.MustGet("game").(models.Game)
+
+	enforcementID, err := strconv.ParseInt(c.Param("enforcement_id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidEnforcementID"}),
+		})
+		return
+	}
+
+	var existing models.Enforcement
+	if err := dbtool.DB().Where("enforcement_id = ? AND game_id = ?", enforcementID, game.GameID).First(&existing).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "EnforcementNotFound"}),
+			})
+		} else {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToLoadEnforcements"}),
+			})
+		}
+		return
+	}
+
+	retried, err := enforcement.Retry(existing.EnforcementID)
+	if err != nil {
+		switch {
+		case errors.Is(err, enforcement.ErrNotFailed):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "EnforcementNotFailed"}),
+			})
+			return
+		case errors.Is(err, enforcement.ErrNotApplicable):
+			tasks.LogAdminOperation(c, models.ActionRetryEnforce, models.ResourceTypeCheat, &existing.CheatID, map[string]interface{}{
+				"game_id":        existing.GameID,
+				"team_id":        existing.TeamID,
+				"enforcement_id": existing.EnforcementID,
+				"action":         existing.Action,
+				"skipped":        true,
+			})
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "EnforcementNotApplicable"}),
+			})
+			return
+		}
+
+		tasks.LogAdminOperationWithError(c, models.ActionRetryEnforce, models.ResourceTypeCheat, &existing.CheatID, map[string]interface{}{
+			"game_id":        existing.GameID,
+			"team_id":        existing.TeamID,
+			"enforcement_id": existing.EnforcementID,
+			"action":         existing.Action,
+		}, err)
+
+		zaphelper.Logger.Error("Failed to retry enforcement", zap.Error(err), zap.Int64("enforcement_id", existing.EnforcementID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToRetryEnforcement", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+		})
+		return
+	}
+
+	tasks.LogAdminOperation(c, models.ActionRetryEnforce, models.ResourceTypeCheat, &retried.CheatID, map[string]interface{}{
+		"game_id":        retried.GameID,
+		"team_id":        retried.TeamID,
+		"enforcement_id": retried.EnforcementID,
+		"rule_name":      retried.RuleName,
+		"action":         retried.Action,
+		"details":        retried.Details,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": retried,
+	})
+}
@@ -16,17 +16,27 @@ import (
 	"gorm.io/gorm"
 
 	"a1ctf/src/db/models"
+	anticheat "a1ctf/src/modules/anti_cheat"
+	attackdefense "a1ctf/src/modules/attack_defense"
+	containerlifetime "a1ctf/src/modules/container_lifetime"
+	containerquota "a1ctf/src/modules/container_quota"
+	"a1ctf/src/modules/koth"
+	scoreengine "a1ctf/src/modules/score_engine"
+	"a1ctf/src/modules/scoring"
+	warmpool "a1ctf/src/modules/warm_pool"
 	"a1ctf/src/tasks"
 	dbtool "a1ctf/src/utils/db_tool"
 	"a1ctf/src/utils/general"
 	i18ntool "a1ctf/src/utils/i18n_tool"
 	noticetool "a1ctf/src/utils/notice_tool"
+	"a1ctf/src/utils/zaphelper"
 	"a1ctf/src/webmodels"
 	"mime"
 
 	"github.com/bytedance/sonic"
 	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"go.uber.org/zap"
 )
 
 // helper: convert slice of strings to LIKE patterns for ILIKE ANY
@@ -117,6 +127,10 @@ func AdminCreateGame(c *gin.Context) {
 		InviteCode:           payload.InviteCode,
 		Description:          payload.Description,
 		TeamPolicy:           payload.TeamPolicy,
+		GameMode:             payload.GameMode,
+		AttackDefenseConfig:  payload.AttackDefenseConfig,
+		ContainerQuota:       payload.ContainerQuota,
+		ContainerLifetime:    payload.ContainerLifetime,
 	}
 
 	// 默认自动审核
@@ -124,6 +138,34 @@ func AdminCreateGame(c *gin.Context) {
 		game.TeamPolicy = models.TeamPolicyAuto
 	}
 
+	if game.GameMode == "" {
+		game.GameMode = models.GameModeJeopardy
+	}
+
+	if err := attackdefense.ValidateConfig(game.GameMode, game.AttackDefenseConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidAttackDefenseConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+		})
+		return
+	}
+
+	if !containerquota.ValidConfig(game.ContainerQuota) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidContainerQuota"}),
+		})
+		return
+	}
+
+	if !containerlifetime.ValidConfig(game.ContainerLifetime) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidContainerLifetime"}),
+		})
+		return
+	}
+
 	if err := dbtool.DB().Create(&game).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
 			"code":    500,
@@ -189,6 +231,11 @@ func AdminGetGame(c *gin.Context) {
 		"first_blood_reward":       game.FirstBloodReward,
 		"second_blood_reward":      game.SecondBloodReward,
 		"third_blood_reward":       game.ThirdBloodReward,
+		"blood_reward_config":      game.BloodRewardConfig,
+		"game_mode":                game.GameMode,
+		"attack_defense_config":    game.AttackDefenseConfig,
+		"container_quota":          game.ContainerQuota,
+		"container_lifetime":       game.ContainerLifetime,
 		"team_policy":              game.TeamPolicy,
 		"group_invite_code_enable": game.GroupInviteCodeEnabled,
 		"challenges":               make([]gin.H, 0),
@@ -232,6 +279,14 @@ func AdminGetGame(c *gin.Context) {
 			"visible":             gc.Visible,
 			"minimal_score":       gc.MinimalScore,
 			"enable_blood_reward": gc.BloodRewardEnabled,
+			"scoring_mode":        gc.ScoringMode,
+			"scoring_config":      gc.ScoringConfig,
+			"ad_service_config":   gc.ADServiceConfig,
+			"challenge_kind":      gc.ChallengeKind,
+			"koth_config":         gc.KothConfig,
+			"container_lifetime":  gc.ContainerLifetime,
+			"warm_pool":           gc.WarmPool,
+			"honeypot":            gc.Honeypot,
 		})
 	}
 
@@ -264,6 +319,14 @@ func AdminGetGameChallenge(c *gin.Context) {
 		"minimal_score":       gc.MinimalScore,
 		"difficulty":          gc.Difficulty,
 		"enable_blood_reward": gc.BloodRewardEnabled,
+		"scoring_mode":        gc.ScoringMode,
+		"scoring_config":      gc.ScoringConfig,
+		"ad_service_config":   gc.ADServiceConfig,
+		"challenge_kind":      gc.ChallengeKind,
+		"koth_config":         gc.KothConfig,
+		"container_lifetime":  gc.ContainerLifetime,
+		"warm_pool":           gc.WarmPool,
+		"honeypot":            gc.Honeypot,
 	}
 
 	c.JSON(http.StatusOK, gin.H{
@@ -391,6 +454,20 @@ func AdminUpdateGameChallenge(c *gin.Context) {
 					})
 					return
 				}
+				if err := validateJudgeScript(&judgeConfig); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"code":    400,
+						"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidJudgeScript", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+					})
+					return
+				}
+				if err := general.ValidateFlagMatchConfig(&judgeConfig); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{
+						"code":    400,
+						"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidFlagMatchConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+					})
+					return
+				}
 				updateData["judge_config"] = judgeConfig
 				updateFields = append(updateFields, "judge_config")
 			}
@@ -416,6 +493,166 @@ func AdminUpdateGameChallenge(c *gin.Context) {
 		updateData["enable_blood_reward"] = bloodRewardEnabled
 		updateFields = append(updateFields, "enable_blood_reward")
 	}
+	if _, ok := payload["scoring_mode"]; ok {
+		// 计分模式和参数一起校验
+		var scoringPayload struct {
+			ScoringMode   models.ScoringMode    `json:"scoring_mode"`
+			ScoringConfig *models.ScoringConfig `json:"scoring_config"`
+		}
+		scoringBytes, _ := sonic.Marshal(payload)
+		if err := sonic.Unmarshal(scoringBytes, &scoringPayload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidScoringConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+			})
+			return
+		}
+		if err := scoring.ValidateScoringConfig(scoringPayload.ScoringMode, scoringPayload.ScoringConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidScoringConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+			})
+			return
+		}
+		updateData["scoring_mode"] = scoringPayload.ScoringMode
+		updateData["scoring_config"] = scoringPayload.ScoringConfig
+		updateFields = append(updateFields, "scoring_mode", "scoring_config")
+	}
+	if serviceConfigData, ok := payload["ad_service_config"]; ok {
+		// 攻防模式的 checker 和写 flag 的方式
+		var serviceConfig *models.ADServiceConfig
+		serviceConfigBytes, _ := sonic.Marshal(serviceConfigData)
+		if err := sonic.Unmarshal(serviceConfigBytes, &serviceConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidADServiceConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+			})
+			return
+		}
+		if err := attackdefense.ValidateServiceConfig(serviceConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidADServiceConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+			})
+			return
+		}
+		updateData["ad_service_config"] = serviceConfig
+		updateFields = append(updateFields, "ad_service_config")
+	}
+	if _, ok := payload["challenge_kind"]; ok {
+		// 题目类型和 KOTH 配置一起校验
+		var kindPayload struct {
+			ChallengeKind models.GameChallengeKind `json:"challenge_kind"`
+			KothConfig    *models.KothConfig       `json:"koth_config"`
+		}
+		kindBytes, _ := sonic.Marshal(payload)
+		if err := sonic.Unmarshal(kindBytes, &kindPayload); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidKothConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+			})
+			return
+		}
+		if kindPayload.ChallengeKind == "" {
+			kindPayload.ChallengeKind = models.GameChallengeStandard
+		}
+
+		var challenge models.Challenge
+		if err := dbtool.DB().Where("challenge_id = ?", challengeID).First(&challenge).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToLoadChallenge"}),
+			})
+			return
+		}
+
+		if err := koth.ValidateConfig(kindPayload.ChallengeKind, kindPayload.KothConfig, &challenge); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidKothConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+			})
+			return
+		}
+		updateData["challenge_kind"] = kindPayload.ChallengeKind
+		updateData["koth_config"] = kindPayload.KothConfig
+		updateFields = append(updateFields, "challenge_kind", "koth_config")
+	}
+
+	if lifetimeData, ok := payload["container_lifetime"]; ok {
+		// 为空时使用比赛的设置
+		var lifetimeConfig *models.ContainerLifetimeConfig
+		lifetimeBytes, _ := sonic.Marshal(lifetimeData)
+		if err := sonic.Unmarshal(lifetimeBytes, &lifetimeConfig); err != nil || !containerlifetime.ValidConfig(lifetimeConfig) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidContainerLifetime"}),
+			})
+			return
+		}
+		updateData["container_lifetime"] = lifetimeConfig
+		updateFields = append(updateFields, "container_lifetime")
+	}
+
+	if warmPoolData, ok := payload["warm_pool"]; ok {
+		// 为空时不使用预热池
+		var warmPoolConfig *models.WarmPoolConfig
+		warmPoolBytes, _ := sonic.Marshal(warmPoolData)
+		if err := sonic.Unmarshal(warmPoolBytes, &warmPoolConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidWarmPoolConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+			})
+			return
+		}
+
+		var challenge models.Challenge
+		if err := dbtool.DB().Where("challenge_id = ?", challengeID).First(&challenge).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToLoadChallenge"}),
+			})
+			return
+		}
+
+		if err := warmpool.ValidateConfig(warmPoolConfig, &challenge); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidWarmPoolConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+			})
+			return
+		}
+		updateData["warm_pool"] = warmPoolConfig
+		updateFields = append(updateFields, "warm_pool")
+	}
+
+	if honeypotData, ok := payload["honeypot"]; ok {
+		// 为空时不使用诱饵 flag 和 canary
+		var honeypotConfig *models.HoneypotConfig
+		honeypotBytes, _ := sonic.Marshal(honeypotData)
+		if err := sonic.Unmarshal(honeypotBytes, &honeypotConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidHoneypotConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+			})
+			return
+		}
+
+		// 同时修改判题配置时按新的配置检查
+		judgeConfig := existingGameChallenge.JudgeConfig
+		if newJudgeConfig, ok := updateData["judge_config"].(models.JudgeConfig); ok {
+			judgeConfig = &newJudgeConfig
+		}
+
+		if err := anticheat.ValidateHoneypot(honeypotConfig, judgeConfig); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidHoneypotConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+			})
+			return
+		}
+		updateData["honeypot"] = honeypotConfig
+		updateFields = append(updateFields, "honeypot")
+	}
 
 	// 如果没有要更新的字段，直接返回
 	if len(updateFields) == 0 {
@@ -436,6 +673,8 @@ func AdminUpdateGameChallenge(c *gin.Context) {
 		return
 	}
 
+	scoreengine.NotifyChallenge(gameID, challengeID)
+
 	if shouldSendNotice {
 		noticetool.InsertNotice(gameID, models.NoticeNewHint, noticeData)
 	}
@@ -479,6 +718,63 @@ func AdminUpdateGame(c *gin.Context) {
 	game.ThirdBloodReward = payload.ThirdBloodReward
 	game.GroupInviteCodeEnabled = payload.GroupInviteCodeEnabled
 
+	// 自定义血奖名次、固定分奖励和时间衰减，没有传时保留原来的配置，
+	// 传一个没有名次的配置表示清除，恢复使用上面的三血比例
+	if payload.BloodRewardConfig != nil {
+		if len(payload.BloodRewardConfig.Ranks) == 0 {
+			game.BloodRewardConfig = nil
+		} else {
+			if err := scoring.ValidateBloodRewardConfig(payload.BloodRewardConfig); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{
+					"code":    400,
+					"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidBloodRewardConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+				})
+				return
+			}
+			game.BloodRewardConfig = payload.BloodRewardConfig
+		}
+	}
+
+	// 比赛模式和攻防轮次配置
+	if payload.GameMode != "" {
+		game.GameMode = payload.GameMode
+	}
+	if err := attackdefense.ValidateConfig(game.GameMode, payload.AttackDefenseConfig); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidAttackDefenseConfig", TemplateData: map[string]interface{}{"Error": err.Error()}}),
+		})
+		return
+	}
+	// 没有传时保留原来的轮次配置
+	if payload.AttackDefenseConfig != nil {
+		game.AttackDefenseConfig = payload.AttackDefenseConfig
+	}
+
+	// 容器资源配额，没有传时保留原来的配额
+	if payload.ContainerQuota != nil {
+		if !containerquota.ValidConfig(payload.ContainerQuota) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidContainerQuota"}),
+			})
+			return
+		}
+		game.ContainerQuota = payload.ContainerQuota
+	}
+
+	// 题目容器的默认有效期，题目可以单独覆盖，没有传时保留原来的设置
+	if payload.ContainerLifetime != nil {
+		if !containerlifetime.ValidConfig(payload.ContainerLifetime) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "InvalidContainerLifetime"}),
+			})
+			return
+		}
+		game.ContainerLifetime = payload.ContainerLifetime
+	}
+
 	// 更新 Belong stage
 	for _, chal := range payload.Challenges {
 
@@ -505,6 +801,9 @@ func AdminUpdateGame(c *gin.Context) {
 		return
 	}
 
+	// 比赛时间和血奖比例会影响所有分数
+	scoreengine.NotifyGame(game.GameID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 	})
@@ -542,6 +841,8 @@ func AdminAddGameChallenge(c *gin.Context) {
 		BelongStage:        nil,
 		Visible:            false,
 		BloodRewardEnabled: true,
+		ScoringMode:        models.ScoringExpDecay,
+		ChallengeKind:      models.GameChallengeStandard,
 	}
 
 	if err := dbtool.DB().Create(&gameChallenge).Error; err != nil {
@@ -552,6 +853,13 @@ func AdminAddGameChallenge(c *gin.Context) {
 		return
 	}
 
+	scoreengine.NotifyChallenge(gameID, challengeID)
+
+	// 提前在所有节点上拉取题目镜像，失败不影响添加题目
+	if err := warmpool.Prewarm(&gameChallenge, &challenge); err != nil {
+		zaphelper.Logger.Error("Failed to prewarm challenge images", zap.Error(err), zap.Int64("ingame_id", gameChallenge.IngameID))
+	}
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 		"data": gin.H{
@@ -582,6 +890,9 @@ func AdminDeleteGameChallenge(c *gin.Context) {
 		}
 	}
 
+	// 题目删掉后解出它的队伍分数都要重算
+	scoreengine.NotifyGame(gameID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
 	})
@@ -1095,6 +1406,8 @@ func AdminCreateScoreAdjustment(c *gin.Context) {
 		return
 	}
 
+	scoreengine.NotifyAdjustment(adjustment.GameID, adjustment.TeamID)
+
 	// 获取创建的记录详情
 	if err := dbtool.DB().Preload("Team").Preload("CreatedByUser").
 		Where("adjustment_id = ?", adjustment.AdjustmentID).
@@ -1229,6 +1542,8 @@ func AdminUpdateScoreAdjustment(c *gin.Context) {
 		return
 	}
 
+	scoreengine.NotifyAdjustment(originalAdjustment.GameID, originalAdjustment.TeamID)
+
 	// 获取更新后的记录详情
 	var updatedAdjustment models.ScoreAdjustment
 	if err := dbtool.DB().Preload("Team").Preload("CreatedByUser").
@@ -1345,6 +1660,8 @@ func AdminDeleteScoreAdjustment(c *gin.Context) {
 		return
 	}
 
+	scoreengine.NotifyAdjustment(adjustment.GameID, adjustment.TeamID)
+
 	c.JSON(http.StatusOK, gin.H{
 		"code":    200,
 		"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "ScoreAdjustmentDeletedSuccessfully"}),
@@ -1475,6 +1792,9 @@ func AdminDeleteChallengeSolves(c *gin.Context) {
 		return
 	}
 
+	// 解题记录变了，题目分数和解出的队伍都要重算
+	scoreengine.NotifyChallenge(gameID, challengeID)
+
 	tasks.NewRecalculateRankForAChallengeTask(gameID, []int64{challengeID})
 
 	// 构建响应消息
@@ -1746,6 +2066,7 @@ func AdminGetCheats(c *gin.Context) {
 		TeamIDs        []int64  `json:"team_ids"`        // 多个队伍ID
 		TeamNames      []string `json:"team_names"`      // 多个队伍名称
 		CheatTypes     []string `json:"cheat_types"`     // 多个作弊类型
+		MinConfidence  *float64 `json:"min_confidence"`  // 关联分析记录的最低置信度
 		StartTime      *string  `json:"start_time"`      // 起始时间 (ISO8601)
 		EndTime        *string  `json:"end_time"`        // 结束时间 (ISO8601)
 	}
@@ -1821,11 +2142,30 @@ func AdminGetCheats(c *gin.Context) {
 				coveredTypes = append(coveredTypes, models.CheatSubmitWithoutDownloadAttachments)
 			case "SubmitWithoutStartContainer":
 				coveredTypes = append(coveredTypes, models.CheatSubmitWithoutStartContainer)
+			case "SharedIP":
+				coveredTypes = append(coveredTypes, models.CheatSharedIP)
+			case "SharedSubnet":
+				coveredTypes = append(coveredTypes, models.CheatSharedSubnet)
+			case "SyncedSolves":
+				coveredTypes = append(coveredTypes, models.CheatSyncedSolves)
+			case "SameWrongFlag":
+				coveredTypes = append(coveredTypes, models.CheatSameWrongFlag)
+			case "SharedAccount":
+				coveredTypes = append(coveredTypes, models.CheatSharedAccount)
+			case "SubmitHoneypotFlag":
+				coveredTypes = append(coveredTypes, models.CheatSubmitHoneypotFlag)
+			case "SubmitCanaryFlag":
+				coveredTypes = append(coveredTypes, models.CheatSubmitCanaryFlag)
 			}
 		}
 		baseQuery = baseQuery.Where("cheat_type IN ?", coveredTypes)
 	}
 
+	// 置信度过滤，没有置信度的记录不是关联分析产生的，不受影响
+	if payload.MinConfidence != nil {
+		baseQuery = baseQuery.Where("(extra_data->>'confidence' IS NULL OR (extra_data->>'confidence')::float >= ?)", *payload.MinConfidence)
+	}
+
 	// 时间范围过滤
 	if payload.StartTime != nil && strings.TrimSpace(*payload.StartTime) != "" {
 		if t, err := time.Parse(time.RFC3339, *payload.StartTime); err == nil {
@@ -1901,3 +2241,33 @@ func AdminGetCheats(c *gin.Context) {
 		"total": total,
 	})
 }
+
+// AdminCorrelateCheats 立即对比赛做一次关联分析，不受定时任务分析范围的限制
+func AdminCorrelateCheats(c *gin.Context) {
+	game := c.MustGet("game").(models.Game)
+
+	if game.GameMode == models.GameModeAttackDefense {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"code":    400,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "CorrelationNotSupported"}),
+		})
+		return
+	}
+
+	count, err := anticheat.CorrelateGame(game.GameID)
+	if err != nil {
+		zaphelper.Logger.Error("Failed to correlate cheats", zap.Error(err), zap.Int64("game_id", game.GameID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToCorrelateCheats"}),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"findings": count,
+		},
+	})
+}
@@ -2,6 +2,7 @@ package controllers
 
 import (
 	"a1ctf/src/db/models"
+	scoreengine "a1ctf/src/modules/score_engine"
 	"a1ctf/src/tasks"
 	dbtool "a1ctf/src/utils/db_tool"
 	i18ntool "a1ctf/src/utils/i18n_tool"
@@ -186,6 +187,8 @@ func AdminApproveTeam(c *gin.Context) {
 		return
 	}
 
+	scoreengine.NotifyTeamStatus(team.GameID, team.TeamID)
+
 	// 记录批准队伍成功日志
 	tasks.LogAdminOperation(c, models.ActionApprove, models.ResourceTypeTeam, &team.TeamName, map[string]interface{}{
 		"team_id":    team.TeamID,
@@ -282,6 +285,7 @@ func AdminBanTeam(c *gin.Context) {
 	}
 
 	tasks.NewRecalculateRankForAChallengeTask(payload.GameID, challengeSolvedList)
+	scoreengine.NotifyTeamStatus(team.GameID, team.TeamID)
 
 	// 记录禁赛队伍成功日志
 	tasks.LogAdminOperation(c, models.ActionBan, models.ResourceTypeTeam, &team.TeamName, map[string]interface{}{
@@ -379,6 +383,7 @@ func AdminUnbanTeam(c *gin.Context) {
 	}
 
 	tasks.NewRecalculateRankForAChallengeTask(payload.GameID, challengeSolvedList)
+	scoreengine.NotifyTeamStatus(team.GameID, team.TeamID)
 
 	// 记录解禁队伍成功日志
 	tasks.LogAdminOperation(c, models.ActionUnban, models.ResourceTypeTeam, &team.TeamName, map[string]interface{}{
@@ -468,6 +473,8 @@ func AdminDeleteTeam(c *gin.Context) {
 		return
 	}
 
+	scoreengine.NotifyGame(team.GameID)
+
 	// 记录删除队伍成功日志
 	tasks.LogAdminOperation(c, models.ActionDelete, models.ResourceTypeTeam, &team.TeamName, map[string]interface{}{
 		"team_id":      team.TeamID,
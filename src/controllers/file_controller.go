@@ -14,14 +14,18 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"go.uber.org/zap"
 
 	"a1ctf/src/db/models"
+	anticheat "a1ctf/src/modules/anti_cheat"
+	jwtauth "a1ctf/src/modules/jwt_auth"
 	"a1ctf/src/tasks"
 	dbtool "a1ctf/src/utils/db_tool"
 	i18ntool "a1ctf/src/utils/i18n_tool"
 	imagetool "a1ctf/src/utils/image_tool"
 	"a1ctf/src/utils/ristretto_tool"
 	securitytool "a1ctf/src/utils/security_tool"
+	"a1ctf/src/utils/zaphelper"
 	"a1ctf/src/webmodels"
 )
 
@@ -194,6 +198,15 @@ func DownloadFile(c *gin.Context) {
 		return
 	}
 
+	// 接口是公开的，登录了才能知道是谁下载的附件，给反作弊检查用
+	if claims, err := jwtauth.GetJwtMiddleWare().GetClaimsFromJWT(c); err == nil {
+		if userID, ok := claims["UserID"].(string); ok {
+			if err := anticheat.RecordDownload(fileID.String(), userID, c.ClientIP(), time.Now().UTC()); err != nil {
+				zaphelper.Logger.Error("Failed to record attachment download", zap.Error(err), zap.String("file_id", fileID.String()), zap.String("user_id", userID))
+			}
+		}
+	}
+
 	// 使用 c.DataFromReader 方法，它会正确设置 Content-Length
 	c.DataFromReader(
 		http.StatusOK,
@@ -0,0 +1,299 @@
+package controllers
+
+import (
+	"a1ctf/src/db/models"
+	jwtauth "a1ctf/src/modules/jwt_auth"
+	"a1ctf/src/modules/sso"
+	"a1ctf/src/tasks"
+	i18ntool "a1ctf/src/utils/i18n_tool"
+	"a1ctf/src/utils/zaphelper"
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 绑定身份的结果显示在个人设置的单点登录页面
+const ssoProfilePath = "/profile/sso"
+
+func ssoFrontendURL(path string, query url.Values) string {
+	target := strings.TrimSuffix(viper.GetString("system.baseURL"), "/") + path
+	if len(query) > 0 {
+		target += "?" + query.Encode()
+	}
+	return target
+}
+
+// 前端按错误码显示提示
+func ssoErrorCode(err error) string {
+	switch {
+	case errors.Is(err, sso.ErrInvalidState):
+		return "invalid_state"
+	case errors.Is(err, sso.ErrProvider):
+		return "provider_error"
+	case errors.Is(err, sso.ErrNotLinked):
+		return "not_linked"
+	case errors.Is(err, sso.ErrAlreadyLinked):
+		return "already_linked"
+	case errors.Is(err, sso.ErrProviderLinked):
+		return "provider_linked"
+	}
+	return "server_error"
+}
+
+// SSOLogin 跳转到提供方登录
+func SSOLogin(c *gin.Context) {
+	provider := sso.GetProvider(c.Param("provider"))
+	if provider == nil {
+		c.Redirect(http.StatusFound, ssoFrontendURL("/login", url.Values{"sso_error": {"unknown_provider"}}))
+		return
+	}
+
+	authURL, err := sso.Begin(c, provider, sso.SafeRedirect(c.Query("redirect"), "/"), "")
+	if err != nil {
+		zaphelper.Logger.Error("Failed to start sso login", zap.Error(err), zap.String("provider", provider.ID))
+		c.Redirect(http.StatusFound, ssoFrontendURL("/login", url.Values{"sso_error": {ssoErrorCode(err)}}))
+		return
+	}
+
+	c.Redirect(http.StatusFound, authURL)
+}
+
+// SSOCallback 提供方登录完成后回调，登录、绑定或者注册用户，然后跳转回前端
+func SSOCallback(c *gin.Context) {
+	provider := sso.GetProvider(c.Param("provider"))
+	if provider == nil {
+		c.Redirect(http.StatusFound, ssoFrontendURL("/login", url.Values{"sso_error": {"unknown_provider"}}))
+		return
+	}
+
+	result, err := sso.Callback(c, provider)
+	if err != nil {
+		code := ssoErrorCode(err)
+		details := map[string]interface{}{
+			"provider": provider.ID,
+			"link":     result.Link,
+			"reason":   code,
+		}
+		if result.Identity != nil {
+			details["subject"] = result.Identity.Subject
+			details["sso_username"] = result.Identity.Username
+		}
+		errMsg := err.Error()
+		tasks.LogFromGinContext(c, tasks.LogEntry{
+			Category:     models.LogCategorySecurity,
+			Action:       models.ActionSsoLoginFailed,
+			ResourceType: models.ResourceTypeUser,
+			Details:      details,
+			Status:       models.LogStatusFailed,
+			ErrorMessage: &errMsg,
+		})
+		if code == "server_error" || code == "provider_error" {
+			zaphelper.Logger.Warn("SSO callback failed", zap.Error(err), zap.String("provider", provider.ID))
+		}
+
+		target := "/login"
+		if result.Link {
+			target = ssoProfilePath
+		}
+		c.Redirect(http.StatusFound, ssoFrontendURL(target, url.Values{"sso_error": {code}}))
+		return
+	}
+
+	user := result.User
+	identityDetails := map[string]interface{}{
+		"username":     user.Username,
+		"provider":     provider.ID,
+		"subject":      result.Identity.Subject,
+		"sso_username": result.Identity.Username,
+	}
+
+	if result.Link {
+		tasks.LogFromGinContext(c, tasks.LogEntry{
+			Category:     models.LogCategoryUser,
+			Action:       models.ActionSsoLink,
+			ResourceType: models.ResourceTypeUser,
+			ResourceID:   &user.UserID,
+			UserID:       &user.UserID,
+			Username:     &user.Username,
+			Details:      identityDetails,
+			Status:       models.LogStatusSuccess,
+		})
+
+		c.Redirect(http.StatusFound, ssoFrontendURL(ssoProfilePath, url.Values{"sso_linked": {provider.ID}}))
+		return
+	}
+
+	if result.Registered {
+		tasks.LogFromGinContext(c, tasks.LogEntry{
+			Category:     models.LogCategoryUser,
+			Action:       "REGISTER",
+			ResourceType: models.ResourceTypeUser,
+			ResourceID:   &user.UserID,
+			UserID:       &user.UserID,
+			Username:     &user.Username,
+			Details: map[string]interface{}{
+				"username": user.Username,
+				"email":    user.Email,
+				"provider": provider.ID,
+				"subject":  result.Identity.Subject,
+			},
+			Status: models.LogStatusSuccess,
+		})
+	} else if result.Linked {
+		// 通过邮箱自动绑定
+		tasks.LogFromGinContext(c, tasks.LogEntry{
+			Category:     models.LogCategoryUser,
+			Action:       models.ActionSsoLink,
+			ResourceType: models.ResourceTypeUser,
+			ResourceID:   &user.UserID,
+			UserID:       &user.UserID,
+			Username:     &user.Username,
+			Details:      identityDetails,
+			Status:       models.LogStatusSuccess,
+		})
+	}
+
+	if err := jwtauth.IssueToken(c, &user); err != nil {
+		zaphelper.Logger.Error("Failed to issue token for sso login", zap.Error(err), zap.String("user_id", user.UserID))
+		c.Redirect(http.StatusFound, ssoFrontendURL("/login", url.Values{"sso_error": {"server_error"}}))
+		return
+	}
+
+	tasks.LogFromGinContext(c, tasks.LogEntry{
+		Category:     models.LogCategoryUser,
+		Action:       models.LoginSuccess,
+		ResourceType: models.ResourceTypeUser,
+		UserID:       &user.UserID,
+		Username:     &user.Username,
+		Details: map[string]interface{}{
+			"username":   user.Username,
+			"login_time": user.LastLoginTime,
+			"login_ip":   c.ClientIP(),
+			"provider":   provider.ID,
+		},
+		Status: models.LogStatusSuccess,
+	})
+
+	// 前端在登录页面加载用户资料之后再跳转到 from
+	c.Redirect(http.StatusFound, ssoFrontendURL("/login", url.Values{
+		"sso_login": {provider.ID},
+		"from":      {base64.StdEncoding.EncodeToString([]byte(result.Redirect))},
+	}))
+}
+
+// SSOLink 已登录用户绑定新的身份，返回提供方的登录地址
+func SSOLink(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+
+	provider := sso.GetProvider(c.Param("provider"))
+	if provider == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"code":    404,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SsoProviderNotFound"}),
+		})
+		return
+	}
+
+	authURL, err := sso.Begin(c, provider, ssoProfilePath, user.UserID)
+	if err != nil {
+		zaphelper.Logger.Error("Failed to start sso link", zap.Error(err), zap.String("provider", provider.ID))
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"code":    500,
+			"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SsoProviderUnavailable"}),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"url": authURL,
+		},
+	})
+}
+
+// SSOUnlink 解绑身份，提供方已经从配置里删除时也可以解绑
+func SSOUnlink(c *gin.Context) {
+	user := c.MustGet("user").(models.User)
+	providerID := c.Param("provider")
+
+	removed, err := sso.Unlink(user.UserID, providerID)
+	if err != nil {
+		switch {
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			c.JSON(http.StatusNotFound, gin.H{
+				"code":    404,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SsoIdentityNotLinked"}),
+			})
+		case errors.Is(err, sso.ErrLastLoginMethod):
+			c.JSON(http.StatusBadRequest, gin.H{
+				"code":    400,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SsoCannotUnlinkLastLogin"}),
+			})
+		default:
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"code":    500,
+				"message": i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
+			})
+		}
+		return
+	}
+
+	tasks.LogUserOperation(c, models.ActionSsoUnlink, models.ResourceTypeUser, &user.UserID, map[string]interface{}{
+		"provider":     providerID,
+		"subject":      removed.Subject,
+		"sso_username": removed.Username,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+	})
+}
+
+// ssoProfileIdentities 个人资料里显示的已绑定身份
+func ssoProfileIdentities(user *models.User) []gin.H {
+	identities := make([]gin.H, 0)
+	if user.SsoData == nil {
+		return identities
+	}
+
+	providerIDs := make([]string, 0, len(*user.SsoData))
+	for providerID := range *user.SsoData {
+		providerIDs = append(providerIDs, providerID)
+	}
+	sort.Strings(providerIDs)
+
+	for _, providerID := range providerIDs {
+		identity := (*user.SsoData)[providerID]
+		identities = append(identities, gin.H{
+			"provider":      providerID,
+			"username":      identity.Username,
+			"email":         identity.Email,
+			"linked_at":     identity.LinkedAt,
+			"last_login_at": identity.LastLoginAt,
+		})
+	}
+	return identities
+}
+
+// ssoClientProviders 登录页面显示的单点登录按钮
+func ssoClientProviders() []gin.H {
+	providers := make([]gin.H, 0, len(sso.Providers()))
+	for _, provider := range sso.Providers() {
+		providers = append(providers, gin.H{
+			"id":   provider.ID,
+			"name": provider.Name,
+			"type": provider.Type,
+		})
+	}
+	return providers
+}
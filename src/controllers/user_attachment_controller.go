@@ -0,0 +1,197 @@
+package controllers
+
+import (
+	"a1ctf/src/db/models"
+	"a1ctf/src/tasks"
+	dbtool "a1ctf/src/utils/db_tool"
+	i18ntool "a1ctf/src/utils/i18n_tool"
+	"a1ctf/src/utils/ristretto_tool"
+	"a1ctf/src/utils/zaphelper"
+	"a1ctf/src/webmodels"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hibiken/asynq"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+// UserGenerateAttachment 请求生成队伍的动态附件，第一次请求时创建生成任务，失败后再次请求会重新生成
+func UserGenerateAttachment(c *gin.Context) {
+	game := c.MustGet("game").(models.Game)
+	team := c.MustGet("team").(models.Team)
+	user := c.MustGet("user").(models.User)
+	gameChallenge := c.MustGet("game_challenge").(models.GameChallenge)
+
+	payload := *c.MustGet("payload").(*webmodels.UserGenerateAttachmentPayload)
+
+	var config *models.AttachmentConfig
+	for idx := range gameChallenge.Challenge.Attachments {
+		if gameChallenge.Challenge.Attachments[idx].AttachName == payload.AttachName {
+			config = &gameChallenge.Challenge.Attachments[idx]
+			break
+		}
+	}
+
+	if config == nil {
+		c.JSON(http.StatusNotFound, webmodels.ErrorMessage{
+			Code:    404,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "AttachmentNotFound"}),
+		})
+		return
+	}
+
+	if config.AttachType != models.AttachmentTypeDynamicFile {
+		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
+			Code:    400,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "AttachmentNotDynamic"}),
+		})
+		return
+	}
+
+	// 生成脚本需要队伍的 flag
+	if gameChallenge.Challenge.FlagType == models.FlagTypeDynamic {
+		allFlags, err := ristretto_tool.CachedAllTeamFlags(game.GameID, gameChallenge.ChallengeID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+				Code:    500,
+				Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
+			})
+			return
+		}
+
+		if _, exists := allFlags[team.TeamID]; !exists {
+			flagTemplate := "flag{[uuid]}"
+			if gameChallenge.JudgeConfig != nil && gameChallenge.JudgeConfig.FlagTemplate != nil && *gameChallenge.JudgeConfig.FlagTemplate != "" {
+				flagTemplate = *gameChallenge.JudgeConfig.FlagTemplate
+			}
+			_ = tasks.NewTeamFlagCreateTask(flagTemplate, team.TeamID, game.GameID, gameChallenge.ChallengeID, team.TeamHash, team.TeamName, gameChallenge.Challenge.FlagType)
+		}
+	}
+
+	now := time.Now().UTC()
+
+	// 并发请求只会有一条记录
+	newAttachment := models.TeamAttachment{
+		GameID:      game.GameID,
+		ChallengeID: gameChallenge.ChallengeID,
+		TeamID:      team.TeamID,
+		AttachName:  config.AttachName,
+		Status:      models.TeamAttachmentPending,
+		CreateTime:  now,
+		UpdateTime:  now,
+	}
+	if err := dbtool.DB().Clauses(clause.OnConflict{DoNothing: true}).Create(&newAttachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+			Code:    500,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
+		})
+		return
+	}
+
+	var attachment models.TeamAttachment
+	if err := dbtool.DB().Where("game_id = ? AND challenge_id = ? AND team_id = ? AND attach_name = ?", game.GameID, gameChallenge.ChallengeID, team.TeamID, config.AttachName).First(&attachment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+			Code:    500,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
+		})
+		return
+	}
+
+	if attachment.Status == models.TeamAttachmentFailed {
+		result := dbtool.DB().Model(&models.TeamAttachment{}).
+			Where("attachment_id = ? AND status = ?", attachment.AttachmentID, models.TeamAttachmentFailed).
+			Updates(map[string]interface{}{
+				"status":      models.TeamAttachmentPending,
+				"message":     "",
+				"update_time": now,
+			})
+		if result.Error != nil {
+			c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+				Code:    500,
+				Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
+			})
+			return
+		}
+		if result.RowsAffected > 0 {
+			attachment.Status = models.TeamAttachmentPending
+			attachment.Message = ""
+			attachment.UpdateTime = now
+		}
+	}
+
+	if attachment.Status == models.TeamAttachmentPending {
+		// 同一次生成的任务 ID 相同，重复请求不会重复生成
+		if err := tasks.NewAttachmentGenerateTask(attachment.AttachmentID, user.UserID, attachment.UpdateTime.UnixNano()); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+			zaphelper.Logger.Error("Failed to enqueue attachment generate task", zap.Error(err), zap.Int64("attachment_id", attachment.AttachmentID))
+		}
+	}
+
+	challengeIDStr := strconv.FormatInt(gameChallenge.ChallengeID, 10)
+	tasks.LogUserOperation(c, models.ActionGenerateAttachment, models.ResourceTypeChallenge, &challengeIDStr, map[string]interface{}{
+		"game_id":       game.GameID,
+		"team_id":       team.TeamID,
+		"user_id":       user.UserID,
+		"attach_name":   attachment.AttachName,
+		"attachment_id": attachment.AttachmentID,
+		"status":        attachment.Status,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": webmodels.UserAttachmentConfig{
+			AttachName:     config.AttachName,
+			AttachType:     config.AttachType,
+			AttachHash:     attachment.FileID,
+			GenerateStatus: &attachment.Status,
+		},
+	})
+}
+
+// 给动态附件填上队伍自己的生成状态和文件，返回新的切片，不修改缓存里的附件列表
+func teamAttachments(attachments []webmodels.UserAttachmentConfig, gameID int64, challengeID int64, teamID int64) ([]webmodels.UserAttachmentConfig, error) {
+	hasDynamic := false
+	for _, attachment := range attachments {
+		if attachment.AttachType == models.AttachmentTypeDynamicFile {
+			hasDynamic = true
+			break
+		}
+	}
+	if !hasDynamic {
+		return attachments, nil
+	}
+
+	var generated []models.TeamAttachment
+	if err := dbtool.DB().Where("game_id = ? AND challenge_id = ? AND team_id = ?", gameID, challengeID, teamID).Find(&generated).Error; err != nil {
+		return nil, err
+	}
+
+	generatedMap := make(map[string]*models.TeamAttachment, len(generated))
+	for idx := range generated {
+		generatedMap[generated[idx].AttachName] = &generated[idx]
+	}
+
+	result := make([]webmodels.UserAttachmentConfig, 0, len(attachments))
+	for _, attachment := range attachments {
+		if attachment.AttachType == models.AttachmentTypeDynamicFile {
+			// 不能把其他队伍或者缓存里的文件带出去
+			attachment.AttachURL = nil
+			attachment.AttachHash = nil
+			attachment.DownloadHash = nil
+
+			if item, ok := generatedMap[attachment.AttachName]; ok {
+				attachment.GenerateStatus = &item.Status
+				if item.Status == models.TeamAttachmentReady {
+					attachment.AttachHash = item.FileID
+				}
+			}
+		}
+		result = append(result, attachment)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,188 @@
+package controllers
+
+import (
+	"a1ctf/src/db/models"
+	attackdefense "a1ctf/src/modules/attack_defense"
+	"a1ctf/src/tasks"
+	dbtool "a1ctf/src/utils/db_tool"
+	i18ntool "a1ctf/src/utils/i18n_tool"
+	"a1ctf/src/webmodels"
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/nicksnyder/go-i18n/v2/i18n"
+)
+
+// AttackDefenseGameMiddleware 只允许攻防模式的比赛访问
+func AttackDefenseGameMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		game := c.MustGet("game").(models.Game)
+
+		if game.GameMode != models.GameModeAttackDefense {
+			c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
+				Code:    400,
+				Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "NotAttackDefenseGame"}),
+			})
+			c.Abort()
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// UserADSubmitFlag 提交从其他队伍服务中拿到的 flag
+func UserADSubmitFlag(c *gin.Context) {
+	game := c.MustGet("game").(models.Game)
+	team := c.MustGet("team").(models.Team)
+	user := c.MustGet("user").(models.User)
+
+	payload := *c.MustGet("payload").(*webmodels.UserSubmitFlagPayload)
+
+	attack, err := attackdefense.SubmitFlag(&game, &team, user.UserID, c.ClientIP(), payload.FlagContent)
+	if err != nil {
+		messageID := ""
+		switch {
+		case errors.Is(err, attackdefense.ErrRoundNotRunning):
+			messageID = "AttackDefenseRoundNotRunning"
+		case errors.Is(err, attackdefense.ErrFlagInvalid):
+			messageID = "AttackDefenseFlagInvalid"
+		case errors.Is(err, attackdefense.ErrFlagOwnTeam):
+			messageID = "AttackDefenseFlagOwnTeam"
+		case errors.Is(err, attackdefense.ErrFlagAlreadySubmitted):
+			messageID = "AttackDefenseFlagAlreadySubmitted"
+		}
+
+		if messageID == "" {
+			tasks.LogUserOperationWithError(c, models.ActionSubmitFlag, models.ResourceTypeChallenge, nil, map[string]interface{}{
+				"game_id":      game.GameID,
+				"team_id":      team.TeamID,
+				"user_id":      user.UserID,
+				"flag_content": payload.FlagContent,
+			}, err)
+
+			c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+				Code:    500,
+				Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
+			})
+			return
+		}
+
+		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
+			Code:    400,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: messageID}),
+		})
+		return
+	}
+
+	challengeIDStr := strconv.FormatInt(attack.ChallengeID, 10)
+	tasks.LogUserOperation(c, models.ActionSubmitFlag, models.ResourceTypeChallenge, &challengeIDStr, map[string]interface{}{
+		"game_id":        game.GameID,
+		"team_id":        team.TeamID,
+		"user_id":        user.UserID,
+		"victim_team_id": attack.VictimTeamID,
+		"round":          attack.Round,
+		"flag_round":     attack.FlagRound,
+		"flag_content":   payload.FlagContent,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"round":          attack.Round,
+			"challenge_id":   attack.ChallengeID,
+			"victim_team_id": attack.VictimTeamID,
+		},
+	})
+}
+
+// UserADGetStatus 当前轮次、自己队伍的服务状态和得分
+func UserADGetStatus(c *gin.Context) {
+	game := c.MustGet("game").(models.Game)
+	team := c.MustGet("team").(models.Team)
+
+	roundInfo := gin.H{
+		"round": attackdefense.RoundAt(&game, time.Now().UTC()),
+	}
+
+	var round models.ADRound
+	if err := dbtool.DB().Where("game_id = ?", game.GameID).Order("round DESC").First(&round).Error; err == nil {
+		roundInfo["round"] = round.Round
+		roundInfo["start_time"] = round.StartTime
+		roundInfo["end_time"] = round.EndTime
+	}
+
+	var services []models.ADService
+	if err := dbtool.DB().Where("game_id = ? AND team_id = ?", game.GameID, team.TeamID).Find(&services).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+			Code:    500,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
+		})
+		return
+	}
+
+	var containers []models.Container
+	if err := dbtool.DB().Where("game_id = ? AND team_id = ? AND container_status IN ?", game.GameID, team.TeamID, []models.ContainerStatus{models.ContainerQueueing, models.ContainerStarting, models.ContainerRunning}).Find(&containers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+			Code:    500,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToLoadContainers"}),
+		})
+		return
+	}
+
+	containerMap := make(map[int64]models.Container, len(containers))
+	for _, container := range containers {
+		containerMap[container.ChallengeID] = container
+	}
+
+	serviceList := make([]gin.H, 0, len(services))
+	for _, service := range services {
+		item := gin.H{
+			"challenge_id":     service.ChallengeID,
+			"status":           service.Status,
+			"message":          service.Message,
+			"check_time":       service.CheckTime,
+			"flag_round":       service.FlagRound,
+			"container_status": models.NoContainer,
+			"expose_ports":     make(models.ContainerExposeInfos, 0),
+		}
+		if container, ok := containerMap[service.ChallengeID]; ok {
+			item["challenge_name"] = container.ChallengeName
+			item["container_status"] = container.ContainerStatus
+			item["expose_ports"] = container.ContainerExposeInfos
+		}
+		serviceList = append(serviceList, item)
+	}
+
+	var score struct {
+		AttackScore  float64
+		DefenseScore float64
+		SLAScore     float64 `gorm:"column:sla_score"`
+	}
+	if err := dbtool.DB().Model(&models.ADRoundScore{}).
+		Select("COALESCE(SUM(attack_score), 0) AS attack_score, COALESCE(SUM(defense_score), 0) AS defense_score, COALESCE(SUM(sla_score), 0) AS sla_score").
+		Where("game_id = ? AND team_id = ?", game.GameID, team.TeamID).
+		Scan(&score).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+			Code:    500,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"code": 200,
+		"data": gin.H{
+			"round":    roundInfo,
+			"services": serviceList,
+			"score": webmodels.TeamADScoreItem{
+				AttackScore:  score.AttackScore,
+				DefenseScore: score.DefenseScore,
+				SLAScore:     score.SLAScore,
+			},
+		},
+	})
+}
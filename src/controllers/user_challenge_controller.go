@@ -2,10 +2,14 @@ package controllers
 
 import (
 	"a1ctf/src/db/models"
+	containerlifetime "a1ctf/src/modules/container_lifetime"
+	"a1ctf/src/modules/koth"
+	sharedcontainer "a1ctf/src/modules/shared_container"
 	"a1ctf/src/tasks"
 	dbtool "a1ctf/src/utils/db_tool"
 	i18ntool "a1ctf/src/utils/i18n_tool"
 	"a1ctf/src/utils/ristretto_tool"
+	"a1ctf/src/utils/zaphelper"
 	"a1ctf/src/webmodels"
 	"net/http"
 	"strconv"
@@ -14,6 +18,7 @@ import (
 	"github.com/gin-gonic/gin"
 	"github.com/google/uuid"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
+	"go.uber.org/zap"
 )
 
 func UserGetGameChallenges(c *gin.Context) {
@@ -79,7 +84,8 @@ func UserGetGameChallenge(c *gin.Context) {
 	gameChallenge := c.MustGet("game_challenge").(models.GameChallenge)
 
 	// 5. Flag 处理，对于没有 flag 的队伍，需要添加到 flag 创建队列里，先判断是否是动态 Flag
-	if gameChallenge.Challenge.FlagType == models.FlagTypeDynamic {
+	// 攻防模式的 flag 由轮次调度生成，KOTH 题目不需要 flag
+	if gameChallenge.Challenge.FlagType == models.FlagTypeDynamic && game.GameMode != models.GameModeAttackDefense && gameChallenge.ChallengeKind != models.GameChallengeKOTH {
 		allFlags, err := ristretto_tool.CachedAllTeamFlags(game.GameID, gameChallenge.ChallengeID)
 		if err != nil {
 			c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
@@ -105,6 +111,16 @@ func UserGetGameChallenge(c *gin.Context) {
 		return
 	}
 
+	// 动态附件每个队伍不同
+	userAttachments, err = teamAttachments(userAttachments, game.GameID, gameChallenge.ChallengeID, team.TeamID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+			Code:    500,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToLoadChallengeAttachments"}),
+		})
+		return
+	}
+
 	// 4. 使用缓存获取可见提示
 	visibleHints, err := ristretto_tool.CachedChallengeVisibleHints(game.GameID, gameChallenge.ChallengeID)
 	if err != nil {
@@ -130,10 +146,37 @@ func UserGetGameChallenge(c *gin.Context) {
 		ContainerStatus:     models.NoContainer,
 		ContainerExpireTime: nil,
 		Visible:             gameChallenge.Visible,
+		ChallengeKind:       gameChallenge.ChallengeKind,
+	}
+
+	// 静态容器挂在管理员队伍下，所有队伍看到的是同一个
+	containerTeamID := team.TeamID
+	if game.GameMode != models.GameModeAttackDefense && sharedcontainer.IsShared(&gameChallenge) {
+		host, err := sharedcontainer.HostTeam(game.GameID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+				Code:    500,
+				Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
+			})
+			return
+		}
+		containerTeamID = host.TeamID
+	}
+
+	if gameChallenge.ChallengeKind == models.GameChallengeKOTH {
+		kothStatus, err := koth.Status(&gameChallenge, team.TeamID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+				Code:    500,
+				Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
+			})
+			return
+		}
+		result.Koth = kothStatus
 	}
 
 	// 6. 容器状态处理 - 使用短时缓存（200ms）平衡性能和实时性
-	containers, err := ristretto_tool.CachedContainerStatus(game.GameID, *gameChallenge.Challenge.ChallengeID, team.TeamID)
+	containers, err := ristretto_tool.CachedContainerStatus(game.GameID, *gameChallenge.Challenge.ChallengeID, containerTeamID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
 			Code:    500,
@@ -169,9 +212,15 @@ func UserGetGameChallenge(c *gin.Context) {
 		result.Containers = append(result.Containers, tempConfig)
 	}
 
+	// 静态容器由调度维护，选手看不到有效期策略
+	if game.GameMode != models.GameModeAttackDefense && gameChallenge.Challenge.ContainerType == models.DYNAMIC_CONTAINER {
+		result.ContainerLifetime = containerlifetime.Resolve(&game, &gameChallenge).Info()
+	}
+
 	if len(containers) > 0 {
 		result.ContainerStatus = containers[0].ContainerStatus
 		result.ContainerExpireTime = &containers[0].ExpireTime
+		result.ContainerExtendCount = containers[0].ExtendCount
 	} else {
 		result.ContainerStatus = models.NoContainer
 		result.ContainerExpireTime = nil
@@ -191,6 +240,23 @@ func UserGameChallengeSubmitFlag(c *gin.Context) {
 
 	payload := *c.MustGet("payload").(*webmodels.UserSubmitFlagPayload)
 
+	if game.GameMode == models.GameModeAttackDefense {
+		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
+			Code:    400,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "UseAttackDefenseSubmit"}),
+		})
+		return
+	}
+
+	// KOTH 题目按占领靶机的时长计分，没有 flag
+	if gameChallenge.ChallengeKind == models.GameChallengeKOTH {
+		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
+			Code:    400,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "KothChallengeNoFlag"}),
+		})
+		return
+	}
+
 	// 2. 使用缓存检查是否已解决
 	hasSolved, err := ristretto_tool.CachedTeamSolveStatus(game.GameID, team.TeamID, gameChallenge.ChallengeID)
 	if err != nil {
@@ -279,16 +345,10 @@ func UserGameChallengeSubmitFlag(c *gin.Context) {
 		"flag_content":   payload.FlagContent, // 只记录前50个字符
 	})
 
-	// dbtool.DB().Model(&models.Judge{}).Preload("TeamFlag").Where("judge_id = ?", newJudge.JudgeID).First(&newJudge)
-
-	// err = tasks.NewJudgeFlagTask(newJudge)
-	// if err != nil {
-	// 	c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
-	// 		Code:    400,
-	// 		Message: "You have a judge in queue, please wait for a moment",
-	// 	})
-	// 	return
-	// }
+	// 入队失败也没关系，兜底扫描会重新入队
+	if err := tasks.NewJudgeFlagTask(newJudge.JudgeID); err != nil {
+		zaphelper.Logger.Error("Failed to enqueue judge task", zap.Error(err), zap.String("judge_id", newJudge.JudgeID))
+	}
 
 	c.JSON(http.StatusOK, gin.H{
 		"code": 200,
@@ -335,6 +395,7 @@ func UserGameGetJudgeResult(c *gin.Context) {
 		"data": gin.H{
 			"judge_id":     judge.JudgeID,
 			"judge_status": judge.JudgeStatus,
+			"judge_result": judge.JudgeResult,
 		},
 	})
 }
@@ -2,10 +2,15 @@ package controllers
 
 import (
 	"a1ctf/src/db/models"
+	containerlifetime "a1ctf/src/modules/container_lifetime"
+	containerquota "a1ctf/src/modules/container_quota"
+	sharedcontainer "a1ctf/src/modules/shared_container"
+	warmpool "a1ctf/src/modules/warm_pool"
 	"a1ctf/src/tasks"
 	dbtool "a1ctf/src/utils/db_tool"
 	i18ntool "a1ctf/src/utils/i18n_tool"
 	redistool "a1ctf/src/utils/redis_tool"
+	"a1ctf/src/utils/zaphelper"
 	"a1ctf/src/webmodels"
 	"errors"
 	"fmt"
@@ -17,6 +22,7 @@ import (
 	"github.com/google/uuid"
 	"github.com/nicksnyder/go-i18n/v2/i18n"
 	"github.com/spf13/viper"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
@@ -38,6 +44,24 @@ func UserCreateGameContainer(c *gin.Context) {
 	user := c.MustGet("user").(models.User)
 	gameChallenge := c.MustGet("game_challenge").(models.GameChallenge)
 
+	// 攻防模式的服务容器由轮次调度维护
+	if game.GameMode == models.GameModeAttackDefense {
+		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
+			Code:    400,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "ContainerManagedByAttackDefense"}),
+		})
+		return
+	}
+
+	// 静态容器所有队伍共用，由调度维护
+	if sharedcontainer.IsShared(&gameChallenge) {
+		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
+			Code:    400,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "ContainerSharedByAllTeams"}),
+		})
+		return
+	}
+
 	var containers []models.Container
 	if err := dbtool.DB().Where("game_id = ? AND team_id = ? AND (container_status = ? or container_status = ? or container_status = ?)", game.GameID, team.TeamID, models.ContainerRunning, models.ContainerQueueing, models.ContainerStarting).Find(&containers).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
@@ -73,8 +97,26 @@ func UserCreateGameContainer(c *gin.Context) {
 		return
 	}
 
+	// 队伍的资源配额包括排队中的容器
+	request := containerquota.RequestOf(*gameChallenge.Challenge.ContainerConfig)
+	if !containerquota.FitsTeamQuota(&game, containers, request) {
+		c.JSON(http.StatusConflict, webmodels.ErrorMessage{
+			Code:    409,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "TeamContainerQuotaExceeded"}),
+		})
+		return
+	}
+
+	if !containerquota.FitsGameQuota(&game, request) {
+		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
+			Code:    400,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "ContainerExceedsGameQuota"}),
+		})
+		return
+	}
+
 	var flag models.TeamFlag
-	if err := dbtool.DB().Where("game_id = ? AND team_id = ? AND challenge_id = ?", game.GameID, team.TeamID, gameChallenge.Challenge.ChallengeID).First(&flag).Error; err != nil {
+	if err := dbtool.DB().Where("game_id = ? AND team_id = ? AND challenge_id = ? AND round = 0", game.GameID, team.TeamID, gameChallenge.Challenge.ChallengeID).First(&flag).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
 			c.JSON(http.StatusForbidden, webmodels.ErrorMessage{
 				Code:    403,
@@ -91,7 +133,8 @@ func UserCreateGameContainer(c *gin.Context) {
 	}
 
 	clientIP := c.ClientIP()
-	now := time.Now()
+	now := time.Now().UTC()
+	lifetime := containerlifetime.Resolve(&game, &gameChallenge)
 
 	// 加入数据库
 	newContainer := models.Container{
@@ -101,8 +144,8 @@ func UserCreateGameContainer(c *gin.Context) {
 		TeamID:               team.TeamID,
 		ChallengeID:          *gameChallenge.Challenge.ChallengeID,
 		InGameID:             gameChallenge.IngameID,
-		StartTime:            now.UTC(),
-		ExpireTime:           now.Add(time.Duration(2) * time.Hour).UTC(),
+		StartTime:            now,
+		ExpireTime:           lifetime.ExpireTime(now),
 		ContainerExposeInfos: make(models.ContainerExposeInfos, 0),
 		ContainerStatus:      models.ContainerQueueing,
 		ContainerConfig:      *gameChallenge.Challenge.ContainerConfig,
@@ -111,6 +154,11 @@ func UserCreateGameContainer(c *gin.Context) {
 		SubmiterIP:           &clientIP,
 	}
 
+	// 没有比赛配额时直接启动，不进入队列，避免和容器任务里的配额调度重复启动
+	if !containerquota.HasGameQuota(&game) {
+		newContainer.ContainerStatus = models.ContainerStarting
+	}
+
 	// 用户操作靶机的 60 秒 CD
 	operationName := fmt.Sprintf("%s:containerOperation", user.UserID)
 	timeLimit = getTimeLimitConfig()
@@ -153,9 +201,20 @@ func UserCreateGameContainer(c *gin.Context) {
 	// k8s 开启 pod 需要 Challenge的AllowWAN/AllowDNS 和 TeamFlag的FlagContent 的信息
 	newContainer.Challenge = gameChallenge.Challenge
 	newContainer.TeamFlag = flag
+	// 预热池的配置
+	newContainer.GameChallenge = gameChallenge
 
-	// 异步开启k8s的pod任务
-	tasks.NewContainerStartTask(newContainer)
+	if containerquota.HasGameQuota(&game) {
+		// 比赛配额用完时在队列里等待，由容器任务按顺序启动
+		if err := containerquota.Admit(game.GameID); err != nil {
+			zaphelper.Logger.Error("Failed to admit queued containers", zap.Error(err), zap.Int64("game_id", game.GameID))
+		}
+	} else {
+		// 有预热的 Pod 时直接认领，否则异步开启k8s的pod任务
+		if err := warmpool.Start(newContainer); err != nil {
+			zaphelper.Logger.Error("Failed to start container", zap.Error(err), zap.String("container_id", newContainer.ContainerID))
+		}
+	}
 
 	// 记录创建容器请求
 	tasks.LogUserOperation(c, models.ActionStartContainer, models.ResourceTypeContainer, &newContainer.ContainerID, map[string]interface{}{
@@ -179,6 +238,15 @@ func UserCloseGameContainer(c *gin.Context) {
 	team := c.MustGet("team").(models.Team)
 	user := c.MustGet("user").(models.User)
 
+	// 攻防模式的服务容器由轮次调度维护
+	if game.GameMode == models.GameModeAttackDefense {
+		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
+			Code:    400,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "ContainerManagedByAttackDefense"}),
+		})
+		return
+	}
+
 	challengeIDStr := c.Param("challenge_id")
 	challengeID, err := strconv.ParseInt(challengeIDStr, 10, 64)
 	if err != nil {
@@ -287,6 +355,15 @@ func UserExtendGameContainer(c *gin.Context) {
 	user := c.MustGet("user").(models.User)
 	challengeID := c.MustGet("challenge_id").(int64)
 
+	// 攻防模式的服务容器由轮次调度维护
+	if game.GameMode == models.GameModeAttackDefense {
+		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
+			Code:    400,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "ContainerManagedByAttackDefense"}),
+		})
+		return
+	}
+
 	operationName := fmt.Sprintf("%s:containerOperation", user.UserID)
 	timeLimit = getTimeLimitConfig()
 	locked := redistool.LockForATime(operationName, timeLimit)
@@ -338,22 +415,46 @@ func UserExtendGameContainer(c *gin.Context) {
 		return
 	}
 
-	// 到期时间大于半小时的不可延长
-	if curContainer.ExpireTime.Sub(time.Now().UTC()) > time.Minute*30 {
+	var gameChallenge models.GameChallenge
+	if err := dbtool.DB().Where("game_id = ? AND challenge_id = ?", game.GameID, challengeID).First(&gameChallenge).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+			Code:    500,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToLoadGameChallenges"}),
+		})
+		return
+	}
+
+	lifetime := containerlifetime.Resolve(&game, &gameChallenge)
+
+	// 剩余时间在可延长的窗口内才能延长，延长次数和最长存活时间由题目设置
+	newExpireTime, err := lifetime.Extend(&curContainer, time.Now().UTC())
+	if err != nil {
+		var message string
+		switch {
+		case errors.Is(err, containerlifetime.ErrExtendLimitReached):
+			message = i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "ContainerExtendLimitReached", TemplateData: map[string]interface{}{"Count": lifetime.MaxExtensions}})
+		case errors.Is(err, containerlifetime.ErrMaxLifetimeReached):
+			message = i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "ContainerMaxLifetimeReached"})
+		default:
+			message = i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "ContainerExpireTimeTooShort", TemplateData: map[string]interface{}{"Minutes": int64(lifetime.ExtendWindow.Minutes())}})
+		}
 		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
 			Code:    400,
-			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "ContainerExpireTimeTooShort"}),
+			Message: message,
 		})
 		return
 	}
 
 	// 记录旧的容器到期时间，Updates更新后会改变该值
 	oldExpireTime := curContainer.ExpireTime
-	// 延长时间为当前时间的2小时之后
-	newExpireTime := time.Now().Add(time.Duration(2) * time.Hour).UTC()
+	// 对容器的操作已经加锁，直接写入新的次数
+	extendCount := curContainer.ExtendCount + 1
 
 	if err := dbtool.DB().Model(&curContainer).Updates(map[string]interface{}{
-		"expire_time": newExpireTime,
+		"expire_time":  newExpireTime,
+		"extend_count": extendCount,
+		// 选手主动延长也算作有活动
+		"last_active_time": time.Now().UTC(),
 	}).Error; err != nil {
 		tasks.LogUserOperationWithError(c, models.ActionExtendContainer, models.ResourceTypeContainer, &curContainer.ContainerID, map[string]interface{}{
 			"game_id":         game.GameID,
@@ -388,6 +489,7 @@ func UserExtendGameContainer(c *gin.Context) {
 		"code": 200,
 		"data": gin.H{
 			"new_expire_time": newExpireTime,
+			"extend_count":    extendCount,
 		},
 	})
 }
@@ -407,31 +509,6 @@ func UserGetGameChallengeContainerInfo(c *gin.Context) {
 		return
 	}
 
-	var containers []models.Container
-	if err := dbtool.DB().Where("challenge_id = ? AND team_id = ? AND (container_status = ? OR container_status = ? OR container_status = ?)", challengeID, team.TeamID, models.ContainerRunning, models.ContainerQueueing, models.ContainerStarting).Find(&containers).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
-			Code:    500,
-			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToLoadContainers"}),
-		})
-		return
-	}
-
-	if len(containers) == 0 {
-		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
-			Code:    400,
-			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "LaunchContainerFirst"}),
-		})
-		return
-	}
-
-	if len(containers) != 1 {
-		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
-			Code:    400,
-			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
-		})
-		return
-	}
-
 	var gameChallenges []models.GameChallenge
 
 	// 使用 Preload 进行关联查询
@@ -456,12 +533,76 @@ func UserGetGameChallengeContainerInfo(c *gin.Context) {
 
 	gameChallenge := gameChallenges[0]
 
+	// 静态容器所有队伍拿到同一个容器的连接信息
+	containerTeamID := team.TeamID
+	if game.GameMode != models.GameModeAttackDefense && sharedcontainer.IsShared(&gameChallenge) {
+		host, err := sharedcontainer.HostTeam(game.GameID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+				Code:    500,
+				Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
+			})
+			return
+		}
+		containerTeamID = host.TeamID
+	}
+
+	var containers []models.Container
+	if err := dbtool.DB().Where("game_id = ? AND challenge_id = ? AND team_id = ? AND (container_status = ? OR container_status = ? OR container_status = ?)", game.GameID, challengeID, containerTeamID, models.ContainerRunning, models.ContainerQueueing, models.ContainerStarting).Find(&containers).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+			Code:    500,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToLoadContainers"}),
+		})
+		return
+	}
+
+	if len(containers) == 0 {
+		messageID := "LaunchContainerFirst"
+		if containerTeamID != team.TeamID {
+			messageID = "SharedContainerNotReady"
+		}
+		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
+			Code:    400,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: messageID}),
+		})
+		return
+	}
+
+	if len(containers) != 1 {
+		c.JSON(http.StatusBadRequest, webmodels.ErrorMessage{
+			Code:    400,
+			Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "SystemError"}),
+		})
+		return
+	}
+
 	result := gin.H{
 		"container_status":     containers[0].ContainerStatus,
 		"containers":           make([]gin.H, 0, len(*gameChallenge.Challenge.ContainerConfig)),
 		"container_expiretime": containers[0].ExpireTime,
 	}
 
+	// 选手自己的容器返回有效期策略和还能延长到的最晚时间
+	if containerTeamID == team.TeamID && game.GameMode != models.GameModeAttackDefense {
+		lifetime := containerlifetime.Resolve(&game, &gameChallenge)
+		result["lifetime"] = lifetime.Info()
+		result["extend_count"] = containers[0].ExtendCount
+		result["max_expire_time"] = lifetime.MaxExpireTime(containers[0].StartTime)
+	}
+
+	// 排队中的容器返回前面还有多少个容器
+	if containers[0].ContainerStatus == models.ContainerQueueing {
+		position, err := containerquota.QueuePosition(&containers[0])
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, webmodels.ErrorMessage{
+				Code:    500,
+				Message: i18ntool.Translate(c, &i18n.LocalizeConfig{MessageID: "FailedToLoadContainers"}),
+			})
+			return
+		}
+		result["queue_position"] = position
+	}
+
 	for _, container := range *gameChallenge.Challenge.ContainerConfig {
 		tempConfig := gin.H{
 			"container_name":  container.Name,
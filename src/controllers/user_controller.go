@@ -61,6 +61,7 @@ func GetProfile(c *gin.Context) {
 			"register_time":         user.RegisterTime,
 			"last_login_time":       user.LastLoginTime,
 			"last_login_ip":         user.LastLoginIP,
+			"sso_identities":        ssoProfileIdentities(&user),
 			"client_config_version": clientconfig.ClientConfig.UpdatedTime,
 		},
 	})
@@ -252,6 +253,9 @@ func GetClientConfig(c *gin.Context) {
 		"fancyBackGroundIconWidth":  ClientConfig.FancyBackGroundIconWidth,
 		"fancyBackGroundIconHeight": ClientConfig.FancyBackGroundIconHeight,
 
+		// 单点登录
+		"ssoProviders": ssoClientProviders(),
+
 		"updateVersion": ClientConfig.UpdatedTime,
 	}
 
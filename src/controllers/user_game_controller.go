@@ -109,6 +109,7 @@ func UserGetGameDetailWithTeamInfo(c *gin.Context) {
 		"visible":                   game.Visible,
 		"team_status":               team_status,
 		"group_invite_code_enabled": game.GroupInviteCodeEnabled,
+		"game_mode":                 game.GameMode,
 		"team_info":                 nil,
 	}
 
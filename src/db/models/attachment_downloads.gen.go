@@ -0,0 +1,25 @@
+package models
+
+import "time"
+
+const TableNameAttachmentDownload = "attachment_downloads"
+
+// AttachmentDownload mapped from table <attachment_downloads>
+// 每个用户下载一个题目附件的记录，重复下载只更新次数和时间
+type AttachmentDownload struct {
+	DownloadID        int64     `gorm:"column:download_id;primaryKey;autoIncrement" json:"download_id"`
+	GameID            int64     `gorm:"column:game_id;not null" json:"game_id"`
+	ChallengeID       int64     `gorm:"column:challenge_id;not null" json:"challenge_id"`
+	TeamID            int64     `gorm:"column:team_id;not null" json:"team_id"`
+	UserID            string    `gorm:"column:user_id;not null" json:"user_id"`
+	FileID            string    `gorm:"column:file_id;not null" json:"file_id"`
+	DownloadCount     int64     `gorm:"column:download_count;not null;default:1" json:"download_count"`
+	FirstDownloadTime time.Time `gorm:"column:first_download_time;not null" json:"first_download_time"`
+	LastDownloadTime  time.Time `gorm:"column:last_download_time;not null" json:"last_download_time"`
+	LastDownloadIP    *string   `gorm:"column:last_download_ip" json:"last_download_ip"`
+}
+
+// TableName AttachmentDownload's table name
+func (*AttachmentDownload) TableName() string {
+	return TableNameAttachmentDownload
+}
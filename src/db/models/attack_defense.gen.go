@@ -0,0 +1,131 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+type ADServiceStatus string
+
+const (
+	ADServiceUp   ADServiceStatus = "UP"
+	ADServiceDown ADServiceStatus = "DOWN"
+	// 还没有检查过
+	ADServicePending ADServiceStatus = "PENDING"
+)
+
+func (e ADServiceStatus) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *ADServiceStatus) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+const TableNameADRound = "ad_rounds"
+
+// ADRound mapped from table <ad_rounds>
+type ADRound struct {
+	GameID    int64     `gorm:"column:game_id;primaryKey" json:"game_id"`
+	Round     int32     `gorm:"column:round;primaryKey" json:"round"`
+	StartTime time.Time `gorm:"column:start_time;not null" json:"start_time"`
+	EndTime   time.Time `gorm:"column:end_time;not null" json:"end_time"`
+	// checker 已经运行
+	Checked bool `gorm:"column:checked;not null;default:false" json:"checked"`
+	// 本轮分数已经结算
+	Scored bool `gorm:"column:scored;not null;default:false" json:"scored"`
+}
+
+// TableName ADRound's table name
+func (*ADRound) TableName() string {
+	return TableNameADRound
+}
+
+const TableNameADService = "ad_services"
+
+// ADService mapped from table <ad_services>
+// 每个队伍每道题一个，记录当前的容器、已经写入的 flag 轮次和最近一次检查结果
+type ADService struct {
+	ServiceID   int64           `gorm:"column:service_id;primaryKey;autoIncrement" json:"service_id"`
+	GameID      int64           `gorm:"column:game_id;not null" json:"game_id"`
+	TeamID      int64           `gorm:"column:team_id;not null" json:"team_id"`
+	Team        Team            `gorm:"foreignKey:TeamID;references:team_id" json:"-"`
+	ChallengeID int64           `gorm:"column:challenge_id;not null" json:"challenge_id"`
+	InGameID    int64           `gorm:"column:ingame_id;not null" json:"ingame_id"`
+	ContainerID *string         `gorm:"column:container_id" json:"container_id"`
+	FlagRound   int32           `gorm:"column:flag_round;not null;default:0" json:"flag_round"`
+	Status      ADServiceStatus `gorm:"column:status;not null" json:"status"`
+	Message     string          `gorm:"column:message;not null;default:''" json:"message"`
+	CheckTime   *time.Time      `gorm:"column:check_time" json:"check_time"`
+}
+
+// TableName ADService's table name
+func (*ADService) TableName() string {
+	return TableNameADService
+}
+
+const TableNameADServiceCheck = "ad_service_checks"
+
+// ADServiceCheck mapped from table <ad_service_checks>
+type ADServiceCheck struct {
+	GameID      int64           `gorm:"column:game_id;primaryKey" json:"game_id"`
+	Round       int32           `gorm:"column:round;primaryKey" json:"round"`
+	TeamID      int64           `gorm:"column:team_id;primaryKey" json:"team_id"`
+	ChallengeID int64           `gorm:"column:challenge_id;primaryKey" json:"challenge_id"`
+	Status      ADServiceStatus `gorm:"column:status;not null" json:"status"`
+	Message     string          `gorm:"column:message;not null;default:''" json:"message"`
+	CheckTime   time.Time       `gorm:"column:check_time;not null" json:"check_time"`
+}
+
+// TableName ADServiceCheck's table name
+func (*ADServiceCheck) TableName() string {
+	return TableNameADServiceCheck
+}
+
+const TableNameADAttack = "ad_attacks"
+
+// ADAttack mapped from table <ad_attacks>
+// 同一个队伍对同一个 flag 只记录一次
+type ADAttack struct {
+	AttackID       int64     `gorm:"column:attack_id;primaryKey;autoIncrement" json:"attack_id"`
+	GameID         int64     `gorm:"column:game_id;not null" json:"game_id"`
+	Round          int32     `gorm:"column:round;not null" json:"round"`
+	AttackerTeamID int64     `gorm:"column:attacker_team_id;not null" json:"attacker_team_id"`
+	VictimTeamID   int64     `gorm:"column:victim_team_id;not null" json:"victim_team_id"`
+	ChallengeID    int64     `gorm:"column:challenge_id;not null" json:"challenge_id"`
+	FlagID         int64     `gorm:"column:flag_id;not null" json:"flag_id"`
+	FlagRound      int32     `gorm:"column:flag_round;not null" json:"flag_round"`
+	SubmiterID     string    `gorm:"column:submiter_id;not null" json:"submiter_id"`
+	SubmiterIP     *string   `gorm:"column:submiter_ip" json:"submiter_ip"`
+	SubmitTime     time.Time `gorm:"column:submit_time;not null" json:"submit_time"`
+}
+
+// TableName ADAttack's table name
+func (*ADAttack) TableName() string {
+	return TableNameADAttack
+}
+
+const TableNameADRoundScore = "ad_round_scores"
+
+// ADRoundScore mapped from table <ad_round_scores>
+type ADRoundScore struct {
+	GameID       int64   `gorm:"column:game_id;primaryKey" json:"game_id"`
+	Round        int32   `gorm:"column:round;primaryKey" json:"round"`
+	TeamID       int64   `gorm:"column:team_id;primaryKey" json:"team_id"`
+	ChallengeID  int64   `gorm:"column:challenge_id;primaryKey" json:"challenge_id"`
+	AttackScore  float64 `gorm:"column:attack_score;not null" json:"attack_score"`
+	DefenseScore float64 `gorm:"column:defense_score;not null" json:"defense_score"`
+	SLAScore     float64 `gorm:"column:sla_score;not null" json:"sla_score"`
+}
+
+// TableName ADRoundScore's table name
+func (*ADRoundScore) TableName() string {
+	return TableNameADRoundScore
+}
@@ -26,6 +26,8 @@ type AttachmentConfig struct {
 	AttachHash     *string        `json:"attach_hash,omitempty"`
 	DownloadHash   *string        `json:"download_hash,omitempty"`
 	GenerateScript *string        `json:"generate_script,omitempty"`
+	// 运行生成脚本的镜像，为空时使用 attachment-generator.image
+	GenerateImage *string `json:"generate_image,omitempty"`
 }
 
 type AttachmentConfigs []AttachmentConfig
@@ -61,10 +63,36 @@ func (e *JudgeType) Scan(value interface{}) error {
 	return sonic.Unmarshal(b, e)
 }
 
+type FlagMatchMode string
+
+const (
+	FlagMatchExact           FlagMatchMode = "EXACT"
+	FlagMatchCaseInsensitive FlagMatchMode = "CASE_INSENSITIVE"
+	FlagMatchRegex           FlagMatchMode = "REGEX"
+)
+
+func (e FlagMatchMode) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *FlagMatchMode) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
 type JudgeConfig struct {
 	JudgeType    JudgeType `json:"judge_type"`
 	JudgeScript  *string   `json:"judge_script,omitempty"`
 	FlagTemplate *string   `json:"flag_template,omitempty"`
+	// 静态 flag 的匹配方式，为空时等同于 EXACT
+	FlagMatchMode FlagMatchMode `json:"flag_match_mode,omitempty"`
+	// 比较前去掉首尾空白
+	TrimSpace bool `json:"trim_space,omitempty"`
+	// 除了 FlagTemplate 以外同样判为正确的 flag，REGEX 模式下为正则
+	AcceptedFlags []string `json:"accepted_flags,omitempty"`
 }
 
 func (e JudgeConfig) Value() (driver.Value, error) {
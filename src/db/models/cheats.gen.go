@@ -14,11 +14,31 @@ const (
 	CheatSubmitSomeonesFlag               = "SubmitSomeonesFlag"
 	CheatSubmitWithoutDownloadAttachments = "SubmitWithoutDownloadAttachments"
 	CheatSubmitWithoutStartContainer      = "SubmitWithoutStartContainer"
+	// 提交了诱饵 flag，证据里记录诱饵的来源
+	CheatSubmitHoneypotFlag = "SubmitHoneypotFlag"
+	// 提交了别的队伍附件里的 canary
+	CheatSubmitCanaryFlag = "SubmitCanaryFlag"
+
+	// 下面的类型由关联分析产生，只是可疑，需要管理员确认
+	// 不同队伍从同一个 IP 提交或登录
+	CheatSharedIP = "SharedIP"
+	// 不同队伍从同一个 /24 网段提交
+	CheatSharedSubnet = "SharedSubnet"
+	// 不同队伍按相同的顺序在很短的时间内解出相同的题目
+	CheatSyncedSolves = "SyncedSolves"
+	// 不同队伍提交了相同的错误 flag
+	CheatSameWrongFlag = "SameWrongFlag"
+	// 同一个账号给不同的队伍提交
+	CheatSharedAccount = "SharedAccount"
 )
 
 type CheatExtraData struct {
 	RelevantTeam     int64  `json:"relevant_team"`
 	RelevantTeamName string `json:"relevant_teamname"`
+	// 关联分析的置信度，0 到 1
+	Confidence float64 `json:"confidence,omitempty"`
+	// 关联分析的证据，比如共同的 IP、题目和时间差、错误的 flag
+	Evidence map[string]interface{} `json:"evidence,omitempty"`
 }
 
 func (e CheatExtraData) Value() (driver.Value, error) {
@@ -53,21 +73,24 @@ type Cheat struct {
 	CheatType     CheatType      `gorm:"column:cheat_type;not null" json:"cheat_type"`
 	GameID        int64          `gorm:"column:game_id;not null" json:"game_id"`
 	Game          Game           `gorm:"foreignKey:GameID;references:game_id" json:"-"`
-	IngameID      int64          `gorm:"column:ingame_id;not null" json:"ingame_id"`
+	IngameID      *int64         `gorm:"column:ingame_id" json:"ingame_id"`
 	GameChallenge GameChallenge  `gorm:"foreignKey:IngameID;references:ingame_id" json:"-"`
-	ChallengeID   int64          `gorm:"column:challenge_id;not null" json:"challenge_id"`
+	ChallengeID   *int64         `gorm:"column:challenge_id" json:"challenge_id"`
 	Challenge     Challenge      `gorm:"foreignKey:ChallengeID;references:challenge_id" json:"-"`
 	TeamID        int64          `gorm:"column:team_id;not null" json:"team_id"`
 	Team          Team           `gorm:"foreignKey:TeamID;references:team_id" json:"-"`
 	FlagID        *int64         `gorm:"column:flag_id" json:"flag_id"`
 	TeamFlag      *TeamFlag      `gorm:"foreignKey:FlagID;references:flag_id" json:"-"`
-	JudgeID       string         `gorm:"column:judge_id;not null" json:"judge_id"`
+	JudgeID       *string        `gorm:"column:judge_id" json:"judge_id"`
 	Judge         Judge          `gorm:"foreignKey:JudgeID;references:judge_id" json:"-"`
-	SubmiterID    string         `gorm:"column:submiter_id;not null" json:"submiter_id"`
+	SubmiterID    *string        `gorm:"column:submiter_id" json:"submiter_id"`
 	Submiter      User           `gorm:"foreignKey:SubmiterID;references:user_id" json:"-"`
 	ExtraData     CheatExtraData `gorm:"column:extra_data;type:jsonb" json:"extra_data"`
 	CheatTime     time.Time      `gorm:"column:cheat_time;not null" json:"cheat_time"`
 	SubmiterIP    *string        `gorm:"column:submiter_ip" json:"submiter_ip"`
+	Fingerprint   *string        `gorm:"column:fingerprint" json:"-"`
+	// 处罚规则执行的时间，为空时等待处罚任务处理
+	EnforcedAt *time.Time `gorm:"column:enforced_at" json:"enforced_at"`
 }
 
 // TableName Cheat's table name
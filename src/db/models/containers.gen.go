@@ -4,6 +4,7 @@ import (
 	k8stool "a1ctf/src/utils/k8s_tool"
 	"database/sql/driver"
 	"errors"
+	"fmt"
 	"time"
 
 	"github.com/bytedance/sonic"
@@ -11,10 +12,25 @@ import (
 
 const TableNameContainer = "containers"
 
+type ExposePortType string
+
+const (
+	// 旧数据没有 type 字段，按 ip:port 处理
+	ExposePortTypePort ExposePortType = "PORT"
+	ExposePortTypeURL  ExposePortType = "URL"
+	// 通过 TCP 网关访问，IP 和 Port 为网关地址，连接时需要 Token
+	ExposePortTypeGateway ExposePortType = "GATEWAY"
+)
+
 type ExposePort struct {
-	PortName string `json:"port_name"`
-	Port     int32  `json:"port"`
-	IP       string `json:"ip"`
+	PortName string         `json:"port_name"`
+	Port     int32          `json:"port"`
+	IP       string         `json:"ip"`
+	Type     ExposePortType `json:"type,omitempty"`
+	// Type 为 URL 时的访问地址，IP 和 Port 为对应的域名和 80/443
+	URL string `json:"url,omitempty"`
+	// Type 为 GATEWAY 时的连接凭证
+	Token string `json:"token,omitempty"`
 }
 
 type ExposePorts []ExposePort
@@ -94,9 +110,23 @@ type Container struct {
 	ChallengeName        string               `gorm:"column:challenge_name;not null" json:"challenge_name"`
 	TeamHash             string               `gorm:"column:team_hash;not null" json:"team_hash"`
 	SubmiterIP           *string              `gorm:"column:submiter_ip" json:"submiter_ip"`
+	// 选手延长有效期的次数
+	ExtendCount int64 `gorm:"column:extend_count;not null;default:0" json:"extend_count"`
+	// 最后一次观察到网络连接或 CPU 占用的时间
+	LastActiveTime *time.Time `gorm:"column:last_active_time" json:"last_active_time"`
+	// 从预热池认领的 Pod 保留原来的名字，为空时为 cl-<ingame_id>-<team_hash>
+	PodName *string `gorm:"column:pod_name" json:"pod_name"`
 }
 
 // TableName Container's table name
 func (*Container) TableName() string {
 	return TableNameContainer
 }
+
+// PodID 容器对应的 Pod 名，Service 和 NetworkPolicy 同名
+func (c *Container) PodID() string {
+	if c.PodName != nil && *c.PodName != "" {
+		return *c.PodName
+	}
+	return fmt.Sprintf("cl-%d-%s", c.InGameID, c.TeamHash)
+}
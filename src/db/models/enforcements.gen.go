@@ -0,0 +1,127 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+type EnforcementAction string
+
+const (
+	// 作废队伍在作弊题目上的解题记录
+	EnforcementInvalidateSolve EnforcementAction = "InvalidateSolve"
+	// 扣分，写入一条作弊类型的分数修正
+	EnforcementScorePenalty EnforcementAction = "ScorePenalty"
+	// 把已审核的队伍改回待审核
+	EnforcementSetPending EnforcementAction = "SetPending"
+	// 禁赛
+	EnforcementBan EnforcementAction = "Ban"
+	// 给管理员发邮件
+	EnforcementNotifyAdmins EnforcementAction = "NotifyAdmins"
+)
+
+type EnforcementStatus string
+
+const (
+	EnforcementApplied EnforcementStatus = "Applied"
+	EnforcementUndone  EnforcementStatus = "Undone"
+	EnforcementFailed  EnforcementStatus = "Failed"
+)
+
+// EnforcementRule 一条处罚规则，作弊记录匹配时对队伍执行 Action
+type EnforcementRule struct {
+	Name string `json:"name"`
+	// 匹配的作弊类型，为空时匹配所有类型
+	CheatTypes []CheatType `json:"cheat_types"`
+	// 关联分析记录的最低置信度，没有置信度的记录总是匹配
+	MinConfidence float64 `json:"min_confidence"`
+	// 队伍累计匹配的作弊记录达到这个数量时执行一次，0 和 1 表示每条记录都执行
+	Threshold int               `json:"threshold"`
+	Action    EnforcementAction `json:"action"`
+	// 同时处罚作弊记录里的相关队伍，比如 flag 的所有者
+	IncludeRelevantTeam bool `json:"include_relevant_team"`
+	// ScorePenalty 扣除的分数
+	Penalty float64 `json:"penalty"`
+}
+
+// EnforcementPolicy 比赛的处罚规则
+type EnforcementPolicy struct {
+	Rules []EnforcementRule `json:"rules"`
+	// 最后修改规则的管理员，自动扣分记在这个管理员名下
+	UpdatedBy string    `json:"updated_by"`
+	UpdatedAt time.Time `json:"updated_at"`
+}
+
+func (e EnforcementPolicy) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *EnforcementPolicy) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+// EnforcementDetails 执行时的规则和撤销需要的数据
+type EnforcementDetails struct {
+	Rule EnforcementRule `json:"rule"`
+	// 触发时队伍累计的作弊记录数量
+	CheatCount int64 `json:"cheat_count,omitempty"`
+
+	ChallengeID *int64   `json:"challenge_id,omitempty"`
+	SolveIDs    []string `json:"solve_ids,omitempty"`
+
+	AdjustmentID *int64 `json:"adjustment_id,omitempty"`
+
+	OldStatus ParticipationStatus `json:"old_status,omitempty"`
+	NewStatus ParticipationStatus `json:"new_status,omitempty"`
+
+	Recipients []string `json:"recipients,omitempty"`
+}
+
+func (e EnforcementDetails) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *EnforcementDetails) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+const TableNameEnforcement = "enforcements"
+
+// Enforcement mapped from table <enforcements>
+// 处罚规则对作弊记录的一次执行
+type Enforcement struct {
+	EnforcementID int64 `gorm:"column:enforcement_id;primaryKey;autoIncrement" json:"enforcement_id"`
+	// 同一条规则对同一条记录或者同一支队伍只执行一次
+	EnforcementKey string             `gorm:"column:enforcement_key;not null" json:"-"`
+	GameID         int64              `gorm:"column:game_id;not null" json:"game_id"`
+	CheatID        string             `gorm:"column:cheat_id;not null" json:"cheat_id"`
+	Cheat          Cheat              `gorm:"foreignKey:CheatID;references:cheat_id" json:"-"`
+	TeamID         int64              `gorm:"column:team_id;not null" json:"team_id"`
+	Team           Team               `gorm:"foreignKey:TeamID;references:team_id" json:"-"`
+	RuleName       string             `gorm:"column:rule_name;not null" json:"rule_name"`
+	Action         EnforcementAction  `gorm:"column:action;not null" json:"action"`
+	Details        EnforcementDetails `gorm:"column:details;type:jsonb;not null" json:"details"`
+	Status         EnforcementStatus  `gorm:"column:status;not null" json:"status"`
+	ErrorMessage   *string            `gorm:"column:error_message" json:"error_message"`
+	CreateTime     time.Time          `gorm:"column:create_time;not null" json:"create_time"`
+	UndoTime       *time.Time         `gorm:"column:undo_time" json:"undo_time"`
+	UndoneBy       *string            `gorm:"column:undone_by" json:"undone_by"`
+	// 执行的次数，失败的处罚会自动重试到上限
+	Attempts int `gorm:"column:attempts;not null" json:"attempts"`
+}
+
+// TableName Enforcement's table name
+func (*Enforcement) TableName() string {
+	return TableNameEnforcement
+}
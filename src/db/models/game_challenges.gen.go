@@ -30,6 +30,182 @@ func (e *Hints) Scan(value interface{}) error {
 	return sonic.Unmarshal(b, e)
 }
 
+type ScoringMode string
+
+const (
+	// 总分不变
+	ScoringStatic ScoringMode = "STATIC"
+	// 指数衰减，使用 Difficulty 控制衰减速度
+	ScoringExpDecay ScoringMode = "EXP_DECAY"
+	// CTFd 的 logarithmic（实际是抛物线）衰减，Decay 个解后降到最低分
+	ScoringLogarithmic ScoringMode = "LOGARITHMIC"
+	// 线性衰减，Decay 个解后降到最低分
+	ScoringLinear ScoringMode = "LINEAR"
+	// 按解题人数查表
+	ScoringTable ScoringMode = "TABLE"
+)
+
+func (e ScoringMode) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *ScoringMode) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+type ScoringConfig struct {
+	// LOGARITHMIC 和 LINEAR 下降到最低分需要的解题人数
+	Decay int32 `json:"decay,omitempty"`
+	// TABLE 模式下第 n 个解之后的分数，超出表长度时使用最后一项
+	ScoreTable []float64 `json:"score_table,omitempty"`
+}
+
+func (e ScoringConfig) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *ScoringConfig) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+// 攻防模式下题目服务的配置
+type ADServiceConfig struct {
+	// Starlark checker 脚本，需要定义 def check(service): ...
+	CheckerScript string `json:"checker_script"`
+	// 写入新 flag 的命令，flag 从标准输入传入，为空时写到 /flag
+	FlagCommand []string `json:"flag_command,omitempty"`
+	// 写入 flag 的容器名，为空时使用第一个容器
+	FlagContainer string `json:"flag_container,omitempty"`
+}
+
+func (e ADServiceConfig) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *ADServiceConfig) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+type GameChallengeKind string
+
+const (
+	// 普通的提交 flag 解题
+	GameChallengeStandard GameChallengeKind = "STANDARD"
+	// King of the Hill，所有队伍争夺同一个靶机，按占领时长计分
+	GameChallengeKOTH GameChallengeKind = "KOTH"
+)
+
+func (e GameChallengeKind) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *GameChallengeKind) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+type KothTokenSource string
+
+const (
+	// 在容器里读取文件
+	KothTokenFile KothTokenSource = "FILE"
+	// 请求靶机的 HTTP 接口
+	KothTokenHTTP KothTokenSource = "HTTP"
+)
+
+// KOTH 题目的配置，靶机里的占领标记是占领队伍的 team_hash
+type KothConfig struct {
+	// 检查间隔，单位秒
+	TickInterval int64 `json:"tick_interval"`
+	// 每次检查占领队伍获得的分数
+	PointsPerTick float64         `json:"points_per_tick"`
+	TokenSource   KothTokenSource `json:"token_source"`
+	// FILE 为容器内的文件路径，HTTP 为请求路径
+	TokenPath string `json:"token_path"`
+	// FILE 读取的容器名，为空时使用第一个容器
+	TokenContainer string `json:"token_container,omitempty"`
+	// HTTP 请求的端口名，为空时使用第一个暴露的端口
+	TokenPort string `json:"token_port,omitempty"`
+}
+
+func (e KothConfig) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *KothConfig) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+// WarmPoolConfig 预先启动的题目 Pod，队伍申请容器时直接认领一个，只支持 kubernetes 后端
+// 预热的 Pod 启动时没有 flag，认领后通过 FlagCommand 写入
+type WarmPoolConfig struct {
+	// 保持就绪的 Pod 数量，0 为关闭
+	Size int32 `json:"size"`
+	// 认领后执行的命令，flag 从标准输入传入，为空时写入 /flag
+	FlagCommand []string `json:"flag_command,omitempty"`
+	// 执行命令的容器名，为空时使用第一个容器
+	FlagContainer string `json:"flag_container,omitempty"`
+}
+
+func (e WarmPoolConfig) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *WarmPoolConfig) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+// HoneypotFlag 看起来有效但只放在诱饵位置的 flag，比如泄露的 writeup、假附件
+type HoneypotFlag struct {
+	Flag string `json:"flag"`
+	// 诱饵放在哪里，记录在作弊记录里
+	Source string `json:"source"`
+}
+
+// HoneypotConfig 诱饵 flag 和队伍 canary，提交后判为错误并记录作弊
+type HoneypotConfig struct {
+	Flags []HoneypotFlag `json:"flags,omitempty"`
+	// 给每支队伍生成 canary，动态附件生成时通过 A1CTF_CANARY 传入
+	CanaryEnabled bool `json:"canary_enabled,omitempty"`
+	// canary 的模板，语法和 flag 模板相同，为空时使用题目的 flag 模板
+	CanaryTemplate string `json:"canary_template,omitempty"`
+}
+
+func (e HoneypotConfig) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *HoneypotConfig) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
 type GameChallenge struct {
 	IngameID     int64        `gorm:"column:ingame_id;primaryKey;autoIncrement:true" json:"ingame_id"`
 	GameID       int64        `gorm:"column:game_id;not null" json:"game_id"`
@@ -47,6 +223,21 @@ type GameChallenge struct {
 	Visible      bool         `gorm:"column:visible" json:"visible"`
 
 	BloodRewardEnabled bool `gorm:"column:enable_blood_reward" json:"enable_blood_reward"`
+
+	ScoringMode   ScoringMode    `gorm:"column:scoring_mode;not null" json:"scoring_mode"`
+	ScoringConfig *ScoringConfig `gorm:"column:scoring_config" json:"scoring_config"`
+
+	ADServiceConfig *ADServiceConfig `gorm:"column:ad_service_config" json:"ad_service_config"`
+
+	ChallengeKind GameChallengeKind `gorm:"column:challenge_kind;not null" json:"challenge_kind"`
+	KothConfig    *KothConfig       `gorm:"column:koth_config" json:"koth_config"`
+
+	// 覆盖比赛的容器有效期设置
+	ContainerLifetime *ContainerLifetimeConfig `gorm:"column:container_lifetime" json:"container_lifetime"`
+
+	WarmPool *WarmPoolConfig `gorm:"column:warm_pool" json:"warm_pool"`
+
+	Honeypot *HoneypotConfig `gorm:"column:honeypot" json:"honeypot"`
 	// Challenge Challenge `gorm:"foreignKey:challenge_id;references:challenges.challenge_id"`
 }
 
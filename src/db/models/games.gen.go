@@ -49,6 +49,139 @@ func (e *TeamPolicy) Scan(value interface{}) error {
 	return sonic.Unmarshal(b, e)
 }
 
+type BloodRewardType string
+
+const (
+	// 按题目当前分数的百分比奖励
+	BloodRewardPercent BloodRewardType = "PERCENT"
+	// 固定分数奖励
+	BloodRewardFixed BloodRewardType = "FIXED"
+)
+
+type BloodRewardRule struct {
+	Type  BloodRewardType `json:"type"`
+	Value float64         `json:"value"`
+}
+
+type BloodRewardConfig struct {
+	// 第 i 项是第 i+1 名的奖励，长度就是有奖励的名次数
+	Ranks []BloodRewardRule `json:"ranks"`
+	// 奖励从比赛开始起在 DecayDuration 秒内线性衰减到 DecayMinRatio，为 0 时不衰减
+	DecayDuration int64   `json:"decay_duration,omitempty"`
+	DecayMinRatio float64 `json:"decay_min_ratio,omitempty"`
+}
+
+func (e BloodRewardConfig) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *BloodRewardConfig) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+// ContainerQuotaConfig 题目容器的资源配额，按容器配置里的 CPULimit 和 MemoryLimit 累加，为 0 时不限制
+type ContainerQuotaConfig struct {
+	// 每个队伍，单位分别为 millicore 和 MiB
+	TeamCPULimit    int64 `json:"team_cpu_limit"`
+	TeamMemoryLimit int64 `json:"team_memory_limit"`
+	// 整场比赛，超出时新的容器按申请顺序排队
+	GameCPULimit    int64 `json:"game_cpu_limit"`
+	GameMemoryLimit int64 `json:"game_memory_limit"`
+}
+
+func (e ContainerQuotaConfig) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *ContainerQuotaConfig) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+// ContainerLifetimeConfig 题目容器的有效期，单位秒，字段为空时使用上一级的设置
+// 题目的设置优先于比赛的设置，都没有设置时使用默认值
+type ContainerLifetimeConfig struct {
+	// 创建后的有效期
+	InitialTTL *int64 `json:"initial_ttl,omitempty"`
+	// 每次延长后的剩余时间
+	ExtendDuration *int64 `json:"extend_duration,omitempty"`
+	// 剩余时间不超过这个值时才能延长，0 为不能延长
+	ExtendWindow *int64 `json:"extend_window,omitempty"`
+	// 最多延长几次，0 为不限制
+	MaxExtensions *int64 `json:"max_extensions,omitempty"`
+	// 从启动开始最长的存活时间，0 为不限制
+	MaxLifetime *int64 `json:"max_lifetime,omitempty"`
+	// 没有网络连接和 CPU 占用超过这个时间后关闭，0 为不检查
+	IdleTimeout *int64 `json:"idle_timeout,omitempty"`
+}
+
+func (e ContainerLifetimeConfig) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *ContainerLifetimeConfig) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+type GameMode string
+
+const (
+	// 解题模式
+	GameModeJeopardy GameMode = "JEOPARDY"
+	// 攻防模式，每队一个长期运行的服务，按轮次计分
+	GameModeAttackDefense GameMode = "ATTACK_DEFENSE"
+)
+
+func (e GameMode) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *GameMode) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+type AttackDefenseConfig struct {
+	// 每轮的时长，单位秒
+	RoundDuration int64 `json:"round_duration"`
+	// 轮次开始多少秒后运行 checker，为 0 时在轮次中间运行
+	CheckDelay int64 `json:"check_delay,omitempty"`
+	// flag 在之后多少轮内都可以提交，至少为 1
+	FlagLifetime int32 `json:"flag_lifetime"`
+	// 每偷到一个 flag 的得分
+	AttackScore float64 `json:"attack_score"`
+	// 服务正常并且本轮没有被偷 flag 的得分
+	DefenseScore float64 `json:"defense_score"`
+	// 服务通过 checker 的得分
+	SLAScore float64 `json:"sla_score"`
+}
+
+func (e AttackDefenseConfig) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *AttackDefenseConfig) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
 // Game mapped from table <games>
 type Game struct {
 	GameID                 int64       `gorm:"column:game_id;primaryKey;autoIncrement:true" json:"game_id"`
@@ -74,6 +207,19 @@ type Game struct {
 	FirstBloodReward  int64 `gorm:"column:first_blood_reward" json:"first_blood_reward"`
 	SecondBloodReward int64 `gorm:"column:second_blood_reward" json:"second_blood_reward"`
 	ThirdBloodReward  int64 `gorm:"column:third_blood_reward" json:"third_blood_reward"`
+
+	// 设置后替代上面的三血比例
+	BloodRewardConfig *BloodRewardConfig `gorm:"column:blood_reward_config" json:"blood_reward_config"`
+
+	GameMode            GameMode             `gorm:"column:game_mode;not null" json:"game_mode"`
+	AttackDefenseConfig *AttackDefenseConfig `gorm:"column:attack_defense_config" json:"attack_defense_config"`
+
+	ContainerQuota *ContainerQuotaConfig `gorm:"column:container_quota" json:"container_quota"`
+
+	ContainerLifetime *ContainerLifetimeConfig `gorm:"column:container_lifetime" json:"container_lifetime"`
+
+	// 作弊记录的自动处罚规则，单独的接口修改
+	EnforcementPolicy *EnforcementPolicy `gorm:"column:enforcement_policy" json:"-"`
 }
 
 // TableName Game's table name
@@ -0,0 +1,50 @@
+package models
+
+import "time"
+
+const TableNameGatewayRoute = "gateway_routes"
+
+// GatewayRoute mapped from table <gateway_routes>
+// TCP 网关的路由，选手用 Token 连接到对应容器的端口
+type GatewayRoute struct {
+	Token         string    `gorm:"column:token;primaryKey" json:"token"`
+	ContainerID   string    `gorm:"column:container_id;not null" json:"container_id"`
+	Container     Container `gorm:"foreignKey:ContainerID;references:container_id" json:"-"`
+	GameID        int64     `gorm:"column:game_id;not null" json:"game_id"`
+	TeamID        int64     `gorm:"column:team_id;not null" json:"team_id"`
+	ContainerName string    `gorm:"column:container_name;not null" json:"container_name"`
+	PortName      string    `gorm:"column:port_name;not null" json:"port_name"`
+	TargetHost    string    `gorm:"column:target_host;not null" json:"-"`
+	TargetPort    int32     `gorm:"column:target_port;not null" json:"-"`
+	CreateTime    time.Time `gorm:"column:create_time;not null" json:"create_time"`
+}
+
+// TableName GatewayRoute's table name
+func (*GatewayRoute) TableName() string {
+	return TableNameGatewayRoute
+}
+
+const TableNameGatewayConnection = "gateway_connections"
+
+// GatewayConnection mapped from table <gateway_connections>
+// 经过 TCP 网关的连接，BytesIn 为选手发往容器的字节数
+type GatewayConnection struct {
+	ConnectionID int64      `gorm:"column:connection_id;primaryKey;autoIncrement" json:"connection_id"`
+	Token        string     `gorm:"column:token;not null" json:"token"`
+	ContainerID  string     `gorm:"column:container_id;not null" json:"container_id"`
+	GameID       int64      `gorm:"column:game_id;not null" json:"game_id"`
+	TeamID       int64      `gorm:"column:team_id;not null" json:"team_id"`
+	PortName     string     `gorm:"column:port_name;not null" json:"port_name"`
+	ClientAddr   string     `gorm:"column:client_addr;not null" json:"client_addr"`
+	Mode         string     `gorm:"column:mode;not null" json:"mode"`
+	StartTime    time.Time  `gorm:"column:start_time;not null" json:"start_time"`
+	EndTime      *time.Time `gorm:"column:end_time" json:"end_time"`
+	BytesIn      int64      `gorm:"column:bytes_in;not null;default:0" json:"bytes_in"`
+	BytesOut     int64      `gorm:"column:bytes_out;not null;default:0" json:"bytes_out"`
+	CloseReason  string     `gorm:"column:close_reason;not null;default:''" json:"close_reason"`
+}
+
+// TableName GatewayConnection's table name
+func (*GatewayConnection) TableName() string {
+	return TableNameGatewayConnection
+}
@@ -0,0 +1,24 @@
+package models
+
+import "time"
+
+const TableNameKothTick = "koth_ticks"
+
+// KothTick mapped from table <koth_ticks>
+// 每个检查周期记录一次占领队伍，没有队伍占领时 TeamID 为空
+type KothTick struct {
+	TickID      int64     `gorm:"column:tick_id;primaryKey;autoIncrement:true" json:"tick_id"`
+	GameID      int64     `gorm:"column:game_id;not null" json:"game_id"`
+	ChallengeID int64     `gorm:"column:challenge_id;not null" json:"challenge_id"`
+	InGameID    int64     `gorm:"column:ingame_id;not null" json:"ingame_id"`
+	Tick        int64     `gorm:"column:tick;not null" json:"tick"`
+	TeamID      *int64    `gorm:"column:team_id" json:"team_id"`
+	Points      float64   `gorm:"column:points;not null" json:"points"`
+	Message     string    `gorm:"column:message;not null;default:''" json:"message"`
+	TickTime    time.Time `gorm:"column:tick_time;not null" json:"tick_time"`
+}
+
+// TableName KothTick's table name
+func (*KothTick) TableName() string {
+	return TableNameKothTick
+}
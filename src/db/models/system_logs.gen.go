@@ -75,6 +75,7 @@ const (
 	ResourceTypeSystem    = "SYSTEM"
 	ResourceTypeScore     = "SCORE"
 	ResourceTypeFile      = "FILE"
+	ResourceTypeCheat     = "CHEAT"
 )
 
 // 操作类型常量
@@ -110,10 +111,23 @@ const (
 	ActionStopContainer   = "STOP_CONTAINER"
 	ActionExtendContainer = "EXTEND_CONTAINER"
 
+	// 生成动态附件
+	ActionGenerateAttachment = "GENERATE_ATTACHMENT"
+
 	ActionView      = "VIEW"
 	ActionTransfer  = "TRANSFER"
 	ActionJoinTeam  = "JOIN_TEAM"
 	ActionLeaveTeam = "LEAVE_TEAM"
 
+	// 作弊处罚规则
+	ActionEnforce      = "ENFORCE"
+	ActionUndoEnforce  = "UNDO_ENFORCE"
+	ActionRetryEnforce = "RETRY_ENFORCE"
+
+	// 单点登录
+	ActionSsoLink        = "SSO_LINK"
+	ActionSsoUnlink      = "SSO_UNLINK"
+	ActionSsoLoginFailed = "SSO_LOGIN_FAILED"
+
 	LoginSuccess = "LOGIN_SUCCESS"
 )
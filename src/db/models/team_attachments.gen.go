@@ -0,0 +1,51 @@
+package models
+
+import (
+	"database/sql/driver"
+	"errors"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+type TeamAttachmentStatus string
+
+const (
+	TeamAttachmentPending TeamAttachmentStatus = "PENDING"
+	TeamAttachmentReady   TeamAttachmentStatus = "READY"
+	TeamAttachmentFailed  TeamAttachmentStatus = "FAILED"
+)
+
+func (e TeamAttachmentStatus) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *TeamAttachmentStatus) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
+const TableNameTeamAttachment = "team_attachments"
+
+// TeamAttachment mapped from table <team_attachments>
+// 动态附件每个队伍生成一份，生成完成后 FileID 指向 uploads 里的文件
+type TeamAttachment struct {
+	AttachmentID int64                `gorm:"column:attachment_id;primaryKey;autoIncrement" json:"attachment_id"`
+	GameID       int64                `gorm:"column:game_id;not null" json:"game_id"`
+	ChallengeID  int64                `gorm:"column:challenge_id;not null" json:"challenge_id"`
+	TeamID       int64                `gorm:"column:team_id;not null" json:"team_id"`
+	AttachName   string               `gorm:"column:attach_name;not null" json:"attach_name"`
+	FileID       *string              `gorm:"column:file_id" json:"file_id"`
+	Status       TeamAttachmentStatus `gorm:"column:status;not null" json:"status"`
+	Message      string               `gorm:"column:message;not null;default:''" json:"message"`
+	CreateTime   time.Time            `gorm:"column:create_time;not null" json:"create_time"`
+	UpdateTime   time.Time            `gorm:"column:update_time;not null" json:"update_time"`
+}
+
+// TableName TeamAttachment's table name
+func (*TeamAttachment) TableName() string {
+	return TableNameTeamAttachment
+}
@@ -0,0 +1,23 @@
+package models
+
+import "time"
+
+const TableNameTeamCanary = "team_canaries"
+
+// TeamCanary mapped from table <team_canaries>
+// 嵌入到队伍动态附件里的 canary，别的队伍提交说明附件泄露了
+type TeamCanary struct {
+	CanaryID    int64     `gorm:"column:canary_id;primaryKey;autoIncrement" json:"canary_id"`
+	GameID      int64     `gorm:"column:game_id;not null" json:"game_id"`
+	IngameID    int64     `gorm:"column:ingame_id;not null" json:"ingame_id"`
+	ChallengeID int64     `gorm:"column:challenge_id;not null" json:"challenge_id"`
+	TeamID      int64     `gorm:"column:team_id;not null" json:"team_id"`
+	Team        Team      `gorm:"foreignKey:TeamID;references:team_id" json:"-"`
+	Canary      string    `gorm:"column:canary;not null" json:"canary"`
+	CreateTime  time.Time `gorm:"column:create_time;not null" json:"create_time"`
+}
+
+// TableName TeamCanary's table name
+func (*TeamCanary) TableName() string {
+	return TableNameTeamCanary
+}
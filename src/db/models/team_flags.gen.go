@@ -12,6 +12,8 @@ type TeamFlag struct {
 	Game        Game      `gorm:"foreignKey:GameID;references:game_id" json:"-"`
 	ChallengeID int64     `gorm:"column:challenge_id;not null" json:"challenge_id"`
 	Challenge   Challenge `gorm:"foreignKey:ChallengeID;references:challenge_id" json:"-"`
+	// 攻防模式的轮次，解题模式固定为 0
+	Round int32 `gorm:"column:round;not null;default:0" json:"round"`
 }
 
 // TableName Team's table name
@@ -30,6 +30,33 @@ func (e *UserRole) Scan(value interface{}) error {
 	return sonic.Unmarshal(b, e)
 }
 
+// SsoIdentity 用户在一个单点登录提供方的身份
+type SsoIdentity struct {
+	// 提供方的用户唯一标识，OIDC 的 sub、GitHub 的用户 ID、CAS 的用户名
+	Subject  string `json:"subject"`
+	Username string `json:"username,omitempty"`
+	Email    string `json:"email,omitempty"`
+	// 最近一次登录时提供方返回的原始属性，方便管理员调整字段映射
+	Claims      map[string]interface{} `json:"claims,omitempty"`
+	LinkedAt    time.Time              `json:"linked_at"`
+	LastLoginAt time.Time              `json:"last_login_at"`
+}
+
+// SsoData 按提供方 ID 保存用户绑定的身份
+type SsoData map[string]SsoIdentity
+
+func (e SsoData) Value() (driver.Value, error) {
+	return sonic.Marshal(e)
+}
+
+func (e *SsoData) Scan(value interface{}) error {
+	b, ok := value.([]byte)
+	if !ok {
+		return errors.New("type assertion to []byte failed")
+	}
+	return sonic.Unmarshal(b, e)
+}
+
 // User mapped from table <users>
 type User struct {
 	UserID        string    `gorm:"column:user_id;primaryKey" json:"user_id"`
@@ -43,7 +70,7 @@ type User struct {
 	Realname      *string   `gorm:"column:realname" json:"realname"`
 	Slogan        *string   `gorm:"column:slogan" json:"slogan"`
 	Avatar        *string   `gorm:"column:avatar" json:"avatar"`
-	SsoData       *string   `gorm:"column:sso_data" json:"sso_data"`
+	SsoData       *SsoData  `gorm:"column:sso_data;type:jsonb" json:"sso_data"`
 	JWTVersion    string    `gorm:"column:jwt_version" json:"jwt_version"`
 	Email         *string   `gorm:"column:email" json:"email"`
 	EmailVerified bool      `gorm:"column:email_verified" json:"email_verified"`
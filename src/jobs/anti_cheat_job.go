@@ -0,0 +1,17 @@
+package jobs
+
+import (
+	anticheat "a1ctf/src/modules/anti_cheat"
+	"a1ctf/src/modules/enforcement"
+)
+
+// AntiCheatCorrelationJob 对进行中和最近结束的比赛做关联分析，结果写入作弊记录
+func AntiCheatCorrelationJob() {
+	anticheat.Correlate()
+}
+
+// CheatEnforcementJob 按比赛的处罚规则处理新的作弊记录，重试失败的处罚
+func CheatEnforcementJob() {
+	enforcement.Run()
+	enforcement.RetryFailed()
+}
@@ -0,0 +1,10 @@
+package jobs
+
+import (
+	attackdefense "a1ctf/src/modules/attack_defense"
+)
+
+// 推进攻防比赛的轮次，生成 flag、维护服务、运行 checker 和结算
+func AttackDefenseRoundJob() {
+	attackdefense.Run()
+}
@@ -4,137 +4,51 @@ import (
 	"a1ctf/src/db/models"
 	"a1ctf/src/tasks"
 	dbtool "a1ctf/src/utils/db_tool"
-	a1locks "a1ctf/src/utils/locks"
-	noticetool "a1ctf/src/utils/notice_tool"
 	"a1ctf/src/utils/zaphelper"
-	"errors"
-	"fmt"
 	"time"
 
-	"github.com/google/uuid"
+	"github.com/spf13/viper"
 	"go.uber.org/zap"
-	"gorm.io/gorm"
 )
 
-func processQueueingJudge(judge *models.Judge) error {
-	switch judge.JudgeType {
-	case models.JudgeTypeDynamic:
-		flagCorrect := false
-
-		switch judge.Challenge.FlagType {
-		case models.FlagTypeDynamic:
-			// 动态和TeamFlag库里的比较
-			flagCorrect = judge.JudgeContent == judge.TeamFlag.FlagContent
-		case models.FlagTypeStatic:
-			// 静态直接比较
-			flagCorrect = judge.JudgeContent == *judge.GameChallenge.JudgeConfig.FlagTemplate
-		}
-
-		if flagCorrect {
-
-			// 如果是系统管理员队伍，不插入 Solves，防止影响积分榜
-			if judge.Team.TeamType == models.TeamTypeAdmin {
-				judge.JudgeStatus = models.JudgeAC
-				return nil
-			}
-
-			// 获取写锁, 防止与重算题目Rank的任务冲突
-			a1locks.RankRWLock.Lock()
-			defer a1locks.RankRWLock.Unlock()
-
-			// 查询已经解出来的人
-			var solves []models.Solve
-			if err := dbtool.DB().Where("game_id = ? AND challenge_id = ? AND solve_time >= ? AND solve_time <= ? AND solve_status = ?", judge.GameID, judge.ChallengeID, judge.Game.StartTime, judge.Game.EndTime, models.SolveCorrect).Preload("Team").Find(&solves).Error; err != nil {
-				judge.JudgeStatus = models.JudgeError
-				if !errors.Is(err, gorm.ErrRecordNotFound) {
-					// 记录错误
-					tasks.LogJudgeOperation(nil, nil, models.ActionJudge, judge.JudgeID, map[string]interface{}{
-						"team_id": judge.TeamID,
-						"game_id": judge.GameID,
-						"judge":   judge,
-					}, err)
-				}
-				return fmt.Errorf("database error: %w data: %+v", err, judge)
-			}
-
-			// 过滤被封禁的队伍
-			solves = filterValidSolves(solves)
-
-			newSolve := models.Solve{
-				IngameID:    judge.IngameID,
-				JudgeID:     judge.JudgeID,
-				SolveID:     uuid.NewString(),
-				GameID:      judge.GameID,
-				ChallengeID: judge.ChallengeID,
-				TeamID:      judge.TeamID,
-				SolveStatus: models.SolveCorrect,
-				SolveTime:   time.Now().UTC(),
-				SolverID:    judge.SubmiterID,
-				Rank:        int32(len(solves) + 1),
-			}
-
-			if err := dbtool.DB().Create(&newSolve).Error; err != nil {
-				judge.JudgeStatus = models.JudgeError
-				return fmt.Errorf("database error: %w data: %+v", err, judge)
-			}
-
-			if newSolve.Rank <= 3 {
-				var solveDetail = models.Solve{}
-
-				if err := dbtool.DB().Where("solve_id = ?", newSolve.SolveID).Preload("Challenge").Preload("Team").First(&solveDetail).Error; err != nil {
-					zaphelper.Logger.Error("Announce first blood error", zap.Error(err))
-				}
-
-				var noticeCate models.NoticeCategory
-
-				// 现在由算分逻辑计算三血分数，不使用 score-adjustment
-
-				if newSolve.Rank == 1 {
-					noticeCate = models.NoticeFirstBlood
-				} else if newSolve.Rank == 2 {
-					noticeCate = models.NoticeSecondBlood
-				} else {
-					noticeCate = models.NoticeThirdBlood
-				}
+// FlagJudgeJob 兜底扫描，提交时入队失败或者 worker 中途退出的判题在这里重新入队
+func FlagJudgeJob() {
+	now := time.Now().UTC()
 
-				go func() {
-					noticetool.InsertNotice(judge.GameID, noticeCate, []string{solveDetail.Team.TeamName, solveDetail.Challenge.Name})
-				}()
-			}
+	runningTimeout := viper.GetDuration("judge.running-timeout")
+	if runningTimeout <= 0 {
+		runningTimeout = 2 * time.Minute
+	}
 
-			judge.JudgeStatus = models.JudgeAC
-			return nil
-		} else {
-			judge.JudgeStatus = models.JudgeWA
-			return nil
-		}
-	case models.JudgeTypeScript:
-		judge.JudgeStatus = models.JudgeError
-		return fmt.Errorf("dynamic judge not implemented now")
-	default:
-		judge.JudgeStatus = models.JudgeError
-		return fmt.Errorf("unknown judge type: %s", judge.JudgeType)
+	sweepGrace := viper.GetDuration("judge.sweep-grace")
+	if sweepGrace <= 0 {
+		sweepGrace = 5 * time.Second
 	}
-}
 
-func FlagJudgeJob() {
-	var judges []models.Judge
-	if err := dbtool.DB().Where(
-		"judge_status IN (?)",
-		[]interface{}{models.JudgeQueueing, models.JudgeRunning},
-	).Preload("TeamFlag").Preload("GameChallenge").Preload("Challenge").Preload("Team").Preload("Game").Find(&judges).Error; err != nil {
-		fmt.Printf("database error: %v\n", err)
+	// 判题中太久的说明 worker 已经挂了，重置为排队中。按 worker 抢到判题的时间算，排队久的判题不会被误判
+	if err := dbtool.DB().Model(&models.Judge{}).Where(
+		"judge_status = ? AND COALESCE(judge_started_at, judge_time) < ?",
+		models.JudgeRunning, now.Add(-runningTimeout),
+	).Updates(map[string]interface{}{
+		"judge_status":     models.JudgeQueueing,
+		"judge_started_at": nil,
+	}).Error; err != nil {
+		zaphelper.Logger.Error("Failed to reset stuck judges", zap.Error(err))
 		return
 	}
 
-	for _, judge := range judges {
-		if err := processQueueingJudge(&judge); err != nil {
-			zaphelper.Logger.Error("Judge task failed", zap.Error(err), zap.Any("judge", judge))
-		}
+	var judgeIDs []string
+	if err := dbtool.DB().Model(&models.Judge{}).Where(
+		"judge_status = ? AND judge_time < ?",
+		models.JudgeQueueing, now.Add(-sweepGrace),
+	).Pluck("judge_id", &judgeIDs).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load missed judges", zap.Error(err))
+		return
+	}
 
-		if err := dbtool.DB().Save(&judge).Error; err != nil {
-			fmt.Printf("database error: %v\n", err)
-			continue
+	for _, judgeID := range judgeIDs {
+		if err := tasks.NewJudgeFlagTask(judgeID); err != nil {
+			zaphelper.Logger.Error("Failed to enqueue judge task", zap.Error(err), zap.String("judge_id", judgeID))
 		}
 	}
 }
@@ -0,0 +1,10 @@
+package jobs
+
+import (
+	"a1ctf/src/modules/koth"
+)
+
+// 每个检查周期记录 KOTH 靶机的占领队伍并计分
+func KothTickJob() {
+	koth.Run()
+}
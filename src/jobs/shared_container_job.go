@@ -0,0 +1,10 @@
+package jobs
+
+import (
+	sharedcontainer "a1ctf/src/modules/shared_container"
+)
+
+// 维护静态容器题目的共享容器
+func SharedContainerJob() {
+	sharedcontainer.Run()
+}
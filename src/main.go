@@ -16,14 +16,20 @@ import (
 
 	"a1ctf/src/controllers"
 	"a1ctf/src/db"
+	"a1ctf/src/db/models"
 	"a1ctf/src/jobs"
 	clientconfig "a1ctf/src/modules/client_config"
+	containerreconciler "a1ctf/src/modules/container_reconciler"
 	jwtauth "a1ctf/src/modules/jwt_auth"
 	emailjwt "a1ctf/src/modules/jwt_email"
 	"a1ctf/src/modules/monitoring"
 	proofofwork "a1ctf/src/modules/proof_of_work"
+	scriptjudge "a1ctf/src/modules/script_judge"
+	"a1ctf/src/modules/sso"
+	tcpgateway "a1ctf/src/modules/tcp_gateway"
 	"a1ctf/src/tasks"
 	"a1ctf/src/utils"
+	containerbackend "a1ctf/src/utils/container_backend"
 	dbtool "a1ctf/src/utils/db_tool"
 	i18ntool "a1ctf/src/utils/i18n_tool"
 	k8stool "a1ctf/src/utils/k8s_tool"
@@ -61,20 +67,60 @@ func StartLoopEvent() {
 
 	s.NewJob(
 		gocron.DurationJob(
-			viper.GetDuration("job-intervals.update-active-game-score-board"),
+			viper.GetDuration("job-intervals.container-updating"),
 		),
 		gocron.NewTask(
-			jobs.UpdateActiveGameScoreBoard,
+			jobs.UpdateLivingContainers,
 		),
 		gocron.WithSingletonMode(gocron.LimitModeWait),
 	)
 
 	s.NewJob(
 		gocron.DurationJob(
-			viper.GetDuration("job-intervals.container-updating"),
+			viper.GetDuration("job-intervals.container-activity"),
 		),
 		gocron.NewTask(
-			jobs.UpdateLivingContainers,
+			jobs.ContainerActivityJob,
+		),
+		gocron.WithSingletonMode(gocron.LimitModeWait),
+	)
+
+	s.NewJob(
+		gocron.DurationJob(
+			viper.GetDuration("job-intervals.container-orphan-gc"),
+		),
+		gocron.NewTask(
+			jobs.ContainerOrphanGCJob,
+		),
+		gocron.WithSingletonMode(gocron.LimitModeWait),
+	)
+
+	s.NewJob(
+		gocron.DurationJob(
+			viper.GetDuration("job-intervals.warm-pool"),
+		),
+		gocron.NewTask(
+			jobs.WarmPoolJob,
+		),
+		gocron.WithSingletonMode(gocron.LimitModeWait),
+	)
+
+	s.NewJob(
+		gocron.DurationJob(
+			viper.GetDuration("job-intervals.anti-cheat-correlation"),
+		),
+		gocron.NewTask(
+			jobs.AntiCheatCorrelationJob,
+		),
+		gocron.WithSingletonMode(gocron.LimitModeWait),
+	)
+
+	s.NewJob(
+		gocron.DurationJob(
+			viper.GetDuration("job-intervals.cheat-enforcement"),
+		),
+		gocron.NewTask(
+			jobs.CheatEnforcementJob,
 		),
 		gocron.WithSingletonMode(gocron.LimitModeWait),
 	)
@@ -99,6 +145,36 @@ func StartLoopEvent() {
 		gocron.WithSingletonMode(gocron.LimitModeWait),
 	)
 
+	s.NewJob(
+		gocron.DurationJob(
+			viper.GetDuration("job-intervals.attack-defense-round"),
+		),
+		gocron.NewTask(
+			jobs.AttackDefenseRoundJob,
+		),
+		gocron.WithSingletonMode(gocron.LimitModeWait),
+	)
+
+	s.NewJob(
+		gocron.DurationJob(
+			viper.GetDuration("job-intervals.shared-container"),
+		),
+		gocron.NewTask(
+			jobs.SharedContainerJob,
+		),
+		gocron.WithSingletonMode(gocron.LimitModeWait),
+	)
+
+	s.NewJob(
+		gocron.DurationJob(
+			viper.GetDuration("job-intervals.koth-tick"),
+		),
+		gocron.NewTask(
+			jobs.KothTickJob,
+		),
+		gocron.WithSingletonMode(gocron.LimitModeWait),
+	)
+
 	s.NewJob(
 		gocron.DurationJob(
 			viper.GetDuration("job-intervals.compress-and-delete-old-logs"),
@@ -128,6 +204,12 @@ func RateLimiter(rateLimit int, rateInterval time.Duration) gin.HandlerFunc {
 }
 
 func main() {
+	// 判题脚本和攻防 checker 在独立的子进程里执行，子进程不需要加载配置和连接
+	if scriptjudge.IsWorker() {
+		scriptjudge.WorkerMain()
+		return
+	}
+
 	// 加载配置文件
 	utils.LoadConfig()
 
@@ -151,6 +233,12 @@ func main() {
 	// 初始化 Proof-of-work backend
 	proofofwork.InitCap()
 
+	// 加载判题脚本沙箱限制
+	scriptjudge.LoadConfig()
+
+	// 加载单点登录提供方
+	sso.Init()
+
 	// 初始化缓存池
 	ristretto_tool.LoadCacheTime()
 	ristretto_tool.InitCachePool()
@@ -159,9 +247,9 @@ func main() {
 	// 初始化 db
 	db.InitDB()
 
-	// 初始化k8s命名空间
-	if err := k8stool.InitNamespace(); err != nil {
-		log.Fatalf("Failed to initialize k8s namespace: %v", err)
+	// 初始化容器后端，k8s 后端会创建命名空间
+	if err := containerbackend.Init(); err != nil {
+		log.Fatalf("Failed to initialize container backend: %v", err)
 	}
 
 	// 加载配置文件
@@ -177,6 +265,18 @@ func main() {
 	// 初始化任务队列
 	tasks.InitTaskQueue()
 
+	// 监听题目容器的 Pod 变化
+	if err := containerreconciler.Start(context.Background()); err != nil {
+		log.Fatalf("Failed to start container reconciler: %v", err)
+	}
+
+	// 题目容器的 TCP 网关
+	if tcpgateway.Enabled() {
+		if err := tcpgateway.Start(context.Background()); err != nil {
+			log.Fatalf("Failed to start tcp gateway: %v", err)
+		}
+	}
+
 	memoryStore := persist.NewMemoryStore(1 * time.Minute)
 
 	// 关闭日志输出
@@ -231,6 +331,10 @@ func main() {
 			webmodels.RegisterPayload{},
 		), controllers.Register)
 
+		// 单点登录
+		public.GET("/sso/:provider/login", controllers.SSOLogin)
+		public.GET("/sso/:provider/callback", controllers.SSOCallback)
+
 		public.GET("/game/list", cache.CacheByRequestURI(memoryStore, 1*time.Second), controllers.UserListGames)
 		public.GET("/game/:game_id/scoreboard", bestGzipMiddleware, controllers.GameStatusMiddleware(controllers.GameStatusMiddlewareProps{
 			VisibleAfterEnded: true,
@@ -297,6 +401,8 @@ func main() {
 				webmodels.UpdateUserEmailPayload{},
 			), controllers.UpdateUserEmail)
 			accountGroup.POST("/sendVerifyEmail", controllers.SendVerifyEmail)
+			accountGroup.POST("/sso/:provider/link", controllers.SSOLink)
+			accountGroup.POST("/sso/:provider/unlink", controllers.SSOUnlink)
 			accountGroup.POST("/changePassword", controllers.PayloadValidator(
 				webmodels.ChangePasswordPayload{},
 			), controllers.UserChangePassword)
@@ -393,6 +499,14 @@ func main() {
 
 			gameGroup.POST("/:game_id/submits", controllers.AdminGetSubmits)
 			gameGroup.POST("/:game_id/cheats", controllers.AdminGetCheats)
+			gameGroup.POST("/:game_id/cheats/correlate", controllers.PathParmsMiddlewareBuilder("G"), controllers.AdminCorrelateCheats)
+
+			// 作弊处罚规则
+			gameGroup.GET("/:game_id/enforcement-policy", controllers.PathParmsMiddlewareBuilder("G"), controllers.AdminGetEnforcementPolicy)
+			gameGroup.PUT("/:game_id/enforcement-policy", controllers.PathParmsMiddlewareBuilder("G"), controllers.AdminUpdateEnforcementPolicy)
+			gameGroup.POST("/:game_id/enforcements", controllers.PathParmsMiddlewareBuilder("G"), controllers.AdminListEnforcements)
+			gameGroup.POST("/:game_id/enforcements/:enforcement_id/undo", controllers.PathParmsMiddlewareBuilder("G"), controllers.AdminUndoEnforcement)
+			gameGroup.POST("/:game_id/enforcements/:enforcement_id/retry", controllers.PathParmsMiddlewareBuilder("G"), controllers.AdminRetryEnforcement)
 
 			// 比赛海报上传路由
 			gameGroup.POST("/:game_id/poster/upload", controllers.AdminUploadGamePoster)
@@ -477,6 +591,14 @@ func main() {
 				CheckGameStarted:  true,
 			}), controllers.TeamStatusMiddleware(), controllers.UserGetGameChallengeContainerInfo)
 
+			// 生成队伍的动态附件
+			userGameGroup.POST("/:game_id/attachment/:challenge_id", RateLimiter(100, 100*time.Millisecond), controllers.PayloadValidator(
+				webmodels.UserGenerateAttachmentPayload{},
+			), controllers.GameStatusMiddleware(controllers.GameStatusMiddlewareProps{
+				VisibleAfterEnded: false,
+				CheckGameStarted:  true,
+			}), controllers.TeamStatusMiddleware(), controllers.ChallengeStatusCheckMiddleWare(true), controllers.UserGenerateAttachment)
+
 			// 提交 Flag
 			userGameGroup.POST("/:game_id/flag/:challenge_id", ratelimiter.RateLimiter(100, 100*time.Millisecond), controllers.PayloadValidator(
 				webmodels.UserSubmitFlagPayload{},
@@ -488,6 +610,18 @@ func main() {
 				VisibleAfterEnded: false,
 				CheckGameStarted:  true,
 			}), controllers.TeamStatusMiddleware(), controllers.UserGameGetJudgeResult)
+
+			// 攻防模式
+			userGameGroup.POST("/:game_id/ad/flag", ratelimiter.RateLimiter(100, 100*time.Millisecond), controllers.PayloadValidator(
+				webmodels.UserSubmitFlagPayload{},
+			), controllers.GameStatusMiddleware(controllers.GameStatusMiddlewareProps{
+				VisibleAfterEnded: false,
+				CheckGameStarted:  true,
+			}), controllers.TeamStatusMiddleware(), controllers.AttackDefenseGameMiddleware(), controllers.UserADSubmitFlag)
+			userGameGroup.GET("/:game_id/ad/status", controllers.GameStatusMiddleware(controllers.GameStatusMiddlewareProps{
+				VisibleAfterEnded: false,
+				CheckGameStarted:  true,
+			}), controllers.TeamStatusMiddleware(), controllers.AttackDefenseGameMiddleware(), controllers.UserADGetStatus)
 		}
 
 		// 实时通知服务
@@ -499,10 +633,14 @@ func main() {
 				return
 			}
 
-			// 处理WebSocket连接
-			dbtool.Melody().HandleRequestWithKeys(c.Writer, c.Request, map[string]interface{}{
+			user := c.MustGet("user").(models.User)
+			keys := map[string]interface{}{
 				"gameID": gameID,
-			})
+				"userID": user.UserID,
+			}
+
+			// 处理WebSocket连接
+			dbtool.Melody().HandleRequestWithKeys(c.Writer, c.Request, keys)
 		})
 
 		// 容器 Exec /bin/sh
@@ -0,0 +1,769 @@
+package anticheat
+
+import (
+	"a1ctf/src/db/models"
+	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/utils/zaphelper"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// IP 的来源，提交记录里的 IP 比登录和注册时的 IP 更可信
+const (
+	ipSourceSubmit   = "submit"
+	ipSourceLogin    = "login"
+	ipSourceRegister = "register"
+)
+
+// 关联分析的阈值，没有配置时使用默认值
+type correlationConfig struct {
+	lookback time.Duration
+	// 场馆的出口、代理等大量队伍共用的地址
+	ignoreNets []*net.IPNet
+	// 超过这么多队伍共用的 IP、网段和错误 flag 不再记录
+	maxTeamsPerIP        int
+	maxTeamsPerWrongFlag int
+	solveWindow          time.Duration
+	minSyncedSolves      int
+	wrongFlagMinLength   int
+}
+
+func loadCorrelationConfig() correlationConfig {
+	config := correlationConfig{
+		lookback:             viper.GetDuration("anti-cheat.correlation.lookback"),
+		maxTeamsPerIP:        viper.GetInt("anti-cheat.correlation.max-teams-per-ip"),
+		maxTeamsPerWrongFlag: viper.GetInt("anti-cheat.correlation.max-teams-per-wrong-flag"),
+		solveWindow:          viper.GetDuration("anti-cheat.correlation.solve-window"),
+		minSyncedSolves:      viper.GetInt("anti-cheat.correlation.min-synced-solves"),
+		wrongFlagMinLength:   viper.GetInt("anti-cheat.correlation.wrong-flag-min-length"),
+	}
+	if config.lookback <= 0 {
+		config.lookback = 24 * time.Hour
+	}
+	if config.maxTeamsPerIP <= 1 {
+		config.maxTeamsPerIP = 4
+	}
+	if config.maxTeamsPerWrongFlag <= 1 {
+		config.maxTeamsPerWrongFlag = 5
+	}
+	if config.solveWindow <= 0 {
+		config.solveWindow = 30 * time.Second
+	}
+	if config.minSyncedSolves <= 1 {
+		config.minSyncedSolves = 3
+	}
+	if config.wrongFlagMinLength <= 0 {
+		config.wrongFlagMinLength = 6
+	}
+
+	for _, item := range viper.GetStringSlice("anti-cheat.correlation.ignore-ips") {
+		if !strings.Contains(item, "/") {
+			if ip := net.ParseIP(item); ip != nil && ip.To4() != nil {
+				item += "/32"
+			} else {
+				item += "/128"
+			}
+		}
+		_, ipNet, err := net.ParseCIDR(item)
+		if err != nil {
+			zaphelper.Logger.Warn("Invalid anti-cheat ignore ip", zap.String("ip", item))
+			continue
+		}
+		config.ignoreNets = append(config.ignoreNets, ipNet)
+	}
+
+	return config
+}
+
+func (c *correlationConfig) ignored(ip net.IP) bool {
+	for _, ipNet := range c.ignoreNets {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+// finding 一条关联分析的结果，两支队伍各记一条，RelevantTeam 是对方
+type finding struct {
+	cheatType    models.CheatType
+	teamID       int64
+	relevantTeam int64
+	// 同一对队伍同一种类型下区分不同证据，比如不同的 IP
+	key         string
+	confidence  float64
+	evidence    map[string]interface{}
+	cheatTime   time.Time
+	ingameID    *int64
+	challengeID *int64
+	judgeID     *string
+	submiterID  *string
+	submiterIP  *string
+}
+
+type correlation struct {
+	config   correlationConfig
+	gameID   int64
+	teams    map[int64]models.Team
+	findings []finding
+}
+
+// Correlate 分析进行中和最近结束的比赛，攻防比赛的提交本来就会互相关联，不分析
+func Correlate() {
+	config := loadCorrelationConfig()
+
+	now := time.Now().UTC()
+	var games []models.Game
+	if err := dbtool.DB().Select("game_id", "game_mode").Where("start_time < ? AND end_time > ?", now, now.Add(-config.lookback)).Find(&games).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load games for anti-cheat correlation", zap.Error(err))
+		return
+	}
+
+	for _, game := range games {
+		if game.GameMode == models.GameModeAttackDefense {
+			continue
+		}
+		if _, err := correlateGame(config, game.GameID); err != nil {
+			zaphelper.Logger.Error("Failed to correlate game", zap.Error(err), zap.Int64("game_id", game.GameID))
+		}
+	}
+}
+
+// CorrelateGame 立即分析一场比赛，返回这次分析得到的记录数
+func CorrelateGame(gameID int64) (int, error) {
+	return correlateGame(loadCorrelationConfig(), gameID)
+}
+
+func correlateGame(config correlationConfig, gameID int64) (int, error) {
+	var teams []models.Team
+	if err := dbtool.DB().Select("team_id", "team_name", "team_members").
+		Where("game_id = ? AND team_type != ?", gameID, models.TeamTypeAdmin).Find(&teams).Error; err != nil {
+		return 0, err
+	}
+	if len(teams) < 2 {
+		return 0, nil
+	}
+
+	c := &correlation{config: config, gameID: gameID, teams: make(map[int64]models.Team, len(teams))}
+	for _, team := range teams {
+		c.teams[team.TeamID] = team
+	}
+
+	for _, detect := range []func() error{c.sharedIPs, c.syncedSolves, c.sameWrongFlags, c.sharedAccounts} {
+		if err := detect(); err != nil {
+			return 0, err
+		}
+	}
+
+	return len(c.findings), c.save()
+}
+
+// pair 给两支队伍各加一条记录，sides 是两边各自的证据
+func (c *correlation) pair(base finding, teamA int64, teamB int64, sideA map[string]interface{}, sideB map[string]interface{}) {
+	a, b := base, base
+	a.teamID, a.relevantTeam = teamA, teamB
+	b.teamID, b.relevantTeam = teamB, teamA
+	a.evidence, b.evidence = mergeEvidence(base.evidence, sideA, sideB), mergeEvidence(base.evidence, sideB, sideA)
+	c.findings = append(c.findings, a, b)
+}
+
+func mergeEvidence(common map[string]interface{}, own map[string]interface{}, relevant map[string]interface{}) map[string]interface{} {
+	evidence := make(map[string]interface{}, len(common)+len(own)+len(relevant))
+	for k, v := range common {
+		evidence[k] = v
+	}
+	for k, v := range own {
+		evidence[k] = v
+	}
+	for k, v := range relevant {
+		evidence["relevant_"+k] = v
+	}
+	return evidence
+}
+
+func (c *correlation) validTeam(teamID int64) bool {
+	_, ok := c.teams[teamID]
+	return ok
+}
+
+// 所有队伍两两组合，按 team_id 排序保证同一对队伍只出现一次
+func teamPairs(teamIDs []int64) [][2]int64 {
+	sort.Slice(teamIDs, func(i, j int) bool { return teamIDs[i] < teamIDs[j] })
+	pairs := make([][2]int64, 0)
+	for i := range teamIDs {
+		for j := i + 1; j < len(teamIDs); j++ {
+			pairs = append(pairs, [2]int64{teamIDs[i], teamIDs[j]})
+		}
+	}
+	return pairs
+}
+
+type ipUse struct {
+	sources    map[string]bool
+	judgeID    *string
+	submiterID *string
+	lastTime   time.Time
+}
+
+func (u *ipUse) sourceList() []string {
+	sources := make([]string, 0, len(u.sources))
+	for source := range u.sources {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	return sources
+}
+
+// 不同队伍的提交 IP、队员的登录和注册 IP 相同，或者提交 IP 在同一个 /24 网段
+func (c *correlation) sharedIPs() error {
+	var judgeIPs []struct {
+		TeamID     int64
+		SubmiterIP string
+		JudgeID    string
+		SubmiterID string
+		LastTime   time.Time
+	}
+	if err := dbtool.DB().Model(&models.Judge{}).
+		Select("team_id, submiter_ip, (array_agg(judge_id ORDER BY judge_time DESC))[1] AS judge_id, (array_agg(submiter_id ORDER BY judge_time DESC))[1] AS submiter_id, max(judge_time) AS last_time").
+		Where("game_id = ? AND submiter_ip IS NOT NULL AND submiter_ip != ''", c.gameID).
+		Group("team_id, submiter_ip").Scan(&judgeIPs).Error; err != nil {
+		return err
+	}
+
+	// ip -> team_id -> 使用记录
+	uses := make(map[string]map[int64]*ipUse)
+	use := func(ip string, teamID int64) *ipUse {
+		if uses[ip] == nil {
+			uses[ip] = make(map[int64]*ipUse)
+		}
+		if uses[ip][teamID] == nil {
+			uses[ip][teamID] = &ipUse{sources: make(map[string]bool)}
+		}
+		return uses[ip][teamID]
+	}
+
+	for _, row := range judgeIPs {
+		if !c.validTeam(row.TeamID) {
+			continue
+		}
+		u := use(row.SubmiterIP, row.TeamID)
+		u.sources[ipSourceSubmit] = true
+		u.judgeID, u.submiterID, u.lastTime = &row.JudgeID, &row.SubmiterID, row.LastTime
+	}
+
+	teamOfUser := make(map[string]int64)
+	userIDs := make([]string, 0)
+	for _, team := range c.teams {
+		for _, member := range team.TeamMembers {
+			teamOfUser[member] = team.TeamID
+			userIDs = append(userIDs, member)
+		}
+	}
+	if len(userIDs) > 0 {
+		var users []models.User
+		if err := dbtool.DB().Select("user_id", "last_login_ip", "register_ip").Where("user_id IN ?", userIDs).Find(&users).Error; err != nil {
+			return err
+		}
+		for _, user := range users {
+			if user.LastLoginIP != nil && *user.LastLoginIP != "" {
+				use(*user.LastLoginIP, teamOfUser[user.UserID]).sources[ipSourceLogin] = true
+			}
+			if user.RegisterIP != nil && *user.RegisterIP != "" {
+				use(*user.RegisterIP, teamOfUser[user.UserID]).sources[ipSourceRegister] = true
+			}
+		}
+	}
+
+	// 共用同一个 IP 的队伍不再按网段记录
+	sameIP := make(map[[2]int64]bool)
+	// 网段 -> team_id -> 提交过的 IP
+	type subnetUse struct {
+		ips      []string
+		lastTime time.Time
+	}
+	subnets := make(map[string]map[int64]*subnetUse)
+
+	for ip, teamUses := range uses {
+		parsed := net.ParseIP(ip)
+		if parsed == nil || c.config.ignored(parsed) {
+			continue
+		}
+
+		if ipv4 := parsed.To4(); ipv4 != nil {
+			subnet := fmt.Sprintf("%d.%d.%d.0/24", ipv4[0], ipv4[1], ipv4[2])
+			for teamID, u := range teamUses {
+				if u.sources[ipSourceSubmit] {
+					if subnets[subnet] == nil {
+						subnets[subnet] = make(map[int64]*subnetUse)
+					}
+					if subnets[subnet][teamID] == nil {
+						subnets[subnet][teamID] = &subnetUse{}
+					}
+					su := subnets[subnet][teamID]
+					su.ips = append(su.ips, ip)
+					su.lastTime = laterTime(su.lastTime, u.lastTime)
+				}
+			}
+		}
+
+		if len(teamUses) < 2 || len(teamUses) > c.config.maxTeamsPerIP {
+			continue
+		}
+
+		teamIDs := make([]int64, 0, len(teamUses))
+		for teamID := range teamUses {
+			teamIDs = append(teamIDs, teamID)
+		}
+		for _, pair := range teamPairs(teamIDs) {
+			a, b := teamUses[pair[0]], teamUses[pair[1]]
+			sameIP[pair] = true
+
+			// 双方都从这个 IP 提交过时最可信
+			confidence := 0.6
+			if a.sources[ipSourceSubmit] && b.sources[ipSourceSubmit] {
+				confidence = 0.8
+			}
+
+			base := finding{
+				cheatType:  models.CheatSharedIP,
+				key:        ip,
+				confidence: confidence,
+				evidence:   map[string]interface{}{"ip": ip},
+				cheatTime:  laterTime(a.lastTime, b.lastTime),
+				submiterIP: &ip,
+			}
+			c.pair(base, pair[0], pair[1],
+				map[string]interface{}{"sources": a.sourceList()},
+				map[string]interface{}{"sources": b.sourceList()})
+			c.attachJudge(a, b)
+		}
+	}
+
+	for subnet, teamUses := range subnets {
+		if len(teamUses) < 2 || len(teamUses) > c.config.maxTeamsPerIP {
+			continue
+		}
+
+		teamIDs := make([]int64, 0, len(teamUses))
+		for teamID := range teamUses {
+			teamIDs = append(teamIDs, teamID)
+		}
+		for _, pair := range teamPairs(teamIDs) {
+			if sameIP[pair] {
+				continue
+			}
+			a, b := teamUses[pair[0]], teamUses[pair[1]]
+			sort.Strings(a.ips)
+			sort.Strings(b.ips)
+			base := finding{
+				cheatType:  models.CheatSharedSubnet,
+				key:        subnet,
+				confidence: 0.3,
+				evidence:   map[string]interface{}{"subnet": subnet},
+				cheatTime:  laterTime(a.lastTime, b.lastTime),
+			}
+			c.pair(base, pair[0], pair[1],
+				map[string]interface{}{"ips": a.ips},
+				map[string]interface{}{"ips": b.ips})
+		}
+	}
+
+	return nil
+}
+
+// attachJudge 把两边最近一次从这个 IP 的提交关联到刚加入的两条记录上
+func (c *correlation) attachJudge(a *ipUse, b *ipUse) {
+	for idx, u := range []*ipUse{a, b} {
+		f := &c.findings[len(c.findings)-2+idx]
+		f.judgeID, f.submiterID = u.judgeID, u.submiterID
+		if !u.lastTime.IsZero() {
+			f.cheatTime = u.lastTime
+		}
+	}
+}
+
+func laterTime(a time.Time, b time.Time) time.Time {
+	if a.After(b) {
+		return a
+	}
+	if b.IsZero() {
+		return time.Now().UTC()
+	}
+	return b
+}
+
+type solveMatch struct {
+	ingameID    int64
+	challengeID int64
+	timeA       time.Time
+	timeB       time.Time
+}
+
+// 两支队伍按相同的顺序解出多道相同的题目，每道题的时间差都在窗口内
+func (c *correlation) syncedSolves() error {
+	var solves []models.Solve
+	if err := dbtool.DB().Select("team_id", "ingame_id", "challenge_id", "solve_time").
+		Where("game_id = ? AND solve_status = ?", c.gameID, models.SolveCorrect).
+		Order("solve_time ASC").Find(&solves).Error; err != nil {
+		return err
+	}
+
+	byChallenge := make(map[int64][]models.Solve)
+	for _, solve := range solves {
+		if c.validTeam(solve.TeamID) {
+			byChallenge[solve.IngameID] = append(byChallenge[solve.IngameID], solve)
+		}
+	}
+
+	matches := make(map[[2]int64][]solveMatch)
+	for _, challengeSolves := range byChallenge {
+		for i := range challengeSolves {
+			for j := i + 1; j < len(challengeSolves); j++ {
+				a, b := challengeSolves[i], challengeSolves[j]
+				if b.SolveTime.Sub(a.SolveTime) > c.config.solveWindow {
+					break
+				}
+				if a.TeamID == b.TeamID {
+					continue
+				}
+				if a.TeamID > b.TeamID {
+					a, b = b, a
+				}
+				key := [2]int64{a.TeamID, b.TeamID}
+				matches[key] = append(matches[key], solveMatch{
+					ingameID:    a.IngameID,
+					challengeID: a.ChallengeID,
+					timeA:       a.SolveTime,
+					timeB:       b.SolveTime,
+				})
+			}
+		}
+	}
+
+	for pair, pairMatches := range matches {
+		if len(pairMatches) < c.config.minSyncedSolves {
+			continue
+		}
+
+		synced := longestSyncedSequence(pairMatches)
+		if len(synced) < c.config.minSyncedSolves {
+			continue
+		}
+
+		// 两边各自看到的解题时间和时间差方向相反
+		challengesA := make([]map[string]interface{}, 0, len(synced))
+		challengesB := make([]map[string]interface{}, 0, len(synced))
+		for _, match := range synced {
+			delay := match.timeB.Sub(match.timeA).Seconds()
+			challengesA = append(challengesA, map[string]interface{}{
+				"challenge_id":  match.challengeID,
+				"ingame_id":     match.ingameID,
+				"time":          match.timeA,
+				"relevant_time": match.timeB,
+				"delay_seconds": delay,
+			})
+			challengesB = append(challengesB, map[string]interface{}{
+				"challenge_id":  match.challengeID,
+				"ingame_id":     match.ingameID,
+				"time":          match.timeB,
+				"relevant_time": match.timeA,
+				"delay_seconds": -delay,
+			})
+		}
+		last := synced[len(synced)-1]
+
+		base := finding{
+			cheatType:  models.CheatSyncedSolves,
+			confidence: min(0.95, 0.5+0.1*float64(len(synced)-c.config.minSyncedSolves)),
+			evidence:   map[string]interface{}{"count": len(synced), "window_seconds": c.config.solveWindow.Seconds()},
+			cheatTime:  laterTime(last.timeA, last.timeB),
+		}
+		c.pair(base, pair[0], pair[1], nil, nil)
+		c.findings[len(c.findings)-2].evidence["challenges"] = challengesA
+		c.findings[len(c.findings)-1].evidence["challenges"] = challengesB
+	}
+
+	return nil
+}
+
+// longestSyncedSequence 按第一支队伍的解题时间排序后，第二支队伍解题时间递增的最长子序列
+func longestSyncedSequence(matches []solveMatch) []solveMatch {
+	sort.Slice(matches, func(i, j int) bool { return matches[i].timeA.Before(matches[j].timeA) })
+
+	length := make([]int, len(matches))
+	prev := make([]int, len(matches))
+	best := -1
+	for i := range matches {
+		length[i], prev[i] = 1, -1
+		for j := 0; j < i; j++ {
+			if matches[j].ingameID != matches[i].ingameID && matches[j].timeB.Before(matches[i].timeB) && length[j]+1 > length[i] {
+				length[i], prev[i] = length[j]+1, j
+			}
+		}
+		if best == -1 || length[i] > length[best] {
+			best = i
+		}
+	}
+
+	result := make([]solveMatch, 0)
+	for i := best; i >= 0; i = prev[i] {
+		result = append(result, matches[i])
+	}
+	for i, j := 0, len(result)-1; i < j; i, j = i+1, j-1 {
+		result[i], result[j] = result[j], result[i]
+	}
+	return result
+}
+
+// 队伍第一次提交某个错误 flag 的记录
+type wrongFlagRow struct {
+	IngameID     int64
+	ChallengeID  int64
+	JudgeContent string
+	TeamID       int64
+	JudgeID      string
+	SubmiterID   string
+	SubmiterIP   *string
+	FirstTime    time.Time
+}
+
+// 不同队伍在同一道题提交了完全相同的错误 flag，太短的和太多队伍提交的不算
+func (c *correlation) sameWrongFlags() error {
+	var rows []wrongFlagRow
+
+	shared := dbtool.DB().Model(&models.Judge{}).Select("ingame_id, judge_content").
+		Where("game_id = ? AND judge_status = ? AND char_length(judge_content) >= ?", c.gameID, models.JudgeWA, c.config.wrongFlagMinLength).
+		Group("ingame_id, judge_content").
+		Having("count(DISTINCT team_id) BETWEEN 2 AND ?", c.config.maxTeamsPerWrongFlag)
+
+	if err := dbtool.DB().Model(&models.Judge{}).
+		Select("ingame_id, challenge_id, judge_content, team_id, (array_agg(judge_id ORDER BY judge_time))[1] AS judge_id, (array_agg(submiter_id ORDER BY judge_time))[1] AS submiter_id, (array_agg(submiter_ip ORDER BY judge_time))[1] AS submiter_ip, min(judge_time) AS first_time").
+		Where("game_id = ? AND judge_status = ? AND (ingame_id, judge_content) IN (?)", c.gameID, models.JudgeWA, shared).
+		Group("ingame_id, challenge_id, judge_content, team_id").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	type flagKey struct {
+		ingameID int64
+		content  string
+	}
+	groups := make(map[flagKey][]int)
+	for idx, row := range rows {
+		if c.validTeam(row.TeamID) {
+			key := flagKey{ingameID: row.IngameID, content: row.JudgeContent}
+			groups[key] = append(groups[key], idx)
+		}
+	}
+
+	for key, indexes := range groups {
+		if len(indexes) < 2 {
+			continue
+		}
+
+		byTeam := make(map[int64]int, len(indexes))
+		teamIDs := make([]int64, 0, len(indexes))
+		for _, idx := range indexes {
+			byTeam[rows[idx].TeamID] = idx
+			teamIDs = append(teamIDs, rows[idx].TeamID)
+		}
+
+		content := []rune(key.content)
+		if len(content) > 128 {
+			content = append(content[:128], []rune("...")...)
+		}
+
+		for _, pair := range teamPairs(teamIDs) {
+			a, b := rows[byTeam[pair[0]]], rows[byTeam[pair[1]]]
+			base := finding{
+				cheatType:   models.CheatSameWrongFlag,
+				key:         fmt.Sprintf("%d:%s", key.ingameID, key.content),
+				confidence:  max(0.3, 0.9-0.15*float64(len(teamIDs)-2)),
+				evidence:    map[string]interface{}{"flag": string(content), "teams": len(teamIDs)},
+				ingameID:    &a.IngameID,
+				challengeID: &a.ChallengeID,
+			}
+			c.pair(base, pair[0], pair[1],
+				map[string]interface{}{"first_time": a.FirstTime},
+				map[string]interface{}{"first_time": b.FirstTime})
+
+			for idx, row := range []wrongFlagRow{a, b} {
+				f := &c.findings[len(c.findings)-2+idx]
+				f.judgeID, f.submiterID, f.submiterIP, f.cheatTime = &row.JudgeID, &row.SubmiterID, row.SubmiterIP, row.FirstTime
+			}
+		}
+	}
+
+	return nil
+}
+
+// 同一个账号给不同的队伍提交过 flag，或者同时是多支队伍的成员
+func (c *correlation) sharedAccounts() error {
+	var rows []struct {
+		SubmiterID string
+		TeamID     int64
+		JudgeID    string
+		Judges     int64
+		LastTime   time.Time
+	}
+	if err := dbtool.DB().Model(&models.Judge{}).
+		Select("submiter_id, team_id, (array_agg(judge_id ORDER BY judge_time DESC))[1] AS judge_id, count(*) AS judges, max(judge_time) AS last_time").
+		Where("game_id = ?", c.gameID).
+		Group("submiter_id, team_id").Scan(&rows).Error; err != nil {
+		return err
+	}
+
+	type accountUse struct {
+		member   bool
+		judgeID  *string
+		judges   int64
+		lastTime time.Time
+	}
+	// user_id -> team_id -> 使用记录
+	accounts := make(map[string]map[int64]*accountUse)
+	use := func(userID string, teamID int64) *accountUse {
+		if accounts[userID] == nil {
+			accounts[userID] = make(map[int64]*accountUse)
+		}
+		if accounts[userID][teamID] == nil {
+			accounts[userID][teamID] = &accountUse{}
+		}
+		return accounts[userID][teamID]
+	}
+
+	for _, row := range rows {
+		if c.validTeam(row.TeamID) {
+			u := use(row.SubmiterID, row.TeamID)
+			u.judgeID, u.judges, u.lastTime = &row.JudgeID, row.Judges, row.LastTime
+		}
+	}
+	for _, team := range c.teams {
+		for _, member := range team.TeamMembers {
+			use(member, team.TeamID).member = true
+		}
+	}
+
+	userIDs := make([]string, 0)
+	for userID, teamUses := range accounts {
+		if len(teamUses) > 1 {
+			userIDs = append(userIDs, userID)
+		}
+	}
+	if len(userIDs) == 0 {
+		return nil
+	}
+
+	var users []models.User
+	if err := dbtool.DB().Select("user_id", "username").Where("user_id IN ?", userIDs).Find(&users).Error; err != nil {
+		return err
+	}
+	usernames := make(map[string]string, len(users))
+	for _, user := range users {
+		usernames[user.UserID] = user.Username
+	}
+
+	for _, userID := range userIDs {
+		teamUses := accounts[userID]
+		teamIDs := make([]int64, 0, len(teamUses))
+		for teamID := range teamUses {
+			teamIDs = append(teamIDs, teamID)
+		}
+
+		for _, pair := range teamPairs(teamIDs) {
+			a, b := teamUses[pair[0]], teamUses[pair[1]]
+
+			// 同时是两支队伍的成员可能是换了队伍，给不是自己队伍的提交更可疑
+			confidence := 0.7
+			if a.judges > 0 && b.judges > 0 {
+				confidence = 0.9
+			}
+
+			submiterID := userID
+			base := finding{
+				cheatType:  models.CheatSharedAccount,
+				key:        userID,
+				confidence: confidence,
+				evidence:   map[string]interface{}{"user_id": userID, "username": usernames[userID]},
+				cheatTime:  laterTime(a.lastTime, b.lastTime),
+				submiterID: &submiterID,
+			}
+			c.pair(base, pair[0], pair[1],
+				map[string]interface{}{"member": a.member, "judges": a.judges},
+				map[string]interface{}{"member": b.member, "judges": b.judges})
+
+			for idx, u := range []*accountUse{a, b} {
+				f := &c.findings[len(c.findings)-2+idx]
+				f.judgeID = u.judgeID
+				if !u.lastTime.IsZero() {
+					f.cheatTime = u.lastTime
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func (f *finding) fingerprint(gameID int64) string {
+	sum := sha256.Sum256([]byte(fmt.Sprintf("%s:%d:%d:%d:%s", f.cheatType, gameID, f.teamID, f.relevantTeam, f.key)))
+	return hex.EncodeToString(sum[:])
+}
+
+// 按指纹写入，已有的记录更新证据和置信度，时间保留第一次发现时的
+func (c *correlation) save() error {
+	if len(c.findings) == 0 {
+		return nil
+	}
+
+	cheats := make([]models.Cheat, 0, len(c.findings))
+	seen := make(map[string]bool, len(c.findings))
+	for _, f := range c.findings {
+		fingerprint := f.fingerprint(c.gameID)
+		if seen[fingerprint] {
+			continue
+		}
+		seen[fingerprint] = true
+
+		cheats = append(cheats, models.Cheat{
+			CheatID:     uuid.NewString(),
+			CheatType:   f.cheatType,
+			GameID:      c.gameID,
+			IngameID:    f.ingameID,
+			ChallengeID: f.challengeID,
+			TeamID:      f.teamID,
+			JudgeID:     f.judgeID,
+			SubmiterID:  f.submiterID,
+			SubmiterIP:  f.submiterIP,
+			CheatTime:   f.cheatTime,
+			Fingerprint: &fingerprint,
+			ExtraData: models.CheatExtraData{
+				RelevantTeam:     f.relevantTeam,
+				RelevantTeamName: c.teams[f.relevantTeam].TeamName,
+				Confidence:       f.confidence,
+				Evidence:         f.evidence,
+			},
+		})
+	}
+
+	updates := clause.AssignmentColumns([]string{"extra_data", "judge_id", "submiter_id", "submiter_ip"})
+	// 置信度和证据变化后重新交给处罚规则，已经执行过的处罚不会重复执行
+	updates = append(updates, clause.Assignment{
+		Column: clause.Column{Name: "enforced_at"},
+		Value:  gorm.Expr("CASE WHEN cheats.extra_data IS DISTINCT FROM excluded.extra_data THEN NULL ELSE cheats.enforced_at END"),
+	})
+
+	return dbtool.DB().Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "fingerprint"}},
+		DoUpdates: updates,
+	}).CreateInBatches(&cheats, 500).Error
+}
@@ -0,0 +1,107 @@
+package anticheat
+
+import (
+	"a1ctf/src/db/models"
+	dbtool "a1ctf/src/utils/db_tool"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type challengeKey struct {
+	gameID      int64
+	challengeID int64
+}
+
+// 文件属于哪些比赛里的题目，静态附件按题目的 attach_hash 找，动态附件按队伍生成的文件找
+func attachmentChallenges(fileID string) ([]challengeKey, error) {
+	keys := make([]challengeKey, 0)
+	seen := make(map[challengeKey]bool)
+	add := func(gameID int64, challengeID int64) {
+		key := challengeKey{gameID: gameID, challengeID: challengeID}
+		if !seen[key] {
+			seen[key] = true
+			keys = append(keys, key)
+		}
+	}
+
+	var teamAttachments []models.TeamAttachment
+	if err := dbtool.DB().Select("game_id", "challenge_id").Where("file_id = ?", fileID).Find(&teamAttachments).Error; err != nil {
+		return nil, err
+	}
+	for _, attachment := range teamAttachments {
+		add(attachment.GameID, attachment.ChallengeID)
+	}
+
+	containment, _ := sonic.Marshal([]map[string]string{{"attach_hash": fileID}})
+	var challengeIDs []int64
+	if err := dbtool.DB().Model(&models.Challenge{}).Where("attachments @> ?::jsonb", string(containment)).Pluck("challenge_id", &challengeIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(challengeIDs) > 0 {
+		var gameChallenges []models.GameChallenge
+		if err := dbtool.DB().Select("game_id", "challenge_id").Where("challenge_id IN ?", challengeIDs).Find(&gameChallenges).Error; err != nil {
+			return nil, err
+		}
+		for _, gameChallenge := range gameChallenges {
+			add(gameChallenge.GameID, gameChallenge.ChallengeID)
+		}
+	}
+
+	return keys, nil
+}
+
+// RecordDownload 记录用户下载了题目附件，记在用户在对应比赛里的队伍下，不是附件的文件不记录
+func RecordDownload(fileID string, userID string, ip string, now time.Time) error {
+	keys, err := attachmentChallenges(fileID)
+	if err != nil || len(keys) == 0 {
+		return err
+	}
+
+	gameIDs := make([]int64, 0, len(keys))
+	for _, key := range keys {
+		gameIDs = append(gameIDs, key.gameID)
+	}
+
+	var teams []models.Team
+	if err := dbtool.DB().Select("team_id", "game_id").Where("game_id IN ? AND ? = ANY(team_members)", gameIDs, userID).Find(&teams).Error; err != nil {
+		return err
+	}
+	teamOfGame := make(map[int64]int64, len(teams))
+	for _, team := range teams {
+		teamOfGame[team.GameID] = team.TeamID
+	}
+
+	records := make([]models.AttachmentDownload, 0, len(keys))
+	for _, key := range keys {
+		teamID, ok := teamOfGame[key.gameID]
+		if !ok {
+			continue
+		}
+		records = append(records, models.AttachmentDownload{
+			GameID:            key.gameID,
+			ChallengeID:       key.challengeID,
+			TeamID:            teamID,
+			UserID:            userID,
+			FileID:            fileID,
+			DownloadCount:     1,
+			FirstDownloadTime: now,
+			LastDownloadTime:  now,
+			LastDownloadIP:    &ip,
+		})
+	}
+	if len(records) == 0 {
+		return nil
+	}
+
+	return dbtool.DB().Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "game_id"}, {Name: "challenge_id"}, {Name: "team_id"}, {Name: "user_id"}, {Name: "file_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"download_count":     gorm.Expr("attachment_downloads.download_count + 1"),
+			"last_download_time": now,
+			"last_download_ip":   ip,
+		}),
+	}).Create(&records).Error
+}
@@ -0,0 +1,183 @@
+package anticheat
+
+import (
+	"a1ctf/src/db/models"
+	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/utils/general"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// 队伍 canary 在作弊记录里的来源
+const canarySource = "canary"
+
+// Bait 提交命中的诱饵
+type Bait struct {
+	Source string
+	// canary 所属的队伍，诱饵 flag 为 0
+	TeamID   int64
+	TeamName string
+}
+
+// IsCanary 命中的是队伍的 canary
+func (b *Bait) IsCanary() bool {
+	return b.TeamID != 0
+}
+
+// ValidateHoneypot 诱饵不能为空，也不能被题目的判题配置判为正确
+func ValidateHoneypot(config *models.HoneypotConfig, judgeConfig *models.JudgeConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	seen := make(map[string]bool, len(config.Flags))
+	for _, bait := range config.Flags {
+		if strings.TrimSpace(bait.Flag) == "" {
+			return errors.New("honeypot flag must not be empty")
+		}
+		if seen[bait.Flag] {
+			return fmt.Errorf("duplicate honeypot flag %s", bait.Flag)
+		}
+		seen[bait.Flag] = true
+		if general.MatchStaticFlag(judgeConfig, bait.Flag) {
+			return fmt.Errorf("honeypot flag %s is accepted by the judge config", bait.Flag)
+		}
+	}
+
+	if config.CanaryEnabled && canaryTemplate(config, judgeConfig) == "" {
+		return errors.New("canary template is required when the challenge has no flag template")
+	}
+
+	return nil
+}
+
+func canaryTemplate(config *models.HoneypotConfig, judgeConfig *models.JudgeConfig) string {
+	if config.CanaryTemplate != "" {
+		return config.CanaryTemplate
+	}
+	if judgeConfig != nil && judgeConfig.FlagTemplate != nil {
+		return *judgeConfig.FlagTemplate
+	}
+	return ""
+}
+
+// MatchBait 提交的内容是不是诱饵 flag 或者某支队伍的 canary，匹配方式和判题保持一致
+func MatchBait(gameChallenge *models.GameChallenge, content string) (*Bait, error) {
+	config := gameChallenge.Honeypot
+	if config == nil {
+		return nil, nil
+	}
+
+	judgeConfig := gameChallenge.JudgeConfig
+	// 诱饵和动态 flag 一样按字面量比较
+	for _, bait := range config.Flags {
+		if general.MatchDynamicFlag(judgeConfig, bait.Flag, content) {
+			return &Bait{Source: bait.Source}, nil
+		}
+	}
+
+	if !config.CanaryEnabled {
+		return nil, nil
+	}
+
+	normalized := general.NormalizeFlag(judgeConfig, content)
+	query := dbtool.DB().Model(&models.TeamCanary{}).Where("ingame_id = ?", gameChallenge.IngameID)
+	if general.FlagCaseInsensitive(judgeConfig) {
+		query = query.Where("LOWER(canary) = LOWER(?)", normalized)
+	} else {
+		query = query.Where("canary = ?", normalized)
+	}
+
+	var canary models.TeamCanary
+	if err := query.Preload("Team").First(&canary).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	return &Bait{Source: canarySource, TeamID: canary.TeamID, TeamName: canary.Team.TeamName}, nil
+}
+
+// TeamCanary 队伍在这道题的 canary，第一次使用时生成，没有开启时返回空字符串
+// gameChallenge 需要预加载 Challenge，静态 flag 的题目要避开题目的 flag
+func TeamCanary(gameChallenge *models.GameChallenge, team *models.Team) (string, error) {
+	config := gameChallenge.Honeypot
+	if config == nil || !config.CanaryEnabled {
+		return "", nil
+	}
+
+	var existing models.TeamCanary
+	err := dbtool.DB().Where("ingame_id = ? AND team_id = ?", gameChallenge.IngameID, team.TeamID).First(&existing).Error
+	if err == nil {
+		return existing.Canary, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return "", err
+	}
+
+	template := canaryTemplate(config, gameChallenge.JudgeConfig)
+	if template == "" {
+		return "", errors.New("no template to generate canary")
+	}
+
+	// canary 不能和队伍的 flag、题目的静态 flag 以及诱饵 flag 相同
+	var flags []string
+	if err := dbtool.DB().Model(&models.TeamFlag{}).Where("game_id = ? AND challenge_id = ?", gameChallenge.GameID, gameChallenge.ChallengeID).Pluck("flag_content", &flags).Error; err != nil {
+		return "", err
+	}
+
+	for range 100 {
+		canary := general.ProcessFlag(template, map[string]string{
+			"team_id":      fmt.Sprintf("%d", team.TeamID),
+			"game_id":      fmt.Sprintf("%d", gameChallenge.GameID),
+			"challenge_id": fmt.Sprintf("%d", gameChallenge.ChallengeID),
+			"team_hash":    team.TeamHash,
+			"team_name":    team.TeamName,
+		}, true)
+		if slices.Contains(flags, canary) || conflictsWithFlag(gameChallenge, canary) {
+			continue
+		}
+
+		err := dbtool.DB().Create(&models.TeamCanary{
+			GameID:      gameChallenge.GameID,
+			IngameID:    gameChallenge.IngameID,
+			ChallengeID: gameChallenge.ChallengeID,
+			TeamID:      team.TeamID,
+			Canary:      canary,
+			CreateTime:  time.Now().UTC(),
+		}).Error
+		if err == nil {
+			return canary, nil
+		}
+		if !errors.Is(err, gorm.ErrDuplicatedKey) {
+			return "", err
+		}
+
+		// 并发生成时用已经写入的那个
+		if err := dbtool.DB().Where("ingame_id = ? AND team_id = ?", gameChallenge.IngameID, team.TeamID).First(&existing).Error; err == nil {
+			return existing.Canary, nil
+		}
+	}
+
+	return "", fmt.Errorf("leet space is not enough for canary template %s", template)
+}
+
+// canary 会被判为正确或者被当成诱饵 flag 时不能使用
+func conflictsWithFlag(gameChallenge *models.GameChallenge, canary string) bool {
+	judgeConfig := gameChallenge.JudgeConfig
+	if gameChallenge.Challenge.FlagType == models.FlagTypeStatic && general.MatchStaticFlag(judgeConfig, canary) {
+		return true
+	}
+	for _, bait := range gameChallenge.Honeypot.Flags {
+		if general.MatchDynamicFlag(judgeConfig, bait.Flag, canary) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,426 @@
+package attackdefense
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	scriptjudge "a1ctf/src/modules/script_judge"
+	"a1ctf/src/utils/general"
+
+	"github.com/bytedance/sonic"
+	"github.com/spf13/viper"
+	"go.starlark.net/lib/json"
+	"go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// checker 脚本的入口函数名，脚本需要定义 def check(service): ...
+const checkerEntryFunction = "check"
+
+// checker 在判题脚本的沙箱子进程里执行，这是注册的任务名
+const checkerWorkerKind = "attack-defense-checker"
+
+// 网络请求最多读取的字节数
+const maxResponseBytes = 1024 * 1024
+
+var ErrCheckerTimeout = errors.New("checker time limit exceeded")
+
+var checkerPredeclared = starlark.StringDict{
+	"json":          json.Module,
+	"math":          math.Module,
+	"http":          httpModule,
+	"tcp":           tcpModule,
+	"random_string": starlark.NewBuiltin("random_string", randomString),
+}
+
+var checkerFileOptions = &syntax.FileOptions{
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+}
+
+// 和判题脚本不同，checker 要等网络，不能串行执行，所以只限制步数和时间
+func checkerLimits() (uint64, time.Duration) {
+	steps := viper.GetUint64("attack-defense.checker-max-steps")
+	if steps == 0 {
+		steps = 10000000
+	}
+	timeout := viper.GetDuration("attack-defense.checker-timeout")
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	return steps, timeout
+}
+
+// ServiceTarget 是传给 checker 的服务信息
+type ServiceTarget struct {
+	Host   string
+	Port   int32
+	Ports  map[string]int32
+	Flag   string
+	Round  int32
+	TeamID int64
+}
+
+type CheckResult struct {
+	Up      bool
+	Message string
+}
+
+// 发给子进程的 checker 任务，限制在父进程读取配置后一起传过去
+type checkerRequest struct {
+	Script   string
+	Target   ServiceTarget
+	Validate bool
+	MaxSteps uint64
+	Timeout  time.Duration
+}
+
+func init() {
+	scriptjudge.RegisterWorkerHandler(checkerWorkerKind, handleCheckerTask)
+}
+
+// 子进程里执行 checker，校验失败通过 error 返回，运行结果序列化后返回
+func handleCheckerTask(payload []byte) ([]byte, error) {
+	var request checkerRequest
+	if err := sonic.Unmarshal(payload, &request); err != nil {
+		return nil, err
+	}
+
+	if request.Validate {
+		return nil, validateChecker(&request)
+	}
+
+	return sonic.Marshal(runChecker(&request))
+}
+
+func runCheckerWorker(request checkerRequest) ([]byte, error) {
+	request.MaxSteps, request.Timeout = checkerLimits()
+
+	payload, err := sonic.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	output, err := scriptjudge.RunInWorker(checkerWorkerKind, payload, request.Timeout)
+	if errors.Is(err, scriptjudge.ErrTimeLimitExceeded) {
+		return nil, ErrCheckerTimeout
+	}
+	return output, err
+}
+
+func compileChecker(script string) (*starlark.Program, error) {
+	_, prog, err := starlark.SourceProgramOptions(checkerFileOptions, "checker.star", script, checkerPredeclared.Has)
+	return prog, err
+}
+
+func newCheckerThread(ctx context.Context, maxSteps uint64) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name:  "checker",
+		Print: func(_ *starlark.Thread, _ string) {},
+		Load: func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+			return nil, fmt.Errorf("load(%q) is not allowed in checker scripts", module)
+		},
+	}
+	thread.SetMaxExecutionSteps(maxSteps)
+	thread.SetLocal("context", ctx)
+	return thread
+}
+
+// ValidateChecker 检查脚本能否编译并且定义了 check 函数，顶层代码同样在子进程里执行
+func ValidateChecker(script string) error {
+	_, err := runCheckerWorker(checkerRequest{Script: script, Validate: true})
+	return err
+}
+
+func validateChecker(request *checkerRequest) error {
+	prog, err := compileChecker(request.Script)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), request.Timeout)
+	defer cancel()
+
+	globals, err := prog.Init(newCheckerThread(ctx, request.MaxSteps), checkerPredeclared)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := globals[checkerEntryFunction].(starlark.Callable); !ok {
+		return fmt.Errorf("checker script must define function %s(service)", checkerEntryFunction)
+	}
+
+	return nil
+}
+
+// RunChecker 在沙箱子进程里对一个队伍的服务运行 checker，脚本出错也算服务异常
+func RunChecker(script string, target ServiceTarget) CheckResult {
+	output, err := runCheckerWorker(checkerRequest{Script: script, Target: target})
+	if err != nil {
+		return CheckResult{Up: false, Message: err.Error()}
+	}
+
+	var result CheckResult
+	if err := sonic.Unmarshal(output, &result); err != nil {
+		return CheckResult{Up: false, Message: fmt.Sprintf("invalid checker result: %v", err)}
+	}
+
+	return result
+}
+
+func runChecker(request *checkerRequest) CheckResult {
+	target := request.Target
+
+	prog, err := compileChecker(request.Script)
+	if err != nil {
+		return CheckResult{Up: false, Message: fmt.Sprintf("checker compile error: %v", err)}
+	}
+
+	maxSteps := request.MaxSteps
+	ctx, cancel := context.WithTimeout(context.Background(), request.Timeout)
+	defer cancel()
+
+	thread := newCheckerThread(ctx, maxSteps)
+
+	// 超时后中断脚本，网络请求本身也会跟着 context 结束
+	stop := context.AfterFunc(ctx, func() {
+		thread.Cancel("time limit exceeded")
+	})
+	defer stop()
+
+	result, err := func() (starlark.Value, error) {
+		globals, err := prog.Init(thread, checkerPredeclared)
+		if err != nil {
+			return nil, err
+		}
+
+		fn, ok := globals[checkerEntryFunction].(starlark.Callable)
+		if !ok {
+			return nil, fmt.Errorf("checker script must define function %s(service)", checkerEntryFunction)
+		}
+
+		ports := starlark.NewDict(len(target.Ports))
+		for name, port := range target.Ports {
+			_ = ports.SetKey(starlark.String(name), starlark.MakeInt(int(port)))
+		}
+
+		service := starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"host":    starlark.String(target.Host),
+			"port":    starlark.MakeInt(int(target.Port)),
+			"ports":   ports,
+			"flag":    starlark.String(target.Flag),
+			"round":   starlark.MakeInt(int(target.Round)),
+			"team_id": starlark.MakeInt64(target.TeamID),
+		})
+
+		return starlark.Call(thread, fn, starlark.Tuple{service}, nil)
+	}()
+
+	if err != nil {
+		if ctx.Err() != nil || thread.ExecutionSteps() >= maxSteps {
+			err = ErrCheckerTimeout
+		}
+		return CheckResult{Up: false, Message: err.Error()}
+	}
+
+	output, err := parseCheckResult(result)
+	if err != nil {
+		return CheckResult{Up: false, Message: err.Error()}
+	}
+
+	return *output
+}
+
+// 脚本可以返回 bool 或者 (bool, message)
+func parseCheckResult(result starlark.Value) (*CheckResult, error) {
+	output := CheckResult{}
+
+	switch v := result.(type) {
+	case starlark.Bool:
+		output.Up = bool(v)
+	case starlark.Tuple:
+		if len(v) != 2 {
+			return nil, fmt.Errorf("checker must return bool or (bool, message), got tuple of %d", len(v))
+		}
+		up, ok := v[0].(starlark.Bool)
+		if !ok {
+			return nil, fmt.Errorf("checker result[0] must be bool, got %s", v[0].Type())
+		}
+		message, ok := starlark.AsString(v[1])
+		if !ok {
+			return nil, fmt.Errorf("checker result[1] must be string, got %s", v[1].Type())
+		}
+		output.Up = bool(up)
+		output.Message = message
+	default:
+		return nil, fmt.Errorf("checker must return bool or (bool, message), got %s", result.Type())
+	}
+
+	if len(output.Message) > 256 {
+		output.Message = output.Message[:256]
+	}
+
+	return &output, nil
+}
+
+func threadContext(thread *starlark.Thread) context.Context {
+	if ctx, ok := thread.Local("context").(context.Context); ok {
+		return ctx
+	}
+	return context.Background()
+}
+
+var httpClient = &http.Client{
+	// 不跟随跳转，让 checker 自己判断
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+	Transport: &http.Transport{
+		DisableKeepAlives: true,
+	},
+}
+
+var httpModule = &starlarkstruct.Module{
+	Name: "http",
+	Members: starlark.StringDict{
+		"get":  starlark.NewBuiltin("get", httpRequest(http.MethodGet)),
+		"post": starlark.NewBuiltin("post", httpRequest(http.MethodPost)),
+	},
+}
+
+// http.get(url, headers={}) / http.post(url, body="", headers={})，返回 struct(status, body, headers)
+func httpRequest(method string) func(*starlark.Thread, *starlark.Builtin, starlark.Tuple, []starlark.Tuple) (starlark.Value, error) {
+	return func(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var url, body string
+		var headers *starlark.Dict
+		if method == http.MethodGet {
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "url", &url, "headers?", &headers); err != nil {
+				return nil, err
+			}
+		} else {
+			if err := starlark.UnpackArgs(b.Name(), args, kwargs, "url", &url, "body?", &body, "headers?", &headers); err != nil {
+				return nil, err
+			}
+		}
+
+		req, err := http.NewRequestWithContext(threadContext(thread), method, url, strings.NewReader(body))
+		if err != nil {
+			return nil, err
+		}
+
+		if headers != nil {
+			for _, item := range headers.Items() {
+				key, ok1 := starlark.AsString(item[0])
+				value, ok2 := starlark.AsString(item[1])
+				if !ok1 || !ok2 {
+					return nil, fmt.Errorf("%s: headers must be dict of strings", b.Name())
+				}
+				req.Header.Set(key, value)
+			}
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", b.Name(), err)
+		}
+		defer resp.Body.Close()
+
+		data, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseBytes))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", b.Name(), err)
+		}
+
+		respHeaders := starlark.NewDict(len(resp.Header))
+		for key := range resp.Header {
+			_ = respHeaders.SetKey(starlark.String(strings.ToLower(key)), starlark.String(resp.Header.Get(key)))
+		}
+
+		return starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"status":  starlark.MakeInt(resp.StatusCode),
+			"body":    starlark.String(data),
+			"headers": respHeaders,
+		}), nil
+	}
+}
+
+var tcpModule = &starlarkstruct.Module{
+	Name: "tcp",
+	Members: starlark.StringDict{
+		"send": starlark.NewBuiltin("send", tcpSend),
+	},
+}
+
+// tcp.send(host, port, data="", until="", max_bytes=4096)
+// 连接后发送 data，然后读到 until 出现、读满 max_bytes 或者对方关闭连接为止，until 为空时读到数据就返回
+func tcpSend(thread *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var host, data, until string
+	var port int
+	maxBytes := 4096
+	if err := starlark.UnpackArgs(b.Name(), args, kwargs, "host", &host, "port", &port, "data?", &data, "until?", &until, "max_bytes?", &maxBytes); err != nil {
+		return nil, err
+	}
+	if maxBytes <= 0 || maxBytes > maxResponseBytes {
+		maxBytes = maxResponseBytes
+	}
+
+	ctx := threadContext(thread)
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+	if err != nil {
+		return nil, fmt.Errorf("%s: %v", b.Name(), err)
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	if data != "" {
+		if _, err := conn.Write([]byte(data)); err != nil {
+			return nil, fmt.Errorf("%s: %v", b.Name(), err)
+		}
+	}
+
+	reader := bufio.NewReader(io.LimitReader(conn, int64(maxBytes)))
+	var received strings.Builder
+	buf := make([]byte, 4096)
+	for {
+		n, err := reader.Read(buf)
+		received.Write(buf[:n])
+		if n > 0 && (until == "" || strings.Contains(received.String(), until)) {
+			break
+		}
+		if err != nil {
+			if errors.Is(err, io.EOF) || received.Len() > 0 {
+				break
+			}
+			return nil, fmt.Errorf("%s: %v", b.Name(), err)
+		}
+	}
+
+	return starlark.String(received.String()), nil
+}
+
+func randomString(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var length int
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &length); err != nil {
+		return nil, err
+	}
+	if length <= 0 || length > 1024 {
+		return nil, fmt.Errorf("%s: length must be between 1 and 1024", b.Name())
+	}
+
+	return starlark.String(general.RandomString(length)), nil
+}
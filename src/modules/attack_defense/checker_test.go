@@ -0,0 +1,125 @@
+package attackdefense
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	scriptjudge "a1ctf/src/modules/script_judge"
+
+	"github.com/spf13/viper"
+)
+
+// checker 在子进程里执行，测试程序自己充当 worker
+func TestMain(m *testing.M) {
+	if scriptjudge.IsWorker() {
+		scriptjudge.WorkerMain()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+func withCheckerLimits(t *testing.T, steps uint64, timeout time.Duration) {
+	t.Helper()
+
+	viper.Set("attack-defense.checker-max-steps", steps)
+	viper.Set("attack-defense.checker-timeout", timeout)
+	t.Cleanup(func() {
+		viper.Set("attack-defense.checker-max-steps", 0)
+		viper.Set("attack-defense.checker-timeout", 0)
+	})
+}
+
+func TestRunChecker(t *testing.T) {
+	withCheckerLimits(t, 1000000, 5*time.Second)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, "flag{ok}")
+	}))
+	defer server.Close()
+
+	host, portText, _ := net.SplitHostPort(strings.TrimPrefix(server.URL, "http://"))
+	port, _ := strconv.Atoi(portText)
+
+	script := `
+def check(service):
+    resp = http.get("http://%s:%d/" % (service.host, service.port))
+    if resp.body != service.flag:
+        return (False, "flag mismatch")
+    return True
+`
+
+	tests := []struct {
+		name        string
+		script      string
+		flag        string
+		wantUp      bool
+		wantMessage string
+	}{
+		{name: "up", script: script, flag: "flag{ok}", wantUp: true},
+		{name: "flag mismatch", script: script, flag: "flag{other}", wantMessage: "flag mismatch"},
+		{name: "compile error", script: "def check(service)\n    return True\n", wantMessage: "checker compile error"},
+		{name: "infinite loop", script: "def check(service):\n    while True:\n        pass\n", wantMessage: ErrCheckerTimeout.Error()},
+		{name: "bad result", script: "def check(service):\n    return 1\n", wantMessage: "checker must return bool"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := RunChecker(tt.script, ServiceTarget{Host: host, Port: int32(port), Flag: tt.flag})
+			if result.Up != tt.wantUp || !strings.Contains(result.Message, tt.wantMessage) {
+				t.Errorf("RunChecker() = %+v, want up %v with message %q", result, tt.wantUp, tt.wantMessage)
+			}
+		})
+	}
+}
+
+func TestValidateChecker(t *testing.T) {
+	withCheckerLimits(t, 1000000, 5*time.Second)
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr bool
+	}{
+		{name: "valid", script: "def check(service):\n    return True\n"},
+		{name: "missing check", script: "def judge(s):\n    return True\n", wantErr: true},
+		{name: "load is not allowed", script: "load(\"os.star\", \"os\")\ndef check(service):\n    return True\n", wantErr: true},
+		{name: "top level loop is limited", script: "while True:\n    pass\ndef check(service):\n    return True\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateChecker(tt.script); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateChecker() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestPruneStartTimes(t *testing.T) {
+	t.Cleanup(func() { pruneStartTimes(nil) })
+
+	now := time.Now()
+	key := serviceKey{TeamID: 1, ChallengeID: 2}
+
+	if !shouldRestart(1, key, now) || !shouldRestart(2, key, now) {
+		t.Fatal("first start of a service should not be delayed")
+	}
+	if shouldRestart(1, key, now.Add(time.Second)) {
+		t.Error("restart within backoff should be delayed")
+	}
+
+	pruneStartTimes(map[int64]bool{2: true})
+	if _, ok := lastStartTimes[1]; ok {
+		t.Error("start times of ended game should be pruned")
+	}
+	if shouldRestart(2, key, now.Add(time.Second)) {
+		t.Error("start times of running game should be kept")
+	}
+}
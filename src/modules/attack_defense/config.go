@@ -0,0 +1,100 @@
+package attackdefense
+
+import (
+	"a1ctf/src/db/models"
+	"errors"
+	"time"
+)
+
+// 默认写入 flag 的命令，flag 从标准输入读入
+var defaultFlagCommand = []string{"sh", "-c", "cat > /flag"}
+
+// 没有配置时使用的默认值
+func configOf(game *models.Game) models.AttackDefenseConfig {
+	config := models.AttackDefenseConfig{
+		RoundDuration: 300,
+		FlagLifetime:  1,
+		AttackScore:   50,
+		DefenseScore:  0,
+		SLAScore:      50,
+	}
+
+	if game.AttackDefenseConfig != nil {
+		config = *game.AttackDefenseConfig
+	}
+
+	if config.RoundDuration <= 0 {
+		config.RoundDuration = 300
+	}
+	if config.FlagLifetime <= 0 {
+		config.FlagLifetime = 1
+	}
+	if config.CheckDelay <= 0 || config.CheckDelay >= config.RoundDuration {
+		config.CheckDelay = config.RoundDuration / 2
+	}
+
+	return config
+}
+
+func roundDuration(config models.AttackDefenseConfig) time.Duration {
+	return time.Duration(config.RoundDuration) * time.Second
+}
+
+// RoundAt 返回某个时间所在的轮次，比赛开始前和结束后为 0
+func RoundAt(game *models.Game, t time.Time) int32 {
+	if t.Before(game.StartTime) || !t.Before(game.EndTime) {
+		return 0
+	}
+	config := configOf(game)
+	return int32(t.Sub(game.StartTime)/roundDuration(config)) + 1
+}
+
+// ValidateConfig 检查比赛的攻防配置
+func ValidateConfig(mode models.GameMode, config *models.AttackDefenseConfig) error {
+	switch mode {
+	case "", models.GameModeJeopardy:
+		return nil
+	case models.GameModeAttackDefense:
+	default:
+		return errors.New("unknown game mode")
+	}
+
+	if config == nil {
+		return nil
+	}
+
+	if config.RoundDuration < 30 {
+		return errors.New("round duration must be at least 30 seconds")
+	}
+	if config.CheckDelay < 0 || config.CheckDelay >= config.RoundDuration {
+		return errors.New("check delay must be between 0 and round duration")
+	}
+	if config.FlagLifetime < 0 {
+		return errors.New("flag lifetime must not be negative")
+	}
+	if config.AttackScore < 0 || config.DefenseScore < 0 || config.SLAScore < 0 {
+		return errors.New("scores must not be negative")
+	}
+
+	return nil
+}
+
+// ValidateServiceConfig 检查题目的服务配置
+func ValidateServiceConfig(config *models.ADServiceConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	if config.CheckerScript == "" {
+		return errors.New("checker script must not be empty")
+	}
+
+	return ValidateChecker(config.CheckerScript)
+}
+
+func flagCommandOf(config *models.ADServiceConfig) []string {
+	if config == nil || len(config.FlagCommand) == 0 {
+		return defaultFlagCommand
+	}
+	return config.FlagCommand
+}
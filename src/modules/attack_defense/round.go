@@ -0,0 +1,627 @@
+package attackdefense
+
+import (
+	"a1ctf/src/db/models"
+	scoreengine "a1ctf/src/modules/score_engine"
+	tcpgateway "a1ctf/src/modules/tcp_gateway"
+	"a1ctf/src/tasks"
+	containerbackend "a1ctf/src/utils/container_backend"
+	dbtool "a1ctf/src/utils/db_tool"
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"a1ctf/src/utils/zaphelper"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 队伍和题目组成的服务标识
+type serviceKey struct {
+	TeamID      int64
+	ChallengeID int64
+}
+
+// 服务上次创建容器的时间，镜像有问题时不要每次调度都重建，按比赛分开存放，比赛结束后清掉
+var (
+	lastStartTimes      = make(map[int64]map[serviceKey]time.Time)
+	lastStartTimesMutex sync.Mutex
+)
+
+func shouldRestart(gameID int64, key serviceKey, now time.Time) bool {
+	backoff := viper.GetDuration("attack-defense.restart-backoff")
+	if backoff <= 0 {
+		backoff = 30 * time.Second
+	}
+
+	lastStartTimesMutex.Lock()
+	defer lastStartTimesMutex.Unlock()
+
+	gameStartTimes, ok := lastStartTimes[gameID]
+	if !ok {
+		gameStartTimes = make(map[serviceKey]time.Time)
+		lastStartTimes[gameID] = gameStartTimes
+	}
+
+	if now.Sub(gameStartTimes[key]) < backoff {
+		return false
+	}
+	gameStartTimes[key] = now
+	return true
+}
+
+// pruneStartTimes 只保留还在进行中的比赛，已经结束或者被删除的比赛不会再重建容器
+func pruneStartTimes(runningGames map[int64]bool) {
+	lastStartTimesMutex.Lock()
+	defer lastStartTimesMutex.Unlock()
+
+	for gameID := range lastStartTimes {
+		if !runningGames[gameID] {
+			delete(lastStartTimes, gameID)
+		}
+	}
+}
+
+// Run 推进所有攻防比赛的轮次：生成 flag、维护服务容器、运行 checker、结算分数
+func Run() {
+	now := time.Now().UTC()
+
+	var games []models.Game
+	if err := dbtool.DB().Where("game_mode = ? AND start_time <= ?", models.GameModeAttackDefense, now).Find(&games).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load attack-defense games", zap.Error(err))
+		return
+	}
+
+	runningGames := make(map[int64]bool)
+	for idx := range games {
+		if now.Before(games[idx].EndTime) {
+			runningGames[games[idx].GameID] = true
+		}
+	}
+	pruneStartTimes(runningGames)
+
+	for idx := range games {
+		if err := processGame(&games[idx], now); err != nil {
+			zaphelper.Logger.Error("Failed to process attack-defense game", zap.Error(err), zap.Int64("game_id", games[idx].GameID))
+		}
+	}
+}
+
+func workerCount() int {
+	if workers := viper.GetInt("attack-defense.workers"); workers > 0 {
+		return workers
+	}
+	return 16
+}
+
+// 并发执行，最多 workerCount 个同时运行
+func runLimited(jobs []func()) {
+	sem := make(chan struct{}, workerCount())
+	var wg sync.WaitGroup
+	for _, job := range jobs {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(job func()) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			job()
+		}(job)
+	}
+	wg.Wait()
+}
+
+func processGame(game *models.Game, now time.Time) error {
+	config := configOf(game)
+
+	if roundNo := RoundAt(game, now); roundNo > 0 {
+		round, err := ensureRound(game, config, roundNo)
+		if err != nil {
+			return err
+		}
+
+		teams, gameChallenges, err := loadParticipants(game.GameID)
+		if err != nil {
+			return err
+		}
+
+		flags, err := roundFlags(game.GameID, round.Round)
+		if err != nil {
+			return err
+		}
+
+		rotateFlags(game, round, teams, gameChallenges, flags)
+
+		if err := syncServices(game, round, teams, gameChallenges, flags); err != nil {
+			return err
+		}
+
+		if !round.Checked && !now.Before(round.StartTime.Add(time.Duration(config.CheckDelay)*time.Second)) {
+			if err := runChecks(game, round, teams, gameChallenges, flags); err != nil {
+				return err
+			}
+		}
+	}
+
+	return scoreFinishedRounds(game, config, now)
+}
+
+func ensureRound(game *models.Game, config models.AttackDefenseConfig, roundNo int32) (*models.ADRound, error) {
+	startTime := game.StartTime.Add(roundDuration(config) * time.Duration(roundNo-1))
+	endTime := startTime.Add(roundDuration(config))
+	if endTime.After(game.EndTime) {
+		endTime = game.EndTime
+	}
+
+	round := models.ADRound{
+		GameID:    game.GameID,
+		Round:     roundNo,
+		StartTime: startTime,
+		EndTime:   endTime,
+	}
+
+	result := dbtool.DB().Clauses(clause.OnConflict{DoNothing: true}).Create(&round)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected > 0 {
+		zaphelper.Logger.Info("Attack-defense round started", zap.Int64("game_id", game.GameID), zap.Int32("round", roundNo))
+	}
+
+	if err := dbtool.DB().Where("game_id = ? AND round = ?", game.GameID, roundNo).First(&round).Error; err != nil {
+		return nil, err
+	}
+
+	return &round, nil
+}
+
+// 审核通过的选手队伍和带容器的可见题目
+func loadParticipants(gameID int64) ([]models.Team, []models.GameChallenge, error) {
+	var teams []models.Team
+	if err := dbtool.DB().Where("game_id = ? AND team_status = ? AND team_type = ?", gameID, models.ParticipateApproved, models.TeamTypePlayer).Find(&teams).Error; err != nil {
+		return nil, nil, err
+	}
+
+	var allChallenges []models.GameChallenge
+	if err := dbtool.DB().Preload("Challenge").Where("game_id = ? AND visible = ?", gameID, true).Find(&allChallenges).Error; err != nil {
+		return nil, nil, err
+	}
+
+	gameChallenges := make([]models.GameChallenge, 0, len(allChallenges))
+	for _, gc := range allChallenges {
+		if gc.Challenge.ContainerConfig == nil || len(*gc.Challenge.ContainerConfig) == 0 {
+			continue
+		}
+		gameChallenges = append(gameChallenges, gc)
+	}
+
+	return teams, gameChallenges, nil
+}
+
+func roundFlags(gameID int64, round int32) (map[serviceKey]models.TeamFlag, error) {
+	var flags []models.TeamFlag
+	if err := dbtool.DB().Where("game_id = ? AND round = ?", gameID, round).Find(&flags).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[serviceKey]models.TeamFlag, len(flags))
+	for _, flag := range flags {
+		result[serviceKey{TeamID: flag.TeamID, ChallengeID: flag.ChallengeID}] = flag
+	}
+	return result, nil
+}
+
+// 给还没有本轮 flag 的服务排队生成，任务 ID 去重，所以每次调度都可以重复调用
+func rotateFlags(game *models.Game, round *models.ADRound, teams []models.Team, gameChallenges []models.GameChallenge, flags map[serviceKey]models.TeamFlag) {
+	for _, gc := range gameChallenges {
+		flagTemplate := "flag{[uuid]}"
+		if gc.JudgeConfig != nil && gc.JudgeConfig.FlagTemplate != nil && *gc.JudgeConfig.FlagTemplate != "" {
+			flagTemplate = *gc.JudgeConfig.FlagTemplate
+		}
+
+		for _, team := range teams {
+			if _, ok := flags[serviceKey{TeamID: team.TeamID, ChallengeID: gc.ChallengeID}]; ok {
+				continue
+			}
+
+			if err := tasks.NewTeamRoundFlagCreateTask(flagTemplate, team.TeamID, game.GameID, gc.ChallengeID, team.TeamHash, team.TeamName, models.FlagTypeDynamic, round.Round); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+				zaphelper.Logger.Error("Failed to enqueue round flag task", zap.Error(err), zap.Int64("team_id", team.TeamID), zap.Int64("challenge_id", gc.ChallengeID), zap.Int32("round", round.Round))
+			}
+		}
+	}
+}
+
+// 保证每个服务都有运行中的容器，并把本轮的 flag 写进去
+func syncServices(game *models.Game, round *models.ADRound, teams []models.Team, gameChallenges []models.GameChallenge, flags map[serviceKey]models.TeamFlag) error {
+	var services []models.ADService
+	if err := dbtool.DB().Where("game_id = ?", game.GameID).Find(&services).Error; err != nil {
+		return err
+	}
+
+	serviceMap := make(map[serviceKey]*models.ADService, len(services))
+	for idx := range services {
+		serviceMap[serviceKey{TeamID: services[idx].TeamID, ChallengeID: services[idx].ChallengeID}] = &services[idx]
+	}
+
+	containerMap, err := liveContainers(game.GameID)
+	if err != nil {
+		return err
+	}
+
+	plantJobs := make([]func(), 0)
+
+	for _, gc := range gameChallenges {
+		for _, team := range teams {
+			key := serviceKey{TeamID: team.TeamID, ChallengeID: gc.ChallengeID}
+
+			service, ok := serviceMap[key]
+			if !ok {
+				service = &models.ADService{
+					GameID:      game.GameID,
+					TeamID:      team.TeamID,
+					ChallengeID: gc.ChallengeID,
+					InGameID:    gc.IngameID,
+					Status:      models.ADServicePending,
+				}
+				if err := dbtool.DB().Clauses(clause.OnConflict{DoNothing: true}).Create(service).Error; err != nil {
+					zaphelper.Logger.Error("Failed to create attack-defense service", zap.Error(err), zap.Int64("team_id", team.TeamID), zap.Int64("challenge_id", gc.ChallengeID))
+					continue
+				}
+				if service.ServiceID == 0 {
+					// 其他实例刚创建，下次调度再处理
+					continue
+				}
+			}
+
+			flag, hasFlag := flags[key]
+
+			container, running := containerMap[key]
+			if !running {
+				// 第一次启动或者容器挂掉了，用本轮的 flag 重新创建
+				if !hasFlag || !shouldRestart(game.GameID, key, time.Now()) {
+					continue
+				}
+				newContainer, err := startServiceContainer(game, &gc, &team, &flag)
+				if err != nil {
+					zaphelper.Logger.Error("Failed to start attack-defense service", zap.Error(err), zap.Int64("team_id", team.TeamID), zap.Int64("challenge_id", gc.ChallengeID))
+					continue
+				}
+				if err := dbtool.DB().Model(service).Updates(map[string]interface{}{
+					"container_id": newContainer.ContainerID,
+					"flag_round":   0,
+				}).Error; err != nil {
+					zaphelper.Logger.Error("Failed to update attack-defense service", zap.Error(err), zap.Int64("service_id", service.ServiceID))
+				}
+				continue
+			}
+
+			if service.ContainerID == nil || *service.ContainerID != container.ContainerID {
+				containerID := container.ContainerID
+				service.ContainerID = &containerID
+				service.FlagRound = 0
+				if err := dbtool.DB().Model(service).Updates(map[string]interface{}{
+					"container_id": containerID,
+					"flag_round":   0,
+				}).Error; err != nil {
+					zaphelper.Logger.Error("Failed to update attack-defense service", zap.Error(err), zap.Int64("service_id", service.ServiceID))
+					continue
+				}
+			}
+
+			if container.ContainerStatus != models.ContainerRunning || !hasFlag || service.FlagRound >= round.Round {
+				continue
+			}
+
+			serviceID := service.ServiceID
+			serviceConfig := gc.ADServiceConfig
+			plantJobs = append(plantJobs, func() {
+				if err := plantFlag(container, serviceConfig, flag.FlagContent); err != nil {
+					zaphelper.Logger.Warn("Failed to write round flag", zap.Error(err), zap.Int64("service_id", serviceID), zap.Int32("round", round.Round))
+					return
+				}
+				if err := dbtool.DB().Model(&models.ADService{}).Where("service_id = ?", serviceID).Update("flag_round", round.Round).Error; err != nil {
+					zaphelper.Logger.Error("Failed to update attack-defense service", zap.Error(err), zap.Int64("service_id", serviceID))
+				}
+			})
+		}
+	}
+
+	runLimited(plantJobs)
+
+	return nil
+}
+
+// 排队中、启动中和运行中的容器
+func liveContainers(gameID int64) (map[serviceKey]models.Container, error) {
+	var containers []models.Container
+	if err := dbtool.DB().Where("game_id = ? AND container_status IN ?", gameID, []models.ContainerStatus{models.ContainerQueueing, models.ContainerStarting, models.ContainerRunning}).Find(&containers).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[serviceKey]models.Container, len(containers))
+	for _, container := range containers {
+		result[serviceKey{TeamID: container.TeamID, ChallengeID: container.ChallengeID}] = container
+	}
+	return result, nil
+}
+
+// 服务容器一直运行到比赛结束，到期后由容器任务回收
+func startServiceContainer(game *models.Game, gc *models.GameChallenge, team *models.Team, flag *models.TeamFlag) (*models.Container, error) {
+	now := time.Now().UTC()
+
+	// 不受比赛配额限制，直接创建为启动中，排队中的容器会被配额调度再启动一次
+	newContainer := models.Container{
+		ContainerID:          uuid.NewString(),
+		GameID:               game.GameID,
+		FlagID:               flag.FlagID,
+		TeamID:               team.TeamID,
+		ChallengeID:          gc.ChallengeID,
+		InGameID:             gc.IngameID,
+		StartTime:            now,
+		ExpireTime:           game.EndTime,
+		ContainerExposeInfos: make(models.ContainerExposeInfos, 0),
+		ContainerStatus:      models.ContainerStarting,
+		ContainerConfig:      *gc.Challenge.ContainerConfig,
+		ChallengeName:        gc.Challenge.Name,
+		TeamHash:             team.TeamHash,
+	}
+
+	if err := dbtool.DB().Create(&newContainer).Error; err != nil {
+		return nil, err
+	}
+
+	// k8s 开启 pod 需要 Challenge的AllowWAN/AllowDNS 和 TeamFlag的FlagContent 的信息
+	newContainer.Challenge = gc.Challenge
+	newContainer.TeamFlag = *flag
+
+	if err := tasks.NewContainerStartTask(newContainer); err != nil {
+		// 启动超时的容器会被容器任务关闭，之后重新创建
+		zaphelper.Logger.Warn("Failed to enqueue container start task", zap.Error(err), zap.String("container_id", newContainer.ContainerID))
+	}
+
+	zaphelper.Logger.Info("Starting attack-defense service", zap.Int64("game_id", game.GameID), zap.Int64("team_id", team.TeamID), zap.Int64("challenge_id", gc.ChallengeID))
+
+	return &newContainer, nil
+}
+
+func plantFlag(container models.Container, config *models.ADServiceConfig, flag string) error {
+	mainContainer := k8stool.FirstMainContainer(container.ContainerConfig)
+	if mainContainer == nil {
+		return fmt.Errorf("container %s has no containers", container.ContainerID)
+	}
+
+	containerName := mainContainer.Name
+	if config != nil && config.FlagContainer != "" {
+		containerName = config.FlagContainer
+	}
+
+	timeout := viper.GetDuration("attack-defense.flag-write-timeout")
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	podName := container.PodID()
+	_, err := containerbackend.Get().Exec(ctx, podName, containerName, flagCommandOf(config), strings.NewReader(flag))
+	return err
+}
+
+// 服务对外暴露的第一个端口作为 checker 的默认目标
+func serviceTarget(container models.Container, flag string, round int32) ServiceTarget {
+	target := ServiceTarget{
+		Ports:  make(map[string]int32),
+		Flag:   flag,
+		Round:  round,
+		TeamID: container.TeamID,
+	}
+
+	for _, info := range container.ContainerExposeInfos {
+		for _, port := range info.ExposePorts {
+			// checker 直接连接容器，不经过 TCP 网关
+			host, portNumber, err := tcpgateway.ResolveTarget(port)
+			if err != nil {
+				zaphelper.Logger.Warn("Failed to resolve service target", zap.Error(err), zap.String("container_id", container.ContainerID))
+				continue
+			}
+			if target.Host == "" {
+				target.Host = host
+				target.Port = portNumber
+			}
+			target.Ports[port.PortName] = portNumber
+		}
+	}
+
+	return target
+}
+
+// 每轮运行一次 checker，先把轮次标记为已检查，多个实例不会重复运行
+func runChecks(game *models.Game, round *models.ADRound, teams []models.Team, gameChallenges []models.GameChallenge, flags map[serviceKey]models.TeamFlag) error {
+	result := dbtool.DB().Model(&models.ADRound{}).Where("game_id = ? AND round = ? AND checked = ?", game.GameID, round.Round, false).Update("checked", true)
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil
+	}
+	round.Checked = true
+
+	containerMap, err := liveContainers(game.GameID)
+	if err != nil {
+		return err
+	}
+
+	var checks []models.ADServiceCheck
+	var checksMutex sync.Mutex
+
+	jobs := make([]func(), 0, len(teams)*len(gameChallenges))
+	for _, gc := range gameChallenges {
+		for _, team := range teams {
+			key := serviceKey{TeamID: team.TeamID, ChallengeID: gc.ChallengeID}
+			container, exists := containerMap[key]
+			flag := flags[key]
+			serviceConfig := gc.ADServiceConfig
+
+			jobs = append(jobs, func() {
+				check := models.ADServiceCheck{
+					GameID:      game.GameID,
+					Round:       round.Round,
+					TeamID:      key.TeamID,
+					ChallengeID: key.ChallengeID,
+					Status:      models.ADServiceDown,
+				}
+
+				switch {
+				case !exists || container.ContainerStatus != models.ContainerRunning:
+					check.Message = "service is not running"
+				case serviceConfig == nil || serviceConfig.CheckerScript == "":
+					// 没有 checker 的题目只看容器是否在运行
+					check.Status = models.ADServiceUp
+				default:
+					output := RunChecker(serviceConfig.CheckerScript, serviceTarget(container, flag.FlagContent, round.Round))
+					if output.Up {
+						check.Status = models.ADServiceUp
+					}
+					check.Message = output.Message
+				}
+
+				check.CheckTime = time.Now().UTC()
+
+				checksMutex.Lock()
+				checks = append(checks, check)
+				checksMutex.Unlock()
+			})
+		}
+	}
+
+	runLimited(jobs)
+
+	if len(checks) == 0 {
+		return nil
+	}
+
+	if err := dbtool.DB().Clauses(clause.OnConflict{UpdateAll: true}).Create(&checks).Error; err != nil {
+		return err
+	}
+
+	for _, check := range checks {
+		if err := dbtool.DB().Model(&models.ADService{}).
+			Where("game_id = ? AND team_id = ? AND challenge_id = ?", check.GameID, check.TeamID, check.ChallengeID).
+			Updates(map[string]interface{}{
+				"status":     check.Status,
+				"message":    check.Message,
+				"check_time": check.CheckTime,
+			}).Error; err != nil {
+			zaphelper.Logger.Error("Failed to update attack-defense service status", zap.Error(err), zap.Int64("team_id", check.TeamID), zap.Int64("challenge_id", check.ChallengeID))
+		}
+	}
+
+	zaphelper.Logger.Info("Attack-defense round checked", zap.Int64("game_id", game.GameID), zap.Int32("round", round.Round), zap.Int("services", len(checks)))
+
+	return nil
+}
+
+// 结算已经结束但还没有计分的轮次
+func scoreFinishedRounds(game *models.Game, config models.AttackDefenseConfig, now time.Time) error {
+	var rounds []models.ADRound
+	if err := dbtool.DB().Where("game_id = ? AND scored = ? AND end_time <= ?", game.GameID, false, now).Order("round ASC").Find(&rounds).Error; err != nil {
+		return err
+	}
+
+	if len(rounds) == 0 {
+		return nil
+	}
+
+	for _, round := range rounds {
+		if err := scoreRound(game, config, &round); err != nil {
+			return err
+		}
+	}
+
+	scoreengine.NotifyGame(game.GameID)
+
+	return nil
+}
+
+// 攻击：每偷到一个 flag 得 AttackScore
+// 防守：服务正常并且本轮没有被偷 flag 得 DefenseScore
+// SLA：本轮 checker 通过得 SLAScore
+func scoreRound(game *models.Game, config models.AttackDefenseConfig, round *models.ADRound) error {
+	return dbtool.DB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.ADRound{}).Where("game_id = ? AND round = ? AND scored = ?", game.GameID, round.Round, false).Update("scored", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+
+		var checks []models.ADServiceCheck
+		if err := tx.Where("game_id = ? AND round = ?", game.GameID, round.Round).Find(&checks).Error; err != nil {
+			return err
+		}
+
+		var attacks []models.ADAttack
+		if err := tx.Where("game_id = ? AND round = ?", game.GameID, round.Round).Find(&attacks).Error; err != nil {
+			return err
+		}
+
+		scores := make(map[serviceKey]*models.ADRoundScore)
+		scoreOf := func(key serviceKey) *models.ADRoundScore {
+			score, ok := scores[key]
+			if !ok {
+				score = &models.ADRoundScore{
+					GameID:      game.GameID,
+					Round:       round.Round,
+					TeamID:      key.TeamID,
+					ChallengeID: key.ChallengeID,
+				}
+				scores[key] = score
+			}
+			return score
+		}
+
+		attacked := make(map[serviceKey]bool)
+		for _, attack := range attacks {
+			scoreOf(serviceKey{TeamID: attack.AttackerTeamID, ChallengeID: attack.ChallengeID}).AttackScore += config.AttackScore
+			attacked[serviceKey{TeamID: attack.VictimTeamID, ChallengeID: attack.ChallengeID}] = true
+		}
+
+		for _, check := range checks {
+			if check.Status != models.ADServiceUp {
+				continue
+			}
+			key := serviceKey{TeamID: check.TeamID, ChallengeID: check.ChallengeID}
+			score := scoreOf(key)
+			score.SLAScore += config.SLAScore
+			if !attacked[key] {
+				score.DefenseScore += config.DefenseScore
+			}
+		}
+
+		if len(scores) == 0 {
+			return nil
+		}
+
+		rows := make([]models.ADRoundScore, 0, len(scores))
+		for _, score := range scores {
+			rows = append(rows, *score)
+		}
+
+		if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).Create(&rows).Error; err != nil {
+			return err
+		}
+
+		zaphelper.Logger.Info("Attack-defense round scored", zap.Int64("game_id", game.GameID), zap.Int32("round", round.Round), zap.Int("attacks", len(attacks)))
+
+		return nil
+	})
+}
@@ -0,0 +1,70 @@
+package attackdefense
+
+import (
+	"a1ctf/src/db/models"
+	dbtool "a1ctf/src/utils/db_tool"
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var (
+	ErrRoundNotRunning      = errors.New("no round is running")
+	ErrFlagInvalid          = errors.New("flag is invalid or expired")
+	ErrFlagOwnTeam          = errors.New("flag belongs to your own team")
+	ErrFlagAlreadySubmitted = errors.New("flag has already been submitted")
+)
+
+// SubmitFlag 提交从其他队伍服务里拿到的 flag，记到当前轮次，在轮次结束后计分
+func SubmitFlag(game *models.Game, team *models.Team, userID string, clientIP string, content string) (*models.ADAttack, error) {
+	now := time.Now().UTC()
+
+	round := RoundAt(game, now)
+	if round == 0 {
+		return nil, ErrRoundNotRunning
+	}
+
+	content = strings.TrimSpace(content)
+	if content == "" {
+		return nil, ErrFlagInvalid
+	}
+
+	config := configOf(game)
+
+	// 最近 FlagLifetime 轮生成的 flag 有效
+	var flag models.TeamFlag
+	if err := dbtool.DB().Where("game_id = ? AND flag_content = ? AND round > ? AND round <= ?", game.GameID, content, round-config.FlagLifetime, round).First(&flag).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrFlagInvalid
+		}
+		return nil, err
+	}
+
+	if flag.TeamID == team.TeamID {
+		return nil, ErrFlagOwnTeam
+	}
+
+	attack := models.ADAttack{
+		GameID:         game.GameID,
+		Round:          round,
+		AttackerTeamID: team.TeamID,
+		VictimTeamID:   flag.TeamID,
+		ChallengeID:    flag.ChallengeID,
+		FlagID:         flag.FlagID,
+		FlagRound:      flag.Round,
+		SubmiterID:     userID,
+		SubmiterIP:     &clientIP,
+		SubmitTime:     now,
+	}
+
+	if err := dbtool.DB().Create(&attack).Error; err != nil {
+		if errors.Is(err, gorm.ErrDuplicatedKey) {
+			return nil, ErrFlagAlreadySubmitted
+		}
+		return nil, err
+	}
+
+	return &attack, nil
+}
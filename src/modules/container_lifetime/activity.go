@@ -0,0 +1,87 @@
+package containerlifetime
+
+import (
+	"a1ctf/src/db/models"
+	containerbackend "a1ctf/src/utils/container_backend"
+	dbtool "a1ctf/src/utils/db_tool"
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"a1ctf/src/utils/zaphelper"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// PolicyOf 容器的有效期策略，需要预加载 Challenge 和 GameChallenge.Game
+// 攻防和静态容器由各自的调度维护，不使用选手容器的策略
+func PolicyOf(container *models.Container) (Policy, bool) {
+	if container.GameChallenge.Game.GameMode == models.GameModeAttackDefense ||
+		container.Challenge.ContainerType == models.STATIC_CONTAINER {
+		return Policy{}, false
+	}
+
+	return Resolve(&container.GameChallenge.Game, &container.GameChallenge), true
+}
+
+// CheckActivity 采样开启了空闲检查的容器的 CPU 占用，超过阈值的记为有活动
+// 网络连接由 TCP 网关记录，这里只补充 CPU 的部分
+func CheckActivity() {
+	var containers []models.Container
+	if err := dbtool.DB().Preload("Challenge").Preload("GameChallenge.Game").
+		Where("container_status = ?", models.ContainerRunning).Find(&containers).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load running containers for activity check", zap.Error(err))
+		return
+	}
+
+	threshold := viper.GetInt64("container-lifetime.idle-cpu-threshold")
+
+	active := make([]string, 0)
+	for idx := range containers {
+		container := &containers[idx]
+
+		policy, ok := PolicyOf(container)
+		if !ok || policy.IdleTimeout <= 0 {
+			continue
+		}
+
+		podInfo := k8stool.PodInfo{
+			Name:       container.PodID(),
+			TeamHash:   container.TeamHash,
+			Containers: container.ContainerConfig,
+		}
+
+		usage, err := containerbackend.Get().PodCPUUsage(&podInfo)
+		if err != nil {
+			// 拿不到数据时不能判断是否空闲，当作有活动，避免误关
+			zaphelper.Logger.Warn("Failed to get container cpu usage", zap.Error(err), zap.String("container_id", container.ContainerID))
+			active = append(active, container.ContainerID)
+			continue
+		}
+
+		if usage > threshold {
+			active = append(active, container.ContainerID)
+		}
+	}
+
+	if err := Touch(active); err != nil {
+		zaphelper.Logger.Error("Failed to update container activity", zap.Error(err))
+	}
+}
+
+// StopIdle 把超过空闲时间的容器标记为停止中，返回是否标记
+func StopIdle(container *models.Container, policy Policy, now time.Time) bool {
+	if !policy.IsIdle(container, now) {
+		return false
+	}
+
+	zaphelper.Logger.Info("Stopping idle container", zap.String("container_id", container.ContainerID), zap.Duration("idle_timeout", policy.IdleTimeout))
+	result := dbtool.DB().Model(&models.Container{}).
+		Where("container_id = ? AND container_status = ?", container.ContainerID, models.ContainerRunning).
+		Update("container_status", models.ContainerStopping)
+	if result.Error != nil {
+		zaphelper.Logger.Error("failed to update container status", zap.Error(result.Error), zap.String("container_id", container.ContainerID))
+		return false
+	}
+
+	return result.RowsAffected > 0
+}
@@ -0,0 +1,178 @@
+package containerlifetime
+
+import (
+	"a1ctf/src/db/models"
+	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/webmodels"
+	"errors"
+	"time"
+)
+
+// 比赛和题目都没有设置时的默认值，和之前固定的 2 小时一致
+const (
+	DefaultInitialTTL     = 2 * time.Hour
+	DefaultExtendDuration = 2 * time.Hour
+	DefaultExtendWindow   = 30 * time.Minute
+)
+
+var (
+	// ErrExtendTooEarly 剩余时间还大于可延长的窗口
+	ErrExtendTooEarly = errors.New("container expire time is too far")
+	// ErrExtendLimitReached 延长次数用完
+	ErrExtendLimitReached = errors.New("container extend limit reached")
+	// ErrMaxLifetimeReached 已经到达最长存活时间，延长不会有效果
+	ErrMaxLifetimeReached = errors.New("container max lifetime reached")
+)
+
+// Policy 合并比赛和题目设置后的容器有效期策略，为 0 的上限表示不限制
+type Policy struct {
+	InitialTTL     time.Duration
+	ExtendDuration time.Duration
+	ExtendWindow   time.Duration
+	MaxExtensions  int64
+	MaxLifetime    time.Duration
+	IdleTimeout    time.Duration
+}
+
+func seconds(value *int64) time.Duration {
+	return time.Duration(*value) * time.Second
+}
+
+// 用 config 里设置了的字段覆盖 policy
+func (p *Policy) apply(config *models.ContainerLifetimeConfig) {
+	if config == nil {
+		return
+	}
+	if config.InitialTTL != nil {
+		p.InitialTTL = seconds(config.InitialTTL)
+	}
+	if config.ExtendDuration != nil {
+		p.ExtendDuration = seconds(config.ExtendDuration)
+	}
+	if config.ExtendWindow != nil {
+		p.ExtendWindow = seconds(config.ExtendWindow)
+	}
+	if config.MaxExtensions != nil {
+		p.MaxExtensions = *config.MaxExtensions
+	}
+	if config.MaxLifetime != nil {
+		p.MaxLifetime = seconds(config.MaxLifetime)
+	}
+	if config.IdleTimeout != nil {
+		p.IdleTimeout = seconds(config.IdleTimeout)
+	}
+}
+
+// Resolve 按 默认值 -> 比赛 -> 题目 的顺序合并有效期设置
+func Resolve(game *models.Game, gameChallenge *models.GameChallenge) Policy {
+	policy := Policy{
+		InitialTTL:     DefaultInitialTTL,
+		ExtendDuration: DefaultExtendDuration,
+		ExtendWindow:   DefaultExtendWindow,
+	}
+
+	if game != nil {
+		policy.apply(game.ContainerLifetime)
+	}
+	if gameChallenge != nil {
+		policy.apply(gameChallenge.ContainerLifetime)
+	}
+
+	return policy
+}
+
+// ValidConfig 有效期和延长时间必须大于 0，其他字段不能为负数
+func ValidConfig(config *models.ContainerLifetimeConfig) bool {
+	if config == nil {
+		return true
+	}
+
+	for _, value := range []*int64{config.InitialTTL, config.ExtendDuration} {
+		if value != nil && *value <= 0 {
+			return false
+		}
+	}
+	for _, value := range []*int64{config.ExtendWindow, config.MaxExtensions, config.MaxLifetime, config.IdleTimeout} {
+		if value != nil && *value < 0 {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Info 返回给选手的有效期策略
+func (p Policy) Info() *webmodels.UserContainerLifetime {
+	return &webmodels.UserContainerLifetime{
+		InitialTTL:     int64(p.InitialTTL.Seconds()),
+		ExtendDuration: int64(p.ExtendDuration.Seconds()),
+		ExtendWindow:   int64(p.ExtendWindow.Seconds()),
+		MaxExtensions:  p.MaxExtensions,
+		MaxLifetime:    int64(p.MaxLifetime.Seconds()),
+		IdleTimeout:    int64(p.IdleTimeout.Seconds()),
+	}
+}
+
+// MaxExpireTime 容器最晚的到期时间，不限制时返回 nil
+func (p Policy) MaxExpireTime(startTime time.Time) *time.Time {
+	if p.MaxLifetime <= 0 {
+		return nil
+	}
+	maxExpireTime := startTime.Add(p.MaxLifetime)
+	return &maxExpireTime
+}
+
+func (p Policy) capExpireTime(startTime time.Time, expireTime time.Time) time.Time {
+	if maxExpireTime := p.MaxExpireTime(startTime); maxExpireTime != nil && expireTime.After(*maxExpireTime) {
+		return *maxExpireTime
+	}
+	return expireTime
+}
+
+// ExpireTime 新建容器的到期时间
+func (p Policy) ExpireTime(startTime time.Time) time.Time {
+	return p.capExpireTime(startTime, startTime.Add(p.InitialTTL))
+}
+
+// Extend 延长后的到期时间为当前时间加上延长时间，不超过最长存活时间
+func (p Policy) Extend(container *models.Container, now time.Time) (time.Time, error) {
+	if container.ExpireTime.Sub(now) > p.ExtendWindow {
+		return time.Time{}, ErrExtendTooEarly
+	}
+
+	if p.MaxExtensions > 0 && container.ExtendCount >= p.MaxExtensions {
+		return time.Time{}, ErrExtendLimitReached
+	}
+
+	newExpireTime := p.capExpireTime(container.StartTime, now.Add(p.ExtendDuration))
+	if !newExpireTime.After(container.ExpireTime) {
+		return time.Time{}, ErrMaxLifetimeReached
+	}
+
+	return newExpireTime, nil
+}
+
+// IsIdle 运行中的容器超过空闲时间没有活动，还没有活动记录时从启动时间算起
+func (p Policy) IsIdle(container *models.Container, now time.Time) bool {
+	if p.IdleTimeout <= 0 || container.ContainerStatus != models.ContainerRunning {
+		return false
+	}
+
+	lastActiveTime := container.StartTime
+	if container.LastActiveTime != nil && container.LastActiveTime.After(lastActiveTime) {
+		lastActiveTime = *container.LastActiveTime
+	}
+
+	return now.Sub(lastActiveTime) > p.IdleTimeout
+}
+
+// Touch 记录容器有活动
+func Touch(containerIDs []string) error {
+	if len(containerIDs) == 0 {
+		return nil
+	}
+
+	return dbtool.DB().Model(&models.Container{}).
+		Where("container_id IN ?", containerIDs).
+		Update("last_active_time", time.Now().UTC()).Error
+}
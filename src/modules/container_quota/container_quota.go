@@ -0,0 +1,238 @@
+package containerquota
+
+import (
+	"a1ctf/src/db/models"
+	warmpool "a1ctf/src/modules/warm_pool"
+	dbtool "a1ctf/src/utils/db_tool"
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"a1ctf/src/utils/zaphelper"
+	"fmt"
+	"sort"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Resources 容器申请的资源，CPU 单位为 millicore，Memory 单位为 MiB
+type Resources struct {
+	CPU    int64 `json:"cpu"`
+	Memory int64 `json:"memory"`
+}
+
+func (r Resources) Add(other Resources) Resources {
+	return Resources{CPU: r.CPU + other.CPU, Memory: r.Memory + other.Memory}
+}
+
+// 占用配额的状态，排队中的容器只占用队伍配额
+var activeStatuses = []models.ContainerStatus{models.ContainerStarting, models.ContainerRunning, models.ContainerStopping}
+
+// RequestOf 一个题目容器所有子容器的资源之和
+func RequestOf(configs k8stool.A1Containers) Resources {
+	var result Resources
+	for _, config := range configs {
+		result.CPU += config.CPULimit
+		result.Memory += config.MemoryLimit
+	}
+	return result
+}
+
+// UsageOf 多个容器的资源之和
+func UsageOf(containers []models.Container) Resources {
+	var result Resources
+	for _, container := range containers {
+		result = result.Add(RequestOf(container.ContainerConfig))
+	}
+	return result
+}
+
+// 0 表示不限制
+func fits(usage Resources, cpuLimit int64, memoryLimit int64) bool {
+	return (cpuLimit <= 0 || usage.CPU <= cpuLimit) && (memoryLimit <= 0 || usage.Memory <= memoryLimit)
+}
+
+// ValidConfig 配额不能为负数
+func ValidConfig(config *models.ContainerQuotaConfig) bool {
+	if config == nil {
+		return true
+	}
+	return config.TeamCPULimit >= 0 && config.TeamMemoryLimit >= 0 && config.GameCPULimit >= 0 && config.GameMemoryLimit >= 0
+}
+
+// HasGameQuota 是否需要按整场比赛的配额排队
+func HasGameQuota(game *models.Game) bool {
+	return game.ContainerQuota != nil && (game.ContainerQuota.GameCPULimit > 0 || game.ContainerQuota.GameMemoryLimit > 0)
+}
+
+// FitsTeamQuota teamContainers 为队伍排队中、启动中和运行中的容器
+func FitsTeamQuota(game *models.Game, teamContainers []models.Container, request Resources) bool {
+	if game.ContainerQuota == nil {
+		return true
+	}
+	return fits(UsageOf(teamContainers).Add(request), game.ContainerQuota.TeamCPULimit, game.ContainerQuota.TeamMemoryLimit)
+}
+
+// FitsGameQuota 单个容器超过整场比赛的配额时永远不会被调度，直接拒绝
+func FitsGameQuota(game *models.Game, request Resources) bool {
+	if game.ContainerQuota == nil {
+		return true
+	}
+	return fits(request, game.ContainerQuota.GameCPULimit, game.ContainerQuota.GameMemoryLimit)
+}
+
+// 排队的顺序，先按申请时间，同一时间按容器 ID
+func queuedBefore(a *models.Container, b *models.Container) bool {
+	if !a.StartTime.Equal(b.StartTime) {
+		return a.StartTime.Before(b.StartTime)
+	}
+	return a.ContainerID < b.ContainerID
+}
+
+func sortQueue(queued []models.Container) {
+	sort.SliceStable(queued, func(i, j int) bool {
+		return queuedBefore(&queued[i], &queued[j])
+	})
+}
+
+// 按排队顺序依次调用 start，队首放不下时停止，返回启动了的容器
+// start 返回 false 表示容器已经不在排队了（比如被取消），跳过并且不占用配额
+func admitInOrder(game *models.Game, queued []models.Container, usage Resources, start func(container *models.Container) (bool, error)) ([]models.Container, error) {
+	sortQueue(queued)
+
+	admitted := make([]models.Container, 0)
+	for _, container := range queued {
+		request := RequestOf(container.ContainerConfig)
+		if HasGameQuota(game) && !fits(usage.Add(request), game.ContainerQuota.GameCPULimit, game.ContainerQuota.GameMemoryLimit) {
+			break
+		}
+
+		started, err := start(&container)
+		if err != nil {
+			return nil, err
+		}
+		if !started {
+			continue
+		}
+
+		usage = usage.Add(request)
+		admitted = append(admitted, container)
+	}
+
+	return admitted, nil
+}
+
+// 排队中的容器前面还有几个容器，从 1 开始
+func queuePosition(queued []models.Container, container *models.Container) int64 {
+	var ahead int64
+	for i := range queued {
+		if queuedBefore(&queued[i], container) {
+			ahead++
+		}
+	}
+	return ahead + 1
+}
+
+// Admit 按申请顺序启动排队中的容器，直到比赛的配额用完
+// 队首的容器放不下时后面的容器也继续等待，保证先申请的先启动
+func Admit(gameID int64) error {
+	var admitted []models.Container
+
+	err := dbtool.DB().Transaction(func(tx *gorm.DB) error {
+		// 锁住比赛，多个实例同时调度时不会超出配额
+		var game models.Game
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("game_id = ?", gameID).First(&game).Error; err != nil {
+			return err
+		}
+
+		var queued []models.Container
+		if err := tx.Where("game_id = ? AND container_status = ?", gameID, models.ContainerQueueing).
+			Order("start_time ASC, container_id ASC").Find(&queued).Error; err != nil {
+			return err
+		}
+		if len(queued) == 0 {
+			return nil
+		}
+
+		var usage Resources
+		if HasGameQuota(&game) {
+			var active []models.Container
+			if err := tx.Select("container_id", "container_config").Where("game_id = ? AND container_status IN ?", gameID, activeStatuses).Find(&active).Error; err != nil {
+				return err
+			}
+			usage = UsageOf(active)
+		}
+
+		now := time.Now().UTC()
+		var err error
+		admitted, err = admitInOrder(&game, queued, usage, func(container *models.Container) (bool, error) {
+			// 排队的时间不算在容器的有效期里
+			expireTime := now.Add(container.ExpireTime.Sub(container.StartTime))
+			result := tx.Model(&models.Container{}).
+				Where("container_id = ? AND container_status = ?", container.ContainerID, models.ContainerQueueing).
+				Updates(map[string]interface{}{
+					"container_status": models.ContainerStarting,
+					"start_time":       now,
+					"expire_time":      expireTime,
+				})
+			if result.Error != nil {
+				return false, result.Error
+			}
+			if result.RowsAffected == 0 {
+				return false, nil
+			}
+
+			container.ContainerStatus = models.ContainerStarting
+			container.StartTime = now
+			container.ExpireTime = expireTime
+			return true, nil
+		})
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to admit queued containers: %w", err)
+	}
+
+	for _, container := range admitted {
+		// 启动任务需要题目的网络配置和 flag，认领预热的 Pod 需要预热池的配置
+		if err := dbtool.DB().Preload("Challenge").Preload("TeamFlag").Preload("GameChallenge").Where("container_id = ?", container.ContainerID).First(&container).Error; err != nil {
+			zaphelper.Logger.Error("Failed to load admitted container", zap.Error(err), zap.String("container_id", container.ContainerID))
+			continue
+		}
+
+		zaphelper.Logger.Info("Starting container", zap.Any("container", container))
+		if err := warmpool.Start(container); err != nil {
+			zaphelper.Logger.Error("Failed to enqueue container start task", zap.Error(err), zap.String("container_id", container.ContainerID))
+		}
+	}
+
+	return nil
+}
+
+// AdmitAll 调度所有比赛里排队中的容器
+func AdmitAll() {
+	var gameIDs []int64
+	if err := dbtool.DB().Model(&models.Container{}).Distinct("game_id").Where("container_status = ?", models.ContainerQueueing).Pluck("game_id", &gameIDs).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load games with queued containers", zap.Error(err))
+		return
+	}
+
+	for _, gameID := range gameIDs {
+		if err := Admit(gameID); err != nil {
+			zaphelper.Logger.Error("Failed to admit queued containers", zap.Error(err), zap.Int64("game_id", gameID))
+		}
+	}
+}
+
+// QueuePosition 排队中的容器前面还有几个容器，从 1 开始
+// 和 Admit 用同一个排队顺序，显示的位置就是启动的先后
+func QueuePosition(container *models.Container) (int64, error) {
+	var queued []models.Container
+	if err := dbtool.DB().Select("container_id", "start_time").
+		Where("game_id = ? AND container_status = ?", container.GameID, models.ContainerQueueing).
+		Find(&queued).Error; err != nil {
+		return 0, err
+	}
+
+	return queuePosition(queued, container), nil
+}
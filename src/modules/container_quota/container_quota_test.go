@@ -0,0 +1,135 @@
+package containerquota
+
+import (
+	"a1ctf/src/db/models"
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func queuedContainer(id string, startTime time.Time, cpu int64) models.Container {
+	return models.Container{
+		ContainerID:     id,
+		ContainerStatus: models.ContainerQueueing,
+		StartTime:       startTime,
+		ContainerConfig: k8stool.A1Containers{{CPULimit: cpu, MemoryLimit: 64}},
+	}
+}
+
+func containerIDs(containers []models.Container) []string {
+	ids := make([]string, 0, len(containers))
+	for _, container := range containers {
+		ids = append(ids, container.ContainerID)
+	}
+	return ids
+}
+
+func TestAdmitInOrder(t *testing.T) {
+	base := time.Date(2025, 10, 1, 12, 0, 0, 0, time.UTC)
+	game := &models.Game{ContainerQuota: &models.ContainerQuotaConfig{GameCPULimit: 1000}}
+
+	tests := []struct {
+		name      string
+		game      *models.Game
+		queued    []models.Container
+		usage     Resources
+		cancelled map[string]bool
+		want      []string
+	}{
+		{
+			name: "start time order",
+			game: game,
+			queued: []models.Container{
+				queuedContainer("c", base.Add(2*time.Second), 300),
+				queuedContainer("a", base, 300),
+				queuedContainer("b", base.Add(time.Second), 300),
+			},
+			want: []string{"a", "b", "c"},
+		},
+		{
+			name: "same start time ordered by id",
+			game: game,
+			queued: []models.Container{
+				queuedContainer("b", base, 500),
+				queuedContainer("a", base, 500),
+				queuedContainer("c", base, 500),
+			},
+			want: []string{"a", "b"},
+		},
+		{
+			name: "head of line blocks smaller containers",
+			game: game,
+			queued: []models.Container{
+				queuedContainer("small-1", base, 200),
+				queuedContainer("big", base.Add(time.Second), 900),
+				queuedContainer("small-2", base.Add(2*time.Second), 100),
+			},
+			want: []string{"small-1"},
+		},
+		{
+			name: "active usage counts",
+			game: game,
+			queued: []models.Container{
+				queuedContainer("a", base, 400),
+				queuedContainer("b", base.Add(time.Second), 400),
+			},
+			usage: Resources{CPU: 500},
+			want:  []string{"a"},
+		},
+		{
+			name: "cancelled container does not use quota",
+			game: game,
+			queued: []models.Container{
+				queuedContainer("a", base, 600),
+				queuedContainer("b", base.Add(time.Second), 600),
+			},
+			cancelled: map[string]bool{"a": true},
+			want:      []string{"b"},
+		},
+		{
+			name: "no game quota admits all",
+			game: &models.Game{},
+			queued: []models.Container{
+				queuedContainer("b", base.Add(time.Second), 5000),
+				queuedContainer("a", base, 5000),
+			},
+			want: []string{"a", "b"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			positions := make(map[string]int64)
+			for i := range tt.queued {
+				positions[tt.queued[i].ContainerID] = queuePosition(tt.queued, &tt.queued[i])
+			}
+
+			started := make([]string, 0)
+			admitted, err := admitInOrder(tt.game, tt.queued, tt.usage, func(container *models.Container) (bool, error) {
+				started = append(started, container.ContainerID)
+				container.ContainerStatus = models.ContainerStarting
+				return !tt.cancelled[container.ContainerID], nil
+			})
+			if err != nil {
+				t.Fatalf("admitInOrder() error = %v", err)
+			}
+
+			if got := containerIDs(admitted); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("admitInOrder() = %v, want %v", got, tt.want)
+			}
+			for _, container := range admitted {
+				if container.ContainerStatus != models.ContainerStarting {
+					t.Errorf("admitted container %s status = %s, want %s", container.ContainerID, container.ContainerStatus, models.ContainerStarting)
+				}
+			}
+
+			// 显示的排队位置和启动的先后一致
+			for i, id := range started {
+				if positions[id] != int64(i+1) {
+					t.Errorf("queuePosition(%s) = %d, want %d", id, positions[id], i+1)
+				}
+			}
+		})
+	}
+}
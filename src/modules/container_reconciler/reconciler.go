@@ -0,0 +1,300 @@
+package containerreconciler
+
+import (
+	"a1ctf/src/db/models"
+	tcpgateway "a1ctf/src/modules/tcp_gateway"
+	"a1ctf/src/tasks"
+	containerbackend "a1ctf/src/utils/container_backend"
+	dbtool "a1ctf/src/utils/db_tool"
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"a1ctf/src/utils/zaphelper"
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"k8s.io/client-go/util/workqueue"
+)
+
+// 待处理的题目容器，key 为 ingame_id/team_hash，同一个容器的多次变化会合并
+var queue workqueue.TypedRateLimitingInterface[string]
+
+// Start 监听题目 Pod 的变化，按 Pod 状态更新数据库里的容器
+// 数据库侧的排队、到期和关闭仍然由容器任务处理
+func Start(ctx context.Context) error {
+	queue = workqueue.NewTypedRateLimitingQueueWithConfig(
+		workqueue.DefaultTypedControllerRateLimiter[string](),
+		workqueue.TypedRateLimitingQueueConfig[string]{Name: "a1ctf-containers"},
+	)
+
+	notify := func(inGameID int64, teamHash string) {
+		queue.Add(k8stool.ContainerKey(inGameID, teamHash))
+	}
+
+	if err := containerbackend.Get().Watch(ctx, notify); err != nil {
+		queue.ShutDown()
+		return err
+	}
+
+	workers := viper.GetInt("container-reconciler.workers")
+	if workers <= 0 {
+		workers = 4
+	}
+
+	for i := 0; i < workers; i++ {
+		go func() {
+			for processNextItem() {
+			}
+		}()
+	}
+
+	go func() {
+		<-ctx.Done()
+		queue.ShutDown()
+	}()
+
+	zaphelper.Logger.Info("Container reconciler started", zap.Int("workers", workers))
+
+	return nil
+}
+
+// Enqueue 主动检查一个容器，用于数据库状态变化后 Pod 没有新事件的情况
+func Enqueue(inGameID int64, teamHash string) {
+	if queue == nil {
+		return
+	}
+	queue.Add(k8stool.ContainerKey(inGameID, teamHash))
+}
+
+func processNextItem() bool {
+	key, shutdown := queue.Get()
+	if shutdown {
+		return false
+	}
+	defer queue.Done(key)
+
+	if err := reconcile(key); err != nil {
+		zaphelper.Logger.Warn("Failed to reconcile container, retrying", zap.Error(err), zap.String("key", key))
+		queue.AddRateLimited(key)
+		return true
+	}
+
+	queue.Forget(key)
+	return true
+}
+
+// 和缓存里的 Pod 状态对比，处理启动完成和启动失败的容器
+func reconcile(key string) error {
+	inGameIDStr, teamHash, found := strings.Cut(key, "/")
+	if !found {
+		return nil
+	}
+
+	inGameID, err := strconv.ParseInt(inGameIDStr, 10, 64)
+	if err != nil {
+		return nil
+	}
+
+	podStatus, err := containerbackend.Get().PodStatus(inGameID, teamHash)
+	if err != nil {
+		return err
+	}
+	// Pod 已经删除，数据库里的容器由容器任务关闭，多余的资源由回收任务处理
+	if podStatus == nil {
+		return nil
+	}
+
+	var containers []models.Container
+	if err := dbtool.DB().Where("ingame_id = ? AND team_hash = ? AND container_status NOT IN ?", inGameID, teamHash, []models.ContainerStatus{models.ContainerError, models.ContainerStopped}).
+		Preload("Challenge").Preload("TeamFlag").Find(&containers).Error; err != nil {
+		return fmt.Errorf("failed to load container: %w", err)
+	}
+	if len(containers) == 0 {
+		return nil
+	}
+	container := &containers[0]
+
+	switch podStatus.Status {
+	case k8stool.CustomPodRunning:
+		if container.ContainerStatus == models.ContainerStarting {
+			// 如果远程服务器Pod已经是Running状态，就获取端口并且更新数据库
+			zaphelper.Logger.Info("Getting container port", zap.Any("container", container))
+			if err := getContainerPorts(podInfoOf(container), container); err != nil {
+				zaphelper.Logger.Error("Failed to get container ports", zap.Error(err), zap.Any("container", container))
+				tasks.NewContainerStopTask(*container)
+			}
+		}
+	case k8stool.CustomPodFailed:
+		zaphelper.Logger.Info("Stopping failed container", zap.Any("container", container), zap.Any("pod_status", podStatus))
+		tasks.NewContainerFailedTask(*container, *podStatus)
+	default:
+		// 等待中的容器
+	}
+
+	return nil
+}
+
+func podInfoOf(container *models.Container) k8stool.PodInfo {
+	return k8stool.PodInfo{
+		Name:       container.PodID(),
+		TeamHash:   container.TeamHash,
+		Containers: container.ContainerConfig,
+		Labels: map[string]string{
+			"team_hash": container.TeamHash,
+			"ingame_id": fmt.Sprintf("%d", container.InGameID),
+		},
+		Flag:     container.TeamFlag.FlagContent,
+		AllowWAN: container.Challenge.AllowWAN,
+		AllowDNS: container.Challenge.AllowDNS,
+	}
+}
+
+func getContainerPorts(podInfo k8stool.PodInfo, task *models.Container) error {
+	ports, err := containerbackend.Get().PodPorts(&podInfo)
+	if err != nil {
+		return fmt.Errorf("getContainerPorts error: %w", err)
+	}
+
+	for index, container := range task.ContainerConfig {
+		for _, expose_port := range container.ExposePorts {
+			port_name := fmt.Sprintf("%d-%s", index, expose_port.Name)
+
+			expose_ports := make([]models.ExposePort, 0)
+
+			for _, port := range *ports {
+				if port.Name == port_name {
+
+					if port.URL != "" {
+						expose_ports = append(expose_ports, urlExposePort(expose_port.Name, port.URL))
+						continue
+					}
+
+					address, ok := k8stool.NodeAddressMap[port.NodeName]
+					if !ok {
+						address = port.NodeName
+					}
+
+					// 通过网关暴露时选手只能看到网关地址和 Token
+					if tcpgateway.Enabled() {
+						// k8s 不再分配 NodePort，网关连接 Service 的集群内地址
+						targetHost, targetPort := address, port.NodePort
+						if port.ClusterIP != "" {
+							targetHost, targetPort = port.ClusterIP, port.Port
+						}
+						if viper.GetBool("tcp-gateway.dial-pod-ip") && port.PodIP != "" {
+							targetHost, targetPort = port.PodIP, port.Port
+						}
+
+						gatewayPort, err := tcpgateway.CreateRoute(task, container.Name, expose_port.Name, targetHost, targetPort)
+						if err != nil {
+							return fmt.Errorf("getContainerPorts error: %w", err)
+						}
+						expose_ports = append(expose_ports, gatewayPort)
+						continue
+					}
+
+					expose_ports = append(expose_ports, models.ExposePort{
+						PortName: expose_port.Name,
+						Port:     port.NodePort,
+						IP:       address,
+						Type:     models.ExposePortTypePort,
+					})
+				}
+			}
+
+			task.ContainerExposeInfos = append(task.ContainerExposeInfos, models.ContainerExposeInfo{
+				ContainerName: container.Name,
+				ExposePorts:   expose_ports,
+			})
+		}
+	}
+
+	if err := dbtool.DB().Model(task).Updates(map[string]interface{}{
+		"container_status": models.ContainerRunning,
+		"expose_ports":     task.ContainerExposeInfos,
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update container status: %v", err)
+	}
+
+	tasks.LogContainerOperation(nil, nil, models.ActionContainerStarted, task.ContainerID, map[string]interface{}{
+		"game_id":               task.GameID,
+		"team_id":               task.TeamID,
+		"team_hash":             task.TeamHash,
+		"challenge_name":        task.ChallengeName,
+		"ingame_id":             task.InGameID,
+		"pod_name":              podInfo.Name,
+		"container_id":          task.ContainerID,
+		"container_expose_info": task.ContainerExposeInfos,
+	}, nil)
+
+	return nil
+}
+
+// 子域名路由的端口，IP 和 Port 填域名和协议的默认端口，兼容只显示 ip:port 的客户端
+func urlExposePort(portName string, rawURL string) models.ExposePort {
+	exposePort := models.ExposePort{
+		PortName: portName,
+		Type:     models.ExposePortTypeURL,
+		URL:      rawURL,
+		Port:     80,
+	}
+
+	if parsed, err := url.Parse(rawURL); err == nil {
+		exposePort.IP = parsed.Hostname()
+		if parsed.Scheme == "https" {
+			exposePort.Port = 443
+		}
+	}
+
+	return exposePort
+}
+
+// CollectOrphans 删除数据库里已经没有运行中容器的后端资源，如 Pod、Service 和 NetworkPolicy
+func CollectOrphans() {
+	grace := viper.GetDuration("container-reconciler.orphan-grace")
+	if grace <= 0 {
+		grace = 2 * time.Minute
+	}
+
+	var containers []models.Container
+	if err := dbtool.DB().Select("ingame_id", "team_hash", "pod_name").Where("container_status NOT IN ?", []models.ContainerStatus{models.ContainerError, models.ContainerStopped}).Find(&containers).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load living containers", zap.Error(err))
+		return
+	}
+
+	liveNames := make(map[string]bool, len(containers))
+	for _, container := range containers {
+		liveNames[container.PodID()] = true
+	}
+
+	collectOrphans(containerbackend.Get(), liveNames, grace)
+}
+
+// collectOrphans 先读数据库再列出后端的对象，读完数据库之后才创建的容器靠 grace 保护
+func collectOrphans(backend containerbackend.ContainerBackend, liveNames map[string]bool, grace time.Duration) {
+	resources, err := backend.Resources()
+	if err != nil {
+		zaphelper.Logger.Error("Failed to list container resources", zap.Error(err))
+		return
+	}
+
+	// 只回收创建时间超过 grace 的对象，避免和正在创建的容器冲突
+	now := time.Now()
+	orphans := make(map[string]bool)
+	for _, resource := range resources {
+		if strings.HasPrefix(resource.Name, "cl-") && !liveNames[resource.Name] && now.Sub(resource.CreateTime) > grace {
+			orphans[resource.Name] = true
+		}
+	}
+
+	for name := range orphans {
+		zaphelper.Logger.Info("Deleting orphaned container resources", zap.String("name", name))
+		if err := backend.DeleteOrphan(name); err != nil {
+			zaphelper.Logger.Error("Failed to delete orphaned container resources", zap.Error(err), zap.String("name", name))
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package containerreconciler
+
+import (
+	containerbackend "a1ctf/src/utils/container_backend"
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"a1ctf/src/utils/zaphelper"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func createTestPod(t *testing.T, backend *containerbackend.FakeBackend, name string, age time.Duration) {
+	t.Helper()
+
+	if err := backend.CreatePod(&k8stool.PodInfo{Name: name}); err != nil {
+		t.Fatalf("CreatePod(%s) error = %v", name, err)
+	}
+	if err := backend.SetPodCreateTime(name, time.Now().Add(-age)); err != nil {
+		t.Fatalf("SetPodCreateTime(%s) error = %v", name, err)
+	}
+}
+
+func podNames(backend *containerbackend.FakeBackend) map[string]bool {
+	names := make(map[string]bool)
+	for _, pod := range backend.Pods() {
+		names[pod.Info.Name] = true
+	}
+	return names
+}
+
+func TestCollectOrphans(t *testing.T) {
+	zaphelper.Logger = zap.NewNop()
+
+	const grace = time.Minute
+
+	backend := containerbackend.NewFakeBackend()
+	createTestPod(t, backend, "cl-1-live", time.Hour)
+	createTestPod(t, backend, "cl-1-orphan", time.Hour)
+	// 数据库里还没有记录，但是刚刚创建，可能是读完数据库之后才申请的容器
+	createTestPod(t, backend, "cl-1-fresh", time.Second)
+	// 不是题目容器创建的对象
+	createTestPod(t, backend, "warm-1-abcdef", time.Hour)
+
+	liveNames := map[string]bool{"cl-1-live": true}
+
+	// 回收的同时不断有新的容器在创建，它们都不在读到的数据库记录里
+	var wg sync.WaitGroup
+	created := make(chan string, 50)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for idx := 0; idx < cap(created); idx++ {
+			name := fmt.Sprintf("cl-2-team%02d", idx)
+			if err := backend.CreatePod(&k8stool.PodInfo{Name: name}); err != nil {
+				t.Errorf("CreatePod(%s) error = %v", name, err)
+				continue
+			}
+			created <- name
+		}
+		close(created)
+	}()
+
+	for idx := 0; idx < 10; idx++ {
+		collectOrphans(backend, liveNames, grace)
+	}
+	wg.Wait()
+	collectOrphans(backend, liveNames, grace)
+
+	names := podNames(backend)
+	if names["cl-1-orphan"] {
+		t.Errorf("orphan older than grace should be deleted")
+	}
+	for _, name := range []string{"cl-1-live", "cl-1-fresh", "warm-1-abcdef"} {
+		if !names[name] {
+			t.Errorf("%s should be kept", name)
+		}
+	}
+	for name := range created {
+		if !names[name] {
+			t.Errorf("in-flight container %s should be kept", name)
+		}
+	}
+
+	// 超过 grace 之后数据库里还是没有的容器才会被回收
+	if err := backend.SetPodCreateTime("cl-1-fresh", time.Now().Add(-2*grace)); err != nil {
+		t.Fatalf("SetPodCreateTime() error = %v", err)
+	}
+	collectOrphans(backend, liveNames, grace)
+	if podNames(backend)["cl-1-fresh"] {
+		t.Errorf("cl-1-fresh should be deleted after grace")
+	}
+}
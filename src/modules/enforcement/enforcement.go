@@ -0,0 +1,421 @@
+package enforcement
+
+import (
+	"a1ctf/src/db/models"
+	scoreengine "a1ctf/src/modules/score_engine"
+	"a1ctf/src/tasks"
+	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/utils/zaphelper"
+	"errors"
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// 每次处理的作弊记录数量
+const batchSize = 200
+
+// 规则对这支队伍不适用，比如没有可以作废的解题、队伍已经被禁赛，不记录
+var errSkip = errors.New("enforcement skipped")
+
+// Run 按比赛的处罚规则处理新的作弊记录
+func Run() {
+	for {
+		var cheats []models.Cheat
+		if err := dbtool.DB().Where("enforced_at IS NULL").Preload("Game").Order("cheat_time ASC").Limit(batchSize).Find(&cheats).Error; err != nil {
+			zaphelper.Logger.Error("Failed to load cheats for enforcement", zap.Error(err))
+			return
+		}
+
+		for idx := range cheats {
+			if err := Enforce(&cheats[idx]); err != nil {
+				// 留到下一次再处理，避免一直重试同一批
+				zaphelper.Logger.Error("Failed to enforce cheat", zap.Error(err), zap.String("cheat_id", cheats[idx].CheatID))
+				return
+			}
+		}
+
+		if len(cheats) < batchSize {
+			return
+		}
+	}
+}
+
+// Enforce 对一条作弊记录执行所有匹配的规则，cheat 需要预加载 Game
+func Enforce(cheat *models.Cheat) error {
+	if policy := cheat.Game.EnforcementPolicy; policy != nil {
+		for idx := range policy.Rules {
+			rule := &policy.Rules[idx]
+			if !matches(rule, cheat) {
+				continue
+			}
+			if err := applyRule(policy, rule, cheat); err != nil {
+				return err
+			}
+		}
+	}
+
+	return dbtool.DB().Model(&models.Cheat{}).Where("cheat_id = ?", cheat.CheatID).Update("enforced_at", time.Now().UTC()).Error
+}
+
+func applyRule(policy *models.EnforcementPolicy, rule *models.EnforcementRule, cheat *models.Cheat) error {
+	hash := ruleHash(rule)
+
+	teamIDs := []int64{cheat.TeamID}
+	relevantTeam := cheat.ExtraData.RelevantTeam
+	// 通知管理员的邮件里已经有相关队伍，只发一次
+	// 关联分析给双方各写一条记录，相关队伍由自己的记录处罚
+	if rule.IncludeRelevantTeam && rule.Action != models.EnforcementNotifyAdmins && cheat.Fingerprint == nil && relevantTeam != 0 && relevantTeam != cheat.TeamID {
+		teamIDs = append(teamIDs, relevantTeam)
+	}
+
+	for _, teamID := range teamIDs {
+		details := models.EnforcementDetails{Rule: *rule}
+		var key string
+
+		if rule.Threshold > 1 {
+			count, err := countCheats(cheat.GameID, teamID, rule)
+			if err != nil {
+				return err
+			}
+			if count < int64(rule.Threshold) {
+				continue
+			}
+			details.CheatCount = count
+			key = fmt.Sprintf("rule:%s:team:%d", hash, teamID)
+		} else {
+			key = fmt.Sprintf("rule:%s:cheat:%s:team:%d", hash, cheat.CheatID, teamID)
+		}
+
+		if err := execute(policy, cheat, teamID, key, details); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 队伍在比赛里匹配规则的作弊记录数量，开启 IncludeRelevantTeam 时也算作为相关队伍的记录
+func countCheats(gameID int64, teamID int64, rule *models.EnforcementRule) (int64, error) {
+	query := dbtool.DB().Model(&models.Cheat{}).Where("game_id = ?", gameID)
+	if rule.IncludeRelevantTeam {
+		query = query.Where("(team_id = ? OR (fingerprint IS NULL AND (extra_data->>'relevant_team')::bigint = ?))", teamID, teamID)
+	} else {
+		query = query.Where("team_id = ?", teamID)
+	}
+	if len(rule.CheatTypes) > 0 {
+		query = query.Where("cheat_type IN ?", rule.CheatTypes)
+	}
+	if rule.MinConfidence > 0 {
+		query = query.Where("(extra_data->>'confidence' IS NULL OR (extra_data->>'confidence')::float >= ?)", rule.MinConfidence)
+	}
+
+	var count int64
+	err := query.Count(&count).Error
+	return count, err
+}
+
+func execute(policy *models.EnforcementPolicy, cheat *models.Cheat, teamID int64, key string, details models.EnforcementDetails) error {
+	err := attempt(policy, cheat, teamID, key, details)
+	switch {
+	case err == nil, errors.Is(err, gorm.ErrDuplicatedKey):
+		// 已经执行过
+		return nil
+	case errors.Is(err, errSkip):
+		// 规则对这支队伍不适用，之前失败的记录也不用再重试
+		return dbtool.DB().Where("enforcement_key = ? AND status = ?", key, models.EnforcementFailed).Delete(&models.Enforcement{}).Error
+	}
+
+	return recordFailure(cheat, teamID, key, details, err)
+}
+
+// attempt 执行一次处罚，同一个 key 已经失败过时在原来的记录上重试
+func attempt(policy *models.EnforcementPolicy, cheat *models.Cheat, teamID int64, key string, details models.EnforcementDetails) error {
+	enforcement := models.Enforcement{
+		EnforcementKey: key,
+		GameID:         cheat.GameID,
+		CheatID:        cheat.CheatID,
+		TeamID:         teamID,
+		RuleName:       details.Rule.Name,
+		Action:         details.Rule.Action,
+		Details:        details,
+		Status:         models.EnforcementApplied,
+		CreateTime:     time.Now().UTC(),
+		Attempts:       1,
+	}
+
+	// 事务提交后刷新分数和排名
+	var after func()
+	err := dbtool.DB().Transaction(func(tx *gorm.DB) error {
+		var existing models.Enforcement
+		err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("enforcement_key = ?", key).First(&existing).Error
+		switch {
+		case err == nil:
+			if existing.Status != models.EnforcementFailed {
+				return gorm.ErrDuplicatedKey
+			}
+			enforcement.EnforcementID = existing.EnforcementID
+			enforcement.CreateTime = existing.CreateTime
+			enforcement.Attempts = existing.Attempts + 1
+			if err := tx.Model(&models.Enforcement{}).Where("enforcement_id = ?", existing.EnforcementID).Updates(map[string]interface{}{
+				"status":        enforcement.Status,
+				"error_message": nil,
+				"attempts":      enforcement.Attempts,
+			}).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			if err := tx.Create(&enforcement).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		after, err = apply(tx, policy, cheat, &enforcement)
+		if err != nil {
+			return err
+		}
+
+		return tx.Model(&models.Enforcement{}).Where("enforcement_id = ?", enforcement.EnforcementID).Update("details", enforcement.Details).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	if after != nil {
+		after()
+	}
+	logEnforcement(cheat, &enforcement, nil)
+	return nil
+}
+
+// recordFailure 记录失败的处罚，由处罚任务自动重试，超过次数后需要管理员手动重试
+func recordFailure(cheat *models.Cheat, teamID int64, key string, details models.EnforcementDetails, cause error) error {
+	errorMessage := cause.Error()
+	failed := models.Enforcement{
+		EnforcementKey: key,
+		GameID:         cheat.GameID,
+		CheatID:        cheat.CheatID,
+		TeamID:         teamID,
+		RuleName:       details.Rule.Name,
+		Action:         details.Rule.Action,
+		Details:        details,
+		Status:         models.EnforcementFailed,
+		ErrorMessage:   &errorMessage,
+		CreateTime:     time.Now().UTC(),
+		Attempts:       1,
+	}
+
+	// 重试失败时更新原来的记录，attempt 的事务已经回滚，执行次数在这里加
+	if err := dbtool.DB().Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "enforcement_key"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"error_message": errorMessage,
+			"attempts":      gorm.Expr(models.TableNameEnforcement + ".attempts + 1"),
+		}),
+		Where: clause.Where{Exprs: []clause.Expression{clause.Eq{Column: clause.Column{Table: models.TableNameEnforcement, Name: "status"}, Value: models.EnforcementFailed}}},
+	}).Create(&failed).Error; err != nil {
+		return err
+	}
+
+	logEnforcement(cheat, &failed, cause)
+	return nil
+}
+
+func apply(tx *gorm.DB, policy *models.EnforcementPolicy, cheat *models.Cheat, enforcement *models.Enforcement) (func(), error) {
+	switch enforcement.Action {
+	case models.EnforcementInvalidateSolve:
+		return invalidateSolve(tx, cheat, enforcement)
+	case models.EnforcementScorePenalty:
+		return scorePenalty(tx, policy, cheat, enforcement)
+	case models.EnforcementSetPending:
+		return setTeamStatus(tx, enforcement, models.ParticipatePending)
+	case models.EnforcementBan:
+		return setTeamStatus(tx, enforcement, models.ParticipateBanned)
+	case models.EnforcementNotifyAdmins:
+		return nil, notifyAdmins(tx, cheat, enforcement)
+	}
+	return nil, fmt.Errorf("unknown action %s", enforcement.Action)
+}
+
+func invalidateSolve(tx *gorm.DB, cheat *models.Cheat, enforcement *models.Enforcement) (func(), error) {
+	if cheat.ChallengeID == nil {
+		return nil, errSkip
+	}
+	challengeID := *cheat.ChallengeID
+
+	var solveIDs []string
+	if err := tx.Model(&models.Solve{}).Where("game_id = ? AND challenge_id = ? AND team_id = ? AND solve_status = ?", enforcement.GameID, challengeID, enforcement.TeamID, models.SolveCorrect).Pluck("solve_id", &solveIDs).Error; err != nil {
+		return nil, err
+	}
+	if len(solveIDs) == 0 {
+		return nil, errSkip
+	}
+
+	if err := tx.Model(&models.Solve{}).Where("solve_id IN ?", solveIDs).Update("solve_status", models.SolveInvalid).Error; err != nil {
+		return nil, err
+	}
+
+	enforcement.Details.ChallengeID = &challengeID
+	enforcement.Details.SolveIDs = solveIDs
+
+	return func() { refreshChallenge(enforcement.GameID, challengeID) }, nil
+}
+
+func scorePenalty(tx *gorm.DB, policy *models.EnforcementPolicy, cheat *models.Cheat, enforcement *models.Enforcement) (func(), error) {
+	// 分数修正必须有创建人，记在设置规则的管理员名下
+	createdBy, err := uuid.Parse(policy.UpdatedBy)
+	if err != nil {
+		return nil, errors.New("enforcement policy has no valid operator")
+	}
+
+	now := time.Now().UTC()
+	adjustment := models.ScoreAdjustment{
+		TeamID:         enforcement.TeamID,
+		GameID:         enforcement.GameID,
+		AdjustmentType: models.AdjustmentTypeCheat,
+		ScoreChange:    -enforcement.Details.Rule.Penalty,
+		Reason:         fmt.Sprintf("Enforcement #%d %s: %s", enforcement.EnforcementID, enforcement.RuleName, cheat.CheatType),
+		CreatedBy:      createdBy,
+		CreatedAt:      now,
+		UpdatedAt:      now,
+	}
+	if err := tx.Create(&adjustment).Error; err != nil {
+		return nil, err
+	}
+	if err := tx.Model(&models.Team{}).Where("team_id = ?", enforcement.TeamID).Update("team_score", gorm.Expr("team_score + ?", adjustment.ScoreChange)).Error; err != nil {
+		return nil, err
+	}
+
+	enforcement.Details.AdjustmentID = &adjustment.AdjustmentID
+
+	return func() { scoreengine.NotifyAdjustment(enforcement.GameID, enforcement.TeamID) }, nil
+}
+
+func setTeamStatus(tx *gorm.DB, enforcement *models.Enforcement, status models.ParticipationStatus) (func(), error) {
+	var team models.Team
+	if err := tx.Where("team_id = ?", enforcement.TeamID).First(&team).Error; err != nil {
+		return nil, err
+	}
+
+	// 管理员队伍不处罚，待审核只针对已经审核通过的队伍
+	if team.TeamType == models.TeamTypeAdmin || team.TeamStatus == status || team.TeamStatus == models.ParticipateBanned {
+		return nil, errSkip
+	}
+	if status == models.ParticipatePending && team.TeamStatus != models.ParticipateApproved {
+		return nil, errSkip
+	}
+
+	if err := tx.Model(&models.Team{}).Where("team_id = ?", team.TeamID).Update("team_status", status).Error; err != nil {
+		return nil, err
+	}
+
+	enforcement.Details.OldStatus = team.TeamStatus
+	enforcement.Details.NewStatus = status
+
+	return func() { refreshTeam(team.GameID, team.TeamID) }, nil
+}
+
+func notifyAdmins(tx *gorm.DB, cheat *models.Cheat, enforcement *models.Enforcement) error {
+	var admins []models.User
+	if err := tx.Where("role = ? AND email IS NOT NULL AND email <> ''", models.UserRoleAdmin).Find(&admins).Error; err != nil {
+		return err
+	}
+	if len(admins) == 0 {
+		return errors.New("no admin has an email address")
+	}
+
+	var team models.Team
+	if err := tx.Where("team_id = ?", enforcement.TeamID).First(&team).Error; err != nil {
+		return err
+	}
+
+	subject := fmt.Sprintf("[%s] %s: %s", cheat.Game.Name, cheat.CheatType, team.TeamName)
+
+	var body strings.Builder
+	fmt.Fprintf(&body, "<p>Rule <b>%s</b> matched a cheat in <b>%s</b>.</p><ul>", html.EscapeString(enforcement.RuleName), html.EscapeString(cheat.Game.Name))
+	fmt.Fprintf(&body, "<li>Type: %s</li>", html.EscapeString(string(cheat.CheatType)))
+	fmt.Fprintf(&body, "<li>Team: %s (#%d)</li>", html.EscapeString(team.TeamName), team.TeamID)
+	if cheat.ExtraData.RelevantTeam != 0 {
+		fmt.Fprintf(&body, "<li>Relevant team: %s (#%d)</li>", html.EscapeString(cheat.ExtraData.RelevantTeamName), cheat.ExtraData.RelevantTeam)
+	}
+	if cheat.ExtraData.Confidence > 0 {
+		fmt.Fprintf(&body, "<li>Confidence: %.2f</li>", cheat.ExtraData.Confidence)
+	}
+	if enforcement.Details.CheatCount > 0 {
+		fmt.Fprintf(&body, "<li>Cheats of this team: %d</li>", enforcement.Details.CheatCount)
+	}
+	fmt.Fprintf(&body, "<li>Time: %s</li></ul>", cheat.CheatTime.Format(time.RFC3339))
+	fmt.Fprintf(&body, `<p><a href="%s/admin/games/%d/events">%s</a></p>`, viper.GetString("system.baseURL"), cheat.GameID, cheat.CheatID)
+
+	recipients := make([]string, 0, len(admins))
+	for _, admin := range admins {
+		if err := tasks.NewCheatAlertMailTask(admin, subject, body.String()); err != nil {
+			return err
+		}
+		recipients = append(recipients, *admin.Email)
+	}
+
+	enforcement.Details.Recipients = recipients
+	return nil
+}
+
+// 解题记录变了，题目分数和排名都要重算
+func refreshChallenge(gameID int64, challengeID int64) {
+	scoreengine.NotifyChallenge(gameID, challengeID)
+	if err := tasks.NewRecalculateRankForAChallengeTask(gameID, []int64{challengeID}); err != nil {
+		zaphelper.Logger.Warn("Failed to enqueue rank recalculation", zap.Error(err), zap.Int64("game_id", gameID))
+	}
+}
+
+// 队伍状态变了，队伍解出的题目的排名都要重算
+func refreshTeam(gameID int64, teamID int64) {
+	var challengeIDs []int64
+	if err := dbtool.DB().Model(&models.Solve{}).Where("team_id = ?", teamID).Distinct().Pluck("challenge_id", &challengeIDs).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load solved challenges", zap.Error(err), zap.Int64("team_id", teamID))
+	}
+	if len(challengeIDs) > 0 {
+		if err := tasks.NewRecalculateRankForAChallengeTask(gameID, challengeIDs); err != nil {
+			zaphelper.Logger.Warn("Failed to enqueue rank recalculation", zap.Error(err), zap.Int64("game_id", gameID))
+		}
+	}
+	scoreengine.NotifyTeamStatus(gameID, teamID)
+}
+
+func logEnforcement(cheat *models.Cheat, enforcement *models.Enforcement, err error) {
+	status := models.LogStatusSuccess
+	var errorMessage *string
+	if err != nil {
+		status = models.LogStatusFailed
+		errMsg := err.Error()
+		errorMessage = &errMsg
+	}
+
+	tasks.LogOperation(tasks.LogEntry{
+		Category:     models.LogCategorySystem,
+		Action:       models.ActionEnforce,
+		ResourceType: models.ResourceTypeCheat,
+		ResourceID:   &cheat.CheatID,
+		Details: map[string]interface{}{
+			"enforcement_id": enforcement.EnforcementID,
+			"cheat_type":     cheat.CheatType,
+			"rule_name":      enforcement.RuleName,
+			"action":         enforcement.Action,
+			"team_id":        enforcement.TeamID,
+			"details":        enforcement.Details,
+		},
+		Status:       status,
+		ErrorMessage: errorMessage,
+		GameID:       &enforcement.GameID,
+		ChallengeID:  cheat.ChallengeID,
+		TeamID:       &enforcement.TeamID,
+	})
+}
@@ -0,0 +1,89 @@
+package enforcement
+
+import (
+	"a1ctf/src/db/models"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/bytedance/sonic"
+)
+
+var knownCheatTypes = []models.CheatType{
+	models.CheatSubmitSomeonesFlag,
+	models.CheatSubmitWithoutDownloadAttachments,
+	models.CheatSubmitWithoutStartContainer,
+	models.CheatSubmitHoneypotFlag,
+	models.CheatSubmitCanaryFlag,
+	models.CheatSharedIP,
+	models.CheatSharedSubnet,
+	models.CheatSyncedSolves,
+	models.CheatSameWrongFlag,
+	models.CheatSharedAccount,
+}
+
+// ValidatePolicy 规则名字不能重复，动作需要的参数必须设置
+func ValidatePolicy(policy *models.EnforcementPolicy) error {
+	if policy == nil {
+		return nil
+	}
+
+	names := make(map[string]bool, len(policy.Rules))
+	for _, rule := range policy.Rules {
+		if strings.TrimSpace(rule.Name) == "" {
+			return errors.New("rule name must not be empty")
+		}
+		if names[rule.Name] {
+			return fmt.Errorf("duplicate rule name %s", rule.Name)
+		}
+		names[rule.Name] = true
+
+		for _, cheatType := range rule.CheatTypes {
+			if !slices.Contains(knownCheatTypes, cheatType) {
+				return fmt.Errorf("rule %s: unknown cheat type %s", rule.Name, cheatType)
+			}
+		}
+		if rule.MinConfidence < 0 || rule.MinConfidence > 1 {
+			return fmt.Errorf("rule %s: min confidence must be between 0 and 1", rule.Name)
+		}
+		if rule.Threshold < 0 {
+			return fmt.Errorf("rule %s: threshold must not be negative", rule.Name)
+		}
+
+		switch rule.Action {
+		case models.EnforcementInvalidateSolve:
+			// 累计触发时没有对应的题目
+			if rule.Threshold > 1 {
+				return fmt.Errorf("rule %s: invalidate solve can only be applied per cheat", rule.Name)
+			}
+		case models.EnforcementScorePenalty:
+			if rule.Penalty <= 0 {
+				return fmt.Errorf("rule %s: penalty must be positive", rule.Name)
+			}
+		case models.EnforcementSetPending, models.EnforcementBan, models.EnforcementNotifyAdmins:
+		default:
+			return fmt.Errorf("rule %s: unknown action %s", rule.Name, rule.Action)
+		}
+	}
+
+	return nil
+}
+
+// 没有置信度的记录不是关联分析产生的，不受置信度限制
+func matches(rule *models.EnforcementRule, cheat *models.Cheat) bool {
+	if len(rule.CheatTypes) > 0 && !slices.Contains(rule.CheatTypes, cheat.CheatType) {
+		return false
+	}
+	confidence := cheat.ExtraData.Confidence
+	return confidence == 0 || confidence >= rule.MinConfidence
+}
+
+// ruleHash 修改过的规则当作新的规则，会重新执行
+func ruleHash(rule *models.EnforcementRule) string {
+	data, _ := sonic.Marshal(rule)
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])[:16]
+}
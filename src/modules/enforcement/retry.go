@@ -0,0 +1,85 @@
+package enforcement
+
+import (
+	"a1ctf/src/db/models"
+	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/utils/zaphelper"
+	"errors"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrNotFailed 只有失败的处罚可以重试
+	ErrNotFailed = errors.New("enforcement has not failed")
+	// ErrNotApplicable 重试时规则已经不适用，比如队伍已经被禁赛，失败的记录会被删除
+	ErrNotApplicable = errors.New("enforcement rule no longer applies")
+)
+
+func maxAttempts() int {
+	if attempts := viper.GetInt("anti-cheat.enforcement.max-attempts"); attempts > 0 {
+		return attempts
+	}
+	return 5
+}
+
+// RetryFailed 自动重试还没达到次数上限的失败处罚
+func RetryFailed() {
+	var failed []models.Enforcement
+	if err := dbtool.DB().Where("status = ? AND attempts < ?", models.EnforcementFailed, maxAttempts()).Order("create_time ASC").Limit(batchSize).Find(&failed).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load failed enforcements", zap.Error(err))
+		return
+	}
+
+	for idx := range failed {
+		if _, err := Retry(failed[idx].EnforcementID); err != nil && !errors.Is(err, ErrNotApplicable) && !errors.Is(err, ErrNotFailed) {
+			zaphelper.Logger.Warn("Failed to retry enforcement", zap.Error(err), zap.Int64("enforcement_id", failed[idx].EnforcementID))
+		}
+	}
+}
+
+// Retry 重新执行一次失败的处罚，使用记录里的规则和比赛当前的规则设置
+func Retry(enforcementID int64) (*models.Enforcement, error) {
+	var existing models.Enforcement
+	if err := dbtool.DB().Where("enforcement_id = ?", enforcementID).First(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing.Status != models.EnforcementFailed {
+		return nil, ErrNotFailed
+	}
+
+	var cheat models.Cheat
+	if err := dbtool.DB().Preload("Game").Where("cheat_id = ?", existing.CheatID).First(&cheat).Error; err != nil {
+		return nil, err
+	}
+
+	// 规则被删除后扣分找不到操作人，会再次失败
+	policy := cheat.Game.EnforcementPolicy
+	if policy == nil {
+		policy = &models.EnforcementPolicy{}
+	}
+
+	err := attempt(policy, &cheat, existing.TeamID, existing.EnforcementKey, existing.Details)
+	switch {
+	case err == nil, errors.Is(err, gorm.ErrDuplicatedKey):
+		// 同时被别的任务重试成功了
+	case errors.Is(err, errSkip):
+		if err := dbtool.DB().Where("enforcement_id = ? AND status = ?", existing.EnforcementID, models.EnforcementFailed).Delete(&models.Enforcement{}).Error; err != nil {
+			return nil, err
+		}
+		return nil, ErrNotApplicable
+	default:
+		if recordErr := recordFailure(&cheat, existing.TeamID, existing.EnforcementKey, existing.Details, err); recordErr != nil {
+			return nil, recordErr
+		}
+		return nil, err
+	}
+
+	var enforcement models.Enforcement
+	if err := dbtool.DB().Where("enforcement_id = ?", enforcementID).First(&enforcement).Error; err != nil {
+		return nil, err
+	}
+	return &enforcement, nil
+}
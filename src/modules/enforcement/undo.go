@@ -0,0 +1,116 @@
+package enforcement
+
+import (
+	"a1ctf/src/db/models"
+	scoreengine "a1ctf/src/modules/score_engine"
+	dbtool "a1ctf/src/utils/db_tool"
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ErrNotApplied 只有执行成功的处罚可以撤销
+var ErrNotApplied = errors.New("enforcement is not applied")
+
+// Undo 撤销一次处罚，处罚之后又被修改过的数据保持不变
+func Undo(enforcementID int64, operatorID string) (*models.Enforcement, error) {
+	var enforcement models.Enforcement
+	var after func()
+
+	err := dbtool.DB().Transaction(func(tx *gorm.DB) error {
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("enforcement_id = ?", enforcementID).First(&enforcement).Error; err != nil {
+			return err
+		}
+		if enforcement.Status != models.EnforcementApplied {
+			return ErrNotApplied
+		}
+
+		var err error
+		after, err = revert(tx, &enforcement)
+		if err != nil {
+			return err
+		}
+
+		now := time.Now().UTC()
+		enforcement.Status = models.EnforcementUndone
+		enforcement.UndoTime = &now
+		enforcement.UndoneBy = &operatorID
+
+		return tx.Model(&models.Enforcement{}).Where("enforcement_id = ?", enforcement.EnforcementID).Updates(map[string]interface{}{
+			"status":    enforcement.Status,
+			"undo_time": enforcement.UndoTime,
+			"undone_by": enforcement.UndoneBy,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if after != nil {
+		after()
+	}
+
+	return &enforcement, nil
+}
+
+func revert(tx *gorm.DB, enforcement *models.Enforcement) (func(), error) {
+	details := enforcement.Details
+
+	switch enforcement.Action {
+	case models.EnforcementInvalidateSolve:
+		if details.ChallengeID == nil || len(details.SolveIDs) == 0 {
+			return nil, nil
+		}
+		challengeID := *details.ChallengeID
+
+		// 队伍之后重新解出了这道题时不恢复，避免重复的解题记录
+		var solved int64
+		if err := tx.Model(&models.Solve{}).Where("game_id = ? AND challenge_id = ? AND team_id = ? AND solve_status = ?", enforcement.GameID, challengeID, enforcement.TeamID, models.SolveCorrect).Count(&solved).Error; err != nil {
+			return nil, err
+		}
+		if solved == 0 {
+			if err := tx.Model(&models.Solve{}).Where("solve_id IN ? AND solve_status = ?", details.SolveIDs, models.SolveInvalid).Update("solve_status", models.SolveCorrect).Error; err != nil {
+				return nil, err
+			}
+		}
+
+		return func() { refreshChallenge(enforcement.GameID, challengeID) }, nil
+	case models.EnforcementScorePenalty:
+		if details.AdjustmentID == nil {
+			return nil, nil
+		}
+
+		// 分数修正已经被管理员删除时不用再处理
+		var adjustment models.ScoreAdjustment
+		if err := tx.Where("adjustment_id = ?", *details.AdjustmentID).First(&adjustment).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return nil, nil
+			}
+			return nil, err
+		}
+		if err := tx.Delete(&adjustment).Error; err != nil {
+			return nil, err
+		}
+		if err := tx.Model(&models.Team{}).Where("team_id = ?", adjustment.TeamID).Update("team_score", gorm.Expr("team_score - ?", adjustment.ScoreChange)).Error; err != nil {
+			return nil, err
+		}
+
+		return func() { scoreengine.NotifyAdjustment(adjustment.GameID, adjustment.TeamID) }, nil
+	case models.EnforcementSetPending, models.EnforcementBan:
+		// 管理员之后改过队伍状态时以管理员的为准
+		result := tx.Model(&models.Team{}).Where("team_id = ? AND team_status = ?", enforcement.TeamID, details.NewStatus).Update("team_status", details.OldStatus)
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil, nil
+		}
+
+		return func() { refreshTeam(enforcement.GameID, enforcement.TeamID) }, nil
+	}
+
+	// 已经发出的邮件无法撤回
+	return nil, nil
+}
@@ -186,6 +186,8 @@ var PermissionMap = map[string]PermissionSetting{
 	"/api/account/changePassword":          {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
 	"/api/account/sendForgetPasswordEmail": {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
 	"/api/account/resetPassword":           {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
+	"/api/account/sso/:provider/link":      {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
+	"/api/account/sso/:provider/unlink":    {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
 
 	"/api/verifyEmailCode": {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
 
@@ -223,6 +225,13 @@ var PermissionMap = map[string]PermissionSetting{
 	"/api/admin/game/:game_id/poster/upload":           {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{models.UserRoleAdmin}},
 	"/api/admin/game/:game_id/submits":                 {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{models.UserRoleAdmin}},
 	"/api/admin/game/:game_id/cheats":                  {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{models.UserRoleAdmin}},
+	"/api/admin/game/:game_id/cheats/correlate":        {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{models.UserRoleAdmin}},
+
+	// 作弊处罚相关权限
+	"/api/admin/game/:game_id/enforcement-policy":                 {RequestMethod: []string{"GET", "PUT"}, Permissions: []models.UserRole{models.UserRoleAdmin}},
+	"/api/admin/game/:game_id/enforcements":                       {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{models.UserRoleAdmin}},
+	"/api/admin/game/:game_id/enforcements/:enforcement_id/undo":  {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{models.UserRoleAdmin}},
+	"/api/admin/game/:game_id/enforcements/:enforcement_id/retry": {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{models.UserRoleAdmin}},
 
 	// 分组管理相关权限
 	"/api/admin/game/:game_id/groups":           {RequestMethod: []string{"GET", "POST"}, Permissions: []models.UserRole{models.UserRoleAdmin}},
@@ -239,17 +248,22 @@ var PermissionMap = map[string]PermissionSetting{
 
 	"/api/admin/game/:game_id/challenge/:challenge_id/solves/delete": {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{models.UserRoleAdmin}},
 
-	"/api/game/list":                             {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
-	"/api/game/:game_id":                         {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
-	"/api/game/:game_id/challenges":              {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
-	"/api/game/:game_id/challenge/:challenge_id": {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
-	"/api/game/:game_id/notices":                 {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
-	"/api/game/:game_id/groups":                  {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
-	"/api/game/:game_id/createTeam":              {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
-	"/api/game/:game_id/scoreboard":              {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
-	"/api/game/:game_id/container/:challenge_id": {RequestMethod: []string{"POST", "DELETE", "PATCH", "GET"}, Permissions: []models.UserRole{}},
-	"/api/game/:game_id/flag/:challenge_id":      {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
-	"/api/game/:game_id/flag/:judge_id":          {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
+	"/api/game/list":                              {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
+	"/api/game/:game_id":                          {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
+	"/api/game/:game_id/challenges":               {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
+	"/api/game/:game_id/challenge/:challenge_id":  {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
+	"/api/game/:game_id/notices":                  {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
+	"/api/game/:game_id/groups":                   {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
+	"/api/game/:game_id/createTeam":               {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
+	"/api/game/:game_id/scoreboard":               {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
+	"/api/game/:game_id/container/:challenge_id":  {RequestMethod: []string{"POST", "DELETE", "PATCH", "GET"}, Permissions: []models.UserRole{}},
+	"/api/game/:game_id/flag/:challenge_id":       {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
+	"/api/game/:game_id/flag/:judge_id":           {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
+	"/api/game/:game_id/attachment/:challenge_id": {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
+
+	// 攻防模式
+	"/api/game/:game_id/ad/flag":   {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
+	"/api/game/:game_id/ad/status": {RequestMethod: []string{"GET"}, Permissions: []models.UserRole{}},
 
 	// 分组邀请码相关权限
 	"/api/game/:game_id/group/invite-code": {RequestMethod: []string{"POST"}, Permissions: []models.UserRole{}},
@@ -518,3 +532,19 @@ func InitJwtMiddleWare() *jwt.GinJWTMiddleware {
 func GetJwtMiddleWare() *jwt.GinJWTMiddleware {
 	return authMiddleware
 }
+
+// IssueToken 给不经过密码登录的用户签发登录凭证，和密码登录一样写入 cookie
+func IssueToken(c *gin.Context, user *models.User) error {
+	token, _, err := authMiddleware.TokenGenerator(&models.JWTUser{
+		UserName:   user.Username,
+		Role:       user.Role,
+		UserID:     user.UserID,
+		JWTVersion: user.JWTVersion,
+	})
+	if err != nil {
+		return err
+	}
+
+	authMiddleware.SetCookie(c, token)
+	return nil
+}
@@ -0,0 +1,87 @@
+package koth
+
+import (
+	"a1ctf/src/db/models"
+	"errors"
+	"strings"
+	"time"
+)
+
+// 没有配置时使用的默认值
+func configOf(gc *models.GameChallenge) models.KothConfig {
+	config := models.KothConfig{
+		TickInterval:  60,
+		PointsPerTick: 10,
+		TokenSource:   models.KothTokenFile,
+		TokenPath:     "/king",
+	}
+
+	if gc.KothConfig != nil {
+		config = *gc.KothConfig
+	}
+
+	if config.TickInterval <= 0 {
+		config.TickInterval = 60
+	}
+	if config.TokenSource == "" {
+		config.TokenSource = models.KothTokenFile
+	}
+
+	return config
+}
+
+func tickInterval(config models.KothConfig) time.Duration {
+	return time.Duration(config.TickInterval) * time.Second
+}
+
+// TickAt 返回某个时间所在的检查周期，从比赛开始时的 0 开始计数，比赛开始前和结束后为 -1
+func TickAt(game *models.Game, gc *models.GameChallenge, t time.Time) int64 {
+	if t.Before(game.StartTime) || !t.Before(game.EndTime) {
+		return -1
+	}
+	return int64(t.Sub(game.StartTime) / tickInterval(configOf(gc)))
+}
+
+// ValidateConfig 检查题目类型和 KOTH 配置，KOTH 题目必须是静态容器
+func ValidateConfig(kind models.GameChallengeKind, config *models.KothConfig, challenge *models.Challenge) error {
+	switch kind {
+	case "", models.GameChallengeStandard:
+		return nil
+	case models.GameChallengeKOTH:
+	default:
+		return errors.New("unknown challenge kind")
+	}
+
+	if challenge.ContainerType != models.STATIC_CONTAINER {
+		return errors.New("king of the hill challenge must use a static container")
+	}
+	if challenge.ContainerConfig == nil || len(*challenge.ContainerConfig) == 0 {
+		return errors.New("king of the hill challenge must have a container config")
+	}
+
+	if config == nil {
+		return nil
+	}
+
+	if config.TickInterval < 5 {
+		return errors.New("tick interval must be at least 5 seconds")
+	}
+	if config.PointsPerTick < 0 {
+		return errors.New("points per tick must not be negative")
+	}
+
+	switch config.TokenSource {
+	case models.KothTokenFile:
+		if config.TokenPath == "" {
+			return errors.New("token path must not be empty")
+		}
+	case models.KothTokenHTTP:
+		if !strings.HasPrefix(config.TokenPath, "/") {
+			return errors.New("token path must start with /")
+		}
+	default:
+		return errors.New("unknown token source")
+	}
+
+	return nil
+}
@@ -0,0 +1,145 @@
+package koth
+
+import (
+	"a1ctf/src/db/models"
+	scoreengine "a1ctf/src/modules/score_engine"
+	sharedcontainer "a1ctf/src/modules/shared_container"
+	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/utils/zaphelper"
+	"time"
+
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+// Run 在每个检查周期记录所有进行中比赛的 KOTH 靶机的占领队伍
+func Run() {
+	now := time.Now().UTC()
+
+	var games []models.Game
+	if err := dbtool.DB().Where("start_time <= ? AND end_time > ?", now, now).Find(&games).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load running games", zap.Error(err))
+		return
+	}
+
+	for idx := range games {
+		if err := processGame(&games[idx], now); err != nil {
+			zaphelper.Logger.Error("Failed to process king of the hill challenges", zap.Error(err), zap.Int64("game_id", games[idx].GameID))
+		}
+	}
+}
+
+func processGame(game *models.Game, now time.Time) error {
+	var gameChallenges []models.GameChallenge
+	if err := dbtool.DB().Preload("Challenge").Where("game_id = ? AND visible = ? AND challenge_kind = ?", game.GameID, true, models.GameChallengeKOTH).Find(&gameChallenges).Error; err != nil {
+		return err
+	}
+
+	if len(gameChallenges) == 0 {
+		return nil
+	}
+
+	host, err := sharedcontainer.HostTeam(game.GameID)
+	if err != nil {
+		return err
+	}
+
+	var teams []models.Team
+	if err := dbtool.DB().Where("game_id = ? AND team_status = ? AND team_type = ?", game.GameID, models.ParticipateApproved, models.TeamTypePlayer).Find(&teams).Error; err != nil {
+		return err
+	}
+
+	teamMap := make(map[string]*models.Team, len(teams))
+	for idx := range teams {
+		teamMap[teams[idx].TeamHash] = &teams[idx]
+	}
+
+	// 靶机就是题目的共享容器
+	containerMap, err := sharedcontainer.LiveContainers(game.GameID, host)
+	if err != nil {
+		return err
+	}
+
+	scored := false
+
+	for idx := range gameChallenges {
+		gc := &gameChallenges[idx]
+
+		tick, err := recordTick(game, gc, containerMap[gc.ChallengeID], teamMap, now)
+		if err != nil {
+			zaphelper.Logger.Error("Failed to record king of the hill tick", zap.Error(err), zap.Int64("game_id", game.GameID), zap.Int64("challenge_id", gc.ChallengeID))
+			continue
+		}
+		if tick != nil && tick.TeamID != nil && tick.Points != 0 {
+			scored = true
+		}
+	}
+
+	if scored {
+		scoreengine.NotifyGame(game.GameID)
+	}
+
+	return nil
+}
+
+// 每个检查周期只记录一次，唯一索引保证多个实例不会重复计分
+func recordTick(game *models.Game, gc *models.GameChallenge, container *models.Container, teamMap map[string]*models.Team, now time.Time) (*models.KothTick, error) {
+	tickNo := TickAt(game, gc, now)
+	if tickNo < 0 {
+		return nil, nil
+	}
+
+	var count int64
+	if err := dbtool.DB().Model(&models.KothTick{}).Where("ingame_id = ? AND tick = ?", gc.IngameID, tickNo).Count(&count).Error; err != nil {
+		return nil, err
+	}
+	if count > 0 {
+		return nil, nil
+	}
+
+	config := configOf(gc)
+
+	tick := models.KothTick{
+		GameID:      game.GameID,
+		ChallengeID: gc.ChallengeID,
+		InGameID:    gc.IngameID,
+		Tick:        tickNo,
+	}
+
+	switch {
+	case container == nil || container.ContainerStatus != models.ContainerRunning:
+		tick.Message = "target is not running"
+	default:
+		token, err := readToken(container, config)
+		if err != nil {
+			tick.Message = err.Error()
+			break
+		}
+
+		team, ok := teamMap[token]
+		switch {
+		case token == "":
+			tick.Message = "no owner"
+		case !ok:
+			tick.Message = "unknown owner token"
+		default:
+			tick.TeamID = &team.TeamID
+			tick.Points = config.PointsPerTick
+		}
+	}
+
+	if len(tick.Message) > 256 {
+		tick.Message = tick.Message[:256]
+	}
+	tick.TickTime = time.Now().UTC()
+
+	result := dbtool.DB().Clauses(clause.OnConflict{DoNothing: true}).Create(&tick)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, nil
+	}
+
+	return &tick, nil
+}
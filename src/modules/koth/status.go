@@ -0,0 +1,44 @@
+package koth
+
+import (
+	"a1ctf/src/db/models"
+	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/webmodels"
+)
+
+// Status 返回靶机当前的占领队伍和队伍在这道题上的累计得分
+func Status(gc *models.GameChallenge, teamID int64) (*webmodels.UserKothStatus, error) {
+	config := configOf(gc)
+
+	status := webmodels.UserKothStatus{
+		TickInterval:  config.TickInterval,
+		PointsPerTick: config.PointsPerTick,
+	}
+
+	var ticks []models.KothTick
+	if err := dbtool.DB().Where("ingame_id = ?", gc.IngameID).Order("tick DESC").Limit(1).Find(&ticks).Error; err != nil {
+		return nil, err
+	}
+
+	if len(ticks) > 0 {
+		status.LastTickTime = &ticks[0].TickTime
+
+		if ticks[0].TeamID != nil {
+			var team models.Team
+			if err := dbtool.DB().Select("team_id", "team_name").Where("team_id = ?", *ticks[0].TeamID).First(&team).Error; err != nil {
+				return nil, err
+			}
+			status.HolderTeamID = &team.TeamID
+			status.HolderTeamName = &team.TeamName
+		}
+	}
+
+	if err := dbtool.DB().Model(&models.KothTick{}).
+		Select("COALESCE(SUM(points), 0)").
+		Where("ingame_id = ? AND team_id = ?", gc.IngameID, teamID).
+		Scan(&status.TeamPoints).Error; err != nil {
+		return nil, err
+	}
+
+	return &status, nil
+}
@@ -0,0 +1,126 @@
+package koth
+
+import (
+	"a1ctf/src/db/models"
+	tcpgateway "a1ctf/src/modules/tcp_gateway"
+	containerbackend "a1ctf/src/utils/container_backend"
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+// 占领标记最多读取的字节数
+const maxTokenBytes = 4096
+
+var httpClient = &http.Client{
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+	Transport: &http.Transport{
+		DisableKeepAlives: true,
+	},
+}
+
+func tokenTimeout() time.Duration {
+	if timeout := viper.GetDuration("koth.token-timeout"); timeout > 0 {
+		return timeout
+	}
+	return 5 * time.Second
+}
+
+// 读取靶机里的占领标记，去掉首尾空白
+func readToken(container *models.Container, config models.KothConfig) (string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), tokenTimeout())
+	defer cancel()
+
+	var token string
+	var err error
+
+	switch config.TokenSource {
+	case models.KothTokenHTTP:
+		token, err = readHTTPToken(ctx, container, config)
+	default:
+		token, err = readFileToken(ctx, container, config)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	return strings.TrimSpace(token), nil
+}
+
+func readFileToken(ctx context.Context, container *models.Container, config models.KothConfig) (string, error) {
+	mainContainer := k8stool.FirstMainContainer(container.ContainerConfig)
+	if mainContainer == nil {
+		return "", fmt.Errorf("container %s has no containers", container.ContainerID)
+	}
+
+	containerName := mainContainer.Name
+	if config.TokenContainer != "" {
+		containerName = config.TokenContainer
+	}
+
+	podName := container.PodID()
+	output, err := containerbackend.Get().Exec(ctx, podName, containerName, []string{"head", "-c", strconv.Itoa(maxTokenBytes), config.TokenPath}, nil)
+	if err != nil {
+		return "", err
+	}
+
+	return output, nil
+}
+
+func readHTTPToken(ctx context.Context, container *models.Container, config models.KothConfig) (string, error) {
+	var exposed *models.ExposePort
+	for _, info := range container.ContainerExposeInfos {
+		for idx := range info.ExposePorts {
+			exposePort := &info.ExposePorts[idx]
+			if (config.TokenPort == "" && exposed == nil) || exposePort.PortName == config.TokenPort {
+				exposed = exposePort
+			}
+		}
+	}
+	if exposed == nil {
+		return "", errors.New("target has no exposed port for token")
+	}
+
+	// 直接连接容器，不经过 TCP 网关
+	host, port, err := tcpgateway.ResolveTarget(*exposed)
+	if err != nil {
+		return "", err
+	}
+
+	url := fmt.Sprintf("http://%s%s", net.JoinHostPort(host, strconv.Itoa(int(port))), config.TokenPath)
+	if exposed.Type == models.ExposePortTypeURL {
+		url = strings.TrimSuffix(exposed.URL, "/") + config.TokenPath
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("token endpoint returned status %d", resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxTokenBytes))
+	if err != nil {
+		return "", err
+	}
+
+	return string(data), nil
+}
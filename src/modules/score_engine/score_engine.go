@@ -0,0 +1,427 @@
+package scoreengine
+
+import (
+	"a1ctf/src/db/models"
+	"a1ctf/src/modules/scoring"
+	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/utils/zaphelper"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+// 一个比赛里等待重新计算的部分
+type dirtyGame struct {
+	// 整场比赛重算
+	full bool
+	// 解题人数或分数配置变化的题目
+	challenges map[int64]struct{}
+	// 只需要重算总分的队伍
+	teams map[int64]struct{}
+	// 状态变化的队伍，解出的题目人数也会跟着变
+	statusTeams map[int64]struct{}
+}
+
+var (
+	dirtyGames      = make(map[int64]*dirtyGame)
+	dirtyGamesMutex sync.Mutex
+
+	lastReconcileTime time.Time
+	catchUpOnce       sync.Once
+)
+
+func markDirty(gameID int64, apply func(dirty *dirtyGame)) {
+	dirtyGamesMutex.Lock()
+	defer dirtyGamesMutex.Unlock()
+
+	dirty, ok := dirtyGames[gameID]
+	if !ok {
+		dirty = &dirtyGame{
+			challenges:  make(map[int64]struct{}),
+			teams:       make(map[int64]struct{}),
+			statusTeams: make(map[int64]struct{}),
+		}
+		dirtyGames[gameID] = dirty
+	}
+	apply(dirty)
+}
+
+// NotifySolve 新的正确解题
+func NotifySolve(gameID int64, challengeID int64, teamID int64) {
+	markDirty(gameID, func(dirty *dirtyGame) {
+		dirty.challenges[challengeID] = struct{}{}
+		dirty.teams[teamID] = struct{}{}
+	})
+}
+
+// NotifyAdjustment 队伍的分数修正发生变化
+func NotifyAdjustment(gameID int64, teamID int64) {
+	markDirty(gameID, func(dirty *dirtyGame) {
+		dirty.teams[teamID] = struct{}{}
+	})
+}
+
+// NotifyChallenge 题目可见性、分数配置、解题记录或者排名发生变化
+func NotifyChallenge(gameID int64, challengeIDs ...int64) {
+	markDirty(gameID, func(dirty *dirtyGame) {
+		for _, challengeID := range challengeIDs {
+			dirty.challenges[challengeID] = struct{}{}
+		}
+	})
+}
+
+// NotifyTeamStatus 队伍被封禁、解封或者审核通过
+func NotifyTeamStatus(gameID int64, teamID int64) {
+	markDirty(gameID, func(dirty *dirtyGame) {
+		dirty.statusTeams[teamID] = struct{}{}
+	})
+}
+
+// NotifyGame 比赛时间、血奖等全局配置变化，整场重算
+func NotifyGame(gameID int64) {
+	markDirty(gameID, func(dirty *dirtyGame) {
+		dirty.full = true
+	})
+}
+
+func takeDirtyGames() map[int64]*dirtyGame {
+	dirtyGamesMutex.Lock()
+	defer dirtyGamesMutex.Unlock()
+
+	result := dirtyGames
+	dirtyGames = make(map[int64]*dirtyGame)
+	return result
+}
+
+// 正在进行的比赛定期整场对账，兜住没有走事件的修改
+func reconcileRunningGames() {
+	interval := viper.GetDuration("score-engine.reconcile-interval")
+	if interval <= 0 {
+		interval = time.Minute
+	}
+
+	now := time.Now().UTC()
+	if now.Sub(lastReconcileTime) < interval {
+		return
+	}
+	lastReconcileTime = now
+
+	var gameIDs []int64
+	if err := dbtool.DB().Model(&models.Game{}).Where("start_time <= ? AND end_time >= ?", now, now).Pluck("game_id", &gameIDs).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load running games", zap.Error(err))
+		return
+	}
+
+	for _, gameID := range gameIDs {
+		NotifyGame(gameID)
+	}
+}
+
+// 启动时事件队列是空的，所有比赛先整场算一次，补上停机期间的修改
+func catchUpAllGames() {
+	var gameIDs []int64
+	if err := dbtool.DB().Model(&models.Game{}).Pluck("game_id", &gameIDs).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load games", zap.Error(err))
+		return
+	}
+
+	for _, gameID := range gameIDs {
+		NotifyGame(gameID)
+	}
+}
+
+// Run 处理积累的事件，只重算受影响的题目和队伍
+func Run() {
+	catchUpOnce.Do(catchUpAllGames)
+	reconcileRunningGames()
+
+	for gameID, dirty := range takeDirtyGames() {
+		if err := processGame(gameID, dirty); err != nil {
+			zaphelper.Logger.Error("Failed to update game scores", zap.Error(err), zap.Int64("game_id", gameID))
+			// 下一轮重试
+			NotifyGame(gameID)
+		}
+	}
+}
+
+func keys(m map[int64]struct{}) []int64 {
+	result := make([]int64, 0, len(m))
+	for k := range m {
+		result = append(result, k)
+	}
+	return result
+}
+
+func processGame(gameID int64, dirty *dirtyGame) error {
+	var game models.Game
+	if err := dbtool.DB().Where("game_id = ?", gameID).First(&game).Error; err != nil {
+		return err
+	}
+
+	var gameChallenges []models.GameChallenge
+	if err := dbtool.DB().Where("game_id = ?", gameID).Find(&gameChallenges).Error; err != nil {
+		return err
+	}
+
+	gameChallengeMap := make(map[int64]*models.GameChallenge, len(gameChallenges))
+	for idx := range gameChallenges {
+		gameChallengeMap[gameChallenges[idx].ChallengeID] = &gameChallenges[idx]
+	}
+
+	challenges := dirty.challenges
+	teams := dirty.teams
+
+	if dirty.full {
+		challenges = make(map[int64]struct{}, len(gameChallenges))
+		for _, gc := range gameChallenges {
+			challenges[gc.ChallengeID] = struct{}{}
+		}
+
+		var teamIDs []int64
+		if err := dbtool.DB().Model(&models.Team{}).Where("game_id = ?", gameID).Pluck("team_id", &teamIDs).Error; err != nil {
+			return err
+		}
+		for _, teamID := range teamIDs {
+			teams[teamID] = struct{}{}
+		}
+	} else if len(dirty.statusTeams) > 0 {
+		// 队伍状态变化会影响它解出的题目的解题人数
+		var challengeIDs []int64
+		if err := dbtool.DB().Model(&models.Solve{}).Where("game_id = ? AND team_id IN ? AND solve_status = ?", gameID, keys(dirty.statusTeams), models.SolveCorrect).Distinct().Pluck("challenge_id", &challengeIDs).Error; err != nil {
+			return err
+		}
+		for _, challengeID := range challengeIDs {
+			challenges[challengeID] = struct{}{}
+		}
+		for teamID := range dirty.statusTeams {
+			teams[teamID] = struct{}{}
+		}
+	}
+
+	if len(challenges) > 0 {
+		affectedTeams, err := updateChallenges(&game, gameChallengeMap, keys(challenges))
+		if err != nil {
+			return err
+		}
+		for _, teamID := range affectedTeams {
+			teams[teamID] = struct{}{}
+		}
+	}
+
+	if len(teams) > 0 {
+		if err := updateTeams(&game, gameChallengeMap, keys(teams)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// 更新题目的解题人数和当前分数，返回解出过这些题目的队伍
+func updateChallenges(game *models.Game, gameChallengeMap map[int64]*models.GameChallenge, challengeIDs []int64) ([]int64, error) {
+	type solveCountRow struct {
+		ChallengeID int64
+		SolveCount  int32
+	}
+
+	// 只统计比赛时间内审核通过的队伍
+	var rows []solveCountRow
+	if err := dbtool.DB().Model(&models.Solve{}).
+		Select("challenge_id, COUNT(*) AS solve_count").
+		Where("game_id = ? AND challenge_id IN ? AND solve_status = ? AND solve_time BETWEEN ? AND ?", game.GameID, challengeIDs, models.SolveCorrect, game.StartTime, game.EndTime).
+		Where("team_id IN (?)", dbtool.DB().Model(&models.Team{}).Select("team_id").Where("game_id = ? AND team_status = ?", game.GameID, models.ParticipateApproved)).
+		Group("challenge_id").
+		Scan(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	solveCountMap := make(map[int64]int32, len(rows))
+	for _, row := range rows {
+		solveCountMap[row.ChallengeID] = row.SolveCount
+	}
+
+	for _, gc := range refreshChallengeScores(gameChallengeMap, challengeIDs, solveCountMap) {
+		if err := dbtool.DB().Model(gc).Select("solve_count", "cur_score").Updates(gc).Error; err != nil {
+			zaphelper.Logger.Error("Failed to update game challenge", zap.Error(err), zap.Int64("ingame_id", gc.IngameID))
+		}
+	}
+
+	var teamIDs []int64
+	if err := dbtool.DB().Model(&models.Solve{}).Where("game_id = ? AND challenge_id IN ? AND solve_status = ?", game.GameID, challengeIDs, models.SolveCorrect).Distinct().Pluck("team_id", &teamIDs).Error; err != nil {
+		return nil, err
+	}
+
+	return teamIDs, nil
+}
+
+// 按解题人数重算题目分数，返回有变化需要写回的题目
+func refreshChallengeScores(gameChallengeMap map[int64]*models.GameChallenge, challengeIDs []int64, solveCountMap map[int64]int32) []*models.GameChallenge {
+	changed := make([]*models.GameChallenge, 0)
+
+	for _, challengeID := range challengeIDs {
+		gc, ok := gameChallengeMap[challengeID]
+		if !ok {
+			continue
+		}
+
+		solveCount := solveCountMap[challengeID]
+		curScore := scoring.ChallengeScore(gc, solveCount)
+
+		// 只更新有变化的
+		if gc.SolveCount == solveCount && gc.CurScore == curScore {
+			continue
+		}
+
+		gc.SolveCount = solveCount
+		gc.CurScore = curScore
+		changed = append(changed, gc)
+	}
+
+	return changed
+}
+
+// 队伍写入 teams.team_score 的总分和计分的解题，被封禁的队伍解题不计分，只保留分数修正
+func teamScoreOf(game *models.Game, gameChallengeMap map[int64]*models.GameChallenge, team *models.Team, solves []models.Solve, adjustment float64, extra float64) (float64, []string) {
+	solvedList := make([]string, 0)
+	if team.TeamStatus != models.ParticipateApproved {
+		return adjustment, solvedList
+	}
+
+	teamScore := scoring.CalculateTeamScore(game, gameChallengeMap, solves, adjustment, extra)
+	for _, item := range teamScore.Solves {
+		solvedList = append(solvedList, item.Solve.SolveID)
+	}
+	return teamScore.Total, solvedList
+}
+
+// 重算队伍总分，写入 teams.team_score 和 scoreboard
+func updateTeams(game *models.Game, gameChallengeMap map[int64]*models.GameChallenge, teamIDs []int64) error {
+	var teams []models.Team
+	if err := dbtool.DB().Where("game_id = ? AND team_id IN ?", game.GameID, teamIDs).Find(&teams).Error; err != nil {
+		return err
+	}
+
+	var solves []models.Solve
+	if err := dbtool.DB().Where("game_id = ? AND team_id IN ? AND solve_status = ? AND solve_time BETWEEN ? AND ?", game.GameID, teamIDs, models.SolveCorrect, game.StartTime, game.EndTime).Find(&solves).Error; err != nil {
+		return err
+	}
+
+	type adjustmentRow struct {
+		TeamID      int64
+		ScoreChange float64
+	}
+
+	var adjustments []adjustmentRow
+	if err := dbtool.DB().Model(&models.ScoreAdjustment{}).
+		Select("team_id, SUM(score_change) AS score_change").
+		Where("game_id = ? AND team_id IN ?", game.GameID, teamIDs).
+		Group("team_id").
+		Scan(&adjustments).Error; err != nil {
+		return err
+	}
+
+	adjustmentMap := make(map[int64]float64, len(adjustments))
+	for _, adjustment := range adjustments {
+		adjustmentMap[adjustment.TeamID] = adjustment.ScoreChange
+	}
+
+	// 攻防模式每轮结算的分数
+	var roundScores []adjustmentRow
+	if err := dbtool.DB().Model(&models.ADRoundScore{}).
+		Select("team_id, SUM(attack_score + defense_score + sla_score) AS score_change").
+		Where("game_id = ? AND team_id IN ?", game.GameID, teamIDs).
+		Group("team_id").
+		Scan(&roundScores).Error; err != nil {
+		return err
+	}
+
+	roundScoreMap := make(map[int64]float64, len(roundScores))
+	for _, roundScore := range roundScores {
+		roundScoreMap[roundScore.TeamID] = roundScore.ScoreChange
+	}
+
+	// KOTH 题目占领靶机的分数
+	var kothScores []adjustmentRow
+	if err := dbtool.DB().Model(&models.KothTick{}).
+		Select("team_id, SUM(points) AS score_change").
+		Where("game_id = ? AND team_id IN ?", game.GameID, teamIDs).
+		Group("team_id").
+		Scan(&kothScores).Error; err != nil {
+		return err
+	}
+
+	kothScoreMap := make(map[int64]float64, len(kothScores))
+	for _, kothScore := range kothScores {
+		kothScoreMap[kothScore.TeamID] = kothScore.ScoreChange
+	}
+
+	solveMap := make(map[int64][]models.Solve)
+	for _, solve := range solves {
+		solveMap[solve.TeamID] = append(solveMap[solve.TeamID], solve)
+	}
+
+	var scoreboards []models.ScoreBoard
+	if err := dbtool.DB().Where("game_id = ? AND team_id IN ?", game.GameID, teamIDs).Find(&scoreboards).Error; err != nil {
+		return err
+	}
+
+	scoreboardMap := make(map[int64]models.ScoreBoard, len(scoreboards))
+	for _, scoreboard := range scoreboards {
+		scoreboardMap[scoreboard.TeamID] = scoreboard
+	}
+
+	curTime := time.Now().UTC()
+	scoreboardsToSave := make([]models.ScoreBoard, 0)
+
+	for _, team := range teams {
+		score, solvedList := teamScoreOf(game, gameChallengeMap, &team, solveMap[team.TeamID], adjustmentMap[team.TeamID], roundScoreMap[team.TeamID]+kothScoreMap[team.TeamID])
+
+		if team.TeamScore != score {
+			if err := dbtool.DB().Model(&models.Team{}).Where("team_id = ?", team.TeamID).Update("team_score", score).Error; err != nil {
+				zaphelper.Logger.Error("Failed to update team score", zap.Error(err), zap.Int64("team_id", team.TeamID))
+			}
+		}
+
+		// 管理员队伍不进积分榜
+		if team.TeamType != models.TeamTypePlayer {
+			continue
+		}
+
+		scoreboard, exists := scoreboardMap[team.TeamID]
+		if !exists {
+			// 没有解题和修正的队伍不需要记录
+			if len(solvedList) == 0 && score == 0 {
+				continue
+			}
+
+			scoreboard = models.ScoreBoard{
+				GameID:       game.GameID,
+				TeamID:       team.TeamID,
+				GenerateTime: curTime,
+			}
+		}
+
+		if scoring.AppendHistory(&scoreboard, models.ScoreBoardData{
+			TeamName:             team.TeamName,
+			SolvedChallenges:     solvedList,
+			NewSolvedChallengeID: nil,
+			Score:                score,
+			RecordTime:           curTime,
+		}) {
+			scoreboardsToSave = append(scoreboardsToSave, scoreboard)
+		}
+	}
+
+	if len(scoreboardsToSave) > 0 {
+		if err := dbtool.DB().Clauses(clause.OnConflict{
+			UpdateAll: true,
+		}).Create(&scoreboardsToSave).Error; err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,301 @@
+package scoreengine
+
+import (
+	"a1ctf/src/db/models"
+	"a1ctf/src/utils/ristretto_tool"
+	"a1ctf/src/webmodels"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+var testGameStart = time.Date(2025, 10, 1, 8, 0, 0, 0, time.UTC)
+
+type fixtureSolve struct {
+	team      int64
+	challenge int64
+	after     time.Duration
+	rank      int32
+	status    models.SolveStatus
+}
+
+type fixtureAdjustment struct {
+	team   int64
+	change float64
+}
+
+// 积分引擎和公开积分榜从数据库读到的同一份数据
+type scoreFixture struct {
+	name        string
+	game        models.Game
+	teams       []models.Team
+	challenges  []models.GameChallenge
+	solves      []fixtureSolve
+	adjustments []fixtureAdjustment
+	adScores    map[int64]*webmodels.TeamADScoreItem
+	kothScores  map[int64]float64
+	want        map[int64]float64
+	wantPenalty map[int64]int64
+}
+
+func (f *scoreFixture) buildSolves() []models.Solve {
+	solves := make([]models.Solve, 0, len(f.solves))
+	for idx, s := range f.solves {
+		status := s.status
+		if status == "" {
+			status = models.SolveCorrect
+		}
+		solves = append(solves, models.Solve{
+			SolveID:     fmt.Sprintf("solve-%d", idx),
+			GameID:      f.game.GameID,
+			ChallengeID: s.challenge,
+			TeamID:      s.team,
+			SolveStatus: status,
+			SolveTime:   testGameStart.Add(s.after),
+			Rank:        s.rank,
+		})
+	}
+	sort.SliceStable(solves, func(i, j int) bool { return solves[i].SolveTime.Before(solves[j].SolveTime) })
+	return solves
+}
+
+func (f *scoreFixture) teamByID() map[int64]models.Team {
+	result := make(map[int64]models.Team, len(f.teams))
+	for _, team := range f.teams {
+		result[team.TeamID] = team
+	}
+	return result
+}
+
+func inGameTime(game *models.Game, solve *models.Solve) bool {
+	return !solve.SolveTime.Before(game.StartTime) && !solve.SolveTime.After(game.EndTime)
+}
+
+// runEngine 按 updateChallenges 和 updateTeams 的查询条件筛选数据，再走积分引擎的计算，
+// 返回题目的当前分数和写入 teams.team_score 的总分
+func runEngine(f *scoreFixture) (map[int64]*models.GameChallenge, map[int64]float64) {
+	game := f.game
+	teams := f.teamByID()
+	solves := f.buildSolves()
+
+	gameChallengeMap := make(map[int64]*models.GameChallenge, len(f.challenges))
+	challengeIDs := make([]int64, 0, len(f.challenges))
+	for idx := range f.challenges {
+		gc := f.challenges[idx]
+		gameChallengeMap[gc.ChallengeID] = &gc
+		challengeIDs = append(challengeIDs, gc.ChallengeID)
+	}
+
+	// 解题人数只统计比赛时间内审核通过的队伍的正确解题
+	solveCountMap := make(map[int64]int32)
+	for idx := range solves {
+		solve := &solves[idx]
+		if solve.SolveStatus == models.SolveCorrect && inGameTime(&game, solve) && teams[solve.TeamID].TeamStatus == models.ParticipateApproved {
+			solveCountMap[solve.ChallengeID]++
+		}
+	}
+	refreshChallengeScores(gameChallengeMap, challengeIDs, solveCountMap)
+
+	solveMap := make(map[int64][]models.Solve)
+	for idx := range solves {
+		solve := &solves[idx]
+		if solve.SolveStatus == models.SolveCorrect && inGameTime(&game, solve) {
+			solveMap[solve.TeamID] = append(solveMap[solve.TeamID], *solve)
+		}
+	}
+
+	adjustmentMap := make(map[int64]float64)
+	for _, adjustment := range f.adjustments {
+		adjustmentMap[adjustment.team] += adjustment.change
+	}
+
+	totals := make(map[int64]float64, len(f.teams))
+	for _, team := range f.teams {
+		extra := f.kothScores[team.TeamID]
+		if adScore, ok := f.adScores[team.TeamID]; ok {
+			extra += adScore.AttackScore + adScore.DefenseScore + adScore.SLAScore
+		}
+		totals[team.TeamID], _ = teamScoreOf(&game, gameChallengeMap, &team, solveMap[team.TeamID], adjustmentMap[team.TeamID], extra)
+	}
+
+	return gameChallengeMap, totals
+}
+
+// runScoreBoard 按 CalculateGameScoreBoard 的查询条件筛选数据，题目分数读取积分引擎写回的结果
+func runScoreBoard(f *scoreFixture, engineChallenges map[int64]*models.GameChallenge) map[int64]webmodels.TeamScoreItem {
+	game := f.game
+	teams := f.teamByID()
+
+	solves := make([]models.Solve, 0)
+	for _, solve := range f.buildSolves() {
+		if inGameTime(&game, &solve) && teams[solve.TeamID].TeamStatus == models.ParticipateApproved {
+			solves = append(solves, solve)
+		}
+	}
+
+	gameChallengeMap := make(map[int64]*models.GameChallenge, len(engineChallenges))
+	for challengeID, gc := range engineChallenges {
+		stored := *gc
+		gameChallengeMap[challengeID] = &stored
+	}
+
+	adjustments := make([]models.ScoreAdjustment, 0, len(f.adjustments))
+	for idx, adjustment := range f.adjustments {
+		adjustments = append(adjustments, models.ScoreAdjustment{
+			AdjustmentID: int64(idx + 1),
+			GameID:       game.GameID,
+			TeamID:       adjustment.team,
+			ScoreChange:  adjustment.change,
+		})
+	}
+
+	teamDataMap := make(map[int64]webmodels.TeamScoreItem)
+	for _, team := range f.teams {
+		if team.TeamStatus == models.ParticipateApproved && team.TeamType == models.TeamTypePlayer {
+			teamDataMap[team.TeamID] = webmodels.TeamScoreItem{
+				TeamID:           team.TeamID,
+				SolvedChallenges: make([]webmodels.TeamSolveItem, 0),
+				ScoreAdjustments: make([]webmodels.TeamScoreAdjustmentItem, 0),
+			}
+		}
+	}
+
+	ristretto_tool.BuildTeamScoreItems(&game, teamDataMap, solves, gameChallengeMap, adjustments, f.adScores, f.kothScores)
+	return teamDataMap
+}
+
+func newFixtureTeam(teamID int64, status models.ParticipationStatus) models.Team {
+	return models.Team{TeamID: teamID, GameID: 1, TeamType: models.TeamTypePlayer, TeamStatus: status}
+}
+
+func TestScoreEngineMatchesScoreBoard(t *testing.T) {
+	fixtures := []scoreFixture{
+		{
+			name: "legacy blood rewards, decay, hidden challenge, banned team and extra scores",
+			game: models.Game{
+				GameID:            1,
+				StartTime:         testGameStart,
+				EndTime:           testGameStart.Add(48 * time.Hour),
+				FirstBloodReward:  5,
+				SecondBloodReward: 3,
+				ThirdBloodReward:  1,
+			},
+			teams: []models.Team{
+				newFixtureTeam(1, models.ParticipateApproved),
+				newFixtureTeam(2, models.ParticipateApproved),
+				newFixtureTeam(3, models.ParticipateApproved),
+				newFixtureTeam(4, models.ParticipateBanned),
+			},
+			challenges: []models.GameChallenge{
+				{ChallengeID: 1, TotalScore: 100, CurScore: 100, ScoringMode: models.ScoringStatic, Visible: true, BloodRewardEnabled: true},
+				{ChallengeID: 2, TotalScore: 300, CurScore: 300, MinimalScore: 100, ScoringMode: models.ScoringLinear, ScoringConfig: &models.ScoringConfig{Decay: 4}, Visible: true},
+				{ChallengeID: 3, TotalScore: 1000, CurScore: 1000, ScoringMode: models.ScoringStatic, Visible: false, BloodRewardEnabled: true},
+			},
+			solves: []fixtureSolve{
+				{team: 1, challenge: 1, after: 10 * time.Minute, rank: 1},
+				{team: 2, challenge: 1, after: 20 * time.Minute, rank: 2},
+				{team: 3, challenge: 1, after: 30 * time.Minute, rank: 3},
+				{team: 1, challenge: 2, after: time.Hour, rank: 1},
+				{team: 1, challenge: 3, after: time.Hour, rank: 1},
+				{team: 2, challenge: 2, after: 2 * time.Hour, rank: 2},
+				// 封禁队伍、无效提交和比赛结束后的解题都不计入解题人数
+				{team: 4, challenge: 2, after: 3 * time.Hour, rank: 3},
+				{team: 3, challenge: 2, after: 4 * time.Hour, rank: 3, status: models.SolveInvalid},
+				{team: 3, challenge: 2, after: 50 * time.Hour, rank: 3},
+			},
+			adjustments: []fixtureAdjustment{
+				{team: 1, change: -50},
+				{team: 2, change: 20},
+				{team: 2, change: 10},
+				{team: 4, change: 7},
+			},
+			adScores: map[int64]*webmodels.TeamADScoreItem{
+				3: {AttackScore: 30, DefenseScore: 5, SLAScore: 5},
+			},
+			kothScores: map[int64]float64{2: 15},
+			// 题目 2 两个解之后是 300 - 200 / 4 = 250，封禁队伍只保留分数修正
+			want:        map[int64]float64{1: 305, 2: 398, 3: 141, 4: 7},
+			wantPenalty: map[int64]int64{1: 0, 2: 600 + 3600, 3: 1200},
+		},
+		{
+			name: "configured fixed and percent blood rewards decaying over time",
+			game: models.Game{
+				GameID:    1,
+				StartTime: testGameStart,
+				EndTime:   testGameStart.Add(48 * time.Hour),
+				BloodRewardConfig: &models.BloodRewardConfig{
+					Ranks: []models.BloodRewardRule{
+						{Type: models.BloodRewardFixed, Value: 50},
+						{Type: models.BloodRewardPercent, Value: 10},
+					},
+					DecayDuration: 3600,
+					DecayMinRatio: 0.5,
+				},
+			},
+			teams: []models.Team{
+				newFixtureTeam(1, models.ParticipateApproved),
+				newFixtureTeam(2, models.ParticipateApproved),
+				newFixtureTeam(3, models.ParticipateApproved),
+			},
+			challenges: []models.GameChallenge{
+				{ChallengeID: 1, TotalScore: 500, CurScore: 500, MinimalScore: 100, Difficulty: 2, ScoringMode: models.ScoringExpDecay, Visible: true, BloodRewardEnabled: true},
+				{ChallengeID: 2, TotalScore: 300, CurScore: 300, MinimalScore: 100, ScoringMode: models.ScoringTable, ScoringConfig: &models.ScoringConfig{ScoreTable: []float64{300, 200, 50}}, Visible: true, BloodRewardEnabled: true},
+			},
+			solves: []fixtureSolve{
+				{team: 1, challenge: 1, after: 0, rank: 1},
+				{team: 2, challenge: 1, after: 30 * time.Minute, rank: 2},
+				{team: 3, challenge: 2, after: 2 * time.Hour, rank: 1},
+				{team: 2, challenge: 2, after: 3 * time.Hour, rank: 2},
+				{team: 1, challenge: 2, after: 4 * time.Hour, rank: 3},
+			},
+			// 题目 1 两个解之后为 floor(500 * (0.2 + 0.8 * e^-0.5)) = 342，二血在衰减一半时拿到 floor(34.2 * 0.75) = 25
+			// 题目 2 三个解之后查表为 50，衰减结束后一血 25 分、二血 floor(5 * 0.5) = 2
+			want:        map[int64]float64{1: 442, 2: 419, 3: 75},
+			wantPenalty: map[int64]int64{1: 7200, 2: 1800 + 3600, 3: 0},
+		},
+	}
+
+	for _, f := range fixtures {
+		t.Run(f.name, func(t *testing.T) {
+			engineChallenges, engineTotals := runEngine(&f)
+			scoreboard := runScoreBoard(&f, engineChallenges)
+
+			for teamID, want := range f.want {
+				if got := engineTotals[teamID]; got != want {
+					t.Errorf("team %d engine total = %v, want %v", teamID, got, want)
+				}
+
+				item, ok := scoreboard[teamID]
+				if !ok {
+					// 封禁的队伍不在公开积分榜上
+					continue
+				}
+				if item.Score != want {
+					t.Errorf("team %d scoreboard score = %v, want %v", teamID, item.Score, want)
+				}
+
+				// 前端展示的解题、血奖和修正明细加起来要和总分一致
+				shown := item.KothScore
+				if item.ADScore != nil {
+					shown += item.ADScore.AttackScore + item.ADScore.DefenseScore + item.ADScore.SLAScore
+				}
+				for _, solve := range item.SolvedChallenges {
+					shown += solve.Score
+				}
+				for _, adjustment := range item.ScoreAdjustments {
+					if adjustment.AdjustmentID > 0 {
+						shown += adjustment.ScoreChange
+					}
+				}
+				if shown != want {
+					t.Errorf("team %d scoreboard details add up to %v, want %v", teamID, shown, want)
+				}
+
+				if penalty, ok := f.wantPenalty[teamID]; ok && item.Penalty != penalty {
+					t.Errorf("team %d penalty = %d, want %d", teamID, item.Penalty, penalty)
+				}
+			}
+		})
+	}
+}
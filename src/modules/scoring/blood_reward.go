@@ -0,0 +1,100 @@
+package scoring
+
+import (
+	"a1ctf/src/db/models"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+)
+
+// 最多支持的奖励名次
+const maxBloodRewardRanks = 100
+
+// BloodRewardRules 返回比赛的血奖配置，没有配置时使用旧的三血比例
+func BloodRewardRules(game *models.Game) *models.BloodRewardConfig {
+	if game.BloodRewardConfig != nil {
+		return game.BloodRewardConfig
+	}
+
+	return &models.BloodRewardConfig{
+		Ranks: []models.BloodRewardRule{
+			{Type: models.BloodRewardPercent, Value: float64(game.FirstBloodReward)},
+			{Type: models.BloodRewardPercent, Value: float64(game.SecondBloodReward)},
+			{Type: models.BloodRewardPercent, Value: float64(game.ThirdBloodReward)},
+		},
+	}
+}
+
+// 奖励随时间衰减的比例
+func decayRatio(config *models.BloodRewardConfig, game *models.Game, solveTime time.Time) float64 {
+	if config.DecayDuration <= 0 {
+		return 1
+	}
+
+	elapsed := solveTime.Sub(game.StartTime).Seconds()
+	if elapsed <= 0 {
+		return 1
+	}
+
+	minRatio := math.Min(math.Max(config.DecayMinRatio, 0), 1)
+	progress := math.Min(elapsed/float64(config.DecayDuration), 1)
+	return 1 - (1-minRatio)*progress
+}
+
+// BloodReward 计算第 rank 个解出题目的队伍的额外奖励，所有计分的地方都用这个
+func BloodReward(game *models.Game, gc *models.GameChallenge, rank int32, solveTime time.Time) float64 {
+	if !gc.BloodRewardEnabled || rank <= 0 {
+		return 0
+	}
+
+	config := BloodRewardRules(game)
+	if int(rank) > len(config.Ranks) {
+		return 0
+	}
+
+	rule := config.Ranks[rank-1]
+	if rule.Value <= 0 {
+		return 0
+	}
+
+	ratio := decayRatio(config, game, solveTime)
+
+	switch rule.Type {
+	case models.BloodRewardFixed:
+		return math.Floor(rule.Value * ratio)
+	default:
+		// 百分比奖励至少给 1 分
+		return math.Max(math.Floor(gc.CurScore*rule.Value/100.0*ratio), 1)
+	}
+}
+
+// ValidateBloodRewardConfig 检查血奖配置
+func ValidateBloodRewardConfig(config *models.BloodRewardConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	if len(config.Ranks) > maxBloodRewardRanks {
+		return fmt.Errorf("at most %d rewarded ranks are supported", maxBloodRewardRanks)
+	}
+
+	for idx, rule := range config.Ranks {
+		if rule.Type != models.BloodRewardPercent && rule.Type != models.BloodRewardFixed {
+			return fmt.Errorf("unknown blood reward type for rank %d: %s", idx+1, rule.Type)
+		}
+		if rule.Value < 0 {
+			return fmt.Errorf("blood reward for rank %d must not be negative", idx+1)
+		}
+	}
+
+	if config.DecayDuration < 0 {
+		return errors.New("decay duration must not be negative")
+	}
+
+	if config.DecayMinRatio < 0 || config.DecayMinRatio > 1 {
+		return errors.New("decay min ratio must be between 0 and 1")
+	}
+
+	return nil
+}
@@ -0,0 +1,94 @@
+package scoring
+
+import (
+	"a1ctf/src/db/models"
+	"errors"
+	"fmt"
+	"math"
+)
+
+func scoringModeOf(gc *models.GameChallenge) models.ScoringMode {
+	if gc.ScoringMode == "" {
+		return models.ScoringExpDecay
+	}
+	return gc.ScoringMode
+}
+
+// ChallengeScore 根据题目的计分模式和解题人数计算当前分数，分数统一向下取整
+func ChallengeScore(gc *models.GameChallenge, solveCount int32) float64 {
+	if solveCount <= 0 {
+		return gc.TotalScore
+	}
+
+	// 第一个解出的队伍拿满分
+	n := float64(solveCount - 1)
+
+	var score float64
+
+	switch scoringModeOf(gc) {
+	case models.ScoringStatic:
+		return gc.TotalScore
+	case models.ScoringExpDecay:
+		if gc.Difficulty <= 0 || gc.TotalScore <= 0 {
+			return gc.TotalScore
+		}
+		minRatio := gc.MinimalScore / gc.TotalScore
+		dynamicRatio := (1 - minRatio) * math.Exp(-n/gc.Difficulty)
+		return math.Floor(gc.TotalScore * (minRatio + dynamicRatio))
+	case models.ScoringLogarithmic:
+		decay := decayOf(gc)
+		if decay <= 0 {
+			return gc.TotalScore
+		}
+		score = (gc.MinimalScore-gc.TotalScore)/(decay*decay)*(n*n) + gc.TotalScore
+	case models.ScoringLinear:
+		decay := decayOf(gc)
+		if decay <= 0 {
+			return gc.TotalScore
+		}
+		score = gc.TotalScore - (gc.TotalScore-gc.MinimalScore)*n/decay
+	case models.ScoringTable:
+		if gc.ScoringConfig == nil || len(gc.ScoringConfig.ScoreTable) == 0 {
+			return gc.TotalScore
+		}
+		// 分数表由管理员逐项指定，不受最低分限制
+		table := gc.ScoringConfig.ScoreTable
+		return math.Floor(table[min(int(solveCount), len(table))-1])
+	default:
+		return gc.TotalScore
+	}
+
+	return math.Floor(math.Max(score, gc.MinimalScore))
+}
+
+func decayOf(gc *models.GameChallenge) float64 {
+	if gc.ScoringConfig == nil {
+		return 0
+	}
+	return float64(gc.ScoringConfig.Decay)
+}
+
+// ValidateScoringConfig 检查计分模式需要的参数是否齐全
+func ValidateScoringConfig(mode models.ScoringMode, config *models.ScoringConfig) error {
+	switch mode {
+	case "", models.ScoringStatic, models.ScoringExpDecay:
+		return nil
+	case models.ScoringLogarithmic, models.ScoringLinear:
+		if config == nil || config.Decay <= 0 {
+			return errors.New("decay must be greater than 0")
+		}
+		return nil
+	case models.ScoringTable:
+		if config == nil || len(config.ScoreTable) == 0 {
+			return errors.New("score table must not be empty")
+		}
+		for idx, score := range config.ScoreTable {
+			if score < 0 {
+				return fmt.Errorf("score table entry %d must not be negative", idx)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown scoring mode: %s", mode)
+	}
+}
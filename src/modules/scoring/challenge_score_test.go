@@ -0,0 +1,91 @@
+package scoring
+
+import (
+	"a1ctf/src/db/models"
+	"testing"
+)
+
+func newScoringChallenge(mode models.ScoringMode, total, minimal, difficulty float64, config *models.ScoringConfig) *models.GameChallenge {
+	return &models.GameChallenge{
+		TotalScore:    total,
+		MinimalScore:  minimal,
+		Difficulty:    difficulty,
+		ScoringMode:   mode,
+		ScoringConfig: config,
+	}
+}
+
+func TestChallengeScore(t *testing.T) {
+	tests := []struct {
+		name       string
+		challenge  *models.GameChallenge
+		solveCount int32
+		want       float64
+	}{
+		{name: "no solves", challenge: newScoringChallenge(models.ScoringLinear, 500, 100, 0, &models.ScoringConfig{Decay: 4}), solveCount: 0, want: 500},
+		{name: "static", challenge: newScoringChallenge(models.ScoringStatic, 500, 100, 10, nil), solveCount: 30, want: 500},
+		{name: "unknown mode keeps total", challenge: newScoringChallenge("FANCY", 500, 100, 10, nil), solveCount: 30, want: 500},
+
+		{name: "default mode is exp decay", challenge: newScoringChallenge("", 1000, 100, 10, nil), solveCount: 2, want: 914},
+		{name: "exp decay first solve full score", challenge: newScoringChallenge(models.ScoringExpDecay, 1000, 100, 10, nil), solveCount: 1, want: 1000},
+		{name: "exp decay floors", challenge: newScoringChallenge(models.ScoringExpDecay, 1000, 100, 10, nil), solveCount: 2, want: 914},
+		{name: "exp decay after difficulty solves", challenge: newScoringChallenge(models.ScoringExpDecay, 1000, 100, 10, nil), solveCount: 11, want: 431},
+		{name: "exp decay approaches minimal", challenge: newScoringChallenge(models.ScoringExpDecay, 1000, 100, 10, nil), solveCount: 500, want: 100},
+		{name: "exp decay without difficulty", challenge: newScoringChallenge(models.ScoringExpDecay, 1000, 100, 0, nil), solveCount: 5, want: 1000},
+
+		{name: "logarithmic first solve", challenge: newScoringChallenge(models.ScoringLogarithmic, 500, 100, 0, &models.ScoringConfig{Decay: 10}), solveCount: 1, want: 500},
+		{name: "logarithmic", challenge: newScoringChallenge(models.ScoringLogarithmic, 500, 100, 0, &models.ScoringConfig{Decay: 10}), solveCount: 6, want: 400},
+		{name: "logarithmic reaches minimal at decay", challenge: newScoringChallenge(models.ScoringLogarithmic, 500, 100, 0, &models.ScoringConfig{Decay: 10}), solveCount: 11, want: 100},
+		{name: "logarithmic clamps to minimal", challenge: newScoringChallenge(models.ScoringLogarithmic, 500, 100, 0, &models.ScoringConfig{Decay: 10}), solveCount: 30, want: 100},
+		{name: "logarithmic floors", challenge: newScoringChallenge(models.ScoringLogarithmic, 500, 100, 0, &models.ScoringConfig{Decay: 3}), solveCount: 2, want: 455},
+		{name: "logarithmic without decay", challenge: newScoringChallenge(models.ScoringLogarithmic, 500, 100, 0, nil), solveCount: 6, want: 500},
+
+		{name: "linear", challenge: newScoringChallenge(models.ScoringLinear, 500, 100, 0, &models.ScoringConfig{Decay: 4}), solveCount: 2, want: 400},
+		{name: "linear reaches minimal at decay", challenge: newScoringChallenge(models.ScoringLinear, 500, 100, 0, &models.ScoringConfig{Decay: 4}), solveCount: 5, want: 100},
+		{name: "linear clamps to minimal", challenge: newScoringChallenge(models.ScoringLinear, 500, 100, 0, &models.ScoringConfig{Decay: 4}), solveCount: 9, want: 100},
+		{name: "linear floors", challenge: newScoringChallenge(models.ScoringLinear, 500, 100, 0, &models.ScoringConfig{Decay: 3}), solveCount: 2, want: 366},
+		{name: "linear without decay", challenge: newScoringChallenge(models.ScoringLinear, 500, 100, 0, &models.ScoringConfig{}), solveCount: 3, want: 500},
+
+		{name: "table first entry", challenge: newScoringChallenge(models.ScoringTable, 500, 100, 0, &models.ScoringConfig{ScoreTable: []float64{500, 400.7, 50}}), solveCount: 1, want: 500},
+		{name: "table floors", challenge: newScoringChallenge(models.ScoringTable, 500, 100, 0, &models.ScoringConfig{ScoreTable: []float64{500, 400.7, 50}}), solveCount: 2, want: 400},
+		{name: "table ignores minimal", challenge: newScoringChallenge(models.ScoringTable, 500, 100, 0, &models.ScoringConfig{ScoreTable: []float64{500, 400.7, 50}}), solveCount: 3, want: 50},
+		{name: "table keeps last entry", challenge: newScoringChallenge(models.ScoringTable, 500, 100, 0, &models.ScoringConfig{ScoreTable: []float64{500, 400.7, 50}}), solveCount: 10, want: 50},
+		{name: "empty table", challenge: newScoringChallenge(models.ScoringTable, 500, 100, 0, &models.ScoringConfig{}), solveCount: 2, want: 500},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ChallengeScore(tt.challenge, tt.solveCount); got != tt.want {
+				t.Errorf("ChallengeScore(%d) = %v, want %v", tt.solveCount, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateScoringConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		mode    models.ScoringMode
+		config  *models.ScoringConfig
+		wantErr bool
+	}{
+		{name: "default", mode: ""},
+		{name: "static", mode: models.ScoringStatic},
+		{name: "exp decay", mode: models.ScoringExpDecay},
+		{name: "logarithmic", mode: models.ScoringLogarithmic, config: &models.ScoringConfig{Decay: 10}},
+		{name: "logarithmic without config", mode: models.ScoringLogarithmic, wantErr: true},
+		{name: "linear zero decay", mode: models.ScoringLinear, config: &models.ScoringConfig{Decay: 0}, wantErr: true},
+		{name: "table", mode: models.ScoringTable, config: &models.ScoringConfig{ScoreTable: []float64{500, 300}}},
+		{name: "empty table", mode: models.ScoringTable, config: &models.ScoringConfig{}, wantErr: true},
+		{name: "negative table entry", mode: models.ScoringTable, config: &models.ScoringConfig{ScoreTable: []float64{500, -1}}, wantErr: true},
+		{name: "unknown", mode: "FANCY", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateScoringConfig(tt.mode, tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateScoringConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package scoring
+
+import (
+	"a1ctf/src/db/models"
+)
+
+// SolveScore 一次解题给队伍带来的分数
+type SolveScore struct {
+	Solve *models.Solve
+	// 题目当前的分数
+	ChallengeScore float64
+	BloodReward    float64
+}
+
+// TeamScore 队伍的总分和组成
+type TeamScore struct {
+	Solves      []SolveScore
+	Adjustments float64
+	// 攻防轮次和 KOTH 占领靶机的分数
+	Extra float64
+	Total float64
+}
+
+// CalculateTeamScore 计算队伍总分，积分引擎写入的总分和积分榜历史、公开积分榜都用这个，
+// 只统计比赛时间内的正确解题和可见的题目
+func CalculateTeamScore(game *models.Game, challenges map[int64]*models.GameChallenge, solves []models.Solve, adjustments float64, extra float64) TeamScore {
+	result := TeamScore{
+		Solves:      make([]SolveScore, 0, len(solves)),
+		Adjustments: adjustments,
+		Extra:       extra,
+		Total:       adjustments + extra,
+	}
+
+	for idx := range solves {
+		solve := &solves[idx]
+		if solve.SolveStatus != models.SolveCorrect || solve.SolveTime.Before(game.StartTime) || solve.SolveTime.After(game.EndTime) {
+			continue
+		}
+
+		gc, ok := challenges[solve.ChallengeID]
+		if !ok || !gc.Visible {
+			continue
+		}
+
+		item := SolveScore{
+			Solve:          solve,
+			ChallengeScore: gc.CurScore,
+			BloodReward:    BloodReward(game, gc, solve.Rank, solve.SolveTime),
+		}
+		result.Total += item.ChallengeScore + item.BloodReward
+		result.Solves = append(result.Solves, item)
+	}
+
+	return result
+}
+
+// AppendHistory 总分变化时在积分榜历史末尾追加一条记录，返回是否追加了
+func AppendHistory(scoreboard *models.ScoreBoard, record models.ScoreBoardData) bool {
+	if len(scoreboard.Data) > 0 && scoreboard.CurScore == record.Score {
+		return false
+	}
+
+	scoreboard.Data = append(scoreboard.Data, record)
+	scoreboard.CurScore = record.Score
+	scoreboard.LastUpdateTime = record.RecordTime
+	return true
+}
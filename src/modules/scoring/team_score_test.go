@@ -0,0 +1,255 @@
+package scoring
+
+import (
+	"a1ctf/src/db/models"
+	"fmt"
+	"sort"
+	"testing"
+	"time"
+)
+
+var testGameStart = time.Date(2025, 10, 1, 8, 0, 0, 0, time.UTC)
+
+type testSolve struct {
+	team      int64
+	challenge int64
+	after     time.Duration
+	status    models.SolveStatus
+}
+
+type testAdjustment struct {
+	team   int64
+	change float64
+}
+
+type teamScoreCase struct {
+	name        string
+	game        models.Game
+	challenges  []models.GameChallenge
+	solves      []testSolve
+	adjustments []testAdjustment
+	extra       map[int64]float64
+	want        map[int64]float64
+}
+
+func newTestGame(first, second, third int64, config *models.BloodRewardConfig) models.Game {
+	return models.Game{
+		GameID:            1,
+		StartTime:         testGameStart,
+		EndTime:           testGameStart.Add(48 * time.Hour),
+		FirstBloodReward:  first,
+		SecondBloodReward: second,
+		ThirdBloodReward:  third,
+		BloodRewardConfig: config,
+	}
+}
+
+// countsForScore 和积分引擎一样，只有比赛时间内的正确解题计入解题人数
+func countsForScore(game *models.Game, solve *models.Solve) bool {
+	return solve.SolveStatus == models.SolveCorrect && !solve.SolveTime.Before(game.StartTime) && !solve.SolveTime.After(game.EndTime)
+}
+
+// replay 按时间顺序重放解题和修正，每个事件之后像积分引擎一样重算题目分数和队伍总分并追加积分榜历史
+func replay(t *testing.T, tc teamScoreCase) (map[int64]TeamScore, map[int64]*models.ScoreBoard) {
+	t.Helper()
+
+	game := tc.game
+	challenges := make(map[int64]*models.GameChallenge, len(tc.challenges))
+	for idx := range tc.challenges {
+		gc := tc.challenges[idx]
+		gc.CurScore = ChallengeScore(&gc, 0)
+		challenges[gc.ChallengeID] = &gc
+	}
+
+	ordered := append([]testSolve(nil), tc.solves...)
+	sort.SliceStable(ordered, func(i, j int) bool { return ordered[i].after < ordered[j].after })
+
+	teams := make(map[int64]struct{})
+	for _, s := range tc.solves {
+		teams[s.team] = struct{}{}
+	}
+	for _, a := range tc.adjustments {
+		teams[a.team] = struct{}{}
+	}
+	for teamID := range tc.extra {
+		teams[teamID] = struct{}{}
+	}
+
+	solves := make(map[int64][]models.Solve)
+	adjustments := make(map[int64]float64)
+	histories := make(map[int64]*models.ScoreBoard)
+	results := make(map[int64]TeamScore)
+
+	recompute := func(now time.Time) {
+		for teamID := range teams {
+			result := CalculateTeamScore(&game, challenges, solves[teamID], adjustments[teamID], tc.extra[teamID])
+			results[teamID] = result
+
+			history, ok := histories[teamID]
+			if !ok {
+				history = &models.ScoreBoard{GameID: game.GameID, TeamID: teamID, GenerateTime: now}
+				histories[teamID] = history
+			}
+			AppendHistory(history, models.ScoreBoardData{Score: result.Total, RecordTime: now})
+		}
+	}
+
+	for idx, s := range ordered {
+		status := s.status
+		if status == "" {
+			status = models.SolveCorrect
+		}
+		solve := models.Solve{
+			SolveID:     fmt.Sprintf("solve-%d", idx),
+			ChallengeID: s.challenge,
+			TeamID:      s.team,
+			GameID:      game.GameID,
+			SolveStatus: status,
+			SolveTime:   testGameStart.Add(s.after),
+		}
+
+		if gc, ok := challenges[s.challenge]; ok && countsForScore(&game, &solve) {
+			gc.SolveCount++
+			solve.Rank = gc.SolveCount
+			gc.CurScore = ChallengeScore(gc, gc.SolveCount)
+		}
+
+		solves[s.team] = append(solves[s.team], solve)
+		recompute(solve.SolveTime)
+	}
+
+	for _, a := range tc.adjustments {
+		adjustments[a.team] += a.change
+	}
+	recompute(game.EndTime)
+
+	return results, histories
+}
+
+// 积分引擎和公开积分榜是否一致见 score_engine 的测试，这里按时间重放检查总分和积分榜历史
+func TestCalculateTeamScoreReplay(t *testing.T) {
+	cases := []teamScoreCase{
+		{
+			name: "static challenge with legacy three blood percentages",
+			game: newTestGame(5, 3, 1, nil),
+			challenges: []models.GameChallenge{
+				{ChallengeID: 1, TotalScore: 100, ScoringMode: models.ScoringStatic, Visible: true, BloodRewardEnabled: true},
+			},
+			solves: []testSolve{
+				{team: 1, challenge: 1, after: 10 * time.Minute},
+				{team: 2, challenge: 1, after: 20 * time.Minute},
+				{team: 3, challenge: 1, after: 30 * time.Minute},
+				{team: 4, challenge: 1, after: 40 * time.Minute},
+			},
+			want: map[int64]float64{1: 105, 2: 103, 3: 101, 4: 100},
+		},
+		{
+			name: "exp decay with fixed and percent ranks decaying over time",
+			game: newTestGame(0, 0, 0, &models.BloodRewardConfig{
+				Ranks: []models.BloodRewardRule{
+					{Type: models.BloodRewardFixed, Value: 50},
+					{Type: models.BloodRewardPercent, Value: 10},
+				},
+				DecayDuration: 3600,
+				DecayMinRatio: 0.5,
+			}),
+			challenges: []models.GameChallenge{
+				{ChallengeID: 1, TotalScore: 500, MinimalScore: 100, Difficulty: 2, ScoringMode: models.ScoringExpDecay, Visible: true, BloodRewardEnabled: true},
+			},
+			solves: []testSolve{
+				{team: 1, challenge: 1, after: 0},
+				{team: 2, challenge: 1, after: 30 * time.Minute},
+			},
+			// 两个解之后题目分数为 floor(500 * (0.2 + 0.8 * e^-0.5)) = 342，二血在衰减一半时拿到 floor(34.2 * 0.75) = 25
+			want: map[int64]float64{1: 392, 2: 367},
+		},
+		{
+			name: "linear decay with adjustments, extra scores and ignored solves",
+			game: newTestGame(10, 5, 0, nil),
+			challenges: []models.GameChallenge{
+				{ChallengeID: 1, TotalScore: 300, MinimalScore: 100, ScoringMode: models.ScoringLinear, ScoringConfig: &models.ScoringConfig{Decay: 4}, Visible: true},
+				{ChallengeID: 2, TotalScore: 1000, ScoringMode: models.ScoringStatic, Visible: false, BloodRewardEnabled: true},
+			},
+			solves: []testSolve{
+				{team: 1, challenge: 1, after: time.Minute},
+				{team: 2, challenge: 1, after: 2 * time.Minute},
+				{team: 3, challenge: 1, after: 3 * time.Minute},
+				// 不可见的题目、无效的解题和比赛结束之后的解题都不计分
+				{team: 1, challenge: 2, after: 4 * time.Minute},
+				{team: 2, challenge: 1, after: 5 * time.Minute, status: models.SolveInvalid},
+				{team: 4, challenge: 1, after: 49 * time.Hour},
+			},
+			adjustments: []testAdjustment{
+				{team: 1, change: -50},
+				{team: 2, change: 20},
+				{team: 2, change: 10},
+			},
+			extra: map[int64]float64{3: 40},
+			want:  map[int64]float64{1: 150, 2: 230, 3: 240, 4: 0},
+		},
+		{
+			name: "score table is not clamped by the minimal score",
+			game: newTestGame(10, 5, 0, nil),
+			challenges: []models.GameChallenge{
+				{ChallengeID: 1, TotalScore: 300, MinimalScore: 100, ScoringMode: models.ScoringTable, ScoringConfig: &models.ScoringConfig{ScoreTable: []float64{300, 200, 50}}, Visible: true, BloodRewardEnabled: true},
+			},
+			solves: []testSolve{
+				{team: 1, challenge: 1, after: time.Minute},
+				{team: 2, challenge: 1, after: 2 * time.Minute},
+				{team: 3, challenge: 1, after: 3 * time.Minute},
+			},
+			// 百分比奖励至少 1 分，二血 floor(2.5) = 2
+			want: map[int64]float64{1: 55, 2: 52, 3: 50},
+		},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			results, histories := replay(t, tc)
+
+			for teamID, want := range tc.want {
+				result := results[teamID]
+
+				// 积分引擎写入 teams.team_score 的总分
+				if result.Total != want {
+					t.Errorf("team %d total = %v, want %v", teamID, result.Total, want)
+				}
+
+				// 积分榜历史的最后一条记录
+				history := histories[teamID]
+				if len(history.Data) == 0 {
+					t.Fatalf("team %d has no scoreboard history", teamID)
+				}
+				if last := history.Data[len(history.Data)-1].Score; last != want || history.CurScore != want {
+					t.Errorf("team %d history ends at %v (cur %v), want %v", teamID, last, history.CurScore, want)
+				}
+			}
+		})
+	}
+}
+
+func TestAppendHistorySkipsUnchangedScore(t *testing.T) {
+	scoreboard := models.ScoreBoard{}
+
+	steps := []struct {
+		score    float64
+		appended bool
+	}{
+		{score: 0, appended: true},
+		{score: 0, appended: false},
+		{score: 100, appended: true},
+		{score: 100, appended: false},
+		{score: 80, appended: true},
+	}
+
+	for idx, step := range steps {
+		got := AppendHistory(&scoreboard, models.ScoreBoardData{Score: step.score, RecordTime: testGameStart.Add(time.Duration(idx) * time.Minute)})
+		if got != step.appended {
+			t.Errorf("step %d: appended = %v, want %v", idx, got, step.appended)
+		}
+	}
+
+	if len(scoreboard.Data) != 3 || scoreboard.CurScore != 80 {
+		t.Errorf("history has %d records ending at %v, want 3 records ending at 80", len(scoreboard.Data), scoreboard.CurScore)
+	}
+}
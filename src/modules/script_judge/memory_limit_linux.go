@@ -0,0 +1,53 @@
+package scriptjudge
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+// 数据段在脚本可用内存之外的余量，运行时的元数据、goroutine 栈和 GC 的工作内存都算在里面
+const memoryHeadroom = 64 * 1024 * 1024
+
+// capMemory 限制 worker 进程的内存，GC 在接近上限时积极回收，只是分配得多的脚本不会被误杀；
+// 数据段的硬上限挡住一次性的大分配，超过时运行时直接以 out of memory 退出
+func capMemory(maxAllocBytes uint64) error {
+	used, err := dataSegmentBytes()
+	if err != nil {
+		return fmt.Errorf("read data segment size: %w", err)
+	}
+
+	debug.SetMemoryLimit(int64(used + maxAllocBytes))
+
+	limit := used + 2*maxAllocBytes + memoryHeadroom
+	return syscall.Setrlimit(syscall.RLIMIT_DATA, &syscall.Rlimit{Cur: limit, Max: limit})
+}
+
+// 当前的数据段大小，RLIMIT_DATA 限制的就是这个值
+func dataSegmentBytes() (uint64, error) {
+	file, err := os.Open("/proc/self/status")
+	if err != nil {
+		return 0, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 3 && fields[0] == "VmData:" && fields[2] == "kB" {
+			kb, err := strconv.ParseUint(fields[1], 10, 64)
+			if err != nil {
+				return 0, err
+			}
+			return kb * 1024, nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return 0, err
+	}
+	return 0, fmt.Errorf("VmData not found")
+}
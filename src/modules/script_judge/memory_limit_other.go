@@ -0,0 +1,16 @@
+//go:build !linux
+
+package scriptjudge
+
+import (
+	"runtime/debug"
+	"runtime/metrics"
+)
+
+// capMemory 其他系统没有 RLIMIT_DATA，只能让 GC 在接近上限时积极回收，一次性的大分配挡不住
+func capMemory(maxAllocBytes uint64) error {
+	sample := []metrics.Sample{{Name: "/memory/classes/total:bytes"}}
+	metrics.Read(sample)
+	debug.SetMemoryLimit(int64(sample[0].Value.Uint64() + maxAllocBytes))
+	return nil
+}
@@ -0,0 +1,283 @@
+package scriptjudge
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"runtime"
+	"runtime/metrics"
+	"sync"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.starlark.net/lib/json"
+	"go.starlark.net/lib/math"
+	"go.starlark.net/starlark"
+	"go.starlark.net/starlarkstruct"
+	"go.starlark.net/syntax"
+)
+
+// 判题脚本的入口函数名，脚本需要定义 def judge(submission): ...
+const entryFunction = "judge"
+
+var (
+	ErrTimeLimitExceeded   = errors.New("judge script time limit exceeded")
+	ErrMemoryLimitExceeded = errors.New("judge script memory limit exceeded")
+)
+
+var (
+	maxExecutionSteps uint64 = 1000000
+	executionTimeout         = time.Second
+	maxAllocBytes     uint64 = 64 * 1024 * 1024
+	maxMessageLength         = 256
+)
+
+// 脚本只能使用这些预定义模块，Starlark 本身没有文件和网络访问能力
+var predeclared = starlark.StringDict{
+	"json":    json.Module,
+	"math":    math.Module,
+	"hashlib": hashlibModule,
+}
+
+var fileOptions = &syntax.FileOptions{
+	While:           true,
+	TopLevelControl: true,
+	GlobalReassign:  true,
+}
+
+type JudgeInput struct {
+	Content     string
+	TeamID      int64
+	TeamHash    string
+	TeamFlag    string
+	GameID      int64
+	ChallengeID int64
+}
+
+type JudgeOutput struct {
+	Accepted bool
+	Message  string
+}
+
+func LoadConfig() {
+	if steps := viper.GetUint64("judge-script.max-steps"); steps > 0 {
+		maxExecutionSteps = steps
+	}
+	if timeout := viper.GetDuration("judge-script.timeout"); timeout > 0 {
+		executionTimeout = timeout
+	}
+	if memory := viper.GetSizeInBytes("judge-script.max-memory"); memory > 0 {
+		maxAllocBytes = uint64(memory)
+	}
+}
+
+func compile(script string) (*starlark.Program, error) {
+	_, prog, err := starlark.SourceProgramOptions(fileOptions, "judge.star", script, predeclared.Has)
+	return prog, err
+}
+
+// Validate 检查脚本能否编译并且定义了 judge 函数，顶层代码同样在子进程里执行
+func Validate(script string) error {
+	_, err := runWorker(workerRequest{Script: script, Validate: true})
+	return err
+}
+
+// Run 在沙箱子进程中执行判题脚本，每个判题独占一个进程，互不影响
+func Run(script string, input JudgeInput) (*JudgeOutput, error) {
+	return runWorker(workerRequest{Script: script, Input: input})
+}
+
+func newThread(maxSteps uint64) *starlark.Thread {
+	thread := &starlark.Thread{
+		Name:  "judge",
+		Print: func(_ *starlark.Thread, _ string) {},
+		Load: func(_ *starlark.Thread, module string) (starlark.StringDict, error) {
+			return nil, fmt.Errorf("load(%q) is not allowed in judge scripts", module)
+		},
+	}
+	thread.SetMaxExecutionSteps(maxSteps)
+	return thread
+}
+
+func heapBytes(sample []metrics.Sample) uint64 {
+	metrics.Read(sample)
+	return sample[0].Value.Uint64()
+}
+
+// heapGrowth 堆上的对象相对执行前增长的大小
+func heapGrowth(sample []metrics.Sample, base uint64) uint64 {
+	if current := heapBytes(sample); current > base {
+		return current - base
+	}
+	return 0
+}
+
+// execute 在 worker 进程里执行脚本，进程里只有这一个脚本，堆上对象的增长就是脚本占用的内存
+func execute(request *workerRequest) (*JudgeOutput, error) {
+	prog, err := compile(request.Script)
+	if err != nil {
+		return nil, fmt.Errorf("compile judge script: %w", err)
+	}
+
+	thread := newThread(request.MaxSteps)
+
+	sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+	heapBase := heapBytes(sample)
+
+	var limitErr error
+	var limitMutex sync.Mutex
+
+	// 看门狗，超时或者占用内存过多时中断脚本
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(5 * time.Millisecond)
+		defer ticker.Stop()
+		deadline := time.NewTimer(request.Timeout)
+		defer deadline.Stop()
+
+		for {
+			select {
+			case <-done:
+				return
+			case <-deadline.C:
+				limitMutex.Lock()
+				limitErr = ErrTimeLimitExceeded
+				limitMutex.Unlock()
+				thread.Cancel("time limit exceeded")
+				return
+			case <-ticker.C:
+				if heapGrowth(sample, heapBase) <= request.MaxAllocBytes {
+					continue
+				}
+				// 堆里可能还有没回收的垃圾，回收之后仍然超过才中断
+				runtime.GC()
+				if heapGrowth(sample, heapBase) > request.MaxAllocBytes {
+					limitMutex.Lock()
+					limitErr = ErrMemoryLimitExceeded
+					limitMutex.Unlock()
+					thread.Cancel("memory limit exceeded")
+					return
+				}
+			}
+		}
+	}()
+
+	result, err := func() (starlark.Value, error) {
+		defer close(done)
+
+		globals, err := prog.Init(thread, predeclared)
+		if err != nil {
+			return nil, err
+		}
+
+		fn, ok := globals[entryFunction].(starlark.Callable)
+		if !ok {
+			return nil, fmt.Errorf("judge script must define function %s(submission)", entryFunction)
+		}
+		if request.Validate {
+			return nil, nil
+		}
+
+		input := request.Input
+		submission := starlarkstruct.FromStringDict(starlarkstruct.Default, starlark.StringDict{
+			"content":      starlark.String(input.Content),
+			"team_id":      starlark.MakeInt64(input.TeamID),
+			"team_hash":    starlark.String(input.TeamHash),
+			"team_flag":    starlark.String(input.TeamFlag),
+			"game_id":      starlark.MakeInt64(input.GameID),
+			"challenge_id": starlark.MakeInt64(input.ChallengeID),
+		})
+
+		return starlark.Call(thread, fn, starlark.Tuple{submission}, nil)
+	}()
+
+	limitMutex.Lock()
+	if limitErr != nil {
+		err = limitErr
+	}
+	limitMutex.Unlock()
+
+	if err != nil {
+		if thread.ExecutionSteps() >= request.MaxSteps {
+			return nil, ErrTimeLimitExceeded
+		}
+		return nil, err
+	}
+	if request.Validate {
+		return nil, nil
+	}
+
+	return parseResult(result, request.MaxMessageLength)
+}
+
+// 脚本可以返回 bool 或者 (bool, message)
+func parseResult(result starlark.Value, maxLength int) (*JudgeOutput, error) {
+	output := JudgeOutput{}
+
+	switch v := result.(type) {
+	case starlark.Bool:
+		output.Accepted = bool(v)
+	case starlark.Tuple:
+		if len(v) != 2 {
+			return nil, fmt.Errorf("judge script must return bool or (bool, message), got tuple of %d", len(v))
+		}
+		accepted, ok := v[0].(starlark.Bool)
+		if !ok {
+			return nil, fmt.Errorf("judge script result[0] must be bool, got %s", v[0].Type())
+		}
+		message, ok := starlark.AsString(v[1])
+		if !ok {
+			return nil, fmt.Errorf("judge script result[1] must be string, got %s", v[1].Type())
+		}
+		output.Accepted = bool(accepted)
+		output.Message = message
+	default:
+		return nil, fmt.Errorf("judge script must return bool or (bool, message), got %s", result.Type())
+	}
+
+	if len(output.Message) > maxLength {
+		output.Message = output.Message[:maxLength]
+	}
+
+	return &output, nil
+}
+
+var hashlibModule = &starlarkstruct.Module{
+	Name: "hashlib",
+	Members: starlark.StringDict{
+		"md5":         hashBuiltin("md5", md5.New),
+		"sha1":        hashBuiltin("sha1", sha1.New),
+		"sha256":      hashBuiltin("sha256", sha256.New),
+		"sha512":      hashBuiltin("sha512", sha512.New),
+		"hmac_sha256": starlark.NewBuiltin("hmac_sha256", hmacSHA256),
+	},
+}
+
+// 返回十六进制的摘要
+func hashBuiltin(name string, newHash func() hash.Hash) *starlark.Builtin {
+	return starlark.NewBuiltin(name, func(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+		var data string
+		if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 1, &data); err != nil {
+			return nil, err
+		}
+		h := newHash()
+		h.Write([]byte(data))
+		return starlark.String(hex.EncodeToString(h.Sum(nil))), nil
+	})
+}
+
+func hmacSHA256(_ *starlark.Thread, b *starlark.Builtin, args starlark.Tuple, kwargs []starlark.Tuple) (starlark.Value, error) {
+	var key, data string
+	if err := starlark.UnpackPositionalArgs(b.Name(), args, kwargs, 2, &key, &data); err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, []byte(key))
+	mac.Write([]byte(data))
+	return starlark.String(hex.EncodeToString(mac.Sum(nil))), nil
+}
@@ -0,0 +1,231 @@
+package scriptjudge
+
+import (
+	"errors"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"go.starlark.net/starlark"
+)
+
+// 测试用的子进程任务，原样返回输入，输入为 oom 时申请超过限制的内存
+func init() {
+	RegisterWorkerHandler("echo", func(payload []byte) ([]byte, error) {
+		switch string(payload) {
+		case "fail":
+			return nil, errors.New("echo failed")
+		case "oom":
+			data := make([][]byte, 0)
+			for {
+				data = append(data, make([]byte, 1<<20))
+			}
+		}
+		return payload, nil
+	})
+}
+
+// runWorker 会重新执行当前程序，测试程序自己充当 worker
+func TestMain(m *testing.M) {
+	if IsWorker() {
+		WorkerMain()
+		return
+	}
+	os.Exit(m.Run())
+}
+
+// withLimits 临时修改沙箱限制，测试结束后恢复
+func withLimits(t *testing.T, steps uint64, timeout time.Duration, memory uint64) {
+	t.Helper()
+
+	oldSteps, oldTimeout, oldMemory := maxExecutionSteps, executionTimeout, maxAllocBytes
+	maxExecutionSteps, executionTimeout, maxAllocBytes = steps, timeout, memory
+	t.Cleanup(func() {
+		maxExecutionSteps, executionTimeout, maxAllocBytes = oldSteps, oldTimeout, oldMemory
+	})
+}
+
+func TestRunLimits(t *testing.T) {
+	tests := []struct {
+		name    string
+		steps   uint64
+		timeout time.Duration
+		memory  uint64
+		script  string
+		wantErr error
+	}{
+		{
+			name:    "step limit",
+			steps:   10000,
+			timeout: 10 * time.Second,
+			memory:  64 << 20,
+			script:  "def judge(s):\n    while True:\n        pass\n",
+			wantErr: ErrTimeLimitExceeded,
+		},
+		{
+			name:    "time limit",
+			steps:   1 << 62,
+			timeout: 200 * time.Millisecond,
+			memory:  64 << 20,
+			script:  "def judge(s):\n    while True:\n        pass\n",
+			wantErr: ErrTimeLimitExceeded,
+		},
+		{
+			name:    "memory kept alive",
+			steps:   1 << 62,
+			timeout: 10 * time.Second,
+			memory:  16 << 20,
+			script:  "def judge(s):\n    kept = []\n    for i in range(100000):\n        kept.append(\"x\" * 1024 + str(i))\n    return True\n",
+			wantErr: ErrMemoryLimitExceeded,
+		},
+		{
+			name:    "single huge allocation",
+			steps:   1 << 62,
+			timeout: 10 * time.Second,
+			memory:  16 << 20,
+			script:  "def judge(s):\n    return len(\"x\" * (1 << 29)) > 0\n",
+			wantErr: ErrMemoryLimitExceeded,
+		},
+		{
+			name:    "churn below the live heap limit",
+			steps:   1 << 62,
+			timeout: 10 * time.Second,
+			memory:  16 << 20,
+			// 总共分配 256MB，同一时间只存活 1MB
+			script: "def judge(s):\n    for i in range(256):\n        chunk = \"x\" * (1 << 20) + str(i)\n    return True\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			withLimits(t, tt.steps, tt.timeout, tt.memory)
+
+			output, err := Run(tt.script, JudgeInput{Content: "flag{test}"})
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("Run() error = %v, want %v", err, tt.wantErr)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Run() error = %v", err)
+			}
+			if !output.Accepted {
+				t.Errorf("Run() accepted = false, want true")
+			}
+		})
+	}
+}
+
+func TestRunInput(t *testing.T) {
+	withLimits(t, 1000000, 10*time.Second, 64<<20)
+
+	script := `
+def judge(s):
+    if s.content == s.team_flag and s.team_id == 7 and s.team_hash == "abc" and s.game_id == 1 and s.challenge_id == 2:
+        return (True, hashlib.sha256(s.content))
+    return (False, "wrong")
+`
+	input := JudgeInput{Content: "flag{ok}", TeamID: 7, TeamHash: "abc", TeamFlag: "flag{ok}", GameID: 1, ChallengeID: 2}
+
+	output, err := Run(script, input)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if !output.Accepted || len(output.Message) != 64 {
+		t.Errorf("Run() = %+v, want accepted with a sha256 message", output)
+	}
+
+	input.Content = "flag{bad}"
+	output, err = Run(script, input)
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+	if output.Accepted || output.Message != "wrong" {
+		t.Errorf("Run() = %+v, want rejected with message", output)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	withLimits(t, 1000000, 10*time.Second, 64<<20)
+
+	tests := []struct {
+		name    string
+		script  string
+		wantErr bool
+	}{
+		{name: "valid", script: "def judge(s):\n    return True\n"},
+		{name: "syntax error", script: "def judge(s)\n    return True\n", wantErr: true},
+		{name: "missing judge", script: "def check(s):\n    return True\n", wantErr: true},
+		{name: "load is not allowed", script: "load(\"os.star\", \"os\")\ndef judge(s):\n    return True\n", wantErr: true},
+		{name: "top level loop is limited", script: "while True:\n    pass\ndef judge(s):\n    return True\n", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := Validate(tt.script); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestRunInWorker(t *testing.T) {
+	withLimits(t, 1000000, 10*time.Second, 64<<20)
+
+	output, err := RunInWorker("echo", []byte("hello"), 10*time.Second)
+	if err != nil || string(output) != "hello" {
+		t.Errorf("RunInWorker() = %q, %v, want hello", output, err)
+	}
+
+	if _, err := RunInWorker("echo", []byte("fail"), 10*time.Second); err == nil || err.Error() != "echo failed" {
+		t.Errorf("RunInWorker() error = %v, want echo failed", err)
+	}
+
+	if _, err := RunInWorker("echo", []byte("oom"), 10*time.Second); !errors.Is(err, ErrMemoryLimitExceeded) {
+		t.Errorf("RunInWorker() error = %v, want %v", err, ErrMemoryLimitExceeded)
+	}
+
+	if _, err := RunInWorker("missing", nil, 10*time.Second); err == nil {
+		t.Error("RunInWorker() with unknown task should fail")
+	}
+}
+
+func TestParseResult(t *testing.T) {
+	longMessage := strings.Repeat("m", 300)
+
+	tests := []struct {
+		name    string
+		result  starlark.Value
+		want    JudgeOutput
+		wantErr bool
+	}{
+		{name: "accepted", result: starlark.True, want: JudgeOutput{Accepted: true}},
+		{name: "rejected", result: starlark.False, want: JudgeOutput{Accepted: false}},
+		{name: "with message", result: starlark.Tuple{starlark.True, starlark.String("nice")}, want: JudgeOutput{Accepted: true, Message: "nice"}},
+		{name: "message is truncated", result: starlark.Tuple{starlark.False, starlark.String(longMessage)}, want: JudgeOutput{Accepted: false, Message: longMessage[:256]}},
+		{name: "wrong tuple size", result: starlark.Tuple{starlark.True}, wantErr: true},
+		{name: "non bool verdict", result: starlark.Tuple{starlark.MakeInt(1), starlark.String("x")}, wantErr: true},
+		{name: "non string message", result: starlark.Tuple{starlark.True, starlark.MakeInt(1)}, wantErr: true},
+		{name: "other type", result: starlark.String("yes"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := parseResult(tt.result, 256)
+			if tt.wantErr {
+				if err == nil {
+					t.Errorf("parseResult() = %+v, want error", got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("parseResult() error = %v", err)
+			}
+			if *got != tt.want {
+				t.Errorf("parseResult() = %+v, want %+v", *got, tt.want)
+			}
+		})
+	}
+}
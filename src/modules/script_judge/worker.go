@@ -0,0 +1,184 @@
+package scriptjudge
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// WorkerCommand 沙箱子进程的启动参数，main 里看到这个参数就只执行一个判题脚本或者注册的任务然后退出
+const WorkerCommand = "judge-script-worker"
+
+// 子进程启动需要时间，父进程多等一会儿，让子进程自己的看门狗先生效
+const workerStartupGrace = 2 * time.Second
+
+const (
+	limitTime   = "time"
+	limitMemory = "memory"
+)
+
+type workerRequest struct {
+	// Kind 不为空时执行其他模块注册的任务，Payload 由注册方自己解析
+	Kind             string
+	Payload          []byte
+	Script           string
+	Input            JudgeInput
+	Validate         bool
+	MaxSteps         uint64
+	Timeout          time.Duration
+	MaxAllocBytes    uint64
+	MaxMessageLength int
+}
+
+type workerResponse struct {
+	Output  *JudgeOutput
+	Payload []byte
+	Limit   string
+	Error   string
+}
+
+var workerExecutable = sync.OnceValues(os.Executable)
+
+// WorkerHandler 在子进程里执行的任务，输入输出的格式由注册方决定
+type WorkerHandler func(payload []byte) ([]byte, error)
+
+var workerHandlers = make(map[string]WorkerHandler)
+
+// RegisterWorkerHandler 注册可以在子进程里执行的任务，需要在 init 里调用，父子进程是同一个程序，两边都能找到
+func RegisterWorkerHandler(kind string, handler WorkerHandler) {
+	workerHandlers[kind] = handler
+}
+
+// IsWorker 当前进程是不是判题脚本子进程
+func IsWorker() bool {
+	return len(os.Args) > 1 && os.Args[1] == WorkerCommand
+}
+
+// WorkerMain 子进程入口，从 stdin 读取脚本和输入，结果写到 stdout
+func WorkerMain() {
+	response := workerResponse{}
+
+	var request workerRequest
+	data, err := io.ReadAll(os.Stdin)
+	if err == nil {
+		err = sonic.Unmarshal(data, &request)
+	}
+
+	if err == nil {
+		err = capMemory(request.MaxAllocBytes)
+	}
+
+	if err == nil {
+		if request.Kind == "" {
+			response.Output, err = execute(&request)
+		} else if handler, ok := workerHandlers[request.Kind]; ok {
+			response.Payload, err = handler(request.Payload)
+		} else {
+			err = fmt.Errorf("unknown worker task %q", request.Kind)
+		}
+	}
+
+	switch {
+	case errors.Is(err, ErrTimeLimitExceeded):
+		response.Limit = limitTime
+	case errors.Is(err, ErrMemoryLimitExceeded):
+		response.Limit = limitMemory
+	case err != nil:
+		response.Error = err.Error()
+	}
+
+	payload, err := sonic.Marshal(response)
+	if err != nil {
+		os.Exit(1)
+	}
+	os.Stdout.Write(payload)
+}
+
+func runWorker(request workerRequest) (*JudgeOutput, error) {
+	request.MaxSteps = maxExecutionSteps
+	request.Timeout = executionTimeout
+	request.MaxMessageLength = maxMessageLength
+
+	response, err := spawnWorker(request, executionTimeout)
+	if err != nil {
+		return nil, err
+	}
+	if !request.Validate && response.Output == nil {
+		return nil, errors.New("judge script worker returned no result")
+	}
+
+	return response.Output, nil
+}
+
+// RunInWorker 在子进程里执行 RegisterWorkerHandler 注册的任务，内存限制和判题脚本相同，
+// 任务自己负责在 timeout 内结束，超出后父进程直接杀掉子进程
+func RunInWorker(kind string, payload []byte, timeout time.Duration) ([]byte, error) {
+	response, err := spawnWorker(workerRequest{Kind: kind, Payload: payload}, timeout)
+	if err != nil {
+		return nil, err
+	}
+	return response.Payload, nil
+}
+
+func spawnWorker(request workerRequest, timeout time.Duration) (*workerResponse, error) {
+	request.MaxAllocBytes = maxAllocBytes
+
+	executable, err := workerExecutable()
+	if err != nil {
+		return nil, fmt.Errorf("locate judge script worker: %w", err)
+	}
+
+	payload, err := sonic.Marshal(request)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), timeout+workerStartupGrace)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, executable, WorkerCommand)
+	// 子进程不继承父进程的环境变量，里面可能有数据库密码之类的配置
+	cmd.Env = []string{}
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	runErr := cmd.Run()
+	if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+		return nil, ErrTimeLimitExceeded
+	}
+
+	var response workerResponse
+	if err := sonic.Unmarshal(stdout.Bytes(), &response); err != nil {
+		// 超过数据段上限时运行时直接退出，按分配的位置报 out of memory 或者 cannot allocate memory
+		if output := stderr.String(); strings.Contains(output, "out of memory") || strings.Contains(output, "cannot allocate memory") {
+			return nil, ErrMemoryLimitExceeded
+		}
+		if runErr != nil {
+			return nil, fmt.Errorf("judge script worker failed: %w", runErr)
+		}
+		return nil, fmt.Errorf("invalid judge script worker response: %w", err)
+	}
+
+	switch response.Limit {
+	case limitTime:
+		return nil, ErrTimeLimitExceeded
+	case limitMemory:
+		return nil, ErrMemoryLimitExceeded
+	}
+	if response.Error != "" {
+		return nil, errors.New(response.Error)
+	}
+
+	return &response, nil
+}
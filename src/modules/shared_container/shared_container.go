@@ -0,0 +1,209 @@
+package sharedcontainer
+
+import (
+	"a1ctf/src/db/models"
+	"a1ctf/src/tasks"
+	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/utils/zaphelper"
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// 共享容器上次创建的时间，镜像有问题时不要每次调度都重建
+var (
+	lastStartTimes      = make(map[int64]time.Time)
+	lastStartTimesMutex sync.Mutex
+)
+
+func shouldRestart(inGameID int64, now time.Time) bool {
+	backoff := viper.GetDuration("shared-container.restart-backoff")
+	if backoff <= 0 {
+		backoff = 30 * time.Second
+	}
+
+	lastStartTimesMutex.Lock()
+	defer lastStartTimesMutex.Unlock()
+
+	if now.Sub(lastStartTimes[inGameID]) < backoff {
+		return false
+	}
+	lastStartTimes[inGameID] = now
+	return true
+}
+
+// IsShared 静态容器题目所有队伍共用一个容器
+func IsShared(gc *models.GameChallenge) bool {
+	return gc.Challenge.ContainerType == models.STATIC_CONTAINER
+}
+
+// HostTeam 共享容器挂在比赛的管理员队伍下，复用容器的生命周期管理
+func HostTeam(gameID int64) (*models.Team, error) {
+	var team models.Team
+	if err := dbtool.DB().Where("game_id = ? AND team_type = ?", gameID, models.TeamTypeAdmin).First(&team).Error; err != nil {
+		return nil, err
+	}
+	return &team, nil
+}
+
+// LiveContainers 比赛里排队中、启动中和运行中的共享容器，按题目索引
+func LiveContainers(gameID int64, host *models.Team) (map[int64]*models.Container, error) {
+	var containers []models.Container
+	if err := dbtool.DB().Where("game_id = ? AND team_id = ? AND container_status IN ?", gameID, host.TeamID, []models.ContainerStatus{models.ContainerQueueing, models.ContainerStarting, models.ContainerRunning}).Find(&containers).Error; err != nil {
+		return nil, err
+	}
+
+	result := make(map[int64]*models.Container, len(containers))
+	for idx := range containers {
+		result[containers[idx].ChallengeID] = &containers[idx]
+	}
+	return result, nil
+}
+
+// Run 给进行中比赛的可见静态容器题目维护共享容器，挂掉后重建，题目隐藏后回收
+// 比赛结束后容器到期，由容器任务回收
+func Run() {
+	now := time.Now().UTC()
+
+	var games []models.Game
+	if err := dbtool.DB().Where("start_time <= ? AND end_time > ?", now, now).Find(&games).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load running games", zap.Error(err))
+		return
+	}
+
+	for idx := range games {
+		if err := processGame(&games[idx], now); err != nil {
+			zaphelper.Logger.Error("Failed to process shared containers", zap.Error(err), zap.Int64("game_id", games[idx].GameID))
+		}
+	}
+}
+
+func processGame(game *models.Game, now time.Time) error {
+	// 攻防模式的题目容器都是队伍自己的服务
+	if game.GameMode == models.GameModeAttackDefense {
+		return nil
+	}
+
+	var gameChallenges []models.GameChallenge
+	if err := dbtool.DB().Preload("Challenge").
+		Joins("JOIN challenges ON challenges.challenge_id = game_challenges.challenge_id").
+		Where("game_challenges.game_id = ? AND challenges.container_type = ?", game.GameID, models.STATIC_CONTAINER).
+		Find(&gameChallenges).Error; err != nil {
+		return err
+	}
+
+	if len(gameChallenges) == 0 {
+		return nil
+	}
+
+	host, err := HostTeam(game.GameID)
+	if err != nil {
+		return err
+	}
+
+	containerMap, err := LiveContainers(game.GameID, host)
+	if err != nil {
+		return err
+	}
+
+	for idx := range gameChallenges {
+		gc := &gameChallenges[idx]
+		container, exists := containerMap[gc.ChallengeID]
+
+		// 动态 flag 每个队伍都不一样，共享容器里只能放一份，创建题目时已经拒绝了这种配置
+		if !gc.Visible || gc.Challenge.FlagType == models.FlagTypeDynamic || gc.Challenge.ContainerConfig == nil || len(*gc.Challenge.ContainerConfig) == 0 {
+			if exists {
+				stopContainer(container)
+			}
+			continue
+		}
+
+		if !exists {
+			if err := startContainer(game, gc, host, now); err != nil {
+				zaphelper.Logger.Error("Failed to start shared container", zap.Error(err), zap.Int64("game_id", game.GameID), zap.Int64("challenge_id", gc.ChallengeID))
+			}
+			continue
+		}
+
+		// 比赛时间调整后跟着延长或者提前回收
+		if !container.ExpireTime.Equal(game.EndTime) {
+			if err := dbtool.DB().Model(container).Update("expire_time", game.EndTime).Error; err != nil {
+				zaphelper.Logger.Error("Failed to update shared container expire time", zap.Error(err), zap.String("container_id", container.ContainerID))
+			}
+		}
+	}
+
+	return nil
+}
+
+// 容器使用管理员队伍的 flag，静态 flag 的题目所有队伍的 flag 相同
+func startContainer(game *models.Game, gc *models.GameChallenge, host *models.Team, now time.Time) error {
+	var flag models.TeamFlag
+	if err := dbtool.DB().Where("game_id = ? AND team_id = ? AND challenge_id = ? AND round = 0", game.GameID, host.TeamID, gc.ChallengeID).Find(&flag).Error; err != nil {
+		return err
+	}
+
+	if flag.FlagID == 0 {
+		flagTemplate := "flag{[uuid]}"
+		if gc.JudgeConfig != nil && gc.JudgeConfig.FlagTemplate != nil && *gc.JudgeConfig.FlagTemplate != "" {
+			flagTemplate = *gc.JudgeConfig.FlagTemplate
+		}
+		if err := tasks.NewTeamFlagCreateTask(flagTemplate, host.TeamID, game.GameID, gc.ChallengeID, host.TeamHash, host.TeamName, gc.Challenge.FlagType); err != nil && !errors.Is(err, asynq.ErrTaskIDConflict) {
+			return err
+		}
+		// 等 flag 生成后下次调度再创建
+		return nil
+	}
+
+	if !shouldRestart(gc.IngameID, now) {
+		return nil
+	}
+
+	// 不受比赛配额限制，直接创建为启动中，排队中的容器会被配额调度再启动一次
+	newContainer := models.Container{
+		ContainerID:          uuid.NewString(),
+		GameID:               game.GameID,
+		FlagID:               flag.FlagID,
+		TeamID:               host.TeamID,
+		ChallengeID:          gc.ChallengeID,
+		InGameID:             gc.IngameID,
+		StartTime:            now,
+		ExpireTime:           game.EndTime,
+		ContainerExposeInfos: make(models.ContainerExposeInfos, 0),
+		ContainerStatus:      models.ContainerStarting,
+		ContainerConfig:      *gc.Challenge.ContainerConfig,
+		ChallengeName:        gc.Challenge.Name,
+		TeamHash:             host.TeamHash,
+	}
+
+	if err := dbtool.DB().Create(&newContainer).Error; err != nil {
+		return err
+	}
+
+	// k8s 开启 pod 需要 Challenge的AllowWAN/AllowDNS 和 TeamFlag的FlagContent 的信息
+	newContainer.Challenge = gc.Challenge
+	newContainer.TeamFlag = flag
+
+	if err := tasks.NewContainerStartTask(newContainer); err != nil {
+		// 启动超时的容器会被容器任务关闭，之后重新创建
+		zaphelper.Logger.Warn("Failed to enqueue container start task", zap.Error(err), zap.String("container_id", newContainer.ContainerID))
+	}
+
+	zaphelper.Logger.Info("Starting shared container", zap.Int64("game_id", game.GameID), zap.Int64("challenge_id", gc.ChallengeID))
+
+	return nil
+}
+
+// 交给容器任务关闭
+func stopContainer(container *models.Container) {
+	if err := dbtool.DB().Model(container).Update("container_status", models.ContainerStopping).Error; err != nil {
+		zaphelper.Logger.Error("Failed to stop shared container", zap.Error(err), zap.String("container_id", container.ContainerID))
+		return
+	}
+	zaphelper.Logger.Info("Stopping shared container of hidden challenge", zap.Int64("game_id", container.GameID), zap.Int64("challenge_id", container.ChallengeID))
+}
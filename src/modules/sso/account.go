@@ -0,0 +1,376 @@
+package sso
+
+import (
+	"a1ctf/src/db/models"
+	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/utils/general"
+	"errors"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+var (
+	// 身份没有绑定用户，也没有开启自动注册
+	ErrNotLinked = errors.New("sso identity is not linked to any user")
+	// 身份已经绑定了其他用户
+	ErrAlreadyLinked = errors.New("sso identity is linked to another user")
+	// 用户已经绑定了这个提供方的另一个身份
+	ErrProviderLinked = errors.New("user already linked another identity of this provider")
+	// 解绑之后用户没有办法再登录
+	ErrLastLoginMethod = errors.New("cannot unlink the only way to sign in")
+)
+
+// Result 回调处理的结果
+type Result struct {
+	User     models.User
+	Identity *Identity
+	// 登录完成后跳转的前端路径
+	Redirect string
+	// 已登录用户绑定身份，而不是登录
+	Link bool
+	// 这次新绑定了身份
+	Linked bool
+	// 自动注册了新用户
+	Registered bool
+}
+
+// Callback 处理提供方的回调，找到、绑定或者注册对应的用户
+func Callback(c *gin.Context, provider *Provider) (*Result, error) {
+	st, identity, err := complete(c, provider)
+
+	result := &Result{Redirect: "/", Identity: identity}
+	if st != nil {
+		result.Redirect = st.Redirect
+		result.Link = st.LinkUserID != ""
+	}
+	if err != nil {
+		return result, err
+	}
+
+	now := time.Now().UTC()
+	err = dbtool.DB().Transaction(func(tx *gorm.DB) error {
+		// 同一个身份的绑定和注册串行执行，避免绑定到多个用户
+		if err := tx.Exec("SELECT pg_advisory_xact_lock(hashtext(?))", "sso:"+provider.ID+":"+identity.Subject).Error; err != nil {
+			return err
+		}
+
+		linked, err := findLinkedUser(tx, provider.ID, identity.Subject)
+		if err != nil {
+			return err
+		}
+
+		switch {
+		case st.LinkUserID != "":
+			if linked != nil && linked.UserID != st.LinkUserID {
+				return ErrAlreadyLinked
+			}
+			if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", st.LinkUserID).First(&result.User).Error; err != nil {
+				return err
+			}
+			if existing, ok := linkedIdentity(&result.User, provider.ID); ok && existing.Subject != identity.Subject {
+				return ErrProviderLinked
+			}
+			result.Linked = linked == nil
+		case linked != nil:
+			result.User = *linked
+		default:
+			user, err := findUserByEmail(tx, provider, identity)
+			if err != nil {
+				return err
+			}
+			if user == nil {
+				if !provider.AutoRegister {
+					return ErrNotLinked
+				}
+				user, err = register(tx, c, provider, identity, now)
+				if err != nil {
+					return err
+				}
+				result.User = *user
+				result.Registered = true
+				return nil
+			}
+			result.User = *user
+			result.Linked = true
+		}
+
+		return syncUser(tx, c, provider, identity, &result.User, !result.Link, now)
+	})
+	if err != nil {
+		return result, err
+	}
+
+	return result, nil
+}
+
+// Unlink 解绑用户在提供方的身份
+func Unlink(userID string, providerID string) (*models.SsoIdentity, error) {
+	var removed models.SsoIdentity
+
+	err := dbtool.DB().Transaction(func(tx *gorm.DB) error {
+		var user models.User
+		if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("user_id = ?", userID).First(&user).Error; err != nil {
+			return err
+		}
+
+		identity, ok := linkedIdentity(&user, providerID)
+		if !ok {
+			return gorm.ErrRecordNotFound
+		}
+		// 自动注册的用户不知道自己的密码，没有邮箱时也没法重置密码
+		if len(*user.SsoData) == 1 && (user.Email == nil || *user.Email == "") {
+			return ErrLastLoginMethod
+		}
+		removed = identity
+
+		data := copySsoData(&user)
+		delete(data, providerID)
+		var value interface{} = data
+		if len(data) == 0 {
+			value = gorm.Expr("NULL")
+		}
+
+		return tx.Model(&models.User{}).Where("user_id = ?", user.UserID).Update("sso_data", value).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &removed, nil
+}
+
+func findLinkedUser(tx *gorm.DB, providerID string, subject string) (*models.User, error) {
+	containment, err := sonic.Marshal(map[string]map[string]string{providerID: {"subject": subject}})
+	if err != nil {
+		return nil, err
+	}
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("sso_data @> ?::jsonb", string(containment)).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	return &user, nil
+}
+
+// findUserByEmail 双方的邮箱都验证过时才认为是同一个人
+func findUserByEmail(tx *gorm.DB, provider *Provider, identity *Identity) (*models.User, error) {
+	if !provider.LinkByEmail || identity.Email == "" || !identity.EmailVerified {
+		return nil, nil
+	}
+
+	var user models.User
+	if err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("LOWER(email) = ? AND email_verified = ?", identity.Email, true).First(&user).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	if _, ok := linkedIdentity(&user, provider.ID); ok {
+		return nil, ErrProviderLinked
+	}
+	return &user, nil
+}
+
+func register(tx *gorm.DB, c *gin.Context, provider *Provider, identity *Identity, now time.Time) (*models.User, error) {
+	username, err := availableUsername(tx, identity)
+	if err != nil {
+		return nil, err
+	}
+
+	// 和普通注册一样，第一个用户是管理员
+	var userCount int64
+	if err := tx.Model(&models.User{}).Count(&userCount).Error; err != nil {
+		return nil, err
+	}
+	role := provider.DefaultRole
+	if identity.Role != "" {
+		role = identity.Role
+	}
+	if userCount == 0 {
+		role = models.UserRoleAdmin
+	}
+
+	var email *string
+	emailVerified := false
+	if identity.Email != "" {
+		available, err := emailAvailable(tx, identity.Email, "")
+		if err != nil {
+			return nil, err
+		}
+		if available {
+			email = &identity.Email
+			emailVerified = identity.EmailVerified
+		}
+	}
+
+	// 密码随机生成，用户需要时可以通过邮箱重置
+	salt := general.GenerateSalt()
+	clientIP := c.ClientIP()
+	newUser := models.User{
+		UserID:        uuid.New().String(),
+		Username:      username,
+		Password:      general.SaltPassword(general.RandomPassword(32), salt),
+		Salt:          salt,
+		Role:          role,
+		Phone:         optionalString(identity.Phone),
+		StudentNumber: optionalString(identity.StudentNumber),
+		Realname:      optionalString(identity.Realname),
+		SsoData:       &models.SsoData{provider.ID: newSsoIdentity(identity, nil, now)},
+		Email:         email,
+		EmailVerified: emailVerified,
+		JWTVersion:    general.RandomString(16),
+		RegisterTime:  now,
+		RegisterIP:    &clientIP,
+		LastLoginTime: now,
+		LastLoginIP:   &clientIP,
+	}
+
+	if err := tx.Create(&newUser).Error; err != nil {
+		return nil, err
+	}
+	return &newUser, nil
+}
+
+// syncUser 保存身份，按映射补全用户资料，开启 sync-on-login 时覆盖已有的资料
+func syncUser(tx *gorm.DB, c *gin.Context, provider *Provider, identity *Identity, user *models.User, login bool, now time.Time) error {
+	data := copySsoData(user)
+	var previous *models.SsoIdentity
+	if existing, ok := data[provider.ID]; ok {
+		previous = &existing
+	}
+	data[provider.ID] = newSsoIdentity(identity, previous, now)
+
+	updates := map[string]interface{}{"sso_data": data}
+	fill := func(column string, current *string, value string) {
+		if value != "" && (provider.SyncOnLogin || current == nil || *current == "") {
+			updates[column] = value
+		}
+	}
+	fill("realname", user.Realname, identity.Realname)
+	fill("student_number", user.StudentNumber, identity.StudentNumber)
+	fill("phone", user.Phone, identity.Phone)
+
+	if identity.Email != "" {
+		if user.Email != nil && strings.EqualFold(*user.Email, identity.Email) {
+			if identity.EmailVerified && !user.EmailVerified {
+				updates["email_verified"] = true
+			}
+		} else if provider.SyncOnLogin || user.Email == nil || *user.Email == "" {
+			available, err := emailAvailable(tx, identity.Email, user.UserID)
+			if err != nil {
+				return err
+			}
+			if available {
+				updates["email"] = identity.Email
+				updates["email_verified"] = identity.EmailVerified
+			}
+		}
+	}
+
+	if provider.SyncOnLogin && identity.Role != "" {
+		updates["role"] = identity.Role
+	}
+
+	if login {
+		updates["last_login_time"] = now
+		updates["last_login_ip"] = c.ClientIP()
+	}
+
+	if err := tx.Model(&models.User{}).Where("user_id = ?", user.UserID).Updates(updates).Error; err != nil {
+		return err
+	}
+	return tx.Where("user_id = ?", user.UserID).First(user).Error
+}
+
+func newSsoIdentity(identity *Identity, previous *models.SsoIdentity, now time.Time) models.SsoIdentity {
+	linkedAt := now
+	if previous != nil && previous.Subject == identity.Subject {
+		linkedAt = previous.LinkedAt
+	}
+	return models.SsoIdentity{
+		Subject:     identity.Subject,
+		Username:    identity.Username,
+		Email:       identity.Email,
+		Claims:      identity.Claims,
+		LinkedAt:    linkedAt,
+		LastLoginAt: now,
+	}
+}
+
+func linkedIdentity(user *models.User, providerID string) (models.SsoIdentity, bool) {
+	if user.SsoData == nil {
+		return models.SsoIdentity{}, false
+	}
+	identity, ok := (*user.SsoData)[providerID]
+	return identity, ok
+}
+
+func copySsoData(user *models.User) models.SsoData {
+	data := make(models.SsoData)
+	if user.SsoData != nil {
+		for providerID, identity := range *user.SsoData {
+			data[providerID] = identity
+		}
+	}
+	return data
+}
+
+func emailAvailable(tx *gorm.DB, email string, exceptUserID string) (bool, error) {
+	query := tx.Model(&models.User{}).Where("LOWER(email) = ?", strings.ToLower(email))
+	if exceptUserID != "" {
+		query = query.Where("user_id <> ?", exceptUserID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		return false, err
+	}
+	return count == 0, nil
+}
+
+// availableUsername 用户名和普通注册一样限制在 2 到 20 个字符，重名时加随机后缀
+func availableUsername(tx *gorm.DB, identity *Identity) (string, error) {
+	base := identity.Username
+	if base == "" && identity.Email != "" {
+		base = strings.SplitN(identity.Email, "@", 2)[0]
+	}
+	if base == "" {
+		base = identity.Provider + "_" + identity.Subject
+	}
+	if utf8.RuneCountInString(base) > 16 {
+		base = string([]rune(base)[:16])
+	}
+
+	for i := 0; i < 5; i++ {
+		candidate := base
+		if i > 0 || utf8.RuneCountInString(base) < 2 {
+			candidate = base + "_" + general.RandomStringLower(3)
+		}
+
+		var count int64
+		if err := tx.Model(&models.User{}).Where("username = ?", candidate).Count(&count).Error; err != nil {
+			return "", err
+		}
+		if count == 0 {
+			return candidate, nil
+		}
+	}
+
+	return "", errors.New("failed to find an available username")
+}
+
+func optionalString(value string) *string {
+	if value == "" {
+		return nil
+	}
+	return &value
+}
@@ -0,0 +1,99 @@
+package sso
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type casResponse struct {
+	XMLName xml.Name `xml:"serviceResponse"`
+	Success *struct {
+		User       string `xml:"user"`
+		Attributes struct {
+			Values []struct {
+				XMLName xml.Name
+				Value   string `xml:",chardata"`
+			} `xml:",any"`
+		} `xml:"attributes"`
+	} `xml:"authenticationSuccess"`
+	Failure *struct {
+		Code    string `xml:"code,attr"`
+		Message string `xml:",chardata"`
+	} `xml:"authenticationFailure"`
+}
+
+// CAS 没有 state 参数，放在 service 地址里，校验票据时 service 必须和登录时完全一致
+func (p *Provider) casService(state string) string {
+	callback := p.callbackURL()
+	separator := "?"
+	if strings.Contains(callback, "?") {
+		separator = "&"
+	}
+	return callback + separator + "state=" + url.QueryEscape(state)
+}
+
+func (p *Provider) casAuthURL(state string) string {
+	return p.Server + "/login?service=" + url.QueryEscape(p.casService(state))
+}
+
+func (p *Provider) casValidate(ctx context.Context, ticket string, state string) (map[string]interface{}, error) {
+	if ticket == "" {
+		return nil, fmt.Errorf("missing ticket")
+	}
+
+	query := url.Values{}
+	query.Set("service", p.casService(state))
+	query.Set("ticket", ticket)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.Server+p.ValidatePath+"?"+query.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("validate ticket: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("validate ticket: unexpected status %d", resp.StatusCode)
+	}
+
+	var result casResponse
+	if err := xml.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("validate ticket: %w", err)
+	}
+	if result.Failure != nil {
+		return nil, fmt.Errorf("validate ticket: %s %s", result.Failure.Code, strings.TrimSpace(result.Failure.Message))
+	}
+	if result.Success == nil || strings.TrimSpace(result.Success.User) == "" {
+		return nil, fmt.Errorf("validate ticket: empty response")
+	}
+
+	// 同名的属性是多值属性，合并成数组
+	claims := map[string]interface{}{"user": strings.TrimSpace(result.Success.User)}
+	for _, attribute := range result.Success.Attributes.Values {
+		name := attribute.XMLName.Local
+		value := strings.TrimSpace(attribute.Value)
+		switch existing := claims[name].(type) {
+		case nil:
+			claims[name] = value
+		case string:
+			claims[name] = []string{existing, value}
+		case []string:
+			claims[name] = append(existing, value)
+		}
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,137 @@
+package sso
+
+import (
+	"a1ctf/src/db/models"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Identity 提供方返回的用户信息，已经按 claim-mapping 映射好
+type Identity struct {
+	Provider      string
+	Subject       string
+	Username      string
+	Email         string
+	EmailVerified bool
+	Realname      string
+	StudentNumber string
+	Phone         string
+	// 按 role-mapping 得到的角色，没有匹配时为空
+	Role   models.UserRole
+	Claims map[string]interface{}
+}
+
+var roleRank = map[models.UserRole]int{
+	models.UserRoleUser:    1,
+	models.UserRoleMonitor: 2,
+	models.UserRoleAdmin:   3,
+}
+
+func (p *Provider) mapIdentity(claims map[string]interface{}) (*Identity, error) {
+	identity := &Identity{
+		Provider:      p.ID,
+		Subject:       p.claimString(claims, FieldSubject),
+		Username:      p.claimString(claims, FieldUsername),
+		Email:         strings.ToLower(p.claimString(claims, FieldEmail)),
+		Realname:      p.claimString(claims, FieldRealname),
+		StudentNumber: p.claimString(claims, FieldStudentNumber),
+		Phone:         p.claimString(claims, FieldPhone),
+		Claims:        claims,
+	}
+	if identity.Subject == "" {
+		return nil, fmt.Errorf("claim %q for subject is missing", p.ClaimMapping[FieldSubject])
+	}
+	if identity.Email != "" {
+		identity.EmailVerified = p.TrustEmail || claimBool(lookupClaim(claims, p.ClaimMapping[FieldEmailVerified]))
+	}
+
+	// 多个值匹配时取权限最高的角色
+	if p.RoleClaim != "" {
+		for _, value := range claimStrings(lookupClaim(claims, p.RoleClaim)) {
+			role, ok := p.RoleMapping[strings.ToLower(value)]
+			if ok && roleRank[role] > roleRank[identity.Role] {
+				identity.Role = role
+			}
+		}
+	}
+
+	return identity, nil
+}
+
+func (p *Provider) claimString(claims map[string]interface{}, field string) string {
+	name := p.ClaimMapping[field]
+	if name == "" {
+		return ""
+	}
+	values := claimStrings(lookupClaim(claims, name))
+	if len(values) == 0 {
+		return ""
+	}
+	return strings.TrimSpace(values[0])
+}
+
+// lookupClaim 属性名本身可能带 .，先整体查找，找不到再按 . 逐层查找
+func lookupClaim(claims map[string]interface{}, name string) interface{} {
+	if name == "" {
+		return nil
+	}
+	if value, ok := claims[name]; ok {
+		return value
+	}
+
+	var current interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		object, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current, ok = object[part]
+		if !ok {
+			return nil
+		}
+	}
+	return current
+}
+
+func claimStrings(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		if v == "" {
+			return nil
+		}
+		return []string{v}
+	case float64:
+		return []string{strconv.FormatFloat(v, 'f', -1, 64)}
+	case int64:
+		return []string{strconv.FormatInt(v, 10)}
+	case bool:
+		return []string{strconv.FormatBool(v)}
+	case []string:
+		return v
+	case []interface{}:
+		result := make([]string, 0, len(v))
+		for _, item := range v {
+			result = append(result, claimStrings(item)...)
+		}
+		return result
+	}
+	return []string{fmt.Sprint(value)}
+}
+
+func claimBool(value interface{}) bool {
+	switch v := value.(type) {
+	case bool:
+		return v
+	case string:
+		parsed, _ := strconv.ParseBool(v)
+		return parsed
+	case []string:
+		return len(v) > 0 && claimBool(v[0])
+	case []interface{}:
+		return len(v) > 0 && claimBool(v[0])
+	}
+	return false
+}
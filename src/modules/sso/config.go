@@ -0,0 +1,236 @@
+package sso
+
+import (
+	"a1ctf/src/db/models"
+	"fmt"
+	"net/url"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+type ProviderType string
+
+const (
+	// 标准 OIDC，通过 issuer 的 .well-known/openid-configuration 获取端点
+	ProviderOIDC ProviderType = "oidc"
+	// GitHub OAuth App，server 可以设置为 GitHub Enterprise 的地址
+	ProviderGitHub ProviderType = "github"
+	// CAS 2.0 / 3.0，大部分学校的统一身份认证都是这种
+	ProviderCAS ProviderType = "cas"
+)
+
+// 可以映射到用户资料的字段
+const (
+	FieldSubject       = "subject"
+	FieldUsername      = "username"
+	FieldEmail         = "email"
+	FieldEmailVerified = "email_verified"
+	FieldRealname      = "realname"
+	FieldStudentNumber = "student_number"
+	FieldPhone         = "phone"
+)
+
+var knownFields = []string{FieldSubject, FieldUsername, FieldEmail, FieldEmailVerified, FieldRealname, FieldStudentNumber, FieldPhone}
+
+// 各类型提供方默认的字段映射，配置里的 claim-mapping 会覆盖对应的字段
+var defaultClaimMapping = map[ProviderType]map[string]string{
+	ProviderOIDC: {
+		FieldSubject:       "sub",
+		FieldUsername:      "preferred_username",
+		FieldEmail:         "email",
+		FieldEmailVerified: "email_verified",
+		FieldRealname:      "name",
+		FieldPhone:         "phone_number",
+	},
+	ProviderGitHub: {
+		FieldSubject:       "id",
+		FieldUsername:      "login",
+		FieldEmail:         "email",
+		FieldEmailVerified: "email_verified",
+		FieldRealname:      "name",
+	},
+	ProviderCAS: {
+		FieldSubject:  "user",
+		FieldUsername: "user",
+	},
+}
+
+// Provider 一个单点登录提供方
+type Provider struct {
+	// 出现在回调地址里，绑定的身份也按这个 ID 保存，配置好之后不要修改
+	ID   string       `mapstructure:"id"`
+	Type ProviderType `mapstructure:"type"`
+	// 登录按钮上显示的名字
+	Name string `mapstructure:"name"`
+
+	Issuer       string   `mapstructure:"issuer"`
+	ClientID     string   `mapstructure:"client-id"`
+	ClientSecret string   `mapstructure:"client-secret"`
+	Scopes       []string `mapstructure:"scopes"`
+	// 不支持自动发现时手动指定端点
+	AuthURL     string `mapstructure:"auth-url"`
+	TokenURL    string `mapstructure:"token-url"`
+	UserInfoURL string `mapstructure:"userinfo-url"`
+
+	// CAS 服务地址，或者 GitHub Enterprise 的地址
+	Server string `mapstructure:"server"`
+	// CAS 票据校验路径，默认 /p3/serviceValidate
+	ValidatePath string `mapstructure:"validate-path"`
+
+	// 默认是 <system.baseURL>/api/sso/<id>/callback
+	RedirectURL string `mapstructure:"redirect-url"`
+
+	// 没有绑定的身份自动注册新用户
+	AutoRegister bool `mapstructure:"auto-register"`
+	// 提供方确认过的邮箱和用户已验证的邮箱相同时自动绑定
+	LinkByEmail bool `mapstructure:"link-by-email"`
+	// 认为提供方返回的邮箱都是验证过的，学校的 CAS 一般不会返回 email_verified
+	TrustEmail bool `mapstructure:"trust-email"`
+	// 每次登录都用提供方的数据覆盖映射的字段，否则只填充空字段
+	SyncOnLogin bool `mapstructure:"sync-on-login"`
+	// 自动注册的用户的角色
+	DefaultRole models.UserRole `mapstructure:"default-role"`
+	// 根据提供方返回的属性设置角色，比如 groups
+	RoleClaim string `mapstructure:"role-claim"`
+	// 属性值到角色的映射，比较时不区分大小写
+	RoleMapping map[string]models.UserRole `mapstructure:"role-mapping"`
+	// 用户字段到提供方属性的映射，属性可以用 . 访问嵌套的值
+	ClaimMapping map[string]string `mapstructure:"claim-mapping"`
+}
+
+var (
+	providers   []*Provider
+	providerMap = make(map[string]*Provider)
+	providerID  = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,31}$`)
+)
+
+// Init 读取 sso.providers，配置有问题时直接退出
+func Init() {
+	var configs []*Provider
+	if err := viper.UnmarshalKey("sso.providers", &configs); err != nil {
+		panic(fmt.Errorf("failed to unmarshal sso.providers: %v", err))
+	}
+
+	for _, provider := range configs {
+		if err := provider.normalize(); err != nil {
+			panic(fmt.Errorf("invalid sso provider %q: %v", provider.ID, err))
+		}
+		if _, ok := providerMap[provider.ID]; ok {
+			panic(fmt.Errorf("duplicate sso provider %q", provider.ID))
+		}
+		providers = append(providers, provider)
+		providerMap[provider.ID] = provider
+	}
+}
+
+func (p *Provider) normalize() error {
+	if !providerID.MatchString(p.ID) {
+		return fmt.Errorf("id must match %s", providerID.String())
+	}
+	if p.Name == "" {
+		p.Name = p.ID
+	}
+
+	switch p.Type {
+	case ProviderOIDC:
+		if p.Issuer == "" && (p.AuthURL == "" || p.TokenURL == "") {
+			return fmt.Errorf("issuer or auth-url and token-url are required")
+		}
+		if p.ClientID == "" {
+			return fmt.Errorf("client-id is required")
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"openid", "profile", "email"}
+		}
+		if !slices.Contains(p.Scopes, "openid") {
+			p.Scopes = append([]string{"openid"}, p.Scopes...)
+		}
+	case ProviderGitHub:
+		if p.ClientID == "" || p.ClientSecret == "" {
+			return fmt.Errorf("client-id and client-secret are required")
+		}
+		if len(p.Scopes) == 0 {
+			p.Scopes = []string{"read:user", "user:email"}
+		}
+	case ProviderCAS:
+		if p.Server == "" {
+			return fmt.Errorf("server is required")
+		}
+		p.Server = strings.TrimSuffix(p.Server, "/")
+		if p.ValidatePath == "" {
+			p.ValidatePath = "/p3/serviceValidate"
+		}
+	default:
+		return fmt.Errorf("unknown type %q", p.Type)
+	}
+
+	for field := range p.ClaimMapping {
+		if !slices.Contains(knownFields, field) {
+			return fmt.Errorf("unknown claim-mapping field %q", field)
+		}
+	}
+	mapping := make(map[string]string, len(knownFields))
+	for field, claim := range defaultClaimMapping[p.Type] {
+		mapping[field] = claim
+	}
+	for field, claim := range p.ClaimMapping {
+		mapping[field] = claim
+	}
+	if mapping[FieldSubject] == "" {
+		return fmt.Errorf("claim-mapping.subject must not be empty")
+	}
+	p.ClaimMapping = mapping
+
+	if p.DefaultRole == "" {
+		p.DefaultRole = models.UserRoleUser
+	}
+	roles := []models.UserRole{models.UserRoleUser, models.UserRoleAdmin, models.UserRoleMonitor}
+	if !slices.Contains(roles, p.DefaultRole) {
+		return fmt.Errorf("unknown default-role %q", p.DefaultRole)
+	}
+	// viper 会把 map 的 key 转成小写，这里统一按小写比较
+	roleMapping := make(map[string]models.UserRole, len(p.RoleMapping))
+	for value, role := range p.RoleMapping {
+		if !slices.Contains(roles, role) {
+			return fmt.Errorf("unknown role %q in role-mapping", role)
+		}
+		roleMapping[strings.ToLower(value)] = role
+	}
+	p.RoleMapping = roleMapping
+
+	if p.RedirectURL != "" {
+		if _, err := url.Parse(p.RedirectURL); err != nil {
+			return fmt.Errorf("invalid redirect-url: %v", err)
+		}
+	}
+
+	return nil
+}
+
+// Providers 所有配置的提供方，按配置文件的顺序
+func Providers() []*Provider {
+	return providers
+}
+
+// GetProvider 找不到时返回 nil
+func GetProvider(id string) *Provider {
+	return providerMap[id]
+}
+
+func (p *Provider) callbackURL() string {
+	if p.RedirectURL != "" {
+		return p.RedirectURL
+	}
+	return strings.TrimSuffix(viper.GetString("system.baseURL"), "/") + "/api/sso/" + p.ID + "/callback"
+}
+
+func requestTimeout() time.Duration {
+	if value := viper.GetDuration("sso.timeout"); value > 0 {
+		return value
+	}
+	return 10 * time.Second
+}
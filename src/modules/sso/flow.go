@@ -0,0 +1,89 @@
+package sso
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/gin-gonic/gin"
+)
+
+// ErrProvider 提供方拒绝了登录或者返回的数据有问题
+var ErrProvider = errors.New("sso provider error")
+
+// Begin 保存登录状态，返回提供方的登录地址
+func Begin(c *gin.Context, provider *Provider, redirect string, linkUserID string) (string, error) {
+	st := &loginState{
+		Provider:   provider.ID,
+		Redirect:   redirect,
+		LinkUserID: linkUserID,
+	}
+	if provider.Type == ProviderOIDC {
+		newOIDCState(st)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), requestTimeout())
+	defer cancel()
+
+	// 先确认能拿到登录地址再保存状态
+	if provider.Type == ProviderOIDC {
+		if _, err := provider.discover(ctx); err != nil {
+			return "", fmt.Errorf("%w: %v", ErrProvider, err)
+		}
+	}
+
+	state, err := saveState(c, st)
+	if err != nil {
+		return "", err
+	}
+
+	switch provider.Type {
+	case ProviderOIDC:
+		authURL, err := provider.oidcAuthURL(ctx, st, state)
+		if err != nil {
+			return "", fmt.Errorf("%w: %v", ErrProvider, err)
+		}
+		return authURL, nil
+	case ProviderGitHub:
+		return provider.githubAuthURL(state), nil
+	default:
+		return provider.casAuthURL(state), nil
+	}
+}
+
+// complete 校验回调，向提供方换取用户信息
+func complete(c *gin.Context, provider *Provider) (*loginState, *Identity, error) {
+	state := c.Query("state")
+	st, err := consumeState(c, provider.ID, state)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// 用户在提供方取消了授权
+	if errorCode := c.Query("error"); errorCode != "" {
+		return st, nil, fmt.Errorf("%w: %s %s", ErrProvider, errorCode, c.Query("error_description"))
+	}
+
+	ctx, cancel := context.WithTimeout(c.Request.Context(), requestTimeout())
+	defer cancel()
+
+	var claims map[string]interface{}
+	switch provider.Type {
+	case ProviderOIDC:
+		claims, err = provider.oidcExchange(ctx, c.Query("code"), st)
+	case ProviderGitHub:
+		claims, err = provider.githubExchange(ctx, c.Query("code"))
+	default:
+		claims, err = provider.casValidate(ctx, c.Query("ticket"), state)
+	}
+	if err != nil {
+		return st, nil, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+
+	identity, err := provider.mapIdentity(claims)
+	if err != nil {
+		return st, nil, fmt.Errorf("%w: %v", ErrProvider, err)
+	}
+
+	return st, identity, nil
+}
@@ -0,0 +1,56 @@
+package sso
+
+import (
+	"context"
+	"fmt"
+	"strings"
+)
+
+type githubEmail struct {
+	Email    string `json:"email"`
+	Primary  bool   `json:"primary"`
+	Verified bool   `json:"verified"`
+}
+
+// server 为空时是 github.com，否则是 GitHub Enterprise
+func (p *Provider) githubEndpoints() (authURL string, tokenURL string, apiURL string) {
+	if p.Server == "" {
+		return "https://github.com/login/oauth/authorize", "https://github.com/login/oauth/access_token", "https://api.github.com"
+	}
+	server := strings.TrimSuffix(p.Server, "/")
+	return server + "/login/oauth/authorize", server + "/login/oauth/access_token", server + "/api/v3"
+}
+
+func (p *Provider) githubAuthURL(state string) string {
+	authURL, tokenURL, _ := p.githubEndpoints()
+	return p.oauth2Config(authURL, tokenURL).AuthCodeURL(state)
+}
+
+func (p *Provider) githubExchange(ctx context.Context, code string) (map[string]interface{}, error) {
+	authURL, tokenURL, apiURL := p.githubEndpoints()
+
+	token, err := p.oauth2Config(authURL, tokenURL).Exchange(withHTTPClient(ctx), code)
+	if err != nil {
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+
+	claims := make(map[string]interface{})
+	if err := getJSON(ctx, apiURL+"/user", token.AccessToken, &claims); err != nil {
+		return nil, fmt.Errorf("user: %w", err)
+	}
+
+	// /user 里的邮箱是用户公开的邮箱，不一定验证过，有 user:email 权限时换成验证过的主邮箱
+	claims["email_verified"] = false
+	var emails []githubEmail
+	if err := getJSON(ctx, apiURL+"/user/emails", token.AccessToken, &emails); err == nil {
+		for _, email := range emails {
+			if email.Primary && email.Verified {
+				claims["email"] = email.Email
+				claims["email_verified"] = true
+				break
+			}
+		}
+	}
+
+	return claims, nil
+}
@@ -0,0 +1,182 @@
+package sso
+
+import (
+	"a1ctf/src/utils/general"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/golang-jwt/jwt/v5"
+	"golang.org/x/oauth2"
+)
+
+type discoveryDocument struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+}
+
+// 发现文档基本不会变，成功获取一次之后一直使用
+var discoveryCache sync.Map
+
+func (p *Provider) discover(ctx context.Context) (*discoveryDocument, error) {
+	if cached, ok := discoveryCache.Load(p.ID); ok {
+		return cached.(*discoveryDocument), nil
+	}
+
+	doc := &discoveryDocument{Issuer: p.Issuer}
+	if p.Issuer != "" {
+		if err := getJSON(ctx, strings.TrimSuffix(p.Issuer, "/")+"/.well-known/openid-configuration", "", doc); err != nil {
+			return nil, fmt.Errorf("discovery: %w", err)
+		}
+		if strings.TrimSuffix(doc.Issuer, "/") != strings.TrimSuffix(p.Issuer, "/") {
+			return nil, fmt.Errorf("discovery: issuer mismatch, got %s", doc.Issuer)
+		}
+	}
+
+	if p.AuthURL != "" {
+		doc.AuthorizationEndpoint = p.AuthURL
+	}
+	if p.TokenURL != "" {
+		doc.TokenEndpoint = p.TokenURL
+	}
+	if p.UserInfoURL != "" {
+		doc.UserinfoEndpoint = p.UserInfoURL
+	}
+	if doc.AuthorizationEndpoint == "" || doc.TokenEndpoint == "" {
+		return nil, errors.New("discovery: missing authorization or token endpoint")
+	}
+
+	discoveryCache.Store(p.ID, doc)
+	return doc, nil
+}
+
+func (p *Provider) oauth2Config(authURL string, tokenURL string) *oauth2.Config {
+	return &oauth2.Config{
+		ClientID:     p.ClientID,
+		ClientSecret: p.ClientSecret,
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  authURL,
+			TokenURL: tokenURL,
+		},
+		RedirectURL: p.callbackURL(),
+		Scopes:      p.Scopes,
+	}
+}
+
+func (p *Provider) oidcAuthURL(ctx context.Context, st *loginState, state string) (string, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	return p.oauth2Config(doc.AuthorizationEndpoint, doc.TokenEndpoint).AuthCodeURL(state,
+		oauth2.S256ChallengeOption(st.Verifier),
+		oauth2.SetAuthURLParam("nonce", st.Nonce),
+	), nil
+}
+
+func (p *Provider) oidcExchange(ctx context.Context, code string, st *loginState) (map[string]interface{}, error) {
+	doc, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	token, err := p.oauth2Config(doc.AuthorizationEndpoint, doc.TokenEndpoint).Exchange(withHTTPClient(ctx), code, oauth2.VerifierOption(st.Verifier))
+	if err != nil {
+		return nil, fmt.Errorf("token exchange: %w", err)
+	}
+
+	rawIDToken, _ := token.Extra("id_token").(string)
+	if rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	// id_token 是服务端通过 TLS 直接从 token 端点拿到的，按 OIDC Core 3.1.3.7 可以不校验签名，
+	// 只校验 iss、aud、exp 和 nonce
+	claims := jwt.MapClaims{}
+	if _, _, err := jwt.NewParser().ParseUnverified(rawIDToken, claims); err != nil {
+		return nil, fmt.Errorf("parse id_token: %w", err)
+	}
+	if doc.Issuer != "" {
+		issuer, _ := claims.GetIssuer()
+		if strings.TrimSuffix(issuer, "/") != strings.TrimSuffix(doc.Issuer, "/") {
+			return nil, fmt.Errorf("id_token issuer mismatch, got %s", issuer)
+		}
+	}
+	audience, _ := claims.GetAudience()
+	if !slices.Contains(audience, p.ClientID) {
+		return nil, errors.New("id_token audience mismatch")
+	}
+	expiresAt, _ := claims.GetExpirationTime()
+	if expiresAt == nil || expiresAt.Before(time.Now().Add(-time.Minute)) {
+		return nil, errors.New("id_token expired")
+	}
+	if nonce, _ := claims["nonce"].(string); nonce != st.Nonce {
+		return nil, errors.New("id_token nonce mismatch")
+	}
+
+	result := map[string]interface{}(claims)
+	if doc.UserinfoEndpoint != "" {
+		userinfo := make(map[string]interface{})
+		if err := getJSON(ctx, doc.UserinfoEndpoint, token.AccessToken, &userinfo); err != nil {
+			return nil, fmt.Errorf("userinfo: %w", err)
+		}
+		if userinfo["sub"] != claims["sub"] {
+			return nil, errors.New("userinfo subject mismatch")
+		}
+		for key, value := range userinfo {
+			result[key] = value
+		}
+	}
+
+	return result, nil
+}
+
+func newOIDCState(st *loginState) {
+	st.Nonce = general.RandomStringLower(32)
+	st.Verifier = oauth2.GenerateVerifier()
+}
+
+func httpClient() *http.Client {
+	return &http.Client{Timeout: requestTimeout()}
+}
+
+func withHTTPClient(ctx context.Context) context.Context {
+	return context.WithValue(ctx, oauth2.HTTPClient, httpClient())
+}
+
+func getJSON(ctx context.Context, url string, accessToken string, result interface{}) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+
+	resp, err := httpClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status %d from %s", resp.StatusCode, url)
+	}
+
+	return sonic.Unmarshal(body, result)
+}
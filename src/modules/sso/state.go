@@ -0,0 +1,90 @@
+package sso
+
+import (
+	"a1ctf/src/utils/general"
+	redistool "a1ctf/src/utils/redis_tool"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	stateTTL    = 10 * time.Minute
+	stateCookie = "a1sso"
+)
+
+var ErrInvalidState = errors.New("invalid or expired sso state")
+
+// loginState 跳转到提供方之前保存，回调时取出并删除
+type loginState struct {
+	Provider string `json:"provider"`
+	// OIDC 的 nonce 和 PKCE verifier
+	Nonce    string `json:"nonce,omitempty"`
+	Verifier string `json:"verifier,omitempty"`
+	// 登录完成后跳转的前端路径
+	Redirect string `json:"redirect"`
+	// 不为空时是已登录用户在绑定新的身份
+	LinkUserID string `json:"link_user_id,omitempty"`
+}
+
+func stateKey(state string) string {
+	return "sso_state:" + state
+}
+
+// SafeRedirect 只允许站内的相对路径，避免被用来跳转到其他网站
+func SafeRedirect(redirect string, fallback string) string {
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.ContainsAny(redirect, "\\\r\n") {
+		return fallback
+	}
+	return redirect
+}
+
+// saveState 同时把 state 写进 cookie，回调时要求一致，防止把别人的登录结果塞给当前浏览器
+func saveState(c *gin.Context, st *loginState) (string, error) {
+	state := general.RandomStringLower(48)
+
+	data, err := sonic.Marshal(st)
+	if err != nil {
+		return "", err
+	}
+	if !redistool.SetValueForATime(stateKey(state), string(data), stateTTL) {
+		return "", errors.New("failed to save sso state")
+	}
+
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(stateCookie, state, int(stateTTL.Seconds()), "/api", "", c.Request.TLS != nil, true)
+
+	return state, nil
+}
+
+// consumeState state 只能用一次
+func consumeState(c *gin.Context, provider string, state string) (*loginState, error) {
+	cookie, err := c.Cookie(stateCookie)
+	c.SetCookie(stateCookie, "", -1, "/api", "", c.Request.TLS != nil, true)
+	if err != nil || state == "" || cookie != state {
+		return nil, ErrInvalidState
+	}
+
+	data, err := redistool.GetValue(stateKey(state))
+	if err != nil {
+		return nil, ErrInvalidState
+	}
+	deleted, err := redistool.RedisClient.Del(stateKey(state)).Result()
+	if err != nil || deleted == 0 {
+		return nil, ErrInvalidState
+	}
+
+	var st loginState
+	if err := sonic.Unmarshal([]byte(data), &st); err != nil {
+		return nil, ErrInvalidState
+	}
+	if st.Provider != provider {
+		return nil, ErrInvalidState
+	}
+
+	return &st, nil
+}
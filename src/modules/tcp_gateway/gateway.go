@@ -0,0 +1,388 @@
+package tcpgateway
+
+import (
+	"a1ctf/src/db/models"
+	containerlifetime "a1ctf/src/modules/container_lifetime"
+	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/utils/zaphelper"
+	"bufio"
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+const (
+	// 连接后先发送一行 Token
+	ModeHandshake = "handshake"
+	// TLS 连接，SNI 为 <token>.<sni-domain>，网关终止 TLS 后转发明文
+	ModeSNI = "sni"
+)
+
+// TLS 记录的第一个字节
+const tlsHandshakeRecord = 0x16
+
+// Token 行的最大长度
+const maxHandshakeLine = 128
+
+var tlsConfig *tls.Config
+
+// 每个容器当前的连接，容器停止后关闭
+var (
+	activeMu    sync.Mutex
+	activeConns = make(map[string]map[*gatewayConn]struct{})
+)
+
+type gatewayConn struct {
+	client   net.Conn
+	upstream net.Conn
+	reason   atomic.Value
+}
+
+func (g *gatewayConn) close(reason string) {
+	g.reason.CompareAndSwap(nil, reason)
+	_ = g.client.Close()
+	_ = g.upstream.Close()
+}
+
+func (g *gatewayConn) closeReason() string {
+	if reason, ok := g.reason.Load().(string); ok {
+		return reason
+	}
+	return ""
+}
+
+// Start 监听 tcp-gateway.listen，把选手的连接按 Token 转发到对应容器
+func Start(ctx context.Context) error {
+	certFile := viper.GetString("tcp-gateway.tls-cert-file")
+	keyFile := viper.GetString("tcp-gateway.tls-key-file")
+	if certFile != "" && keyFile != "" {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			return fmt.Errorf("failed to load tcp gateway certificate: %w", err)
+		}
+		tlsConfig = &tls.Config{
+			Certificates: []tls.Certificate{cert},
+			MinVersion:   tls.VersionTLS12,
+		}
+	}
+
+	listener, err := net.Listen("tcp", viper.GetString("tcp-gateway.listen"))
+	if err != nil {
+		return fmt.Errorf("failed to listen tcp gateway: %w", err)
+	}
+
+	go func() {
+		<-ctx.Done()
+		_ = listener.Close()
+	}()
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				if ctx.Err() != nil || errors.Is(err, net.ErrClosed) {
+					return
+				}
+				zaphelper.Logger.Warn("TCP gateway accept error", zap.Error(err))
+				time.Sleep(100 * time.Millisecond)
+				continue
+			}
+
+			go handleConn(conn)
+		}
+	}()
+
+	go watchContainers(ctx)
+
+	zaphelper.Logger.Info("TCP gateway started", zap.String("listen", listener.Addr().String()), zap.Bool("sni", tlsConfig != nil))
+
+	return nil
+}
+
+func durationOr(key string, fallback time.Duration) time.Duration {
+	if value := viper.GetDuration(key); value > 0 {
+		return value
+	}
+	return fallback
+}
+
+// bufferedConn 读取时先消费握手阶段已经缓冲的数据
+type bufferedConn struct {
+	net.Conn
+	reader *bufio.Reader
+}
+
+func (c *bufferedConn) Read(p []byte) (int, error) {
+	return c.reader.Read(p)
+}
+
+// 读取第一行 Token，忽略行尾的 \r
+func readHandshakeLine(reader *bufio.Reader) (string, error) {
+	var line []byte
+	for {
+		b, err := reader.ReadByte()
+		if err != nil {
+			return "", err
+		}
+		if b == '\n' {
+			break
+		}
+		if len(line) >= maxHandshakeLine {
+			return "", errors.New("handshake line too long")
+		}
+		line = append(line, b)
+	}
+
+	return strings.TrimSpace(string(line)), nil
+}
+
+// 从连接里取出 Token，TLS 连接在这里完成握手
+func acceptToken(conn net.Conn) (net.Conn, string, string, error) {
+	reader := bufio.NewReader(conn)
+	buffered := &bufferedConn{Conn: conn, reader: reader}
+
+	first, err := reader.Peek(1)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	if first[0] == tlsHandshakeRecord {
+		if tlsConfig == nil || sniDomain() == "" {
+			return nil, "", "", errors.New("sni mode is not enabled")
+		}
+
+		tlsConn := tls.Server(buffered, tlsConfig)
+		if err := tlsConn.Handshake(); err != nil {
+			return nil, "", "", err
+		}
+
+		serverName := strings.ToLower(tlsConn.ConnectionState().ServerName)
+		token, found := strings.CutSuffix(serverName, "."+sniDomain())
+		if !found || strings.Contains(token, ".") {
+			_ = tlsConn.Close()
+			return nil, "", "", fmt.Errorf("unexpected server name %s", serverName)
+		}
+
+		return tlsConn, token, ModeSNI, nil
+	}
+
+	token, err := readHandshakeLine(reader)
+	if err != nil {
+		return nil, "", "", err
+	}
+
+	return buffered, token, ModeHandshake, nil
+}
+
+func handleConn(conn net.Conn) {
+	_ = conn.SetDeadline(time.Now().Add(durationOr("tcp-gateway.handshake-timeout", 10*time.Second)))
+
+	client, token, mode, err := acceptToken(conn)
+	if err != nil {
+		_ = conn.Close()
+		return
+	}
+
+	route, err := lookupRoute(token)
+	if err != nil {
+		zaphelper.Logger.Error("Failed to lookup gateway route", zap.Error(err))
+		_ = client.Close()
+		return
+	}
+	if route == nil {
+		if mode == ModeHandshake {
+			_, _ = client.Write([]byte("invalid or expired token\n"))
+		}
+		_ = client.Close()
+		return
+	}
+
+	if limit := viper.GetInt("tcp-gateway.max-connections-per-container"); limit > 0 && activeCount(route.ContainerID) >= limit {
+		if mode == ModeHandshake {
+			_, _ = client.Write([]byte("too many connections\n"))
+		}
+		_ = client.Close()
+		return
+	}
+
+	target := net.JoinHostPort(route.TargetHost, strconv.Itoa(int(route.TargetPort)))
+	upstream, err := net.DialTimeout("tcp", target, durationOr("tcp-gateway.dial-timeout", 5*time.Second))
+	if err != nil {
+		zaphelper.Logger.Warn("Failed to dial gateway target", zap.Error(err), zap.String("container_id", route.ContainerID), zap.String("target", target))
+		if mode == ModeHandshake {
+			_, _ = client.Write([]byte("target unavailable\n"))
+		}
+		_ = client.Close()
+		return
+	}
+
+	_ = conn.SetDeadline(time.Time{})
+
+	record := models.GatewayConnection{
+		Token:       token,
+		ContainerID: route.ContainerID,
+		GameID:      route.GameID,
+		TeamID:      route.TeamID,
+		PortName:    route.PortName,
+		ClientAddr:  conn.RemoteAddr().String(),
+		Mode:        mode,
+		StartTime:   time.Now().UTC(),
+	}
+	if err := dbtool.DB().Create(&record).Error; err != nil {
+		zaphelper.Logger.Error("Failed to record gateway connection", zap.Error(err))
+	}
+
+	active := &gatewayConn{client: client, upstream: upstream}
+	register(route.ContainerID, active)
+	defer unregister(route.ContainerID, active)
+
+	// 有连接的容器不算空闲
+	if err := containerlifetime.Touch([]string{route.ContainerID}); err != nil {
+		zaphelper.Logger.Error("Failed to update container activity", zap.Error(err))
+	}
+
+	bytesIn, bytesOut := pipe(active, durationOr("tcp-gateway.idle-timeout", 10*time.Minute))
+
+	if record.ConnectionID != 0 {
+		if err := dbtool.DB().Model(&record).Updates(map[string]interface{}{
+			"end_time":     time.Now().UTC(),
+			"bytes_in":     bytesIn,
+			"bytes_out":    bytesOut,
+			"close_reason": active.closeReason(),
+		}).Error; err != nil {
+			zaphelper.Logger.Error("Failed to update gateway connection", zap.Error(err))
+		}
+	}
+}
+
+// idleConn 每次读取前刷新超时，长时间没有数据的连接会被关闭
+type idleConn struct {
+	net.Conn
+	timeout time.Duration
+}
+
+func (c *idleConn) Read(p []byte) (int, error) {
+	_ = c.Conn.SetReadDeadline(time.Now().Add(c.timeout))
+	return c.Conn.Read(p)
+}
+
+// 双向转发，任意一边结束后关闭两边，返回两个方向的字节数
+func pipe(active *gatewayConn, idleTimeout time.Duration) (int64, int64) {
+	var bytesIn, bytesOut int64
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		n, err := io.Copy(active.upstream, &idleConn{Conn: active.client, timeout: idleTimeout})
+		bytesIn = n
+		active.close(copyCloseReason("client", err))
+	}()
+
+	go func() {
+		defer wg.Done()
+		n, err := io.Copy(active.client, &idleConn{Conn: active.upstream, timeout: idleTimeout})
+		bytesOut = n
+		active.close(copyCloseReason("target", err))
+	}()
+
+	wg.Wait()
+	return bytesIn, bytesOut
+}
+
+func copyCloseReason(side string, err error) string {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return "idle timeout"
+	}
+	return side + " closed"
+}
+
+func register(containerID string, conn *gatewayConn) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+
+	if activeConns[containerID] == nil {
+		activeConns[containerID] = make(map[*gatewayConn]struct{})
+	}
+	activeConns[containerID][conn] = struct{}{}
+}
+
+func unregister(containerID string, conn *gatewayConn) {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+
+	delete(activeConns[containerID], conn)
+	if len(activeConns[containerID]) == 0 {
+		delete(activeConns, containerID)
+	}
+}
+
+func activeCount(containerID string) int {
+	activeMu.Lock()
+	defer activeMu.Unlock()
+
+	return len(activeConns[containerID])
+}
+
+// 定期关闭已经停止的容器上的连接，还有连接的容器记为有活动
+func watchContainers(ctx context.Context) {
+	ticker := time.NewTicker(durationOr("tcp-gateway.check-interval", 30*time.Second))
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		activeMu.Lock()
+		containerIDs := make([]string, 0, len(activeConns))
+		for containerID := range activeConns {
+			containerIDs = append(containerIDs, containerID)
+		}
+		activeMu.Unlock()
+
+		if len(containerIDs) == 0 {
+			continue
+		}
+
+		var running []string
+		if err := dbtool.DB().Model(&models.Container{}).Where("container_id IN ? AND container_status = ?", containerIDs, models.ContainerRunning).Pluck("container_id", &running).Error; err != nil {
+			zaphelper.Logger.Error("Failed to load running containers for tcp gateway", zap.Error(err))
+			continue
+		}
+
+		if err := containerlifetime.Touch(running); err != nil {
+			zaphelper.Logger.Error("Failed to update container activity", zap.Error(err))
+		}
+
+		runningSet := make(map[string]bool, len(running))
+		for _, containerID := range running {
+			runningSet[containerID] = true
+		}
+
+		activeMu.Lock()
+		for containerID, conns := range activeConns {
+			if runningSet[containerID] {
+				continue
+			}
+			for conn := range conns {
+				conn.close("container stopped")
+			}
+		}
+		activeMu.Unlock()
+	}
+}
@@ -0,0 +1,103 @@
+package tcpgateway
+
+import (
+	"a1ctf/src/db/models"
+	dbtool "a1ctf/src/utils/db_tool"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"gorm.io/gorm"
+)
+
+// Enabled 是否通过 TCP 网关暴露题目容器的端口
+func Enabled() bool {
+	return viper.GetBool("tcp-gateway.enabled")
+}
+
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// sni 模式下 Token 作为子域名
+func sniDomain() string {
+	return strings.TrimPrefix(viper.GetString("tcp-gateway.sni-domain"), ".")
+}
+
+// CreateRoute 为容器的一个端口生成 Token，返回给选手看到的网关地址
+func CreateRoute(container *models.Container, containerName string, portName string, targetHost string, targetPort int32) (models.ExposePort, error) {
+	token, err := newToken()
+	if err != nil {
+		return models.ExposePort{}, fmt.Errorf("failed to generate gateway token: %w", err)
+	}
+
+	route := models.GatewayRoute{
+		Token:         token,
+		ContainerID:   container.ContainerID,
+		GameID:        container.GameID,
+		TeamID:        container.TeamID,
+		ContainerName: containerName,
+		PortName:      portName,
+		TargetHost:    targetHost,
+		TargetPort:    targetPort,
+		CreateTime:    time.Now().UTC(),
+	}
+	if err := dbtool.DB().Create(&route).Error; err != nil {
+		return models.ExposePort{}, fmt.Errorf("failed to create gateway route: %w", err)
+	}
+
+	host := viper.GetString("tcp-gateway.public-host")
+	if sniDomain() != "" && tlsConfig != nil {
+		host = fmt.Sprintf("%s.%s", token, sniDomain())
+	}
+
+	return models.ExposePort{
+		PortName: portName,
+		Port:     int32(viper.GetInt("tcp-gateway.public-port")),
+		IP:       host,
+		Type:     models.ExposePortTypeGateway,
+		Token:    token,
+	}, nil
+}
+
+// ResolveTarget 平台内部访问容器端口使用的地址，网关端口换成真实的目标地址
+func ResolveTarget(port models.ExposePort) (string, int32, error) {
+	if port.Type != models.ExposePortTypeGateway {
+		return port.IP, port.Port, nil
+	}
+
+	var route models.GatewayRoute
+	if err := dbtool.DB().Where("token = ?", port.Token).First(&route).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", 0, fmt.Errorf("gateway route %s not found", port.Token)
+		}
+		return "", 0, err
+	}
+
+	return route.TargetHost, route.TargetPort, nil
+}
+
+// 只返回运行中容器的路由
+func lookupRoute(token string) (*models.GatewayRoute, error) {
+	var route models.GatewayRoute
+	if err := dbtool.DB().Preload("Container").Where("token = ?", token).First(&route).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	if route.Container.ContainerStatus != models.ContainerRunning {
+		return nil, nil
+	}
+
+	return &route, nil
+}
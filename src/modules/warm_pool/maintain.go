@@ -0,0 +1,287 @@
+package warmpool
+
+import (
+	"a1ctf/src/db/models"
+	containerbackend "a1ctf/src/utils/container_backend"
+	dbtool "a1ctf/src/utils/db_tool"
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"a1ctf/src/utils/zaphelper"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+)
+
+// 预热 Pod 的名字前缀，和选手容器的 cl- 区分，由这里回收
+const warmPodPrefix = "wp-"
+
+type desiredPool struct {
+	gameChallenge models.GameChallenge
+	size          int
+}
+
+func newWarmPodName(inGameID int64) string {
+	b := make([]byte, 4)
+	_, _ = rand.Read(b)
+	return fmt.Sprintf("%s%d-%s", warmPodPrefix, inGameID, hex.EncodeToString(b))
+}
+
+func leadTime() time.Duration {
+	if leadTime := viper.GetDuration("warm-pool.lead-time"); leadTime > 0 {
+		return leadTime
+	}
+	return 30 * time.Minute
+}
+
+// 即将开始和进行中的比赛里配置了预热池的题目，攻防比赛的容器由轮次调度维护，不使用预热池
+func activeGameChallenges() ([]models.GameChallenge, error) {
+	now := time.Now().UTC()
+	var games []models.Game
+	if err := dbtool.DB().Select("game_id", "game_mode").Where("start_time < ? AND end_time > ?", now.Add(leadTime()), now).Find(&games).Error; err != nil {
+		return nil, err
+	}
+
+	gameIDs := make([]int64, 0, len(games))
+	for _, game := range games {
+		if game.GameMode != models.GameModeAttackDefense {
+			gameIDs = append(gameIDs, game.GameID)
+		}
+	}
+	if len(gameIDs) == 0 {
+		return nil, nil
+	}
+
+	var gameChallenges []models.GameChallenge
+	if err := dbtool.DB().Preload("Challenge").Where("game_id IN ? AND warm_pool IS NOT NULL", gameIDs).Find(&gameChallenges).Error; err != nil {
+		return nil, err
+	}
+	return gameChallenges, nil
+}
+
+// Maintain 补充预热池里的 Pod，删除多余的、配置过期的、启动失败的 Pod 和没有容器使用的已认领 Pod
+func Maintain() {
+	if containerbackend.Get().Driver() != containerbackend.DriverKubernetes {
+		return
+	}
+
+	gameChallenges, err := activeGameChallenges()
+	if err != nil {
+		zaphelper.Logger.Error("Failed to load warm pool challenges", zap.Error(err))
+		return
+	}
+
+	desired := make(map[string]desiredPool)
+	for _, gameChallenge := range gameChallenges {
+		if !Enabled(&gameChallenge) || ValidateConfig(gameChallenge.WarmPool, &gameChallenge.Challenge) != nil {
+			continue
+		}
+		key := poolKey(gameChallenge.IngameID, *gameChallenge.Challenge.ContainerConfig, gameChallenge.Challenge.AllowWAN, gameChallenge.Challenge.AllowDNS)
+		desired[key] = desiredPool{gameChallenge: gameChallenge, size: int(gameChallenge.WarmPool.Size)}
+	}
+
+	pools, err := k8stool.CachedWarmPoolPods()
+	if err != nil {
+		zaphelper.Logger.Error("Failed to list warm pods", zap.Error(err))
+		return
+	}
+
+	keep := make(map[string]bool)
+	aliveCount := make(map[string]int)
+
+	for key, pods := range pools {
+		pool, ok := desired[key]
+
+		alive := make([]*corev1.Pod, 0, len(pods))
+		for _, pod := range pods {
+			if ok && pod.DeletionTimestamp == nil && pod.Status.Phase != corev1.PodFailed && pod.Status.Phase != corev1.PodSucceeded {
+				alive = append(alive, pod)
+				continue
+			}
+			deleteWarmPod(pod.Name)
+		}
+
+		// 多余的 Pod 先删除没有就绪的和最新的
+		sort.SliceStable(alive, func(i, j int) bool {
+			readyI, readyJ := k8stool.IsPodReady(alive[i]), k8stool.IsPodReady(alive[j])
+			if readyI != readyJ {
+				return readyI
+			}
+			return alive[i].CreationTimestamp.Before(&alive[j].CreationTimestamp)
+		})
+		for idx, pod := range alive {
+			if idx >= pool.size {
+				deleteWarmPod(pod.Name)
+				continue
+			}
+			keep[pod.Name] = true
+			aliveCount[key]++
+		}
+	}
+
+	for key, pool := range desired {
+		for i := aliveCount[key]; i < pool.size; i++ {
+			name, err := createWarmPod(key, &pool.gameChallenge)
+			if err != nil {
+				zaphelper.Logger.Error("Failed to create warm pod", zap.Error(err), zap.Int64("ingame_id", pool.gameChallenge.IngameID))
+				break
+			}
+			keep[name] = true
+		}
+	}
+
+	collectWarmOrphans(keep)
+}
+
+func createWarmPod(key string, gameChallenge *models.GameChallenge) (string, error) {
+	name := newWarmPodName(gameChallenge.IngameID)
+	podInfo := k8stool.PodInfo{
+		Name: name,
+		Labels: map[string]string{
+			k8stool.WarmPodLabel:  name,
+			k8stool.WarmPoolLabel: key,
+		},
+		SelectorLabels: map[string]string{
+			k8stool.WarmPodLabel: name,
+		},
+		Containers: *gameChallenge.Challenge.ContainerConfig,
+		AllowWAN:   gameChallenge.Challenge.AllowWAN,
+		AllowDNS:   gameChallenge.Challenge.AllowDNS,
+	}
+
+	if err := containerbackend.Get().CreatePod(&podInfo); err != nil {
+		// 创建了一部分的对象由回收处理
+		return "", err
+	}
+
+	zaphelper.Logger.Info("Created warm pod", zap.String("pod_name", name), zap.Int64("ingame_id", gameChallenge.IngameID))
+	return name, nil
+}
+
+func deleteWarmPod(name string) {
+	if err := containerbackend.Get().DeleteOrphan(name); err != nil {
+		zaphelper.Logger.Error("Failed to delete warm pod", zap.Error(err), zap.String("pod_name", name))
+	}
+}
+
+// 回收已认领但容器已经关闭的 Pod，以及创建失败留下的 Service 和 NetworkPolicy
+func collectWarmOrphans(keep map[string]bool) {
+	grace := viper.GetDuration("container-reconciler.orphan-grace")
+	if grace <= 0 {
+		grace = 2 * time.Minute
+	}
+
+	var podNames []string
+	if err := dbtool.DB().Model(&models.Container{}).
+		Where("pod_name IS NOT NULL AND container_status NOT IN ?", []models.ContainerStatus{models.ContainerError, models.ContainerStopped}).
+		Pluck("pod_name", &podNames).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load claimed warm pods", zap.Error(err))
+		return
+	}
+	for _, name := range podNames {
+		keep[name] = true
+	}
+
+	resources, err := containerbackend.Get().Resources()
+	if err != nil {
+		zaphelper.Logger.Error("Failed to list container resources", zap.Error(err))
+		return
+	}
+
+	now := time.Now()
+	orphans := make(map[string]bool)
+	for _, resource := range resources {
+		if strings.HasPrefix(resource.Name, warmPodPrefix) && !keep[resource.Name] && now.Sub(resource.CreateTime) > grace {
+			orphans[resource.Name] = true
+		}
+	}
+
+	for name := range orphans {
+		zaphelper.Logger.Info("Deleting orphaned warm pod", zap.String("name", name))
+		deleteWarmPod(name)
+	}
+}
+
+// Prewarm 题目加入比赛时在所有节点上预先拉取镜像
+func Prewarm(gameChallenge *models.GameChallenge, challenge *models.Challenge) error {
+	if containerbackend.Get().Driver() != containerbackend.DriverKubernetes || !viper.GetBool("k8s.image-prewarm.enabled") {
+		return nil
+	}
+	if challenge.ContainerConfig == nil || len(*challenge.ContainerConfig) == 0 {
+		return nil
+	}
+
+	images := make([]string, 0, len(*challenge.ContainerConfig))
+	seen := make(map[string]bool)
+	for _, container := range *challenge.ContainerConfig {
+		if container.Image != "" && !seen[container.Image] {
+			seen[container.Image] = true
+			images = append(images, container.Image)
+		}
+	}
+
+	inGameID := strconv.FormatInt(gameChallenge.IngameID, 10)
+	return k8stool.ApplyPrewarmDaemonSet("prewarm-"+inGameID, inGameID, images)
+}
+
+// MaintainPrewarm 比赛进入预热时间后补上缺少的预拉取 DaemonSet，删除已经结束或者移除了题目的
+// 题目加入比赛时就会创建，这里处理创建失败和手动删除的情况，还没开始的比赛不会被删除
+func MaintainPrewarm() {
+	if containerbackend.Get().Driver() != containerbackend.DriverKubernetes || !viper.GetBool("k8s.image-prewarm.enabled") {
+		return
+	}
+
+	daemonSets, err := k8stool.ListPrewarmDaemonSets()
+	if err != nil {
+		zaphelper.Logger.Error("Failed to list prewarm daemonsets", zap.Error(err))
+		return
+	}
+
+	now := time.Now().UTC()
+	var gameChallenges []models.GameChallenge
+	if err := dbtool.DB().Preload("Game").
+		Where("game_id IN (?)", dbtool.DB().Model(&models.Game{}).Select("game_id").Where("end_time > ?", now)).
+		Find(&gameChallenges).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load game challenges", zap.Error(err))
+		return
+	}
+
+	existing := make(map[string]bool, len(daemonSets))
+	for _, inGameID := range daemonSets {
+		existing[inGameID] = true
+	}
+
+	active := make(map[string]bool, len(gameChallenges))
+	for _, gameChallenge := range gameChallenges {
+		inGameID := strconv.FormatInt(gameChallenge.IngameID, 10)
+		active[inGameID] = true
+
+		if existing[inGameID] || gameChallenge.Game.StartTime.After(now.Add(leadTime())) {
+			continue
+		}
+
+		var challenge models.Challenge
+		if err := dbtool.DB().Where("challenge_id = ?", gameChallenge.ChallengeID).First(&challenge).Error; err != nil {
+			zaphelper.Logger.Error("Failed to load challenge", zap.Error(err), zap.Int64("ingame_id", gameChallenge.IngameID))
+			continue
+		}
+		if err := Prewarm(&gameChallenge, &challenge); err != nil {
+			zaphelper.Logger.Error("Failed to prewarm challenge images", zap.Error(err), zap.Int64("ingame_id", gameChallenge.IngameID))
+		}
+	}
+
+	for name, inGameID := range daemonSets {
+		if active[inGameID] {
+			continue
+		}
+		if err := k8stool.DeletePrewarmDaemonSet(name); err != nil {
+			zaphelper.Logger.Error("Failed to delete prewarm daemonset", zap.Error(err), zap.String("name", name))
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package warmpool
+
+import (
+	"a1ctf/src/db/models"
+	containerreconciler "a1ctf/src/modules/container_reconciler"
+	"a1ctf/src/tasks"
+	containerbackend "a1ctf/src/utils/container_backend"
+	dbtool "a1ctf/src/utils/db_tool"
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"a1ctf/src/utils/zaphelper"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// 没有配置命令时把 flag 写入 /flag
+var defaultFlagCommand = []string{"sh", "-c", "cat > /flag"}
+
+// 认领期间容器已经被关闭或者已经绑定了 Pod，不需要再启动
+var errNotStarting = errors.New("container is not waiting for a pod")
+
+// Enabled 预热池只支持 kubernetes 后端
+func Enabled(gameChallenge *models.GameChallenge) bool {
+	return gameChallenge.WarmPool != nil && gameChallenge.WarmPool.Size > 0 &&
+		containerbackend.Get().Driver() == containerbackend.DriverKubernetes
+}
+
+// ValidateConfig 预热池只能用于动态容器题目，写 flag 的容器必须存在
+func ValidateConfig(config *models.WarmPoolConfig, challenge *models.Challenge) error {
+	if config == nil || config.Size == 0 {
+		return nil
+	}
+	if config.Size < 0 {
+		return errors.New("size must not be negative")
+	}
+	if maxSize := viper.GetInt32("warm-pool.max-size"); maxSize > 0 && config.Size > maxSize {
+		return fmt.Errorf("size must not be greater than %d", maxSize)
+	}
+	if challenge.ContainerType != models.DYNAMIC_CONTAINER || challenge.ContainerConfig == nil || len(*challenge.ContainerConfig) == 0 {
+		return errors.New("warm pool requires a dynamic container challenge")
+	}
+	if config.FlagContainer != "" {
+		for _, container := range *challenge.ContainerConfig {
+			if container.Name == config.FlagContainer {
+				return nil
+			}
+		}
+		return fmt.Errorf("container %s not found", config.FlagContainer)
+	}
+	return nil
+}
+
+// Start 启动选手的容器，优先从预热池认领 Pod，没有可用的 Pod 时创建新的 Pod
+// container 需要预加载 Challenge、TeamFlag 和 GameChallenge，并且已经由调用方切换到启动中，
+// 排队中的容器只能由配额调度切换，保证每个容器只启动一次
+func Start(container models.Container) error {
+	if Enabled(&container.GameChallenge) {
+		claimed, err := claim(&container)
+		if errors.Is(err, errNotStarting) {
+			return nil
+		}
+		if err != nil {
+			zaphelper.Logger.Warn("Failed to claim warm pod, creating a new one", zap.Error(err), zap.String("container_id", container.ContainerID))
+		}
+		if claimed {
+			return nil
+		}
+	}
+
+	return tasks.NewContainerStartTask(container)
+}
+
+// poolKey 预热池的标签值，题目的容器配置变化后旧的 Pod 不会再被认领
+func poolKey(inGameID int64, containers k8stool.A1Containers, allowWAN bool, allowDNS bool) string {
+	data, _ := sonic.Marshal(containers)
+	sum := sha256.Sum256(append(data, fmt.Sprintf("%t%t", allowWAN, allowDNS)...))
+	return fmt.Sprintf("%d-%s", inGameID, hex.EncodeToString(sum[:])[:12])
+}
+
+func claim(container *models.Container) (bool, error) {
+	claimer, ok := containerbackend.Get().(containerbackend.WarmPoolBackend)
+	if !ok {
+		return false, nil
+	}
+
+	pool := poolKey(container.InGameID, container.ContainerConfig, container.Challenge.AllowWAN, container.Challenge.AllowDNS)
+	podName, err := claimer.ClaimWarmPod(pool, map[string]string{
+		"team_hash": container.TeamHash,
+		"ingame_id": fmt.Sprintf("%d", container.InGameID),
+	})
+	if err != nil || podName == "" {
+		return false, err
+	}
+
+	podInfo := k8stool.PodInfo{Name: podName, TeamHash: container.TeamHash, Containers: container.ContainerConfig}
+
+	// 认领之后 Pod 已经属于这个队伍，出错时直接删除，交给普通的启动流程
+	release := func(cause error) (bool, error) {
+		if err := containerbackend.Get().DeletePod(&podInfo, nil); err != nil {
+			zaphelper.Logger.Error("Failed to delete claimed warm pod", zap.Error(err), zap.String("pod_name", podName))
+		}
+		return false, cause
+	}
+
+	if err := injectFlag(container, podName); err != nil {
+		return release(fmt.Errorf("failed to inject flag into warm pod %s: %w", podName, err))
+	}
+
+	// 只有还没有绑定 Pod 的启动中的容器可以认领，重复调用时后认领的 Pod 会被删除
+	result := dbtool.DB().Model(&models.Container{}).
+		Where("container_id = ? AND container_status = ? AND pod_name IS NULL", container.ContainerID, models.ContainerStarting).
+		Update("pod_name", podName)
+	if result.Error != nil {
+		return release(fmt.Errorf("failed to update container: %w", result.Error))
+	}
+	if result.RowsAffected == 0 {
+		return release(errNotStarting)
+	}
+
+	tasks.LogContainerOperation(nil, nil, models.ActionContainerStarting, container.ContainerID, map[string]interface{}{
+		"game_id":        container.GameID,
+		"team_id":        container.TeamID,
+		"team_hash":      container.TeamHash,
+		"challenge_name": container.ChallengeName,
+		"ingame_id":      container.InGameID,
+		"pod_name":       podName,
+		"container_id":   container.ContainerID,
+		"warm_pod":       true,
+	}, nil)
+
+	// 标签变化的事件可能早于数据库更新，主动触发一次端口的获取
+	containerreconciler.Enqueue(container.InGameID, container.TeamHash)
+
+	return true, nil
+}
+
+// 预热的 Pod 启动时没有 flag，执行题目配置的命令写入
+func injectFlag(container *models.Container, podName string) error {
+	config := container.GameChallenge.WarmPool
+
+	containerName := config.FlagContainer
+	if containerName == "" {
+		mainContainer := k8stool.FirstMainContainer(container.ContainerConfig)
+		if mainContainer == nil {
+			return errors.New("challenge has no containers")
+		}
+		containerName = mainContainer.Name
+	}
+
+	command := config.FlagCommand
+	if len(command) == 0 {
+		command = defaultFlagCommand
+		// 容器配置了 flag 文件时写到同一个路径
+		for _, c := range container.ContainerConfig {
+			if c.Name == containerName && c.FlagConfig != nil && c.FlagConfig.Path != "" {
+				command = []string{"sh", "-c", `cat > "$0"`, c.FlagConfig.Path}
+			}
+		}
+	}
+
+	timeout := viper.GetDuration("warm-pool.flag-timeout")
+	if timeout <= 0 {
+		timeout = 10 * time.Second
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	_, err := containerbackend.Get().Exec(ctx, podName, containerName, command, strings.NewReader(container.TeamFlag.FlagContent))
+	return err
+}
@@ -2,7 +2,9 @@ package tasks
 
 import (
 	"a1ctf/src/db/models"
+	anticheat "a1ctf/src/modules/anti_cheat"
 	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/utils/general"
 	"a1ctf/src/utils/zaphelper"
 	"context"
 	"fmt"
@@ -12,6 +14,7 @@ import (
 	"github.com/hibiken/asynq"
 	"github.com/vmihailenco/msgpack/v5"
 	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
 )
 
 type FlagAntiCheatPayload struct {
@@ -41,23 +44,47 @@ func HandleFlagAntiCheatTask(ctx context.Context, t *asynq.Task) error {
 	}
 
 	var judge models.Judge
-	dbtool.DB().Model(&models.Judge{}).Where("judge_id = ?", p.Judge.JudgeID).Preload("TeamFlag").Preload("Challenge").First(&judge)
+	if err := dbtool.DB().Model(&models.Judge{}).Where("judge_id = ?", p.Judge.JudgeID).Preload("TeamFlag").Preload("GameChallenge").Preload("Challenge").First(&judge).Error; err != nil {
+		return fmt.Errorf("failed to load judge %s: %v", p.Judge.JudgeID, err)
+	}
+
+	// 需要知道判题结果，还没判完时等待重试
+	if judge.JudgeStatus == models.JudgeQueueing || judge.JudgeStatus == models.JudgeRunning {
+		return fmt.Errorf("judge %s is not finished", judge.JudgeID)
+	}
+
+	if judge.JudgeStatus == models.JudgeWA {
+		// 提交了诱饵 flag 或者别的队伍的 canary
+		if err := checkBait(&judge); err != nil {
+			return err
+		}
+	}
+
+	judgeConfig := judge.GameChallenge.JudgeConfig
+
+	if judge.Challenge.FlagType == models.FlagTypeDynamic && !general.MatchDynamicFlag(judgeConfig, judge.TeamFlag.FlagContent, judge.JudgeContent) {
+		// 如果 flag 不一致，需要检查是否是别的队伍的 Flag，匹配模式和判题保持一致
+		content := general.NormalizeFlag(judgeConfig, judge.JudgeContent)
+		query := dbtool.DB().Model(&models.TeamFlag{}).Where("team_id != ?", judge.TeamID)
+		if general.FlagCaseInsensitive(judgeConfig) {
+			query = query.Where("LOWER(flag_content) = LOWER(?)", content)
+		} else {
+			query = query.Where("flag_content = ?", content)
+		}
 
-	if judge.TeamFlag.FlagContent != judge.JudgeContent && judge.Challenge.FlagType == models.FlagTypeDynamic {
-		// 如果 flag 不一致，需要检查是否是别的队伍的 Flag
 		var teamFlag models.TeamFlag
-		if err := dbtool.DB().Model(&models.TeamFlag{}).Where("flag_content = ? AND team_id != ?", judge.JudgeContent, judge.TeamID).Preload("Team").First(&teamFlag).Error; err == nil {
+		if err := query.Preload("Team").First(&teamFlag).Error; err == nil {
 			// 找到了 flag 所属的队伍
 			cheat := models.Cheat{
 				CheatID:     uuid.NewString(),
 				CheatType:   models.CheatSubmitSomeonesFlag,
 				GameID:      judge.GameID,
-				IngameID:    judge.IngameID,
-				ChallengeID: judge.ChallengeID,
+				IngameID:    &judge.IngameID,
+				ChallengeID: &judge.ChallengeID,
 				TeamID:      judge.TeamID,
 				FlagID:      &teamFlag.FlagID,
-				JudgeID:     judge.JudgeID,
-				SubmiterID:  judge.SubmiterID,
+				JudgeID:     &judge.JudgeID,
+				SubmiterID:  &judge.SubmiterID,
 				CheatTime:   judge.JudgeTime,
 				SubmiterIP:  judge.SubmiterIP,
 				ExtraData: models.CheatExtraData{
@@ -66,13 +93,108 @@ func HandleFlagAntiCheatTask(ctx context.Context, t *asynq.Task) error {
 				},
 			}
 
-			if err := dbtool.DB().Create(cheat).Error; err != nil {
+			if err := dbtool.DB().Clauses(clause.OnConflict{DoNothing: true}).Create(&cheat).Error; err != nil {
 				zaphelper.Logger.Error("Failed to save cheat info for game ", zap.Error(err), zap.Int64("game_id", judge.GameID), zap.Any("cheat_data", cheat))
 			}
 		}
 	}
 
-	// TODO 检查是否在未下载附件或者未启动靶机的情况下提交正确 flag
+	if judge.JudgeStatus == models.JudgeAC {
+		// 检查是否在未下载附件或者未启动靶机的情况下提交正确 flag
+		if err := checkSolveWithoutTouching(&judge); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func newJudgeCheat(judge *models.Judge, cheatType models.CheatType) models.Cheat {
+	return models.Cheat{
+		CheatID:     uuid.NewString(),
+		CheatType:   cheatType,
+		GameID:      judge.GameID,
+		IngameID:    &judge.IngameID,
+		ChallengeID: &judge.ChallengeID,
+		TeamID:      judge.TeamID,
+		FlagID:      judge.FlagID,
+		JudgeID:     &judge.JudgeID,
+		SubmiterID:  &judge.SubmiterID,
+		CheatTime:   judge.JudgeTime,
+		SubmiterIP:  judge.SubmiterIP,
+	}
+}
+
+// 队伍提交自己附件里的 canary 只是误以为是 flag，不记录
+func checkBait(judge *models.Judge) error {
+	bait, err := anticheat.MatchBait(&judge.GameChallenge, judge.JudgeContent)
+	if err != nil {
+		return fmt.Errorf("failed to match honeypot flags: %v", err)
+	}
+	if bait == nil || bait.TeamID == judge.TeamID {
+		return nil
+	}
+
+	cheat := newJudgeCheat(judge, models.CheatSubmitHoneypotFlag)
+	cheat.ExtraData.Evidence = map[string]interface{}{"source": bait.Source}
+	if bait.IsCanary() {
+		cheat.CheatType = models.CheatSubmitCanaryFlag
+		cheat.ExtraData.RelevantTeam = bait.TeamID
+		cheat.ExtraData.RelevantTeamName = bait.TeamName
+	}
+
+	if err := dbtool.DB().Clauses(clause.OnConflict{DoNothing: true}).Create(&cheat).Error; err != nil {
+		zaphelper.Logger.Error("Failed to save cheat info for game ", zap.Error(err), zap.Int64("game_id", judge.GameID), zap.Any("cheat_data", cheat))
+	}
+
+	return nil
+}
+
+// 题目有可以记录下载的附件，远程附件的下载记录不到
+func hasTrackedAttachments(challenge *models.Challenge) bool {
+	for _, attachment := range challenge.Attachments {
+		if attachment.AttachType == models.AttachmentTypeStaticFile || attachment.AttachType == models.AttachmentTypeDynamicFile {
+			return true
+		}
+	}
+	return false
+}
+
+// 正确提交之前队伍没有下载过附件，或者没有开启过靶机
+// 查询失败时整个任务重试，前面已经写入的记录靠 (judge_id, cheat_type) 唯一索引去重
+func checkSolveWithoutTouching(judge *models.Judge) error {
+	cheats := make([]models.Cheat, 0, 2)
+
+	if hasTrackedAttachments(&judge.Challenge) {
+		var count int64
+		if err := dbtool.DB().Model(&models.AttachmentDownload{}).
+			Where("game_id = ? AND challenge_id = ? AND team_id = ? AND first_download_time <= ?", judge.GameID, judge.ChallengeID, judge.TeamID, judge.JudgeTime).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count attachment downloads: %v", err)
+		}
+		if count == 0 {
+			cheats = append(cheats, newJudgeCheat(judge, models.CheatSubmitWithoutDownloadAttachments))
+		}
+	}
+
+	// 共享靶机没有队伍自己的容器
+	if judge.Challenge.ContainerType == models.DYNAMIC_CONTAINER {
+		var count int64
+		if err := dbtool.DB().Model(&models.Container{}).
+			Where("game_id = ? AND challenge_id = ? AND team_id = ? AND start_time <= ?", judge.GameID, judge.ChallengeID, judge.TeamID, judge.JudgeTime).
+			Count(&count).Error; err != nil {
+			return fmt.Errorf("failed to count containers: %v", err)
+		}
+		if count == 0 {
+			cheats = append(cheats, newJudgeCheat(judge, models.CheatSubmitWithoutStartContainer))
+		}
+	}
+
+	for _, cheat := range cheats {
+		if err := dbtool.DB().Clauses(clause.OnConflict{DoNothing: true}).Create(&cheat).Error; err != nil {
+			zaphelper.Logger.Error("Failed to save cheat info for game ", zap.Error(err), zap.Int64("game_id", judge.GameID), zap.Any("cheat_data", cheat))
+		}
+	}
 
 	return nil
 }
@@ -0,0 +1,224 @@
+package tasks
+
+import (
+	"a1ctf/src/db/models"
+	anticheat "a1ctf/src/modules/anti_cheat"
+	containerbackend "a1ctf/src/utils/container_backend"
+	dbtool "a1ctf/src/utils/db_tool"
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"a1ctf/src/utils/zaphelper"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/spf13/viper"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.uber.org/zap"
+)
+
+type GenerateAttachmentPayload struct {
+	AttachmentID int64
+	// 生成的文件记在第一次请求附件的用户名下
+	UserID string
+}
+
+func generatorTimeout() time.Duration {
+	if timeout := viper.GetDuration("attachment-generator.timeout"); timeout > 0 {
+		return timeout
+	}
+	return 2 * time.Minute
+}
+
+// NewAttachmentGenerateTask 生成队伍的动态附件，attempt 区分失败后重新生成的任务
+func NewAttachmentGenerateTask(attachmentID int64, userID string, attempt int64) error {
+	payload, err := msgpack.Marshal(GenerateAttachmentPayload{AttachmentID: attachmentID, UserID: userID})
+	if err != nil {
+		return err
+	}
+
+	task := asynq.NewTask(TypeGenerateAttachment, payload)
+	_, err = client.Enqueue(task, asynq.TaskID(fmt.Sprintf("attachment_generate_%d_%d", attachmentID, attempt)),
+		asynq.MaxRetry(5),
+		// 留出创建 Job 和拉取镜像的时间
+		asynq.Timeout(generatorTimeout()+time.Minute),
+	)
+
+	return err
+}
+
+func HandleAttachmentGenerateTask(ctx context.Context, t *asynq.Task) error {
+	var p GenerateAttachmentPayload
+	if err := msgpack.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	var attachment models.TeamAttachment
+	if err := dbtool.DB().Where("attachment_id = ?", p.AttachmentID).First(&attachment).Error; err != nil {
+		return fmt.Errorf("failed to load team attachment %d: %v: %w", p.AttachmentID, err, asynq.SkipRetry)
+	}
+
+	if attachment.Status != models.TeamAttachmentPending {
+		return nil
+	}
+
+	fileID, err := generateAttachment(ctx, &attachment, p.UserID)
+	if err != nil {
+		retryCount, _ := asynq.GetRetryCount(ctx)
+		maxRetry, _ := asynq.GetMaxRetry(ctx)
+
+		if errors.Is(err, asynq.SkipRetry) || retryCount >= maxRetry {
+			message := err.Error()
+			if len(message) > 512 {
+				message = message[:512]
+			}
+			if updateErr := dbtool.DB().Model(&attachment).Updates(map[string]interface{}{
+				"status":      models.TeamAttachmentFailed,
+				"message":     message,
+				"update_time": time.Now().UTC(),
+			}).Error; updateErr != nil {
+				zaphelper.Logger.Error("Failed to mark team attachment as failed", zap.Error(updateErr), zap.Int64("attachment_id", attachment.AttachmentID))
+			}
+			zaphelper.Logger.Error("Failed to generate team attachment", zap.Error(err), zap.Int64("attachment_id", attachment.AttachmentID), zap.Int64("team_id", attachment.TeamID), zap.Int64("challenge_id", attachment.ChallengeID))
+		}
+
+		return err
+	}
+
+	if err := dbtool.DB().Model(&attachment).Updates(map[string]interface{}{
+		"status":      models.TeamAttachmentReady,
+		"file_id":     fileID,
+		"message":     "",
+		"update_time": time.Now().UTC(),
+	}).Error; err != nil {
+		return fmt.Errorf("failed to update team attachment: %w", err)
+	}
+
+	zaphelper.Logger.Info("Successfully generated team attachment", zap.Int64("attachment_id", attachment.AttachmentID), zap.Int64("team_id", attachment.TeamID), zap.Int64("challenge_id", attachment.ChallengeID), zap.String("file_id", fileID))
+
+	return nil
+}
+
+// 运行题目的生成脚本，保存为上传文件后返回文件 ID
+func generateAttachment(ctx context.Context, attachment *models.TeamAttachment, userID string) (string, error) {
+	var gameChallenge models.GameChallenge
+	if err := dbtool.DB().Preload("Challenge").Where("game_id = ? AND challenge_id = ?", attachment.GameID, attachment.ChallengeID).First(&gameChallenge).Error; err != nil {
+		return "", fmt.Errorf("failed to load game challenge: %w", err)
+	}
+
+	var config *models.AttachmentConfig
+	for idx := range gameChallenge.Challenge.Attachments {
+		attach := &gameChallenge.Challenge.Attachments[idx]
+		if attach.AttachName == attachment.AttachName && attach.AttachType == models.AttachmentTypeDynamicFile {
+			config = attach
+			break
+		}
+	}
+	if config == nil || config.GenerateScript == nil || *config.GenerateScript == "" {
+		return "", fmt.Errorf("attachment %s has no generate script: %w", attachment.AttachName, asynq.SkipRetry)
+	}
+
+	// 生成任务使用 k8s Job 运行
+	if containerbackend.Get().Driver() != containerbackend.DriverKubernetes {
+		return "", fmt.Errorf("attachment generation is not supported by the %s container backend: %w", containerbackend.Get().Driver(), asynq.SkipRetry)
+	}
+
+	image := viper.GetString("attachment-generator.image")
+	if config.GenerateImage != nil && *config.GenerateImage != "" {
+		image = *config.GenerateImage
+	}
+	if image == "" {
+		return "", fmt.Errorf("no image to run generate script: %w", asynq.SkipRetry)
+	}
+
+	var team models.Team
+	if err := dbtool.DB().Where("team_id = ?", attachment.TeamID).First(&team).Error; err != nil {
+		return "", fmt.Errorf("failed to load team: %w", err)
+	}
+
+	// 动态 flag 由查看题目时的 flag 创建任务生成，还没有生成就等待重试
+	var flag string
+	if gameChallenge.Challenge.FlagType == models.FlagTypeDynamic {
+		var teamFlag models.TeamFlag
+		if err := dbtool.DB().Where("game_id = ? AND team_id = ? AND challenge_id = ? AND round = 0", attachment.GameID, attachment.TeamID, attachment.ChallengeID).First(&teamFlag).Error; err != nil {
+			return "", fmt.Errorf("team flag is not ready: %w", err)
+		}
+		flag = teamFlag.FlagContent
+	} else if gameChallenge.JudgeConfig != nil && gameChallenge.JudgeConfig.FlagTemplate != nil {
+		flag = *gameChallenge.JudgeConfig.FlagTemplate
+	}
+
+	// 开启了 canary 时嵌入到附件里，别的队伍提交说明附件泄露了
+	canary, err := anticheat.TeamCanary(&gameChallenge, &team)
+	if err != nil {
+		return "", fmt.Errorf("failed to generate team canary: %w", err)
+	}
+
+	maxSize := int64(viper.GetSizeInBytes("attachment-generator.max-size"))
+	if maxSize <= 0 {
+		maxSize = 50 * 1024 * 1024
+	}
+
+	jobCtx, cancel := context.WithTimeout(ctx, generatorTimeout())
+	defer cancel()
+
+	data, err := k8stool.RunGeneratorJob(jobCtx, &k8stool.GeneratorJob{
+		Name:   fmt.Sprintf("gen-%d-%s", attachment.AttachmentID, uuid.NewString()[:8]),
+		Image:  image,
+		Script: *config.GenerateScript,
+		Env: map[string]string{
+			"A1CTF_TEAM_ID":      strconv.FormatInt(team.TeamID, 10),
+			"A1CTF_TEAM_HASH":    team.TeamHash,
+			"A1CTF_TEAM_NAME":    team.TeamName,
+			"A1CTF_GAME_ID":      strconv.FormatInt(attachment.GameID, 10),
+			"A1CTF_CHALLENGE_ID": strconv.FormatInt(attachment.ChallengeID, 10),
+		},
+		// flag 和 canary 通过 Secret 传入，有 Job 读取权限的人也看不到
+		SecretEnv: map[string]string{
+			"A1CTF_FLAG":   flag,
+			"A1CTF_CANARY": canary,
+		},
+		OutputFile: "attachment",
+		MaxBytes:   maxSize,
+	})
+	if err != nil {
+		return "", err
+	}
+
+	fileID := uuid.NewString()
+	uploadDir := "./data/uploads/generated"
+	if err := os.MkdirAll(uploadDir, 0755); err != nil {
+		return "", fmt.Errorf("failed to create upload directory: %w", err)
+	}
+
+	savedPath := filepath.Join(uploadDir, fileID)
+	if err := os.WriteFile(savedPath, data, 0644); err != nil {
+		return "", fmt.Errorf("failed to save generated file: %w", err)
+	}
+
+	hash := sha256.Sum256(data)
+	upload := models.Upload{
+		FileID:     fileID,
+		UserID:     userID,
+		FileName:   attachment.AttachName,
+		FilePath:   savedPath,
+		FileHash:   hex.EncodeToString(hash[:]),
+		FileType:   "application/octet-stream",
+		FileSize:   int64(len(data)),
+		UploadTime: time.Now().UTC(),
+	}
+
+	if err := dbtool.DB().Create(&upload).Error; err != nil {
+		os.Remove(savedPath)
+		return "", fmt.Errorf("failed to save file record: %w", err)
+	}
+
+	return fileID, nil
+}
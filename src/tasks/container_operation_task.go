@@ -2,6 +2,7 @@ package tasks
 
 import (
 	"a1ctf/src/db/models"
+	containerbackend "a1ctf/src/utils/container_backend"
 	dbtool "a1ctf/src/utils/db_tool"
 	"context"
 	"fmt"
@@ -63,7 +64,7 @@ func HandleContainerStartTask(ctx context.Context, t *asynq.Task) error {
 	}
 
 	podInfo := k8stool.PodInfo{
-		Name:       fmt.Sprintf("cl-%d-%s", task.InGameID, task.TeamHash),
+		Name:       task.PodID(),
 		TeamHash:   task.TeamHash,
 		Containers: task.ContainerConfig,
 		Labels: map[string]string{
@@ -75,7 +76,7 @@ func HandleContainerStartTask(ctx context.Context, t *asynq.Task) error {
 		AllowDNS: task.Challenge.AllowDNS,
 	}
 
-	err := k8stool.CreatePod(&podInfo)
+	err := containerbackend.Get().CreatePod(&podInfo)
 	if err != nil {
 		// 记录容器创建失败日志
 		LogContainerOperation(nil, nil, models.ActionContainerStarting, task.ContainerID, map[string]interface{}{
@@ -119,7 +120,7 @@ func HandleContainerStopTask(ctx context.Context, t *asynq.Task) error {
 	}
 
 	podInfo := k8stool.PodInfo{
-		Name:       fmt.Sprintf("cl-%d-%s", task.InGameID, task.TeamHash),
+		Name:       task.PodID(),
 		TeamHash:   task.TeamHash,
 		Containers: task.ContainerConfig,
 		Labels: map[string]string{
@@ -135,11 +136,14 @@ func HandleContainerStopTask(ctx context.Context, t *asynq.Task) error {
 
 	for _, port := range task.ContainerExposeInfos {
 		for _, port2 := range port.ExposePorts {
+			if port2.Type == models.ExposePortTypeURL || port2.Type == models.ExposePortTypeGateway {
+				continue
+			}
 			flatPorts = append(flatPorts, port2.Port)
 		}
 	}
 
-	err := k8stool.DeletePod(&podInfo, flatPorts)
+	err := containerbackend.Get().DeletePod(&podInfo, flatPorts)
 	if err != nil {
 		LogContainerOperation(nil, nil, models.ActionContainerStopping, task.ContainerID, map[string]interface{}{
 			"game_id":               task.GameID,
@@ -194,7 +198,7 @@ func HandleContainerFailedTask(ctx context.Context, t *asynq.Task) error {
 	podStatus := payload.PodStatus
 
 	podInfo := k8stool.PodInfo{
-		Name:       fmt.Sprintf("cl-%d-%s", task.InGameID, task.TeamHash),
+		Name:       task.PodID(),
 		TeamHash:   task.TeamHash,
 		Containers: task.ContainerConfig,
 		Labels: map[string]string{
@@ -210,11 +214,14 @@ func HandleContainerFailedTask(ctx context.Context, t *asynq.Task) error {
 
 	for _, port := range task.ContainerExposeInfos {
 		for _, port2 := range port.ExposePorts {
+			if port2.Type == models.ExposePortTypeURL || port2.Type == models.ExposePortTypeGateway {
+				continue
+			}
 			flatPorts = append(flatPorts, port2.Port)
 		}
 	}
 
-	err := k8stool.DeletePod(&podInfo, flatPorts)
+	err := containerbackend.Get().DeletePod(&podInfo, flatPorts)
 	if err != nil {
 		LogContainerOperation(nil, nil, models.ActionContainerFailed, task.ContainerID, map[string]interface{}{
 			"game_id":               task.GameID,
@@ -26,17 +26,28 @@ type CreateTeamFlagPayload struct {
 	TeamHash     string
 	TeamName     string
 	FlagType     models.FlagType
+	Round        int32
 }
 
 func NewTeamFlagCreateTask(flagTemplate string, teamID int64, gameID int64, challengeID int64, teamHash string, teamName string, flagType models.FlagType) error {
-	payload, err := msgpack.Marshal(CreateTeamFlagPayload{FlagTemplate: flagTemplate, TeamID: teamID, GameID: gameID, ChallengeID: challengeID, TeamHash: teamHash, TeamName: teamName, FlagType: flagType})
+	return NewTeamRoundFlagCreateTask(flagTemplate, teamID, gameID, challengeID, teamHash, teamName, flagType, 0)
+}
+
+// NewTeamRoundFlagCreateTask 攻防模式每一轮都给队伍生成新的 flag
+func NewTeamRoundFlagCreateTask(flagTemplate string, teamID int64, gameID int64, challengeID int64, teamHash string, teamName string, flagType models.FlagType, round int32) error {
+	payload, err := msgpack.Marshal(CreateTeamFlagPayload{FlagTemplate: flagTemplate, TeamID: teamID, GameID: gameID, ChallengeID: challengeID, TeamHash: teamHash, TeamName: teamName, FlagType: flagType, Round: round})
 	if err != nil {
 		return err
 	}
 
+	taskID := fmt.Sprintf("teamFlag_create_%d_%d_%d", teamID, gameID, challengeID)
+	if round > 0 {
+		taskID = fmt.Sprintf("%s_round_%d", taskID, round)
+	}
+
 	task := asynq.NewTask(TypeNewTeamFlag, payload)
 	// taskID 是为了防止重复创建任务
-	_, err = client.Enqueue(task, asynq.TaskID(fmt.Sprintf("teamFlag_create_%d_%d_%d", teamID, gameID, challengeID)),
+	_, err = client.Enqueue(task, asynq.TaskID(taskID),
 		asynq.MaxRetry(100),
 		asynq.Timeout(10*time.Second),
 	)
@@ -51,10 +62,10 @@ func HandleTeamCreateTask(ctx context.Context, t *asynq.Task) error {
 	}
 
 	var existingFlag models.TeamFlag
-	result := dbtool.DB().Where("team_id = ? AND game_id = ? AND challenge_id = ?", p.TeamID, p.GameID, p.ChallengeID).First(&existingFlag)
+	result := dbtool.DB().Where("team_id = ? AND game_id = ? AND challenge_id = ? AND round = ?", p.TeamID, p.GameID, p.ChallengeID, p.Round).First(&existingFlag)
 	if result.Error == nil {
 		// 已经有 FLAG
-		return fmt.Errorf("[TeamID: %d, GameID: %d, ChallengeID: %d] team already has the flag: %w", p.TeamID, p.GameID, p.ChallengeID, asynq.SkipRetry)
+		return fmt.Errorf("[TeamID: %d, GameID: %d, ChallengeID: %d, Round: %d] team already has the flag: %w", p.TeamID, p.GameID, p.ChallengeID, p.Round, asynq.SkipRetry)
 	}
 
 	var flags []string
@@ -95,10 +106,11 @@ func HandleTeamCreateTask(ctx context.Context, t *asynq.Task) error {
 		ChallengeID: p.ChallengeID,
 		TeamID:      p.TeamID,
 		FlagContent: flag,
+		Round:       p.Round,
 	}).Error
 
 	if err == nil {
-		zaphelper.Logger.Info("Successfully created flag for team", zap.Int64("team_id", p.TeamID), zap.Int64("game_id", p.GameID), zap.Int64("challenge_id", p.ChallengeID))
+		zaphelper.Logger.Info("Successfully created flag for team", zap.Int64("team_id", p.TeamID), zap.Int64("game_id", p.GameID), zap.Int64("challenge_id", p.ChallengeID), zap.Int32("round", p.Round))
 		return nil
 	}
 
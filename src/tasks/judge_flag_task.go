@@ -0,0 +1,323 @@
+package tasks
+
+import (
+	"a1ctf/src/db/models"
+	anticheat "a1ctf/src/modules/anti_cheat"
+	scoreengine "a1ctf/src/modules/score_engine"
+	scriptjudge "a1ctf/src/modules/script_judge"
+	dbtool "a1ctf/src/utils/db_tool"
+	"a1ctf/src/utils/general"
+	a1locks "a1ctf/src/utils/locks"
+	noticetool "a1ctf/src/utils/notice_tool"
+	"a1ctf/src/utils/zaphelper"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hibiken/asynq"
+	"github.com/vmihailenco/msgpack/v5"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// 判题任务单独使用的队列，由独立的 worker 池消费
+const JudgeQueue = "judge"
+
+type JudgeFlagPayload struct {
+	JudgeID string
+}
+
+func NewJudgeFlagTask(judgeID string) error {
+	payload, err := msgpack.Marshal(JudgeFlagPayload{JudgeID: judgeID})
+	if err != nil {
+		return err
+	}
+
+	task := asynq.NewTask(TypeJudgeFlag, payload)
+	// taskID 是为了防止重复创建任务
+	taskID := fmt.Sprintf("judge_%s", judgeID)
+	enqueue := func() error {
+		_, err := client.Enqueue(task, asynq.TaskID(taskID),
+			asynq.Queue(JudgeQueue),
+			asynq.MaxRetry(3),
+			asynq.Timeout(30*time.Second),
+		)
+		return err
+	}
+
+	err = enqueue()
+	if !errors.Is(err, asynq.ErrTaskIDConflict) {
+		return err
+	}
+
+	// 同一个 ID 的任务还在的话分两种情况: 等待执行或者重试中的不用管，
+	// 重试次数用完被归档的会一直占着这个 ID，需要删掉再重新入队，否则兜底任务永远无法重判
+	info, err := inspector.GetTaskInfo(JudgeQueue, taskID)
+	if err != nil {
+		if errors.Is(err, asynq.ErrTaskNotFound) {
+			return enqueue()
+		}
+		return err
+	}
+	if info.State != asynq.TaskStateArchived && info.State != asynq.TaskStateCompleted {
+		return nil
+	}
+
+	if err := inspector.DeleteTask(JudgeQueue, taskID); err != nil && !errors.Is(err, asynq.ErrTaskNotFound) {
+		return err
+	}
+
+	err = enqueue()
+	if errors.Is(err, asynq.ErrTaskIDConflict) {
+		// 删除之后又被别人入队了
+		return nil
+	}
+	return err
+}
+
+func HandleJudgeFlagTask(ctx context.Context, t *asynq.Task) error {
+	var p JudgeFlagPayload
+	if err := msgpack.Unmarshal(t.Payload(), &p); err != nil {
+		return fmt.Errorf("json.Unmarshal failed: %v: %w", err, asynq.SkipRetry)
+	}
+
+	// 先加载再抢占，加载失败时判题仍然是排队中，asynq 重试或者兜底任务都能再次处理
+	var judge models.Judge
+	if err := dbtool.DB().Where("judge_id = ?", p.JudgeID).Preload("TeamFlag").Preload("GameChallenge").Preload("Challenge").Preload("Team").Preload("Game").First(&judge).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return fmt.Errorf("judge %s not found: %w", p.JudgeID, asynq.SkipRetry)
+		}
+		return fmt.Errorf("database error: %w", err)
+	}
+	if judge.JudgeStatus != models.JudgeQueueing {
+		return nil
+	}
+
+	// 把状态从排队中改成判题中，抢不到说明已经被别的 worker 处理了
+	claim := dbtool.DB().Model(&models.Judge{}).Where("judge_id = ? AND judge_status = ?", p.JudgeID, models.JudgeQueueing).Updates(map[string]interface{}{
+		"judge_status":     models.JudgeRunning,
+		"judge_started_at": time.Now().UTC(),
+	})
+	if claim.Error != nil {
+		return fmt.Errorf("database error: %w", claim.Error)
+	}
+	if claim.RowsAffected == 0 {
+		return nil
+	}
+	judge.JudgeStatus = models.JudgeRunning
+
+	if err := processQueueingJudge(&judge); err != nil {
+		zaphelper.Logger.Error("Judge task failed", zap.Error(err), zap.Any("judge", judge))
+	}
+
+	// 没有得出结果的判题不能一直停在判题中
+	if judge.JudgeStatus == models.JudgeRunning {
+		judge.JudgeStatus = models.JudgeError
+	}
+
+	if err := dbtool.DB().Model(&models.Judge{}).Where("judge_id = ?", judge.JudgeID).Updates(map[string]interface{}{
+		"judge_status": judge.JudgeStatus,
+		"judge_result": judge.JudgeResult,
+	}).Error; err != nil {
+		// 结果没写进去就放回排队中重新判，已经插入的 Solve 会在重判时被识别出来
+		releaseJudgeClaim(judge.JudgeID)
+		return fmt.Errorf("database error: %w", err)
+	}
+
+	noticetool.AnnounceJudgeResult(judge)
+
+	return nil
+}
+
+// 把抢占的判题放回排队中
+func releaseJudgeClaim(judgeID string) {
+	if err := dbtool.DB().Model(&models.Judge{}).Where("judge_id = ? AND judge_status = ?", judgeID, models.JudgeRunning).Updates(map[string]interface{}{
+		"judge_status":     models.JudgeQueueing,
+		"judge_started_at": nil,
+	}).Error; err != nil {
+		zaphelper.Logger.Error("Failed to release judge claim", zap.Error(err), zap.String("judge_id", judgeID))
+	}
+}
+
+// 判题通过后插入 Solve 记录
+func acceptJudge(judge *models.Judge) error {
+	// 如果是系统管理员队伍，不插入 Solves，防止影响积分榜
+	if judge.Team.TeamType == models.TeamTypeAdmin {
+		judge.JudgeStatus = models.JudgeAC
+		return nil
+	}
+
+	// 获取写锁, 防止与重算题目Rank的任务冲突
+	a1locks.RankRWLock.Lock()
+	defer a1locks.RankRWLock.Unlock()
+
+	// 查询已经解出来的人
+	var solves []models.Solve
+	if err := dbtool.DB().Where("game_id = ? AND challenge_id = ? AND solve_time >= ? AND solve_time <= ? AND solve_status = ?", judge.GameID, judge.ChallengeID, judge.Game.StartTime, judge.Game.EndTime, models.SolveCorrect).Preload("Team").Find(&solves).Error; err != nil {
+		judge.JudgeStatus = models.JudgeError
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			// 记录错误
+			LogJudgeOperation(nil, nil, models.ActionJudge, judge.JudgeID, map[string]interface{}{
+				"team_id": judge.TeamID,
+				"game_id": judge.GameID,
+				"judge":   judge,
+			}, err)
+		}
+		return fmt.Errorf("database error: %w data: %+v", err, judge)
+	}
+
+	// 过滤被封禁的队伍，同时检查是否已经解出过，并发判题时同一队伍可能有多个正确提交
+	validSolves := make([]models.Solve, 0, len(solves))
+	for _, solve := range solves {
+		if solve.TeamID == judge.TeamID {
+			judge.JudgeStatus = models.JudgeAC
+			return nil
+		}
+		if solve.Team.TeamStatus == models.ParticipateApproved {
+			validSolves = append(validSolves, solve)
+		}
+	}
+	solves = validSolves
+
+	newSolve := models.Solve{
+		IngameID:    judge.IngameID,
+		JudgeID:     judge.JudgeID,
+		SolveID:     uuid.NewString(),
+		GameID:      judge.GameID,
+		ChallengeID: judge.ChallengeID,
+		TeamID:      judge.TeamID,
+		SolveStatus: models.SolveCorrect,
+		SolveTime:   time.Now().UTC(),
+		SolverID:    judge.SubmiterID,
+		Rank:        int32(len(solves) + 1),
+	}
+
+	if err := dbtool.DB().Create(&newSolve).Error; err != nil {
+		judge.JudgeStatus = models.JudgeError
+		return fmt.Errorf("database error: %w data: %+v", err, judge)
+	}
+
+	scoreengine.NotifySolve(judge.GameID, judge.ChallengeID, judge.TeamID)
+
+	if newSolve.Rank <= 3 {
+		var solveDetail = models.Solve{}
+
+		if err := dbtool.DB().Where("solve_id = ?", newSolve.SolveID).Preload("Challenge").Preload("Team").First(&solveDetail).Error; err != nil {
+			zaphelper.Logger.Error("Announce first blood error", zap.Error(err))
+		}
+
+		var noticeCate models.NoticeCategory
+
+		// 现在由算分逻辑计算三血分数，不使用 score-adjustment
+
+		if newSolve.Rank == 1 {
+			noticeCate = models.NoticeFirstBlood
+		} else if newSolve.Rank == 2 {
+			noticeCate = models.NoticeSecondBlood
+		} else {
+			noticeCate = models.NoticeThirdBlood
+		}
+
+		go func() {
+			noticetool.InsertNotice(judge.GameID, noticeCate, []string{solveDetail.Team.TeamName, solveDetail.Challenge.Name})
+		}()
+	}
+
+	judge.JudgeStatus = models.JudgeAC
+	return nil
+}
+
+// 题目级别的判题脚本优先，没有的话用题库里的
+func judgeScriptOf(judge *models.Judge) *string {
+	if judge.GameChallenge.JudgeConfig != nil && judge.GameChallenge.JudgeConfig.JudgeScript != nil && *judge.GameChallenge.JudgeConfig.JudgeScript != "" {
+		return judge.GameChallenge.JudgeConfig.JudgeScript
+	}
+	if judge.Challenge.JudgeConfig != nil && judge.Challenge.JudgeConfig.JudgeScript != nil && *judge.Challenge.JudgeConfig.JudgeScript != "" {
+		return judge.Challenge.JudgeConfig.JudgeScript
+	}
+	return nil
+}
+
+// scriptJudgeStatus 判题脚本的结果对应的判题状态，超时和超内存都算超时
+func scriptJudgeStatus(result *scriptjudge.JudgeOutput, err error) models.JudgeStatus {
+	switch {
+	case errors.Is(err, scriptjudge.ErrTimeLimitExceeded), errors.Is(err, scriptjudge.ErrMemoryLimitExceeded):
+		return models.JudgeTimeout
+	case err != nil:
+		return models.JudgeError
+	case result.Accepted:
+		return models.JudgeAC
+	default:
+		return models.JudgeWA
+	}
+}
+
+func processQueueingJudge(judge *models.Judge) error {
+	// 诱饵 flag 和 canary 和普通的错误一样返回，作弊记录由反作弊任务写入
+	bait, err := anticheat.MatchBait(&judge.GameChallenge, judge.JudgeContent)
+	if err != nil {
+		zaphelper.Logger.Error("Failed to match honeypot flags", zap.Error(err), zap.String("judge_id", judge.JudgeID))
+	}
+	if bait != nil {
+		judge.JudgeStatus = models.JudgeWA
+		return nil
+	}
+
+	switch judge.JudgeType {
+	case models.JudgeTypeDynamic:
+		flagCorrect := false
+
+		switch judge.Challenge.FlagType {
+		case models.FlagTypeDynamic:
+			// 动态和TeamFlag库里的比较
+			flagCorrect = general.MatchDynamicFlag(judge.GameChallenge.JudgeConfig, judge.TeamFlag.FlagContent, judge.JudgeContent)
+		case models.FlagTypeStatic:
+			// 静态按照题目配置的匹配模式比较
+			flagCorrect = general.MatchStaticFlag(judge.GameChallenge.JudgeConfig, judge.JudgeContent)
+		}
+
+		if flagCorrect {
+			return acceptJudge(judge)
+		} else {
+			judge.JudgeStatus = models.JudgeWA
+			return nil
+		}
+	case models.JudgeTypeScript:
+		script := judgeScriptOf(judge)
+		if script == nil {
+			judge.JudgeStatus = models.JudgeError
+			return fmt.Errorf("judge script not found for challenge %d", judge.ChallengeID)
+		}
+
+		// 静态 flag 没有 TeamFlag，把模板给脚本
+		teamFlag := judge.TeamFlag.FlagContent
+		if judge.Challenge.FlagType == models.FlagTypeStatic && judge.GameChallenge.JudgeConfig != nil && judge.GameChallenge.JudgeConfig.FlagTemplate != nil {
+			teamFlag = *judge.GameChallenge.JudgeConfig.FlagTemplate
+		}
+
+		result, err := scriptjudge.Run(*script, scriptjudge.JudgeInput{
+			Content:     judge.JudgeContent,
+			TeamID:      judge.TeamID,
+			TeamHash:    judge.Team.TeamHash,
+			TeamFlag:    teamFlag,
+			GameID:      judge.GameID,
+			ChallengeID: judge.ChallengeID,
+		})
+		judge.JudgeStatus = scriptJudgeStatus(result, err)
+		if err != nil {
+			return fmt.Errorf("judge script error: %w", err)
+		}
+
+		judge.JudgeResult = result.Message
+
+		if judge.JudgeStatus == models.JudgeAC {
+			return acceptJudge(judge)
+		}
+		return nil
+	default:
+		judge.JudgeStatus = models.JudgeError
+		return fmt.Errorf("unknown judge type: %s", judge.JudgeType)
+	}
+}
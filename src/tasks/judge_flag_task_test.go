@@ -0,0 +1,32 @@
+package tasks
+
+import (
+	"a1ctf/src/db/models"
+	scriptjudge "a1ctf/src/modules/script_judge"
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestScriptJudgeStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		result *scriptjudge.JudgeOutput
+		err    error
+		want   models.JudgeStatus
+	}{
+		{name: "accepted", result: &scriptjudge.JudgeOutput{Accepted: true}, want: models.JudgeAC},
+		{name: "rejected", result: &scriptjudge.JudgeOutput{Accepted: false, Message: "close"}, want: models.JudgeWA},
+		{name: "time limit", err: scriptjudge.ErrTimeLimitExceeded, want: models.JudgeTimeout},
+		{name: "memory limit", err: fmt.Errorf("worker: %w", scriptjudge.ErrMemoryLimitExceeded), want: models.JudgeTimeout},
+		{name: "script error", err: errors.New("judge script must define function judge(submission)"), want: models.JudgeError},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := scriptJudgeStatus(tt.result, tt.err); got != tt.want {
+				t.Errorf("scriptJudgeStatus() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -2,6 +2,7 @@ package tasks
 
 import (
 	"a1ctf/src/db/models"
+	scoreengine "a1ctf/src/modules/score_engine"
 	dbtool "a1ctf/src/utils/db_tool"
 	a1locks "a1ctf/src/utils/locks"
 	"a1ctf/src/utils/zaphelper"
@@ -62,7 +63,7 @@ func HandleRecalculateRankForAChallengeTask(ctx context.Context, t *asynq.Task)
 	a1locks.RankRWLock.Lock()
 	defer a1locks.RankRWLock.Unlock()
 
-	return dbtool.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+	err := dbtool.DB().WithContext(ctx).Transaction(func(tx *gorm.DB) error {
 		var game models.Game
 		if err := tx.Where("game_id = ?", p.GameID).First(&game).Error; err != nil {
 			zaphelper.Logger.Error("failed to fetch game for recalculating ranks", zap.Error(err), zap.Any("data", p))
@@ -105,4 +106,11 @@ func HandleRecalculateRankForAChallengeTask(ctx context.Context, t *asynq.Task)
 		zaphelper.Logger.Info("recalculate rank finished", zap.Any("rank_data", p))
 		return nil
 	})
+
+	if err == nil {
+		// 排名变了血奖也会变
+		scoreengine.NotifyChallenge(p.GameID, p.ChallengeIDList...)
+	}
+
+	return err
 }
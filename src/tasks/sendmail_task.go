@@ -23,17 +23,26 @@ const (
 	MailTaskTypeEmailVerification MailTaskType = "emailVerification"
 	MailTaskTypeSendTestMail      MailTaskType = "emailSendTestMail"
 	MailTaskTypeForgetPassword    MailTaskType = "forgetPasswordEmail"
+	MailTaskTypeCheatAlert        MailTaskType = "cheatAlertEmail"
 )
 
 type EmailVerificationData struct {
 	User models.User
 }
 
+// CheatAlertData 作弊处罚通知，内容由处罚规则生成
+type CheatAlertData struct {
+	User    models.User
+	Subject string
+	Body    string
+}
+
 type SendMailTaskPayload struct {
 	MailSendType          MailTaskType
 	EmailVerificationData *EmailVerificationData
 	SendTestMailTo        *string
 	TestMailType          *string
+	CheatAlertData        *CheatAlertData
 }
 
 func checkEmailConfig() error {
@@ -121,6 +130,29 @@ func NewSendTestMailTask(to string, mailType string) error {
 	return err
 }
 
+func NewCheatAlertMailTask(user models.User, subject string, body string) error {
+	if err := checkEmailConfig(); err != nil {
+		return err
+	}
+
+	payload, err := msgpack.Marshal(SendMailTaskPayload{
+		MailSendType:   MailTaskTypeCheatAlert,
+		CheatAlertData: &CheatAlertData{User: user, Subject: subject, Body: body},
+	})
+
+	if err != nil {
+		return err
+	}
+
+	task := asynq.NewTask(TypeSendMail, payload)
+	_, err = client.Enqueue(task,
+		asynq.MaxRetry(3),
+		asynq.Timeout(10*time.Second),
+	)
+
+	return err
+}
+
 func HandleSendMailTask(ctx context.Context, t *asynq.Task) error {
 	var p SendMailTaskPayload
 	if err := msgpack.Unmarshal(t.Payload(), &p); err != nil {
@@ -183,6 +215,11 @@ func HandleSendMailTask(ctx context.Context, t *asynq.Task) error {
 		mailTeamplate = strings.ReplaceAll(mailTeamplate, "{reset_link}", reset_url)
 
 		m.SetBody("text/html", mailTeamplate)
+	case MailTaskTypeCheatAlert:
+		receiver := p.CheatAlertData.User
+		m.SetAddressHeader("To", *receiver.Email, receiver.Username)
+		m.SetHeader("Subject", p.CheatAlertData.Subject)
+		m.SetBody("text/html", p.CheatAlertData.Body)
 	case MailTaskTypeSendTestMail:
 		m.SetAddressHeader("To", *p.SendTestMailTo, "EMMMMMMMMM")
 
@@ -10,6 +10,7 @@ import (
 
 var client *asynq.Client
 var server *asynq.Server
+var judgeServer *asynq.Server
 var inspector *asynq.Inspector
 
 func InitTaskQueue() {
@@ -61,15 +62,44 @@ func InitTaskQueue() {
 
 		mux.HandleFunc(TypeRecalculateRankForAChallenge, HandleRecalculateRankForAChallengeTask)
 
+		mux.HandleFunc(TypeGenerateAttachment, HandleAttachmentGenerateTask)
+
 		if err := server.Run(mux); err != nil {
 			log.Fatalf("could not run server: %v", err)
 		}
 	}()
+
+	go func() {
+		// 判题使用独立的 worker 池，避免被容器等耗时任务占满
+		concurrency := viper.GetInt("judge.workers")
+		if concurrency <= 0 {
+			concurrency = 8
+		}
+
+		judgeServer = asynq.NewServer(
+			redisOpt,
+			asynq.Config{
+				Concurrency: concurrency,
+				Queues: map[string]int{
+					JudgeQueue: 1,
+				},
+				Logger: zaphelper.NewZapLogger(zaphelper.Logger),
+			},
+		)
+
+		mux := asynq.NewServeMux()
+		mux.HandleFunc(TypeJudgeFlag, HandleJudgeFlagTask)
+
+		if err := judgeServer.Run(mux); err != nil {
+			log.Fatalf("could not run judge server: %v", err)
+		}
+	}()
 }
 
 func CloseTaskQueue() {
 	client.Close()
 	server.Shutdown()
+	judgeServer.Shutdown()
 }
 
 const (
@@ -83,4 +113,5 @@ const (
 	TypeAntiCheat                    = "flag:anticheat"
 	TypeSendMail                     = "mail:send"
 	TypeRecalculateRankForAChallenge = "other:recalculateForAChallenge"
+	TypeGenerateAttachment           = "attachment:generate"
 )
@@ -0,0 +1,92 @@
+package containerbackend
+
+import (
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/spf13/viper"
+)
+
+const (
+	DriverKubernetes = "kubernetes"
+	DriverDocker     = "docker"
+	DriverFake       = "fake"
+)
+
+// Resource 后端里由题目容器创建的对象，Name 和 Pod 名相同
+type Resource struct {
+	Name       string
+	CreateTime time.Time
+}
+
+// ContainerBackend 题目容器的运行环境，一个 Pod 对应一个队伍的一道题
+// Pod 名为 cl-<ingame_id>-<team_hash>，带有 team_hash 和 ingame_id 标签
+type ContainerBackend interface {
+	// Driver 后端名称
+	Driver() string
+	// Init 启动时检查连接，创建命名空间等
+	Init() error
+
+	CreatePod(podInfo *k8stool.PodInfo) error
+	// DeletePod ports 为已经分配的端口，需要释放
+	DeletePod(podInfo *k8stool.PodInfo, ports []int32) error
+	// PodStatus Pod 不存在时返回 nil
+	PodStatus(inGameID int64, teamHash string) (*k8stool.PodStatusDecision, error)
+	// PodPorts 端口名为 <容器序号>-<端口名>
+	PodPorts(podInfo *k8stool.PodInfo) (*k8stool.PodPorts, error)
+	// Exec 在 Pod 的容器里执行命令，返回标准输出
+	Exec(ctx context.Context, podName string, containerName string, command []string, stdin io.Reader) (string, error)
+	// PodCPUUsage Pod 所有容器当前的 CPU 占用，单位 millicore
+	PodCPUUsage(podInfo *k8stool.PodInfo) (int64, error)
+
+	// Watch Pod 状态变化时调用 notify，返回前完成第一次同步
+	Watch(ctx context.Context, notify func(inGameID int64, teamHash string)) error
+	// Resources 后端里所有题目容器创建的对象，用于回收孤儿资源
+	Resources() ([]Resource, error)
+	// DeleteOrphan 删除数据库里已经没有对应容器的对象
+	DeleteOrphan(name string) error
+}
+
+// WarmPoolBackend 支持预热池的后端
+type WarmPoolBackend interface {
+	// ClaimWarmPod 从预热池认领一个就绪的 Pod 并换成队伍的标签，没有可用的 Pod 时返回空字符串
+	// 多个实例同时认领时同一个 Pod 只有一个能成功
+	ClaimWarmPod(pool string, teamLabels map[string]string) (string, error)
+}
+
+var backend ContainerBackend
+
+// Init 按 container-backend.driver 选择后端并初始化
+func Init() error {
+	driver := viper.GetString("container-backend.driver")
+
+	switch driver {
+	case "", DriverKubernetes:
+		backend = &kubernetesBackend{}
+	case DriverDocker:
+		dockerBackend, err := newDockerBackend()
+		if err != nil {
+			return err
+		}
+		backend = dockerBackend
+	case DriverFake:
+		backend = NewFakeBackend()
+	default:
+		return fmt.Errorf("unknown container backend driver %s", driver)
+	}
+
+	return backend.Init()
+}
+
+// Get 当前使用的后端
+func Get() ContainerBackend {
+	return backend
+}
+
+// Set 替换当前使用的后端，测试时注入 FakeBackend
+func Set(b ContainerBackend) {
+	backend = b
+}
@@ -0,0 +1,684 @@
+package containerbackend
+
+import (
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"a1ctf/src/utils/zaphelper"
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+	"github.com/spf13/viper"
+	"go.uber.org/zap"
+)
+
+// 所有题目容器和网络都带这个标签，值为 Pod 名
+const dockerPodLabel = "a1ctf.pod"
+
+// 容器在 Pod 里的序号，第一个容器持有网络和端口，其他容器共用它的网络命名空间
+const dockerContainerIndexLabel = "a1ctf.container-index"
+
+// dockerBackend 在单机 Docker 上模拟 Pod：每个 Pod 一个 bridge 网络，容器共用第一个容器的网络命名空间
+type dockerBackend struct {
+	client *dockerClient
+}
+
+func newDockerBackend() (*dockerBackend, error) {
+	host := viper.GetString("container-backend.docker.host")
+	if host == "" {
+		host = "unix:///var/run/docker.sock"
+	}
+
+	client, err := newDockerClient(host, viper.GetString("container-backend.docker.api-version"))
+	if err != nil {
+		return nil, err
+	}
+
+	return &dockerBackend{client: client}, nil
+}
+
+func (b *dockerBackend) Driver() string {
+	return DriverDocker
+}
+
+func (b *dockerBackend) Init() error {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	if err := b.client.do(ctx, http.MethodGet, "/_ping", nil, nil, nil); err != nil {
+		return fmt.Errorf("error connecting to docker: %v", err)
+	}
+
+	zaphelper.Logger.Info("Docker container backend connected")
+	return nil
+}
+
+func dockerContainerName(podName string, containerName string) string {
+	return podName + "-" + containerName
+}
+
+func (b *dockerBackend) CreatePod(podInfo *k8stool.PodInfo) error {
+	timeout := viper.GetDuration("container-backend.docker.create-timeout")
+	if timeout <= 0 {
+		timeout = 5 * time.Minute
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if len(podInfo.Containers) == 0 {
+		return fmt.Errorf("pod %s has no containers", podInfo.Name)
+	}
+
+	labels := make(map[string]string, len(podInfo.Labels)+1)
+	for key, value := range podInfo.Labels {
+		labels[key] = value
+	}
+	labels[dockerPodLabel] = podInfo.Name
+
+	networkOptions := map[string]string{}
+	if !podInfo.AllowWAN {
+		// 关闭 NAT 后容器访问不到外网，映射到宿主机的端口不受影响
+		networkOptions["com.docker.network.bridge.enable_ip_masquerade"] = "false"
+	}
+
+	if err := b.client.do(ctx, http.MethodPost, "/networks/create", nil, map[string]interface{}{
+		"Name":           podInfo.Name,
+		"Driver":         "bridge",
+		"CheckDuplicate": true,
+		"Labels":         labels,
+		"Options":        networkOptions,
+	}, nil); err != nil {
+		return fmt.Errorf("error creating network: %v", err)
+	}
+
+	if err := b.createContainers(ctx, podInfo, labels); err != nil {
+		_ = b.DeletePod(podInfo, nil)
+		return err
+	}
+
+	return nil
+}
+
+func (b *dockerBackend) createContainers(ctx context.Context, podInfo *k8stool.PodInfo, labels map[string]string) error {
+	mainContainer := k8stool.FirstMainContainer(podInfo.Containers)
+	if mainContainer == nil {
+		return fmt.Errorf("pod %s has no main containers", podInfo.Name)
+	}
+	firstName := dockerContainerName(podInfo.Name, mainContainer.Name)
+
+	// 所有容器共用第一个容器的网络，端口都映射在第一个容器上
+	exposedPorts := make(map[string]struct{})
+	portBindings := make(map[string][]dockerPortBinding)
+	for _, c := range podInfo.Containers {
+		for _, port := range c.ExposePorts {
+			key := fmt.Sprintf("%d/tcp", port.Port)
+			exposedPorts[key] = struct{}{}
+			portBindings[key] = []dockerPortBinding{{HostIP: viper.GetString("container-backend.docker.bind-address")}}
+		}
+	}
+
+	if err := b.createVolumes(ctx, podInfo, labels); err != nil {
+		return err
+	}
+
+	// init 容器按顺序运行完成后再启动其他容器
+	order := make([]int, 0, len(podInfo.Containers))
+	for index, c := range podInfo.Containers {
+		if c.Init {
+			order = append(order, index)
+		}
+	}
+	for index, c := range podInfo.Containers {
+		if !c.Init {
+			order = append(order, index)
+		}
+	}
+
+	for _, index := range order {
+		c := podInfo.Containers[index]
+		if err := b.ensureImage(ctx, c.Image); err != nil {
+			return err
+		}
+
+		env := make([]string, 0, len(c.Env)+1)
+		for _, envVar := range c.Env {
+			env = append(env, envVar.Name+"="+envVar.Value)
+		}
+		if podInfo.Flag != "" && (c.FlagConfig == nil || !c.FlagConfig.DisableEnv) {
+			env = append(env, "A1CTF_FLAG="+podInfo.Flag)
+		}
+
+		containerLabels := make(map[string]string, len(labels)+1)
+		for key, value := range labels {
+			containerLabels[key] = value
+		}
+		containerLabels[dockerContainerIndexLabel] = strconv.Itoa(index)
+
+		hostConfig := map[string]interface{}{
+			"RestartPolicy": map[string]interface{}{"Name": "on-failure", "MaximumRetryCount": 3},
+		}
+		if c.Init {
+			hostConfig["RestartPolicy"] = map[string]interface{}{"Name": "no"}
+		}
+		if c.CPULimit > 0 {
+			hostConfig["NanoCpus"] = c.CPULimit * 1000 * 1000
+		}
+		if c.MemoryLimit > 0 {
+			hostConfig["Memory"] = c.MemoryLimit * 1024 * 1024
+		}
+
+		config := map[string]interface{}{
+			"Image":  c.Image,
+			"Env":    env,
+			"Labels": containerLabels,
+		}
+		if len(c.Command) > 0 {
+			config["Entrypoint"] = c.Command
+		}
+
+		applyDockerContainerSpec(podInfo.Name, &c, config, hostConfig)
+
+		if c.Init {
+			// init 容器运行时第一个容器还没有启动，直接加入网络
+			hostConfig["NetworkMode"] = podInfo.Name
+		} else if c.Name == mainContainer.Name {
+			hostConfig["NetworkMode"] = podInfo.Name
+			hostConfig["PortBindings"] = portBindings
+			config["ExposedPorts"] = exposedPorts
+			if viper.GetBool("k8s.custom-dns-server.enabled") {
+				hostConfig["Dns"] = viper.GetStringSlice("k8s.custom-dns-server.nameservers")
+			}
+		} else {
+			hostConfig["NetworkMode"] = "container:" + firstName
+		}
+		config["HostConfig"] = hostConfig
+
+		name := dockerContainerName(podInfo.Name, c.Name)
+		if err := b.client.do(ctx, http.MethodPost, "/containers/create", url.Values{"name": []string{name}}, config, nil); err != nil {
+			return fmt.Errorf("error creating container %s: %v", name, err)
+		}
+
+		// Docker 没有 Secret，flag 文件在启动前写入容器
+		if podInfo.Flag != "" && c.FlagConfig != nil && c.FlagConfig.Path != "" {
+			var uid, gid int64
+			if c.FlagConfig.UID != nil {
+				uid = *c.FlagConfig.UID
+			}
+			if c.FlagConfig.GID != nil {
+				gid = *c.FlagConfig.GID
+			}
+			if err := b.client.putFile(ctx, name, c.FlagConfig.Path, []byte(podInfo.Flag), uid, gid, int64(c.FlagConfig.FileMode())); err != nil {
+				return fmt.Errorf("error writing flag into container %s: %v", name, err)
+			}
+		}
+
+		if err := b.client.do(ctx, http.MethodPost, "/containers/"+name+"/start", nil, nil, nil); err != nil {
+			return fmt.Errorf("error starting container %s: %v", name, err)
+		}
+
+		if c.Init {
+			var result struct {
+				StatusCode int `json:"StatusCode"`
+			}
+			if err := b.client.do(ctx, http.MethodPost, "/containers/"+name+"/wait", nil, nil, &result); err != nil {
+				return fmt.Errorf("error waiting for init container %s: %v", name, err)
+			}
+			if result.StatusCode != 0 {
+				return fmt.Errorf("init container %s exited with code %d", name, result.StatusCode)
+			}
+		}
+	}
+
+	return nil
+}
+
+func dockerVolumeName(podName string, volumeName string) string {
+	return podName + "-" + volumeName
+}
+
+// createVolumes 容器共享的卷，带 Pod 的标签，删除 Pod 时一起删除
+func (b *dockerBackend) createVolumes(ctx context.Context, podInfo *k8stool.PodInfo, labels map[string]string) error {
+	created := make(map[string]bool)
+	for _, c := range podInfo.Containers {
+		for _, volume := range c.Volumes {
+			name := dockerVolumeName(podInfo.Name, volume.Name)
+			if created[name] {
+				continue
+			}
+			created[name] = true
+
+			if err := b.client.do(ctx, http.MethodPost, "/volumes/create", nil, map[string]interface{}{
+				"Name":   name,
+				"Labels": labels,
+			}, nil); err != nil {
+				return fmt.Errorf("error creating volume %s: %v", name, err)
+			}
+		}
+	}
+	return nil
+}
+
+// applyDockerContainerSpec 卷、安全选项和就绪检查，Docker 只支持 exec 的就绪检查
+func applyDockerContainerSpec(podName string, c *k8stool.A1Container, config map[string]interface{}, hostConfig map[string]interface{}) {
+	if len(c.Volumes) > 0 {
+		mounts := make([]map[string]interface{}, 0, len(c.Volumes))
+		for _, volume := range c.Volumes {
+			mounts = append(mounts, map[string]interface{}{
+				"Type":     "volume",
+				"Source":   dockerVolumeName(podName, volume.Name),
+				"Target":   volume.MountPath,
+				"ReadOnly": volume.ReadOnly,
+			})
+		}
+		hostConfig["Mounts"] = mounts
+	}
+
+	if sc := c.SecurityContext; sc != nil {
+		hostConfig["ReadonlyRootfs"] = sc.ReadOnlyRootFilesystem
+		if len(sc.DropCapabilities) > 0 {
+			hostConfig["CapDrop"] = sc.DropCapabilities
+		}
+		if len(sc.AddCapabilities) > 0 {
+			hostConfig["CapAdd"] = sc.AddCapabilities
+		}
+
+		securityOpt := make([]string, 0)
+		if sc.Seccomp == k8stool.SeccompUnconfined {
+			securityOpt = append(securityOpt, "seccomp=unconfined")
+		}
+		if sc.AllowPrivilegeEscalation != nil && !*sc.AllowPrivilegeEscalation {
+			securityOpt = append(securityOpt, "no-new-privileges:true")
+		}
+		if len(securityOpt) > 0 {
+			hostConfig["SecurityOpt"] = securityOpt
+		}
+
+		if sc.RunAsUser != nil || sc.RunAsGroup != nil {
+			user := "0"
+			if sc.RunAsUser != nil {
+				user = strconv.FormatInt(*sc.RunAsUser, 10)
+			}
+			if sc.RunAsGroup != nil {
+				user += ":" + strconv.FormatInt(*sc.RunAsGroup, 10)
+			}
+			config["User"] = user
+		}
+	}
+
+	if probe := c.ReadinessProbe; probe != nil && probe.Type == k8stool.ProbeExec {
+		seconds := func(value int32, fallback int32) int64 {
+			if value <= 0 {
+				value = fallback
+			}
+			return int64(value) * int64(time.Second)
+		}
+		retries := probe.FailureThreshold
+		if retries <= 0 {
+			retries = 3
+		}
+		config["Healthcheck"] = map[string]interface{}{
+			"Test":        append([]string{"CMD"}, probe.Command...),
+			"Interval":    seconds(probe.PeriodSeconds, 10),
+			"Timeout":     seconds(probe.TimeoutSeconds, 1),
+			"Retries":     retries,
+			"StartPeriod": int64(probe.InitialDelaySeconds) * int64(time.Second),
+		}
+	}
+}
+
+func (b *dockerBackend) ensureImage(ctx context.Context, image string) error {
+	err := b.client.do(ctx, http.MethodGet, "/images/"+image+"/json", nil, nil, nil)
+	if err == nil {
+		return nil
+	}
+	if !isDockerNotFound(err) {
+		return fmt.Errorf("error inspecting image %s: %v", image, err)
+	}
+
+	return b.client.pullImage(ctx, image)
+}
+
+func (b *dockerBackend) listPodContainers(ctx context.Context, podName string) ([]dockerContainerSummary, error) {
+	query := labelFilters(map[string][]string{"label": {dockerPodLabel + "=" + podName}})
+	query.Set("all", "true")
+
+	var containers []dockerContainerSummary
+	if err := b.client.do(ctx, http.MethodGet, "/containers/json", query, nil, &containers); err != nil {
+		return nil, err
+	}
+
+	// 先删除共用网络的容器
+	sort.Slice(containers, func(i, j int) bool {
+		return containers[i].Labels[dockerContainerIndexLabel] > containers[j].Labels[dockerContainerIndexLabel]
+	})
+
+	return containers, nil
+}
+
+func (b *dockerBackend) DeletePod(podInfo *k8stool.PodInfo, ports []int32) error {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	containers, err := b.listPodContainers(ctx, podInfo.Name)
+	if err != nil {
+		return fmt.Errorf("error listing containers: %v", err)
+	}
+
+	for _, container := range containers {
+		err := b.client.do(ctx, http.MethodDelete, "/containers/"+container.ID, url.Values{"force": []string{"true"}, "v": []string{"true"}}, nil, nil)
+		if err != nil && !isDockerNotFound(err) {
+			return fmt.Errorf("error deleting container %s: %v", container.ID, err)
+		}
+	}
+
+	err = b.client.do(ctx, http.MethodDelete, "/networks/"+podInfo.Name, nil, nil, nil)
+	if err != nil && !isDockerNotFound(err) {
+		return fmt.Errorf("error deleting network: %v", err)
+	}
+
+	var volumes struct {
+		Volumes []struct {
+			Name string `json:"Name"`
+		} `json:"Volumes"`
+	}
+	query := labelFilters(map[string][]string{"label": {dockerPodLabel + "=" + podInfo.Name}})
+	if err := b.client.do(ctx, http.MethodGet, "/volumes", query, nil, &volumes); err != nil {
+		return fmt.Errorf("error listing volumes: %v", err)
+	}
+	for _, volume := range volumes.Volumes {
+		err := b.client.do(ctx, http.MethodDelete, "/volumes/"+volume.Name, nil, nil, nil)
+		if err != nil && !isDockerNotFound(err) {
+			return fmt.Errorf("error deleting volume %s: %v", volume.Name, err)
+		}
+	}
+
+	return nil
+}
+
+func (b *dockerBackend) PodStatus(inGameID int64, teamHash string) (*k8stool.PodStatusDecision, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	containers, err := b.listPodContainers(ctx, fmt.Sprintf("cl-%d-%s", inGameID, teamHash))
+	if err != nil {
+		return nil, err
+	}
+	if len(containers) == 0 {
+		return nil, nil
+	}
+
+	for _, container := range containers {
+		switch container.State {
+		case "running":
+			// 就绪检查还没有通过
+			if strings.Contains(container.Status, "(health: starting)") || strings.Contains(container.Status, "(unhealthy)") {
+				return &k8stool.PodStatusDecision{
+					Status:         k8stool.CustomPodWaiting,
+					ShouldContinue: true,
+					ShouldReport:   false,
+					Message:        fmt.Sprintf("Waiting for container to be ready (current status: %s)", container.Status),
+				}, nil
+			}
+			continue
+		case "exited", "dead":
+			var inspect dockerContainerInspect
+			if err := b.client.do(ctx, http.MethodGet, "/containers/"+container.ID+"/json", nil, nil, &inspect); err != nil {
+				return nil, err
+			}
+			// 正常退出的容器和 k8s 的 Succeeded 一样看作运行中
+			if inspect.State.ExitCode == 0 && !inspect.State.OOMKilled {
+				continue
+			}
+			message := fmt.Sprintf("Container exited with code %d", inspect.State.ExitCode)
+			if inspect.State.OOMKilled {
+				message = "Container was OOM killed"
+			}
+			if inspect.State.Error != "" {
+				message += ": " + inspect.State.Error
+			}
+			return &k8stool.PodStatusDecision{
+				Status:         k8stool.CustomPodFailed,
+				ShouldContinue: false,
+				ShouldReport:   true,
+				Message:        message,
+			}, nil
+		default:
+			return &k8stool.PodStatusDecision{
+				Status:         k8stool.CustomPodWaiting,
+				ShouldContinue: true,
+				ShouldReport:   false,
+				Message:        fmt.Sprintf("Waiting for container to start (current state: %s)", container.State),
+			}, nil
+		}
+	}
+
+	return &k8stool.PodStatusDecision{
+		Status:         k8stool.CustomPodRunning,
+		ShouldContinue: false,
+		ShouldReport:   false,
+		Message:        "Pod is running successfully",
+	}, nil
+}
+
+func (b *dockerBackend) PodPorts(podInfo *k8stool.PodInfo) (*k8stool.PodPorts, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	mainContainer := k8stool.FirstMainContainer(podInfo.Containers)
+	if mainContainer == nil {
+		return nil, fmt.Errorf("pod %s has no containers", podInfo.Name)
+	}
+
+	var inspect dockerContainerInspect
+	if err := b.client.do(ctx, http.MethodGet, "/containers/"+dockerContainerName(podInfo.Name, mainContainer.Name)+"/json", nil, nil, &inspect); err != nil {
+		return nil, fmt.Errorf("error inspecting container: %v", err)
+	}
+
+	// 和 k8s 的节点名一样，通过 k8s.node-ip-map 换成选手访问的地址
+	nodeName := viper.GetString("container-backend.docker.node-name")
+	if nodeName == "" {
+		nodeName = "localhost"
+	}
+
+	result := make(k8stool.PodPorts, 0)
+	for index, c := range podInfo.Containers {
+		for _, port := range c.ExposePorts {
+			bindings := inspect.NetworkSettings.Ports[fmt.Sprintf("%d/tcp", port.Port)]
+			if len(bindings) == 0 {
+				return nil, fmt.Errorf("port %d of pod %s is not published", port.Port, podInfo.Name)
+			}
+
+			hostPort, err := strconv.ParseInt(bindings[0].HostPort, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("invalid host port %s: %v", bindings[0].HostPort, err)
+			}
+
+			result = append(result, k8stool.PodPort{
+				Name:     fmt.Sprintf("%d-%s", index, port.Name),
+				Port:     port.Port,
+				NodePort: int32(hostPort),
+				NodeName: nodeName,
+			})
+		}
+	}
+
+	return &result, nil
+}
+
+func (b *dockerBackend) Exec(ctx context.Context, podName string, containerName string, command []string, stdin io.Reader) (string, error) {
+	return b.client.exec(ctx, dockerContainerName(podName, containerName), command, stdin)
+}
+
+func (b *dockerBackend) PodCPUUsage(podInfo *k8stool.PodInfo) (int64, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	containers, err := b.listPodContainers(ctx, podInfo.Name)
+	if err != nil {
+		return 0, fmt.Errorf("error listing containers: %v", err)
+	}
+
+	var usage int64
+	for _, container := range containers {
+		if container.State != "running" {
+			continue
+		}
+
+		var stats dockerContainerStats
+		if err := b.client.do(ctx, http.MethodGet, "/containers/"+container.ID+"/stats", url.Values{"stream": []string{"false"}}, nil, &stats); err != nil {
+			return 0, fmt.Errorf("error getting container stats: %v", err)
+		}
+
+		// 和 docker stats 的算法一样，按两次采样之间占用的 CPU 时间比例换算
+		cpuDelta := float64(stats.CPUStats.CPUUsage.TotalUsage) - float64(stats.PreCPUStats.CPUUsage.TotalUsage)
+		systemDelta := float64(stats.CPUStats.SystemCPUUsage) - float64(stats.PreCPUStats.SystemCPUUsage)
+		if cpuDelta <= 0 || systemDelta <= 0 {
+			continue
+		}
+		onlineCPUs := stats.CPUStats.OnlineCPUs
+		if onlineCPUs == 0 {
+			onlineCPUs = 1
+		}
+		usage += int64(cpuDelta / systemDelta * float64(onlineCPUs) * 1000)
+	}
+
+	return usage, nil
+}
+
+// Watch 订阅容器事件，另外定期全量同步一次，防止事件流断开时漏掉变化
+func (b *dockerBackend) Watch(ctx context.Context, notify func(inGameID int64, teamHash string)) error {
+	notifyLabels := func(labels map[string]string) {
+		teamHash, exists1 := labels["team_hash"]
+		inGameID, exists2 := labels["ingame_id"]
+		if !exists1 || !exists2 {
+			return
+		}
+
+		inGameIDInt, err := strconv.ParseInt(inGameID, 10, 64)
+		if err != nil {
+			return
+		}
+
+		notify(inGameIDInt, teamHash)
+	}
+
+	resyncAll := func() error {
+		listCtx, cancel := context.WithTimeout(ctx, 30*time.Second)
+		defer cancel()
+
+		query := labelFilters(map[string][]string{"label": {dockerPodLabel}})
+		query.Set("all", "true")
+
+		var containers []dockerContainerSummary
+		if err := b.client.do(listCtx, http.MethodGet, "/containers/json", query, nil, &containers); err != nil {
+			return err
+		}
+		for _, container := range containers {
+			notifyLabels(container.Labels)
+		}
+		return nil
+	}
+
+	if err := resyncAll(); err != nil {
+		return fmt.Errorf("error listing containers: %v", err)
+	}
+
+	resync := viper.GetDuration("container-backend.docker.resync")
+	if resync <= 0 {
+		resync = 30 * time.Second
+	}
+
+	go func() {
+		ticker := time.NewTicker(resync)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if err := resyncAll(); err != nil {
+					zaphelper.Logger.Warn("Failed to resync docker containers", zap.Error(err))
+				}
+			}
+		}
+	}()
+
+	go func() {
+		for {
+			if err := b.watchEvents(ctx, notifyLabels); err != nil && ctx.Err() == nil {
+				zaphelper.Logger.Warn("Docker event stream closed, reconnecting", zap.Error(err))
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-time.After(5 * time.Second):
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (b *dockerBackend) watchEvents(ctx context.Context, notifyLabels func(labels map[string]string)) error {
+	query := labelFilters(map[string][]string{"type": {"container"}, "label": {dockerPodLabel}})
+
+	resp, err := b.client.request(ctx, http.MethodGet, "/events", query, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var event dockerEvent
+		if err := sonic.Unmarshal(scanner.Bytes(), &event); err != nil {
+			continue
+		}
+		// 容器的标签会出现在事件的 Attributes 里
+		notifyLabels(event.Actor.Attributes)
+	}
+
+	return scanner.Err()
+}
+
+func (b *dockerBackend) Resources() ([]Resource, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+	defer cancel()
+
+	query := labelFilters(map[string][]string{"label": {dockerPodLabel}})
+
+	var networks []dockerNetworkSummary
+	if err := b.client.do(ctx, http.MethodGet, "/networks", query, nil, &networks); err != nil {
+		return nil, err
+	}
+
+	query.Set("all", "true")
+	var containers []dockerContainerSummary
+	if err := b.client.do(ctx, http.MethodGet, "/containers/json", query, nil, &containers); err != nil {
+		return nil, err
+	}
+
+	result := make([]Resource, 0, len(networks)+len(containers))
+	for _, network := range networks {
+		result = append(result, Resource{Name: network.Name, CreateTime: network.Created})
+	}
+	for _, container := range containers {
+		result = append(result, Resource{Name: container.Labels[dockerPodLabel], CreateTime: time.Unix(container.Created, 0)})
+	}
+
+	return result, nil
+}
+
+func (b *dockerBackend) DeleteOrphan(name string) error {
+	return b.DeletePod(&k8stool.PodInfo{Name: name}, nil)
+}
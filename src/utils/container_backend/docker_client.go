@@ -0,0 +1,350 @@
+package containerbackend
+
+import (
+	"archive/tar"
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/bytedance/sonic"
+)
+
+// dockerClient 直接调用 Docker Engine API，只实现题目容器需要的接口
+type dockerClient struct {
+	network    string
+	address    string
+	apiVersion string
+	httpClient *http.Client
+}
+
+type dockerAPIError struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *dockerAPIError) Error() string {
+	return fmt.Sprintf("docker api returned status %d: %s", e.StatusCode, e.Message)
+}
+
+func isDockerNotFound(err error) bool {
+	var apiErr *dockerAPIError
+	return errors.As(err, &apiErr) && apiErr.StatusCode == http.StatusNotFound
+}
+
+// host 形如 unix:///var/run/docker.sock 或 tcp://127.0.0.1:2375
+func newDockerClient(host string, apiVersion string) (*dockerClient, error) {
+	u, err := url.Parse(host)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker host %s: %v", host, err)
+	}
+
+	client := &dockerClient{apiVersion: apiVersion}
+	switch u.Scheme {
+	case "unix":
+		client.network = "unix"
+		client.address = u.Path
+	case "tcp":
+		client.network = "tcp"
+		client.address = u.Host
+	default:
+		return nil, fmt.Errorf("unsupported docker host scheme %s", u.Scheme)
+	}
+
+	client.httpClient = &http.Client{
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return client.dial(ctx)
+			},
+		},
+	}
+
+	return client, nil
+}
+
+func (d *dockerClient) dial(ctx context.Context) (net.Conn, error) {
+	var dialer net.Dialer
+	return dialer.DialContext(ctx, d.network, d.address)
+}
+
+func (d *dockerClient) path(path string, query url.Values) string {
+	result := path
+	if d.apiVersion != "" {
+		result = "/v" + d.apiVersion + path
+	}
+	if len(query) > 0 {
+		result += "?" + query.Encode()
+	}
+	return result
+}
+
+func (d *dockerClient) request(ctx context.Context, method string, path string, query url.Values, body interface{}) (*http.Response, error) {
+	// io.Reader 的请求体是 tar 包，其他的编码为 JSON
+	var reader io.Reader
+	contentType := "application/json"
+	if archive, ok := body.(io.Reader); ok {
+		reader = archive
+		contentType = "application/x-tar"
+	} else if body != nil {
+		data, err := sonic.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, "http://docker"+d.path(path, query), reader)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", contentType)
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode >= 400 {
+		defer resp.Body.Close()
+		var apiErr struct {
+			Message string `json:"message"`
+		}
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		if sonic.Unmarshal(data, &apiErr) != nil || apiErr.Message == "" {
+			apiErr.Message = strings.TrimSpace(string(data))
+		}
+		return nil, &dockerAPIError{StatusCode: resp.StatusCode, Message: apiErr.Message}
+	}
+
+	return resp, nil
+}
+
+// do 发送请求，out 不为空时解析返回的 JSON
+func (d *dockerClient) do(ctx context.Context, method string, path string, query url.Values, body interface{}, out interface{}) error {
+	resp, err := d.request(ctx, method, path, query, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if out == nil {
+		_, _ = io.Copy(io.Discard, resp.Body)
+		return nil
+	}
+
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	return sonic.Unmarshal(data, out)
+}
+
+// labelFilters 生成按标签过滤的 filters 参数
+func labelFilters(filters map[string][]string) url.Values {
+	data, _ := sonic.Marshal(filters)
+	return url.Values{"filters": []string{string(data)}}
+}
+
+type dockerContainerSummary struct {
+	ID      string            `json:"Id"`
+	Names   []string          `json:"Names"`
+	State   string            `json:"State"`
+	Status  string            `json:"Status"`
+	Labels  map[string]string `json:"Labels"`
+	Created int64             `json:"Created"`
+}
+
+type dockerPortBinding struct {
+	HostIP   string `json:"HostIp"`
+	HostPort string `json:"HostPort"`
+}
+
+type dockerContainerInspect struct {
+	ID    string `json:"Id"`
+	State struct {
+		Status    string `json:"Status"`
+		ExitCode  int    `json:"ExitCode"`
+		OOMKilled bool   `json:"OOMKilled"`
+		Error     string `json:"Error"`
+	} `json:"State"`
+	NetworkSettings struct {
+		Ports map[string][]dockerPortBinding `json:"Ports"`
+	} `json:"NetworkSettings"`
+}
+
+type dockerCPUStats struct {
+	CPUUsage struct {
+		TotalUsage uint64 `json:"total_usage"`
+	} `json:"cpu_usage"`
+	SystemCPUUsage uint64 `json:"system_cpu_usage"`
+	OnlineCPUs     uint64 `json:"online_cpus"`
+}
+
+// stream=false 时 Docker 会等待两次采样，PreCPUStats 为上一次的值
+type dockerContainerStats struct {
+	CPUStats    dockerCPUStats `json:"cpu_stats"`
+	PreCPUStats dockerCPUStats `json:"precpu_stats"`
+}
+
+type dockerNetworkSummary struct {
+	Name    string            `json:"Name"`
+	Created time.Time         `json:"Created"`
+	Labels  map[string]string `json:"Labels"`
+}
+
+type dockerEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+	Actor  struct {
+		ID         string            `json:"ID"`
+		Attributes map[string]string `json:"Attributes"`
+	} `json:"Actor"`
+}
+
+// pullImage 拉取镜像，需要读完返回的进度流才算完成
+// putFile 在容器启动前写入一个文件，缺少的目录由 Docker 创建
+func (d *dockerClient) putFile(ctx context.Context, containerName string, filePath string, content []byte, uid int64, gid int64, mode int64) error {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	if err := tw.WriteHeader(&tar.Header{
+		Name:    strings.TrimPrefix(filePath, "/"),
+		Mode:    mode,
+		Uid:     int(uid),
+		Gid:     int(gid),
+		Size:    int64(len(content)),
+		ModTime: time.Now(),
+	}); err != nil {
+		return err
+	}
+	if _, err := tw.Write(content); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+
+	return d.do(ctx, http.MethodPut, "/containers/"+containerName+"/archive", url.Values{"path": []string{"/"}}, &buf, nil)
+}
+
+func (d *dockerClient) pullImage(ctx context.Context, image string) error {
+	resp, err := d.request(ctx, http.MethodPost, "/images/create", url.Values{"fromImage": []string{image}}, nil)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var progress struct {
+			Error string `json:"error"`
+		}
+		if sonic.Unmarshal(scanner.Bytes(), &progress) == nil && progress.Error != "" {
+			return fmt.Errorf("error pulling image %s: %s", image, progress.Error)
+		}
+	}
+
+	return scanner.Err()
+}
+
+// exec 在容器里执行命令，需要劫持连接才能写入标准输入
+func (d *dockerClient) exec(ctx context.Context, containerName string, command []string, stdin io.Reader) (string, error) {
+	var created struct {
+		ID string `json:"Id"`
+	}
+	if err := d.do(ctx, http.MethodPost, "/containers/"+containerName+"/exec", nil, map[string]interface{}{
+		"AttachStdin":  stdin != nil,
+		"AttachStdout": true,
+		"AttachStderr": true,
+		"Tty":          false,
+		"Cmd":          command,
+	}, &created); err != nil {
+		return "", err
+	}
+
+	conn, err := d.dial(ctx)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		_ = conn.SetDeadline(deadline)
+	}
+
+	body := []byte(`{"Detach":false,"Tty":false}`)
+	req, err := http.NewRequest(http.MethodPost, "http://docker"+d.path("/exec/"+created.ID+"/start", nil), bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Upgrade", "tcp")
+
+	if err := req.Write(conn); err != nil {
+		return "", err
+	}
+
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols && resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		resp.Body.Close()
+		return "", &dockerAPIError{StatusCode: resp.StatusCode, Message: strings.TrimSpace(string(data))}
+	}
+
+	if stdin != nil {
+		go func() {
+			_, _ = io.Copy(conn, stdin)
+			if closer, ok := conn.(interface{ CloseWrite() error }); ok {
+				_ = closer.CloseWrite()
+			}
+		}()
+	}
+
+	// 没有 TTY 时输出按 8 字节头分帧，第一个字节区分 stdout 和 stderr
+	var stdout, stderr bytes.Buffer
+	header := make([]byte, 8)
+	for {
+		if _, err := io.ReadFull(reader, header); err != nil {
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			return stdout.String(), err
+		}
+
+		size := int64(binary.BigEndian.Uint32(header[4:]))
+		target := &stdout
+		if header[0] == 2 {
+			target = &stderr
+		}
+		if _, err := io.CopyN(target, reader, size); err != nil {
+			return stdout.String(), err
+		}
+	}
+
+	var inspect struct {
+		ExitCode int `json:"ExitCode"`
+	}
+	if err := d.do(ctx, http.MethodGet, "/exec/"+created.ID+"/json", nil, nil, &inspect); err != nil {
+		return stdout.String(), err
+	}
+	if inspect.ExitCode != 0 {
+		return stdout.String(), fmt.Errorf("error executing %v in container %s: exit code %d: %s", command, containerName, inspect.ExitCode, stderr.String())
+	}
+
+	return stdout.String(), nil
+}
@@ -0,0 +1,337 @@
+package containerbackend
+
+import (
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strconv"
+	"sync"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// FakePod FakeBackend 里的 Pod
+type FakePod struct {
+	Info       k8stool.PodInfo
+	Status     k8stool.PodStatusDecision
+	Ports      k8stool.PodPorts
+	CreateTime time.Time
+	// CPUUsage PodCPUUsage 返回的值，单位 millicore
+	CPUUsage int64
+	// ResourceVersion 每次修改加一，认领预热的 Pod 时用来检测冲突
+	ResourceVersion int64
+}
+
+// FakeBackend 内存里的后端，不运行任何容器，用来在没有集群的环境里测试容器的生命周期
+type FakeBackend struct {
+	mu       sync.Mutex
+	pods     map[string]*FakePod
+	nextPort int32
+	notify   func(inGameID int64, teamHash string)
+
+	// StartPending 为 true 时新建的 Pod 保持等待状态，需要用 SetPodStatus 推进
+	StartPending bool
+	// ExecHandler 处理 Exec 调用，为空时返回空输出
+	ExecHandler func(podName string, containerName string, command []string, stdin string) (string, error)
+}
+
+func NewFakeBackend() *FakeBackend {
+	return &FakeBackend{
+		pods:     make(map[string]*FakePod),
+		nextPort: 30000,
+	}
+}
+
+func (b *FakeBackend) Driver() string {
+	return DriverFake
+}
+
+func (b *FakeBackend) Init() error {
+	return nil
+}
+
+func (b *FakeBackend) CreatePod(podInfo *k8stool.PodInfo) error {
+	b.mu.Lock()
+
+	if _, exists := b.pods[podInfo.Name]; exists {
+		b.mu.Unlock()
+		return fmt.Errorf("pod %s already exists", podInfo.Name)
+	}
+
+	pod := &FakePod{
+		Info:            *podInfo,
+		Ports:           make(k8stool.PodPorts, 0),
+		CreateTime:      time.Now(),
+		ResourceVersion: 1,
+		Status: k8stool.PodStatusDecision{
+			Status:  k8stool.CustomPodRunning,
+			Message: "Pod is running successfully",
+		},
+	}
+	// 认领时会修改标签，不能和调用方共用
+	pod.Info.Labels = maps.Clone(podInfo.Labels)
+	if b.StartPending {
+		pod.Status = k8stool.PodStatusDecision{
+			Status:         k8stool.CustomPodWaiting,
+			ShouldContinue: true,
+			Message:        "Waiting for pod to stabilize",
+		}
+	}
+
+	for index, c := range podInfo.Containers {
+		for _, port := range c.ExposePorts {
+			pod.Ports = append(pod.Ports, k8stool.PodPort{
+				Name:     fmt.Sprintf("%d-%s", index, port.Name),
+				Port:     port.Port,
+				NodePort: b.nextPort,
+				NodeName: "fake",
+			})
+			b.nextPort++
+		}
+	}
+
+	b.pods[podInfo.Name] = pod
+	b.mu.Unlock()
+
+	b.notifyPod(podInfo)
+	return nil
+}
+
+func (b *FakeBackend) DeletePod(podInfo *k8stool.PodInfo, ports []int32) error {
+	b.mu.Lock()
+	pod, exists := b.pods[podInfo.Name]
+	delete(b.pods, podInfo.Name)
+	b.mu.Unlock()
+
+	if exists {
+		b.notifyPod(&pod.Info)
+	}
+	return nil
+}
+
+func (b *FakeBackend) PodStatus(inGameID int64, teamHash string) (*k8stool.PodStatusDecision, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pod, exists := b.pods[fmt.Sprintf("cl-%d-%s", inGameID, teamHash)]
+	if !exists {
+		return nil, nil
+	}
+
+	status := pod.Status
+	return &status, nil
+}
+
+func (b *FakeBackend) PodPorts(podInfo *k8stool.PodInfo) (*k8stool.PodPorts, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pod, exists := b.pods[podInfo.Name]
+	if !exists {
+		return nil, fmt.Errorf("pod %s not found", podInfo.Name)
+	}
+
+	ports := make(k8stool.PodPorts, len(pod.Ports))
+	copy(ports, pod.Ports)
+	return &ports, nil
+}
+
+func (b *FakeBackend) Exec(ctx context.Context, podName string, containerName string, command []string, stdin io.Reader) (string, error) {
+	b.mu.Lock()
+	_, exists := b.pods[podName]
+	handler := b.ExecHandler
+	b.mu.Unlock()
+
+	if !exists {
+		return "", fmt.Errorf("pod %s not found", podName)
+	}
+
+	input := ""
+	if stdin != nil {
+		data, err := io.ReadAll(stdin)
+		if err != nil {
+			return "", err
+		}
+		input = string(data)
+	}
+
+	if handler == nil {
+		return "", nil
+	}
+	return handler(podName, containerName, command, input)
+}
+
+func (b *FakeBackend) PodCPUUsage(podInfo *k8stool.PodInfo) (int64, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pod, exists := b.pods[podInfo.Name]
+	if !exists {
+		return 0, fmt.Errorf("pod %s not found", podInfo.Name)
+	}
+	return pod.CPUUsage, nil
+}
+
+func (b *FakeBackend) Watch(ctx context.Context, notify func(inGameID int64, teamHash string)) error {
+	b.mu.Lock()
+	b.notify = notify
+	pods := make([]k8stool.PodInfo, 0, len(b.pods))
+	for _, pod := range b.pods {
+		pods = append(pods, pod.Info)
+	}
+	b.mu.Unlock()
+
+	for idx := range pods {
+		b.notifyPod(&pods[idx])
+	}
+	return nil
+}
+
+func (b *FakeBackend) Resources() ([]Resource, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := make([]Resource, 0, len(b.pods))
+	for name, pod := range b.pods {
+		result = append(result, Resource{Name: name, CreateTime: pod.CreateTime})
+	}
+	return result, nil
+}
+
+func (b *FakeBackend) DeleteOrphan(name string) error {
+	return b.DeletePod(&k8stool.PodInfo{Name: name}, nil)
+}
+
+// SetPodStatus 修改 Pod 的状态，模拟启动完成、崩溃等情况
+func (b *FakeBackend) SetPodStatus(name string, status k8stool.PodStatusDecision) error {
+	b.mu.Lock()
+	pod, exists := b.pods[name]
+	if !exists {
+		b.mu.Unlock()
+		return fmt.Errorf("pod %s not found", name)
+	}
+	pod.Status = status
+	pod.ResourceVersion++
+	info := pod.Info
+	b.mu.Unlock()
+
+	b.notifyPod(&info)
+	return nil
+}
+
+// SetPodCPUUsage 修改 Pod 的 CPU 占用，模拟空闲和活跃的容器
+func (b *FakeBackend) SetPodCPUUsage(name string, usage int64) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pod, exists := b.pods[name]
+	if !exists {
+		return fmt.Errorf("pod %s not found", name)
+	}
+	pod.CPUUsage = usage
+	return nil
+}
+
+// ClaimWarmPod 和 kubernetes 一样先从快照里挑选 Pod，再按 resourceVersion 更新标签，快照过期时更新冲突
+// 预热池的 Pod 由调用方用 CreatePod 创建，带上 k8stool.WarmPoolLabel 标签
+func (b *FakeBackend) ClaimWarmPod(pool string, teamLabels map[string]string) (string, error) {
+	b.mu.Lock()
+	candidates := make([]*corev1.Pod, 0)
+	for _, pod := range b.pods {
+		if pod.Info.Labels[k8stool.WarmPoolLabel] == pool {
+			candidates = append(candidates, pod.kubernetesPod())
+		}
+	}
+	b.mu.Unlock()
+
+	return k8stool.ClaimReadyPod(candidates, teamLabels, b.updatePodLabels)
+}
+
+func (b *FakeBackend) updatePodLabels(update *corev1.Pod) error {
+	b.mu.Lock()
+	pod, exists := b.pods[update.Name]
+	if !exists {
+		b.mu.Unlock()
+		return apierrors.NewNotFound(corev1.Resource("pods"), update.Name)
+	}
+	if strconv.FormatInt(pod.ResourceVersion, 10) != update.ResourceVersion {
+		b.mu.Unlock()
+		return apierrors.NewConflict(corev1.Resource("pods"), update.Name, errors.New("the object has been modified"))
+	}
+	pod.Info.Labels = maps.Clone(update.Labels)
+	pod.ResourceVersion++
+	info := pod.Info
+	b.mu.Unlock()
+
+	b.notifyPod(&info)
+	return nil
+}
+
+// 转换成 kubernetes 的 Pod，只填认领需要的字段
+func (p *FakePod) kubernetesPod() *corev1.Pod {
+	ready := p.Status.Status == k8stool.CustomPodRunning
+	phase := corev1.PodPending
+	if ready {
+		phase = corev1.PodRunning
+	}
+
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:              p.Info.Name,
+			Labels:            maps.Clone(p.Info.Labels),
+			ResourceVersion:   strconv.FormatInt(p.ResourceVersion, 10),
+			CreationTimestamp: metav1.NewTime(p.CreateTime),
+		},
+		Status: corev1.PodStatus{
+			Phase:             phase,
+			ContainerStatuses: []corev1.ContainerStatus{{Ready: ready}},
+		},
+	}
+}
+
+// SetPodCreateTime 修改 Pod 的创建时间，模拟已经存在一段时间的 Pod
+func (b *FakeBackend) SetPodCreateTime(name string, createTime time.Time) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	pod, exists := b.pods[name]
+	if !exists {
+		return fmt.Errorf("pod %s not found", name)
+	}
+	pod.CreateTime = createTime
+	return nil
+}
+
+// Pods 当前所有 Pod 的快照
+func (b *FakeBackend) Pods() []FakePod {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := make([]FakePod, 0, len(b.pods))
+	for _, pod := range b.pods {
+		result = append(result, *pod)
+	}
+	return result
+}
+
+func (b *FakeBackend) notifyPod(podInfo *k8stool.PodInfo) {
+	b.mu.Lock()
+	notify := b.notify
+	b.mu.Unlock()
+
+	if notify == nil {
+		return
+	}
+
+	var inGameID int64
+	if _, err := fmt.Sscanf(podInfo.Labels["ingame_id"], "%d", &inGameID); err != nil {
+		return
+	}
+	notify(inGameID, podInfo.Labels["team_hash"])
+}
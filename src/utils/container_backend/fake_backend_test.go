@@ -0,0 +1,333 @@
+package containerbackend
+
+import (
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+)
+
+func newTestPodInfo(inGameID string, teamHash string) *k8stool.PodInfo {
+	return &k8stool.PodInfo{
+		Name:     "cl-" + inGameID + "-" + teamHash,
+		TeamHash: teamHash,
+		Labels: map[string]string{
+			"ingame_id": inGameID,
+			"team_hash": teamHash,
+		},
+		Containers: []k8stool.A1Container{
+			{
+				Name:  "web",
+				Image: "nginx",
+				ExposePorts: []k8stool.PortName{
+					{Name: "http", Port: 80},
+					{Name: "ssh", Port: 22},
+				},
+			},
+			{
+				Name:        "db",
+				Image:       "mysql",
+				ExposePorts: []k8stool.PortName{{Name: "mysql", Port: 3306}},
+			},
+		},
+		Flag: "flag{fake}",
+	}
+}
+
+type notifyRecorder struct {
+	mu     sync.Mutex
+	events []string
+}
+
+func (r *notifyRecorder) notify(inGameID int64, teamHash string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.events = append(r.events, teamHash)
+}
+
+func (r *notifyRecorder) count() int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return len(r.events)
+}
+
+func TestFakeBackendLifecycle(t *testing.T) {
+	tests := []struct {
+		name         string
+		startPending bool
+		wantStatus   k8stool.CustomPodStatus
+	}{
+		{name: "running immediately", wantStatus: k8stool.CustomPodRunning},
+		{name: "pending until advanced", startPending: true, wantStatus: k8stool.CustomPodWaiting},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			backend := NewFakeBackend()
+			backend.StartPending = tt.startPending
+
+			var execCommands []string
+			backend.ExecHandler = func(podName string, containerName string, command []string, stdin string) (string, error) {
+				execCommands = append(execCommands, containerName+":"+strings.Join(command, " "))
+				return strings.ToUpper(stdin), nil
+			}
+
+			recorder := &notifyRecorder{}
+			if err := backend.Watch(context.Background(), recorder.notify); err != nil {
+				t.Fatalf("Watch() error = %v", err)
+			}
+
+			podInfo := newTestPodInfo("42", "abcdef")
+
+			// 创建
+			if err := backend.CreatePod(podInfo); err != nil {
+				t.Fatalf("CreatePod() error = %v", err)
+			}
+			if err := backend.CreatePod(podInfo); err == nil {
+				t.Fatalf("CreatePod() on an existing pod should fail")
+			}
+			if recorder.count() != 1 {
+				t.Errorf("notify called %d times after create, want 1", recorder.count())
+			}
+
+			// 状态
+			status, err := backend.PodStatus(42, "abcdef")
+			if err != nil || status == nil {
+				t.Fatalf("PodStatus() = %v, %v, want a status", status, err)
+			}
+			if status.Status != tt.wantStatus {
+				t.Errorf("PodStatus() = %s, want %s", status.Status, tt.wantStatus)
+			}
+
+			if tt.startPending {
+				if err := backend.SetPodStatus(podInfo.Name, k8stool.PodStatusDecision{Status: k8stool.CustomPodRunning}); err != nil {
+					t.Fatalf("SetPodStatus() error = %v", err)
+				}
+				status, _ = backend.PodStatus(42, "abcdef")
+				if status.Status != k8stool.CustomPodRunning {
+					t.Errorf("PodStatus() after SetPodStatus = %s, want %s", status.Status, k8stool.CustomPodRunning)
+				}
+				if recorder.count() != 2 {
+					t.Errorf("notify called %d times after status change, want 2", recorder.count())
+				}
+			}
+
+			// 端口
+			ports, err := backend.PodPorts(podInfo)
+			if err != nil {
+				t.Fatalf("PodPorts() error = %v", err)
+			}
+			wantPorts := map[string]int32{"0-http": 80, "0-ssh": 22, "1-mysql": 3306}
+			if len(*ports) != len(wantPorts) {
+				t.Fatalf("PodPorts() returned %d ports, want %d", len(*ports), len(wantPorts))
+			}
+			nodePorts := make(map[int32]struct{})
+			for _, port := range *ports {
+				if wantPorts[port.Name] != port.Port {
+					t.Errorf("port %s = %d, want %d", port.Name, port.Port, wantPorts[port.Name])
+				}
+				if _, duplicated := nodePorts[port.NodePort]; duplicated {
+					t.Errorf("node port %d assigned twice", port.NodePort)
+				}
+				nodePorts[port.NodePort] = struct{}{}
+			}
+
+			// 执行命令
+			output, err := backend.Exec(context.Background(), podInfo.Name, "web", []string{"cat"}, strings.NewReader("flag{fake}"))
+			if err != nil {
+				t.Fatalf("Exec() error = %v", err)
+			}
+			if output != "FLAG{FAKE}" {
+				t.Errorf("Exec() = %q, want %q", output, "FLAG{FAKE}")
+			}
+			if len(execCommands) != 1 || execCommands[0] != "web:cat" {
+				t.Errorf("exec handler saw %v, want [web:cat]", execCommands)
+			}
+
+			resources, err := backend.Resources()
+			if err != nil || len(resources) != 1 || resources[0].Name != podInfo.Name {
+				t.Errorf("Resources() = %v, %v, want [%s]", resources, err, podInfo.Name)
+			}
+
+			// 删除
+			notified := recorder.count()
+			if err := backend.DeletePod(podInfo, nil); err != nil {
+				t.Fatalf("DeletePod() error = %v", err)
+			}
+			if recorder.count() != notified+1 {
+				t.Errorf("notify called %d times after delete, want %d", recorder.count(), notified+1)
+			}
+
+			status, err = backend.PodStatus(42, "abcdef")
+			if err != nil || status != nil {
+				t.Errorf("PodStatus() after delete = %v, %v, want nil", status, err)
+			}
+			if _, err := backend.PodPorts(podInfo); err == nil {
+				t.Errorf("PodPorts() after delete should fail")
+			}
+			if _, err := backend.Exec(context.Background(), podInfo.Name, "web", []string{"id"}, nil); err == nil {
+				t.Errorf("Exec() after delete should fail")
+			}
+			if len(backend.Pods()) != 0 {
+				t.Errorf("%d pods left after delete, want 0", len(backend.Pods()))
+			}
+
+			// 删除不存在的 Pod 不报错
+			if err := backend.DeletePod(podInfo, nil); err != nil {
+				t.Errorf("DeletePod() on a missing pod error = %v", err)
+			}
+		})
+	}
+}
+
+func TestFakeBackendOrphans(t *testing.T) {
+	backend := NewFakeBackend()
+
+	for _, teamHash := range []string{"aaaaaa", "bbbbbb"} {
+		if err := backend.CreatePod(newTestPodInfo("7", teamHash)); err != nil {
+			t.Fatalf("CreatePod() error = %v", err)
+		}
+	}
+
+	if err := backend.DeleteOrphan("cl-7-aaaaaa"); err != nil {
+		t.Fatalf("DeleteOrphan() error = %v", err)
+	}
+
+	resources, err := backend.Resources()
+	if err != nil {
+		t.Fatalf("Resources() error = %v", err)
+	}
+	if len(resources) != 1 || resources[0].Name != "cl-7-bbbbbb" {
+		t.Errorf("Resources() after DeleteOrphan = %v, want [cl-7-bbbbbb]", resources)
+	}
+}
+
+func newWarmPodInfo(pool string, name string) *k8stool.PodInfo {
+	podInfo := newTestPodInfo("7", name)
+	podInfo.Name = "cl-warm-" + name
+	podInfo.Labels = map[string]string{k8stool.WarmPoolLabel: pool}
+	return podInfo
+}
+
+// 建一个有 3 个就绪 Pod 的预热池，另外有一个还没就绪的 Pod 和一个别的池子的 Pod
+func newWarmPoolBackend(t *testing.T) *FakeBackend {
+	t.Helper()
+
+	backend := NewFakeBackend()
+	now := time.Now()
+	for i, name := range []string{"old", "mid", "new"} {
+		if err := backend.CreatePod(newWarmPodInfo("pool-a", name)); err != nil {
+			t.Fatalf("CreatePod() error = %v", err)
+		}
+		if err := backend.SetPodCreateTime("cl-warm-"+name, now.Add(time.Duration(i-3)*time.Minute)); err != nil {
+			t.Fatalf("SetPodCreateTime() error = %v", err)
+		}
+	}
+	if err := backend.CreatePod(newWarmPodInfo("pool-b", "other")); err != nil {
+		t.Fatalf("CreatePod() error = %v", err)
+	}
+	if err := backend.SetPodCreateTime("cl-warm-other", now.Add(-time.Hour)); err != nil {
+		t.Fatalf("SetPodCreateTime() error = %v", err)
+	}
+
+	backend.StartPending = true
+	if err := backend.CreatePod(newWarmPodInfo("pool-a", "pending")); err != nil {
+		t.Fatalf("CreatePod() error = %v", err)
+	}
+	if err := backend.SetPodCreateTime("cl-warm-pending", now.Add(-time.Hour)); err != nil {
+		t.Fatalf("SetPodCreateTime() error = %v", err)
+	}
+
+	return backend
+}
+
+func TestFakeBackendClaimWarmPod(t *testing.T) {
+	t.Run("claims oldest ready pod", func(t *testing.T) {
+		backend := newWarmPoolBackend(t)
+
+		name, err := backend.ClaimWarmPod("pool-a", map[string]string{"team_hash": "aaaaaa"})
+		if err != nil {
+			t.Fatalf("ClaimWarmPod() error = %v", err)
+		}
+		if name != "cl-warm-old" {
+			t.Errorf("ClaimWarmPod() = %q, want cl-warm-old", name)
+		}
+	})
+
+	t.Run("stale snapshot conflicts", func(t *testing.T) {
+		backend := newWarmPoolBackend(t)
+
+		backend.mu.Lock()
+		stale := backend.pods["cl-warm-old"].kubernetesPod()
+		backend.mu.Unlock()
+
+		if _, err := backend.ClaimWarmPod("pool-a", map[string]string{"team_hash": "aaaaaa"}); err != nil {
+			t.Fatalf("ClaimWarmPod() error = %v", err)
+		}
+		if err := backend.updatePodLabels(stale); !apierrors.IsConflict(err) {
+			t.Errorf("updatePodLabels() with stale resource version error = %v, want conflict", err)
+		}
+	})
+
+	t.Run("concurrent claims", func(t *testing.T) {
+		backend := newWarmPoolBackend(t)
+
+		const claimers = 10
+		names := make([]string, claimers)
+		errs := make([]error, claimers)
+
+		var wg sync.WaitGroup
+		start := make(chan struct{})
+		for i := 0; i < claimers; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				<-start
+				names[i], errs[i] = backend.ClaimWarmPod("pool-a", map[string]string{"team_hash": fmt.Sprintf("team%02d", i)})
+			}(i)
+		}
+		close(start)
+		wg.Wait()
+
+		claimed := make(map[string]string)
+		for i, name := range names {
+			if errs[i] != nil {
+				t.Fatalf("ClaimWarmPod() error = %v", errs[i])
+			}
+			if name == "" {
+				continue
+			}
+			if _, exists := claimed[name]; exists {
+				t.Errorf("pod %s claimed more than once", name)
+			}
+			claimed[name] = fmt.Sprintf("team%02d", i)
+		}
+		if len(claimed) != 3 {
+			t.Errorf("claimed %d pods, want 3: %v", len(claimed), claimed)
+		}
+
+		for _, pod := range backend.Pods() {
+			teamHash, wantClaimed := claimed[pod.Info.Name]
+			switch {
+			case wantClaimed:
+				if pod.Info.Labels["team_hash"] != teamHash {
+					t.Errorf("pod %s team_hash = %q, want %q", pod.Info.Name, pod.Info.Labels["team_hash"], teamHash)
+				}
+				if _, exists := pod.Info.Labels[k8stool.WarmPoolLabel]; exists {
+					t.Errorf("claimed pod %s still has warm pool label", pod.Info.Name)
+				}
+			case pod.Info.Name == "cl-warm-pending" || pod.Info.Name == "cl-warm-other":
+				if _, exists := pod.Info.Labels["team_hash"]; exists || pod.Info.Labels[k8stool.WarmPoolLabel] == "" {
+					t.Errorf("pod %s should not be claimed, labels = %v", pod.Info.Name, pod.Info.Labels)
+				}
+			default:
+				t.Errorf("ready pod %s was not claimed", pod.Info.Name)
+			}
+		}
+	})
+}
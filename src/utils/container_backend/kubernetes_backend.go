@@ -0,0 +1,115 @@
+package containerbackend
+
+import (
+	k8stool "a1ctf/src/utils/k8s_tool"
+	"context"
+	"io"
+	"strconv"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// kubernetesBackend 使用 a1ctf-challenges 命名空间，每个 Pod 带一个同名的 Service 和 NetworkPolicy
+type kubernetesBackend struct{}
+
+func (b *kubernetesBackend) Driver() string {
+	return DriverKubernetes
+}
+
+func (b *kubernetesBackend) Init() error {
+	return k8stool.InitNamespace()
+}
+
+func (b *kubernetesBackend) CreatePod(podInfo *k8stool.PodInfo) error {
+	return k8stool.CreatePod(podInfo)
+}
+
+func (b *kubernetesBackend) DeletePod(podInfo *k8stool.PodInfo, ports []int32) error {
+	return k8stool.DeletePod(podInfo, ports)
+}
+
+func (b *kubernetesBackend) ClaimWarmPod(pool string, teamLabels map[string]string) (string, error) {
+	return k8stool.ClaimWarmPod(pool, teamLabels)
+}
+
+func (b *kubernetesBackend) PodStatus(inGameID int64, teamHash string) (*k8stool.PodStatusDecision, error) {
+	pod, err := k8stool.CachedContainerPod(inGameID, teamHash)
+	if err != nil || pod == nil {
+		return nil, err
+	}
+
+	podStatus, err := k8stool.CheckPodStatus(pod)
+	if err != nil {
+		return nil, err
+	}
+
+	return &podStatus, nil
+}
+
+func (b *kubernetesBackend) PodPorts(podInfo *k8stool.PodInfo) (*k8stool.PodPorts, error) {
+	return k8stool.GetPodPorts(podInfo)
+}
+
+func (b *kubernetesBackend) Exec(ctx context.Context, podName string, containerName string, command []string, stdin io.Reader) (string, error) {
+	return k8stool.ExecInPod(ctx, podName, containerName, command, stdin)
+}
+
+func (b *kubernetesBackend) PodCPUUsage(podInfo *k8stool.PodInfo) (int64, error) {
+	return k8stool.GetPodCPUUsage(podInfo.Name)
+}
+
+func (b *kubernetesBackend) Watch(ctx context.Context, notify func(inGameID int64, teamHash string)) error {
+	onPod := func(obj interface{}) {
+		if tombstone, ok := obj.(cache.DeletedFinalStateUnknown); ok {
+			obj = tombstone.Obj
+		}
+
+		pod, ok := obj.(*corev1.Pod)
+		if !ok {
+			return
+		}
+
+		teamHash, exists1 := pod.Labels["team_hash"]
+		inGameID, exists2 := pod.Labels["ingame_id"]
+		if !exists1 || !exists2 {
+			return
+		}
+
+		inGameIDInt, err := strconv.ParseInt(inGameID, 10, 64)
+		if err != nil {
+			return
+		}
+
+		notify(inGameIDInt, teamHash)
+	}
+
+	return k8stool.StartInformers(ctx, cache.ResourceEventHandlerFuncs{
+		AddFunc: onPod,
+		UpdateFunc: func(oldObj, newObj interface{}) {
+			onPod(newObj)
+		},
+		DeleteFunc: onPod,
+	})
+}
+
+func (b *kubernetesBackend) Resources() ([]Resource, error) {
+	objects, err := k8stool.CachedChallengeObjects()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]Resource, 0, len(objects))
+	for _, object := range objects {
+		result = append(result, Resource{
+			Name:       object.GetName(),
+			CreateTime: object.GetCreationTimestamp().Time,
+		})
+	}
+
+	return result, nil
+}
+
+func (b *kubernetesBackend) DeleteOrphan(name string) error {
+	return k8stool.DeleteOrphan(name)
+}
@@ -22,10 +22,17 @@ import (
 var db *gorm.DB
 var redis_instance *redis.Client
 var ml *melody.Melody
-var gameSessions map[*melody.Session]int64 = make(map[*melody.Session]int64)
+var gameSessions map[*melody.Session]HubSession = make(map[*melody.Session]HubSession)
 var gameSessionsMutex sync.RWMutex
 var ctx = context.Background()
 
+// HubSession 实时通知连接所属的比赛和用户。队伍不在连接时绑定，
+// 连接期间可能加入、退出或者换队，推送时再按队伍成员匹配
+type HubSession struct {
+	GameID int64
+	UserID string
+}
+
 func DB() *gorm.DB {
 	return db
 }
@@ -87,7 +94,7 @@ func Init() {
 	// Init melody
 	ml = melody.New()
 
-	gameSessions = make(map[*melody.Session]int64)
+	gameSessions = make(map[*melody.Session]HubSession)
 
 	ml.HandleConnect(func(s *melody.Session) {
 		// 从会话keys中获取gameID
@@ -103,8 +110,13 @@ func Init() {
 			return
 		}
 
+		hubSession := HubSession{GameID: gameID}
+		if userID, ok := s.Get("userID"); ok {
+			hubSession.UserID, _ = userID.(string)
+		}
+
 		gameSessionsMutex.Lock()
-		gameSessions[s] = gameID
+		gameSessions[s] = hubSession
 		gameSessionsMutex.Unlock()
 
 		s.Write([]byte("{ \"status\": \"connected\" }"))
@@ -128,7 +140,26 @@ func GameSessions() map[*melody.Session]int64 {
 	// 返回一个拷贝以避免外部修改
 	result := make(map[*melody.Session]int64)
 	for k, v := range gameSessions {
-		result[k] = v
+		result[k] = v.GameID
+	}
+	return result
+}
+
+// UserSessions 返回比赛里属于这些用户的所有连接
+func UserSessions(gameID int64, userIDs []string) []*melody.Session {
+	users := make(map[string]struct{}, len(userIDs))
+	for _, userID := range userIDs {
+		users[userID] = struct{}{}
+	}
+
+	gameSessionsMutex.RLock()
+	defer gameSessionsMutex.RUnlock()
+
+	result := make([]*melody.Session, 0)
+	for k, v := range gameSessions {
+		if _, ok := users[v.UserID]; ok && v.GameID == gameID {
+			result = append(result, k)
+		}
 	}
 	return result
 }
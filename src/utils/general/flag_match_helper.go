@@ -0,0 +1,142 @@
+package general
+
+import (
+	"a1ctf/src/db/models"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/dgraph-io/ristretto/v2"
+)
+
+// 判题时编译出来的正则缓存，key 为原始表达式，按条数限制大小，
+// 管理员修改题目留下的旧表达式会被逐步淘汰
+var flagRegexCache = newFlagRegexCache()
+
+const flagRegexCacheSize = 1024
+
+func newFlagRegexCache() *ristretto.Cache[string, *regexp.Regexp] {
+	cache, err := ristretto.NewCache(&ristretto.Config[string, *regexp.Regexp]{
+		NumCounters: flagRegexCacheSize * 10,
+		MaxCost:     flagRegexCacheSize,
+		BufferItems: 64,
+	})
+	if err != nil {
+		panic(err)
+	}
+	return cache
+}
+
+// 正则需要匹配整个 flag，所以统一加上首尾锚点
+func anchorFlagRegex(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(`^(?:` + pattern + `)$`)
+}
+
+func compileFlagRegex(pattern string) (*regexp.Regexp, error) {
+	if re, ok := flagRegexCache.Get(pattern); ok {
+		return re, nil
+	}
+
+	re, err := anchorFlagRegex(pattern)
+	if err != nil {
+		return nil, err
+	}
+
+	flagRegexCache.Set(pattern, re, 1)
+	return re, nil
+}
+
+func flagMatchModeOf(config *models.JudgeConfig) models.FlagMatchMode {
+	if config == nil || config.FlagMatchMode == "" {
+		return models.FlagMatchExact
+	}
+	return config.FlagMatchMode
+}
+
+// ValidateFlagMatchConfig 检查匹配模式是否合法，正则模式下检查所有表达式能否编译
+func ValidateFlagMatchConfig(config *models.JudgeConfig) error {
+	if config == nil {
+		return nil
+	}
+
+	switch flagMatchModeOf(config) {
+	case models.FlagMatchExact, models.FlagMatchCaseInsensitive:
+		return nil
+	case models.FlagMatchRegex:
+		patterns := config.AcceptedFlags
+		if config.FlagTemplate != nil {
+			patterns = append([]string{*config.FlagTemplate}, patterns...)
+		}
+		for _, pattern := range patterns {
+			// 只校验不缓存，保存失败或者随后被修改掉的表达式不会占用缓存
+			if _, err := anchorFlagRegex(pattern); err != nil {
+				return fmt.Errorf("invalid flag regex %q: %w", pattern, err)
+			}
+		}
+		return nil
+	default:
+		return fmt.Errorf("unknown flag match mode: %s", config.FlagMatchMode)
+	}
+}
+
+// NormalizeFlag 按照配置处理选手提交的内容
+func NormalizeFlag(config *models.JudgeConfig, content string) string {
+	if config != nil && config.TrimSpace {
+		return strings.TrimSpace(content)
+	}
+	return content
+}
+
+// FlagCaseInsensitive 是否忽略大小写比较
+func FlagCaseInsensitive(config *models.JudgeConfig) bool {
+	return flagMatchModeOf(config) == models.FlagMatchCaseInsensitive
+}
+
+func matchLiteralFlag(config *models.JudgeConfig, expected string, content string) bool {
+	expected = NormalizeFlag(config, expected)
+	if FlagCaseInsensitive(config) {
+		return strings.EqualFold(expected, content)
+	}
+	return expected == content
+}
+
+// MatchDynamicFlag 比较队伍的动态 flag，动态 flag 是生成出来的字面量，正则模式按 EXACT 处理
+func MatchDynamicFlag(config *models.JudgeConfig, teamFlag string, content string) bool {
+	return matchLiteralFlag(config, teamFlag, NormalizeFlag(config, content))
+}
+
+// MatchStaticFlag 依次和 FlagTemplate 以及 AcceptedFlags 比较，任意一个匹配即正确
+func MatchStaticFlag(config *models.JudgeConfig, content string) bool {
+	if config == nil {
+		return false
+	}
+
+	content = NormalizeFlag(config, content)
+
+	candidates := make([]string, 0, len(config.AcceptedFlags)+1)
+	if config.FlagTemplate != nil {
+		candidates = append(candidates, *config.FlagTemplate)
+	}
+	for _, accepted := range config.AcceptedFlags {
+		// 空行不算一个 flag，否则正则模式下会接受空提交
+		if accepted != "" {
+			candidates = append(candidates, accepted)
+		}
+	}
+
+	for _, candidate := range candidates {
+		if flagMatchModeOf(config) == models.FlagMatchRegex {
+			re, err := compileFlagRegex(candidate)
+			if err != nil {
+				continue
+			}
+			if re.MatchString(content) {
+				return true
+			}
+		} else if matchLiteralFlag(config, candidate, content) {
+			return true
+		}
+	}
+
+	return false
+}
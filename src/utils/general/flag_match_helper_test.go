@@ -0,0 +1,118 @@
+package general
+
+import (
+	"a1ctf/src/db/models"
+	"testing"
+)
+
+func judgeConfig(mode models.FlagMatchMode, trim bool, template string, accepted ...string) *models.JudgeConfig {
+	return &models.JudgeConfig{
+		JudgeType:     models.JudgeTypeDynamic,
+		FlagTemplate:  &template,
+		FlagMatchMode: mode,
+		TrimSpace:     trim,
+		AcceptedFlags: accepted,
+	}
+}
+
+func TestMatchStaticFlag(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *models.JudgeConfig
+		content string
+		want    bool
+	}{
+		{name: "nil config", config: nil, content: "flag{a}", want: false},
+		{name: "default mode is exact", config: judgeConfig("", false, "flag{Abc}"), content: "flag{Abc}", want: true},
+		{name: "exact is case sensitive", config: judgeConfig(models.FlagMatchExact, false, "flag{Abc}"), content: "flag{abc}", want: false},
+		{name: "case insensitive", config: judgeConfig(models.FlagMatchCaseInsensitive, false, "flag{Abc}"), content: "FLAG{aBC}", want: true},
+		{name: "case insensitive still compares content", config: judgeConfig(models.FlagMatchCaseInsensitive, false, "flag{Abc}"), content: "flag{abd}", want: false},
+		{name: "no trim keeps whitespace", config: judgeConfig(models.FlagMatchExact, false, "flag{a}"), content: " flag{a}\n", want: false},
+		{name: "trim space", config: judgeConfig(models.FlagMatchExact, true, "flag{a}"), content: " flag{a}\n", want: true},
+		{name: "trim keeps inner whitespace", config: judgeConfig(models.FlagMatchExact, true, "flag{a b}"), content: "flag{ab}", want: false},
+		{name: "trim applies to configured flag", config: judgeConfig(models.FlagMatchExact, true, " flag{a} "), content: "flag{a}", want: true},
+		{name: "regex", config: judgeConfig(models.FlagMatchRegex, false, `flag\{[0-9]+\}`), content: "flag{123}", want: true},
+		{name: "regex anchored at start", config: judgeConfig(models.FlagMatchRegex, false, `flag\{[0-9]+\}`), content: "xflag{123}", want: false},
+		{name: "regex anchored at end", config: judgeConfig(models.FlagMatchRegex, false, `flag\{[0-9]+\}`), content: "flag{123}x", want: false},
+		{name: "regex alternation is anchored as a whole", config: judgeConfig(models.FlagMatchRegex, false, `flag\{a\}|flag\{b\}`), content: "flag{a}tail", want: false},
+		{name: "invalid regex never matches", config: judgeConfig(models.FlagMatchRegex, false, `flag\{(`), content: "flag{(", want: false},
+		{name: "regex with trim", config: judgeConfig(models.FlagMatchRegex, true, `flag\{[a-z]+\}`), content: "  flag{abc}  ", want: true},
+		{name: "accepted flag", config: judgeConfig(models.FlagMatchExact, false, "flag{a}", "flag{b}", "flag{c}"), content: "flag{c}", want: true},
+		{name: "template still accepted with list", config: judgeConfig(models.FlagMatchExact, false, "flag{a}", "flag{b}"), content: "flag{a}", want: true},
+		{name: "not in list", config: judgeConfig(models.FlagMatchExact, false, "flag{a}", "flag{b}"), content: "flag{d}", want: false},
+		{name: "accepted flags follow case mode", config: judgeConfig(models.FlagMatchCaseInsensitive, false, "flag{a}", "flag{B}"), content: "FLAG{b}", want: true},
+		{name: "accepted regex list", config: judgeConfig(models.FlagMatchRegex, false, `flag\{a+\}`, `flag\{b+\}`), content: "flag{bbb}", want: true},
+		{name: "invalid regex in list is skipped", config: judgeConfig(models.FlagMatchRegex, false, `flag\{(`, `flag\{b\}`), content: "flag{b}", want: true},
+		{name: "empty accepted flag does not accept empty regex", config: judgeConfig(models.FlagMatchRegex, false, `flag\{a\}`, ""), content: "", want: false},
+		{name: "empty accepted flag does not accept empty exact", config: judgeConfig(models.FlagMatchExact, true, "flag{a}", ""), content: "  ", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchStaticFlag(tt.config, tt.content); got != tt.want {
+				t.Errorf("MatchStaticFlag(%q) = %v, want %v", tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestMatchDynamicFlag(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   *models.JudgeConfig
+		teamFlag string
+		content  string
+		want     bool
+	}{
+		{name: "nil config is exact", config: nil, teamFlag: "flag{T3am}", content: "flag{T3am}", want: true},
+		{name: "exact", config: judgeConfig(models.FlagMatchExact, false, ""), teamFlag: "flag{T3am}", content: "flag{t3am}", want: false},
+		{name: "case insensitive", config: judgeConfig(models.FlagMatchCaseInsensitive, false, ""), teamFlag: "flag{T3am}", content: "FLAG{t3AM}", want: true},
+		{name: "trim space", config: judgeConfig(models.FlagMatchExact, true, ""), teamFlag: "flag{T3am}", content: "\tflag{T3am} ", want: true},
+		{name: "regex mode compares literally", config: judgeConfig(models.FlagMatchRegex, false, ""), teamFlag: "flag{a.b}", content: "flag{axb}", want: false},
+		{name: "regex mode exact literal", config: judgeConfig(models.FlagMatchRegex, false, ""), teamFlag: "flag{a.b}", content: "flag{a.b}", want: true},
+		{name: "accepted flags ignored for dynamic", config: judgeConfig(models.FlagMatchExact, false, "", "flag{other}"), teamFlag: "flag{T3am}", content: "flag{other}", want: false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := MatchDynamicFlag(tt.config, tt.teamFlag, tt.content); got != tt.want {
+				t.Errorf("MatchDynamicFlag(%q, %q) = %v, want %v", tt.teamFlag, tt.content, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestValidateFlagMatchConfig(t *testing.T) {
+	tests := []struct {
+		name    string
+		config  *models.JudgeConfig
+		wantErr bool
+	}{
+		{name: "nil", config: nil},
+		{name: "default", config: judgeConfig("", false, "flag{(")},
+		{name: "exact ignores regex syntax", config: judgeConfig(models.FlagMatchExact, false, "flag{(")},
+		{name: "valid regex", config: judgeConfig(models.FlagMatchRegex, false, `flag\{\w+\}`, `ctf\{\d+\}`)},
+		{name: "invalid template regex", config: judgeConfig(models.FlagMatchRegex, false, `flag\{(`), wantErr: true},
+		{name: "invalid accepted regex", config: judgeConfig(models.FlagMatchRegex, false, `flag\{a\}`, `ctf\{[`), wantErr: true},
+		{name: "unknown mode", config: judgeConfig("FUZZY", false, "flag{a}"), wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := ValidateFlagMatchConfig(tt.config); (err != nil) != tt.wantErr {
+				t.Errorf("ValidateFlagMatchConfig() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidateFlagMatchConfigDoesNotCache(t *testing.T) {
+	pattern := `validate\{only\}`
+	if err := ValidateFlagMatchConfig(judgeConfig(models.FlagMatchRegex, false, pattern)); err != nil {
+		t.Fatalf("ValidateFlagMatchConfig() error = %v", err)
+	}
+	flagRegexCache.Wait()
+	if _, ok := flagRegexCache.Get(pattern); ok {
+		t.Errorf("validated pattern %q should not be cached", pattern)
+	}
+}
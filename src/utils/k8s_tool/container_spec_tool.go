@@ -0,0 +1,267 @@
+package k8stool
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"path"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	"k8s.io/apimachinery/pkg/util/intstr"
+	"k8s.io/apimachinery/pkg/util/validation"
+)
+
+const (
+	ProbeTCP  = "tcp"
+	ProbeHTTP = "http"
+	ProbeExec = "exec"
+
+	SeccompRuntimeDefault = "RuntimeDefault"
+	SeccompUnconfined     = "Unconfined"
+)
+
+// VolumeMount 同一个 Pod 里的容器按名字共享的 emptyDir 卷
+type VolumeMount struct {
+	Name      string `json:"name"`
+	MountPath string `json:"mount_path"`
+	ReadOnly  bool   `json:"read_only"`
+	// 卷的大小上限，单位 MB，同名的卷取最大值，为 0 时不限制
+	SizeLimit int64 `json:"size_limit"`
+}
+
+// Probe 就绪检查，所有容器就绪后容器才会变成运行中
+type Probe struct {
+	// tcp / http / exec
+	Type                string   `json:"type"`
+	Port                int32    `json:"port"`
+	Path                string   `json:"path"`
+	Command             []string `json:"command"`
+	InitialDelaySeconds int32    `json:"initial_delay_seconds"`
+	PeriodSeconds       int32    `json:"period_seconds"`
+	TimeoutSeconds      int32    `json:"timeout_seconds"`
+	FailureThreshold    int32    `json:"failure_threshold"`
+}
+
+// SecurityContext 容器的安全选项，没有设置的字段使用运行时的默认值
+type SecurityContext struct {
+	ReadOnlyRootFilesystem   bool   `json:"read_only_root_filesystem"`
+	RunAsUser                *int64 `json:"run_as_user"`
+	RunAsGroup               *int64 `json:"run_as_group"`
+	RunAsNonRoot             bool   `json:"run_as_non_root"`
+	AllowPrivilegeEscalation *bool  `json:"allow_privilege_escalation"`
+	// 删除的 capability，ALL 表示全部删除
+	DropCapabilities []string `json:"drop_capabilities"`
+	AddCapabilities  []string `json:"add_capabilities"`
+	// RuntimeDefault / Unconfined
+	Seccomp string `json:"seccomp"`
+}
+
+// EgressRule 关闭 AllowWAN 时额外放行的出站地址
+type EgressRule struct {
+	CIDR string `json:"cidr"`
+	// 为空时放行所有端口
+	Ports []int32 `json:"ports"`
+	// TCP / UDP，默认 TCP
+	Protocol string `json:"protocol"`
+}
+
+// IsMainContainer 不是 init 容器
+func (c *A1Container) IsMainContainer() bool {
+	return !c.Init
+}
+
+// FirstMainContainer 第一个不是 init 容器的容器，端口和 exec 默认使用这个容器
+func FirstMainContainer(containers []A1Container) *A1Container {
+	for idx := range containers {
+		if containers[idx].IsMainContainer() {
+			return &containers[idx]
+		}
+	}
+	return nil
+}
+
+func validContainerSpec(container *A1Container) error {
+	if container.Init && (len(container.ExposePorts) > 0 || container.ReadinessProbe != nil) {
+		return errors.New("init containers can not expose ports or have readiness probes")
+	}
+
+	for _, volume := range container.Volumes {
+		if len(validation.IsDNS1123Label(volume.Name)) > 0 {
+			return fmt.Errorf("volume name %s must be a DNS_LABEL", volume.Name)
+		}
+		if !path.IsAbs(volume.MountPath) || path.Clean(volume.MountPath) == "/" {
+			return fmt.Errorf("volume %s mount path must be an absolute path", volume.Name)
+		}
+		if volume.SizeLimit < 0 {
+			return fmt.Errorf("volume %s size limit must not be negative", volume.Name)
+		}
+	}
+
+	if probe := container.ReadinessProbe; probe != nil {
+		switch probe.Type {
+		case ProbeTCP, ProbeHTTP:
+			if probe.Port < 1 || probe.Port > 65535 {
+				return errors.New("probe port must be between 1 and 65535")
+			}
+		case ProbeExec:
+			if len(probe.Command) == 0 {
+				return errors.New("exec probe requires a command")
+			}
+		default:
+			return fmt.Errorf("unknown probe type %s", probe.Type)
+		}
+		if probe.InitialDelaySeconds < 0 || probe.PeriodSeconds < 0 || probe.TimeoutSeconds < 0 || probe.FailureThreshold < 0 {
+			return errors.New("probe settings must not be negative")
+		}
+	}
+
+	if securityContext := container.SecurityContext; securityContext != nil {
+		switch securityContext.Seccomp {
+		case "", SeccompRuntimeDefault, SeccompUnconfined:
+		default:
+			return fmt.Errorf("unknown seccomp profile %s", securityContext.Seccomp)
+		}
+		for _, id := range []*int64{securityContext.RunAsUser, securityContext.RunAsGroup} {
+			if id != nil && *id < 0 {
+				return errors.New("run as user and group must not be negative")
+			}
+		}
+	}
+
+	for _, rule := range container.EgressAllow {
+		if _, _, err := net.ParseCIDR(rule.CIDR); err != nil {
+			return fmt.Errorf("invalid egress cidr %s", rule.CIDR)
+		}
+		switch strings.ToUpper(rule.Protocol) {
+		case "", string(corev1.ProtocolTCP), string(corev1.ProtocolUDP):
+		default:
+			return fmt.Errorf("unknown egress protocol %s", rule.Protocol)
+		}
+		for _, port := range rule.Ports {
+			if port < 1 || port > 65535 {
+				return errors.New("egress port must be between 1 and 65535")
+			}
+		}
+	}
+
+	return nil
+}
+
+// 所有容器挂载的卷，同名的卷只创建一个
+func podVolumes(containers []A1Container) []corev1.Volume {
+	sizeLimits := make(map[string]int64)
+	names := make([]string, 0)
+	for _, container := range containers {
+		for _, volume := range container.Volumes {
+			if _, ok := sizeLimits[volume.Name]; !ok {
+				names = append(names, volume.Name)
+			}
+			sizeLimits[volume.Name] = max(sizeLimits[volume.Name], volume.SizeLimit)
+		}
+	}
+
+	volumes := make([]corev1.Volume, 0, len(names))
+	for _, name := range names {
+		emptyDir := &corev1.EmptyDirVolumeSource{}
+		if sizeLimits[name] > 0 {
+			emptyDir.SizeLimit = resource.NewQuantity(sizeLimits[name]*1024*1024, resource.BinarySI)
+		}
+		volumes = append(volumes, corev1.Volume{
+			Name:         name,
+			VolumeSource: corev1.VolumeSource{EmptyDir: emptyDir},
+		})
+	}
+
+	return volumes
+}
+
+// applyContainerSpec 卷、就绪检查和安全选项
+func applyContainerSpec(container *corev1.Container, c *A1Container) {
+	for _, volume := range c.Volumes {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      volume.Name,
+			MountPath: volume.MountPath,
+			ReadOnly:  volume.ReadOnly,
+		})
+	}
+
+	if probe := c.ReadinessProbe; probe != nil {
+		readinessProbe := &corev1.Probe{
+			InitialDelaySeconds: probe.InitialDelaySeconds,
+			PeriodSeconds:       probe.PeriodSeconds,
+			TimeoutSeconds:      probe.TimeoutSeconds,
+			FailureThreshold:    probe.FailureThreshold,
+		}
+		switch probe.Type {
+		case ProbeTCP:
+			readinessProbe.TCPSocket = &corev1.TCPSocketAction{Port: intstr.FromInt32(probe.Port)}
+		case ProbeHTTP:
+			probePath := probe.Path
+			if probePath == "" {
+				probePath = "/"
+			}
+			readinessProbe.HTTPGet = &corev1.HTTPGetAction{Path: probePath, Port: intstr.FromInt32(probe.Port)}
+		case ProbeExec:
+			readinessProbe.Exec = &corev1.ExecAction{Command: probe.Command}
+		}
+		container.ReadinessProbe = readinessProbe
+	}
+
+	if sc := c.SecurityContext; sc != nil {
+		securityContext := &corev1.SecurityContext{
+			ReadOnlyRootFilesystem:   &sc.ReadOnlyRootFilesystem,
+			RunAsUser:                sc.RunAsUser,
+			RunAsGroup:               sc.RunAsGroup,
+			AllowPrivilegeEscalation: sc.AllowPrivilegeEscalation,
+		}
+		if sc.RunAsNonRoot {
+			securityContext.RunAsNonRoot = &sc.RunAsNonRoot
+		}
+		if len(sc.DropCapabilities) > 0 || len(sc.AddCapabilities) > 0 {
+			securityContext.Capabilities = &corev1.Capabilities{}
+			for _, capability := range sc.DropCapabilities {
+				securityContext.Capabilities.Drop = append(securityContext.Capabilities.Drop, corev1.Capability(capability))
+			}
+			for _, capability := range sc.AddCapabilities {
+				securityContext.Capabilities.Add = append(securityContext.Capabilities.Add, corev1.Capability(capability))
+			}
+		}
+		switch sc.Seccomp {
+		case SeccompRuntimeDefault:
+			securityContext.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeRuntimeDefault}
+		case SeccompUnconfined:
+			securityContext.SeccompProfile = &corev1.SeccompProfile{Type: corev1.SeccompProfileTypeUnconfined}
+		}
+		container.SecurityContext = securityContext
+	}
+}
+
+// 各个容器放行的出站地址，同一个 Pod 的容器共用网络，NetworkPolicy 只能按 Pod 放行
+func egressAllowRules(containers []A1Container) []networkingv1.NetworkPolicyEgressRule {
+	rules := make([]networkingv1.NetworkPolicyEgressRule, 0)
+	for _, container := range containers {
+		for _, rule := range container.EgressAllow {
+			protocol := corev1.ProtocolTCP
+			if strings.ToUpper(rule.Protocol) == string(corev1.ProtocolUDP) {
+				protocol = corev1.ProtocolUDP
+			}
+
+			egressRule := networkingv1.NetworkPolicyEgressRule{
+				To: []networkingv1.NetworkPolicyPeer{
+					{IPBlock: &networkingv1.IPBlock{CIDR: rule.CIDR}},
+				},
+			}
+			for _, port := range rule.Ports {
+				egressRule.Ports = append(egressRule.Ports, networkingv1.NetworkPolicyPort{
+					Protocol: &protocol,
+					Port:     &intstr.IntOrString{IntVal: port},
+				})
+			}
+			rules = append(rules, egressRule)
+		}
+	}
+	return rules
+}
@@ -0,0 +1,210 @@
+package k8stool
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"path"
+	"strconv"
+
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	// Secret 里保存 flag 的 key
+	flagSecretKey = "flag"
+	// 挂载 flag 的卷和 init 容器的名字前缀
+	flagVolumePrefix = "a1ctf-flag"
+	// 默认的 flag 文件权限
+	defaultFlagMode int32 = 0444
+)
+
+// FileMode 配置的文件权限，没有配置时为 0444
+func (f *FlagConfig) FileMode() int32 {
+	if f == nil || f.Mode == "" {
+		return defaultFlagMode
+	}
+	mode, err := strconv.ParseInt(f.Mode, 8, 32)
+	if err != nil {
+		return defaultFlagMode
+	}
+	return int32(mode)
+}
+
+func validFlagConfig(config *FlagConfig) error {
+	if config == nil {
+		return nil
+	}
+	if config.Path != "" && (!path.IsAbs(config.Path) || path.Clean(config.Path) == "/") {
+		return errors.New("flag path must be an absolute file path")
+	}
+	if config.Mode != "" {
+		mode, err := strconv.ParseInt(config.Mode, 8, 32)
+		if err != nil || mode < 0 || mode > 0777 {
+			return fmt.Errorf("invalid flag file mode %s", config.Mode)
+		}
+	}
+	for _, id := range []*int64{config.UID, config.GID} {
+		if id != nil && *id < 0 {
+			return errors.New("flag file owner must not be negative")
+		}
+	}
+	if config.DisableEnv && config.Path == "" {
+		return errors.New("flag path is required when the flag env is disabled")
+	}
+	return nil
+}
+
+// 和 Pod 同名的 Secret，重试创建时删除旧的再创建
+func createFlagSecret(clientset *kubernetes.Clientset, podInfo *PodInfo) error {
+	return createSecret(clientset, podInfo.Name, podInfo.Labels, map[string]string{flagSecretKey: podInfo.Flag})
+}
+
+// 创建不可修改的 Secret，已经存在时删除旧的再创建
+func createSecret(clientset *kubernetes.Clientset, name string, labels map[string]string, data map[string]string) error {
+	namespace := "a1ctf-challenges"
+	immutable := true
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: labels,
+		},
+		Type:       corev1.SecretTypeOpaque,
+		Immutable:  &immutable,
+		StringData: data,
+	}
+
+	_, err := clientset.CoreV1().Secrets(namespace).Create(context.Background(), secret, metav1.CreateOptions{})
+	if apierrors.IsAlreadyExists(err) {
+		if err := clientset.CoreV1().Secrets(namespace).Delete(context.Background(), name, metav1.DeleteOptions{}); err != nil && !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error deleting old secret: %v", err)
+		}
+		_, err = clientset.CoreV1().Secrets(namespace).Create(context.Background(), secret, metav1.CreateOptions{})
+	}
+	if err != nil {
+		return fmt.Errorf("error creating secret: %v", err)
+	}
+
+	return nil
+}
+
+// 把 Secret 挂到 Pod 上，Pod 被删除时由 k8s 一起回收，DeletePod 也会主动删除
+func adoptFlagSecret(clientset *kubernetes.Clientset, pod *corev1.Pod) {
+	adoptSecret(clientset, pod.Name, metav1.OwnerReference{
+		APIVersion: "v1",
+		Kind:       "Pod",
+		Name:       pod.Name,
+		UID:        pod.UID,
+	})
+}
+
+func adoptSecret(clientset *kubernetes.Clientset, name string, owner metav1.OwnerReference) {
+	namespace := "a1ctf-challenges"
+
+	secret, err := clientset.CoreV1().Secrets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		return
+	}
+
+	secret.OwnerReferences = []metav1.OwnerReference{owner}
+	_, _ = clientset.CoreV1().Secrets(namespace).Update(context.Background(), secret, metav1.UpdateOptions{})
+}
+
+// mountFlag 按容器的配置把 flag 作为环境变量或文件传入
+// 设置了属主时由 init 容器把 Secret 里的 flag 复制到内存卷并修改属主和权限
+func mountFlag(spec *corev1.PodSpec, container *corev1.Container, index int, secretName string, config *FlagConfig) {
+	if config == nil || !config.DisableEnv {
+		container.Env = append(container.Env, corev1.EnvVar{
+			Name: "A1CTF_FLAG",
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: secretName},
+					Key:                  flagSecretKey,
+				},
+			},
+		})
+	}
+
+	if config == nil || config.Path == "" {
+		return
+	}
+
+	// 每个容器单独一个 Secret 卷，文件权限可以不同
+	mode := config.FileMode()
+	secretVolume := fmt.Sprintf("%s-%d", flagVolumePrefix, index)
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: secretVolume,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName:  secretName,
+				DefaultMode: &mode,
+			},
+		},
+	})
+
+	if config.UID == nil && config.GID == nil {
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      secretVolume,
+			MountPath: config.Path,
+			SubPath:   flagSecretKey,
+			ReadOnly:  true,
+		})
+		return
+	}
+
+	owner := func(id *int64) string {
+		if id == nil {
+			return "0"
+		}
+		return strconv.FormatInt(*id, 10)
+	}
+
+	initImage := viper.GetString("k8s.flag-secret.init-image")
+	if initImage == "" {
+		initImage = "busybox:1.36"
+	}
+
+	volumeName := secretVolume + "-copy"
+	spec.Volumes = append(spec.Volumes, corev1.Volume{
+		Name: volumeName,
+		VolumeSource: corev1.VolumeSource{
+			EmptyDir: &corev1.EmptyDirVolumeSource{
+				Medium:    corev1.StorageMediumMemory,
+				SizeLimit: resource.NewQuantity(1024*1024, resource.BinarySI),
+			},
+		},
+	})
+
+	limits := corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewMilliQuantity(50, resource.DecimalSI),
+		corev1.ResourceMemory: *resource.NewQuantity(16*1024*1024, resource.BinarySI),
+	}
+	spec.InitContainers = append(spec.InitContainers, corev1.Container{
+		Name:  secretVolume,
+		Image: initImage,
+		Command: []string{
+			"sh", "-c",
+			fmt.Sprintf("cp /a1ctf-secret/%[1]s /a1ctf-flag/%[1]s && chown %[2]s:%[3]s /a1ctf-flag/%[1]s && chmod %#[4]o /a1ctf-flag/%[1]s",
+				flagSecretKey, owner(config.UID), owner(config.GID), mode),
+		},
+		ImagePullPolicy: corev1.PullIfNotPresent,
+		VolumeMounts: []corev1.VolumeMount{
+			{Name: secretVolume, MountPath: "/a1ctf-secret", ReadOnly: true},
+			{Name: volumeName, MountPath: "/a1ctf-flag"},
+		},
+		Resources: corev1.ResourceRequirements{Limits: limits},
+	})
+
+	container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+		Name:      volumeName,
+		MountPath: config.Path,
+		SubPath:   flagSecretKey,
+		ReadOnly:  true,
+	})
+}
@@ -0,0 +1,230 @@
+package k8stool
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"strconv"
+	"time"
+
+	"github.com/spf13/viper"
+	batchv1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// 生成的文件放在这个目录下
+const generatorOutputDir = "/output"
+
+// GeneratorJob 一次性运行生成脚本的 Job
+type GeneratorJob struct {
+	Name   string
+	Image  string
+	Script string
+	Env    map[string]string
+	// flag 之类的敏感变量放在和 Job 同名的 Secret 里，不出现在 Job 的定义中
+	SecretEnv map[string]string
+	// 脚本需要把文件写到 /output/<OutputFile>
+	OutputFile string
+	MaxBytes   int64
+}
+
+// RunGeneratorJob 在 Job 的 init 容器里运行生成脚本，脚本成功后从常驻的容器里读出生成的文件
+// Job 没有网络，结束后会被删除
+func RunGeneratorJob(ctx context.Context, job *GeneratorJob) ([]byte, error) {
+	clientset, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+	namespace := "a1ctf-challenges"
+
+	deadline := int64(600)
+	if d, ok := ctx.Deadline(); ok {
+		deadline = int64(time.Until(d).Seconds()) + 1
+	}
+
+	env := make([]corev1.EnvVar, 0, len(job.Env)+len(job.SecretEnv)+1)
+	for name, value := range job.Env {
+		env = append(env, corev1.EnvVar{Name: name, Value: value})
+	}
+	for name := range job.SecretEnv {
+		env = append(env, corev1.EnvVar{
+			Name: name,
+			ValueFrom: &corev1.EnvVarSource{
+				SecretKeyRef: &corev1.SecretKeySelector{
+					LocalObjectReference: corev1.LocalObjectReference{Name: job.Name},
+					Key:                  name,
+				},
+			},
+		})
+	}
+	env = append(env, corev1.EnvVar{Name: "A1CTF_OUTPUT", Value: path.Join(generatorOutputDir, job.OutputFile)})
+
+	cpuLimit := viper.GetInt64("attachment-generator.cpu-limit")
+	if cpuLimit <= 0 {
+		cpuLimit = 1000
+	}
+	memoryLimit := int64(viper.GetSizeInBytes("attachment-generator.memory-limit"))
+	if memoryLimit <= 0 {
+		memoryLimit = 512 * 1024 * 1024
+	}
+	resources := corev1.ResourceRequirements{
+		Limits: corev1.ResourceList{
+			corev1.ResourceCPU:    *resource.NewMilliQuantity(cpuLimit, resource.DecimalSI),
+			corev1.ResourceMemory: *resource.NewQuantity(memoryLimit, resource.BinarySI),
+		},
+	}
+
+	outputMount := []corev1.VolumeMount{{Name: "output", MountPath: generatorOutputDir}}
+	labels := map[string]string{"a1ctf-generator": job.Name}
+
+	backoffLimit := int32(0)
+	ttl := int32(60)
+	falseVal := false
+
+	batchJob := &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   job.Name,
+			Labels: labels,
+		},
+		Spec: batchv1.JobSpec{
+			BackoffLimit:            &backoffLimit,
+			ActiveDeadlineSeconds:   &deadline,
+			TTLSecondsAfterFinished: &ttl,
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: labels},
+				Spec: corev1.PodSpec{
+					RestartPolicy:                corev1.RestartPolicyNever,
+					AutomountServiceAccountToken: &falseVal,
+					EnableServiceLinks:           &falseVal,
+					Volumes: []corev1.Volume{{
+						Name:         "output",
+						VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}},
+					}},
+					InitContainers: []corev1.Container{{
+						Name:         "generate",
+						Image:        job.Image,
+						Command:      []string{"sh", "-c", job.Script},
+						WorkingDir:   generatorOutputDir,
+						Env:          env,
+						VolumeMounts: outputMount,
+						Resources:    resources,
+					}},
+					// 等待读取生成的文件，超过期限后由 Job 结束
+					Containers: []corev1.Container{{
+						Name:         "collect",
+						Image:        job.Image,
+						Command:      []string{"sh", "-c", "sleep " + strconv.FormatInt(deadline, 10)},
+						VolumeMounts: outputMount,
+						Resources:    resources,
+					}},
+				},
+			},
+		},
+	}
+
+	secretNames := viper.GetStringSlice("k8s.pull-secret-names")
+	for _, secretName := range secretNames {
+		batchJob.Spec.Template.Spec.ImagePullSecrets = append(batchJob.Spec.Template.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: secretName})
+	}
+
+	// 禁止生成脚本访问网络
+	networkPolicy := &networkingv1.NetworkPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: job.Name},
+		Spec: networkingv1.NetworkPolicySpec{
+			PodSelector: metav1.LabelSelector{MatchLabels: labels},
+			PolicyTypes: []networkingv1.PolicyType{networkingv1.PolicyTypeIngress, networkingv1.PolicyTypeEgress},
+		},
+	}
+
+	if _, err := clientset.NetworkingV1().NetworkPolicies(namespace).Create(ctx, networkPolicy, metav1.CreateOptions{}); err != nil {
+		return nil, fmt.Errorf("error creating network policy: %v", err)
+	}
+
+	defer func() {
+		propagation := metav1.DeletePropagationBackground
+		_ = clientset.BatchV1().Jobs(namespace).Delete(context.Background(), job.Name, metav1.DeleteOptions{PropagationPolicy: &propagation})
+		_ = clientset.NetworkingV1().NetworkPolicies(namespace).Delete(context.Background(), job.Name, metav1.DeleteOptions{})
+		_ = clientset.CoreV1().Secrets(namespace).Delete(context.Background(), job.Name, metav1.DeleteOptions{})
+	}()
+
+	if len(job.SecretEnv) > 0 {
+		if err := createSecret(clientset, job.Name, labels, job.SecretEnv); err != nil {
+			return nil, err
+		}
+	}
+
+	createdJob, err := clientset.BatchV1().Jobs(namespace).Create(ctx, batchJob, metav1.CreateOptions{})
+	if err != nil {
+		return nil, fmt.Errorf("error creating job: %v", err)
+	}
+
+	// 平台在 Job 结束前退出时 Secret 随 Job 一起回收
+	if len(job.SecretEnv) > 0 {
+		adoptSecret(clientset, job.Name, metav1.OwnerReference{
+			APIVersion: "batch/v1",
+			Kind:       "Job",
+			Name:       createdJob.Name,
+			UID:        createdJob.UID,
+		})
+	}
+
+	podName, err := waitGeneratorPod(ctx, job.Name)
+	if err != nil {
+		return nil, err
+	}
+
+	// 多读一个字节判断是否超过大小限制
+	output, err := ExecInPod(ctx, podName, "collect", []string{"head", "-c", strconv.FormatInt(job.MaxBytes+1, 10), path.Join(generatorOutputDir, job.OutputFile)}, nil)
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(output)) > job.MaxBytes {
+		return nil, fmt.Errorf("generated file is larger than %d bytes", job.MaxBytes)
+	}
+
+	return []byte(output), nil
+}
+
+// 等待生成脚本结束，返回 Pod 名
+func waitGeneratorPod(ctx context.Context, jobName string) (string, error) {
+	clientset, err := GetClient()
+	if err != nil {
+		return "", err
+	}
+	namespace := "a1ctf-challenges"
+
+	ticker := time.NewTicker(time.Second)
+	defer ticker.Stop()
+
+	for {
+		pods, err := clientset.CoreV1().Pods(namespace).List(ctx, metav1.ListOptions{LabelSelector: "a1ctf-generator=" + jobName})
+		if err != nil {
+			return "", err
+		}
+
+		for _, pod := range pods.Items {
+			for _, status := range pod.Status.InitContainerStatuses {
+				if terminated := status.State.Terminated; terminated != nil && terminated.ExitCode != 0 {
+					return "", fmt.Errorf("generator exited with code %d: %s %s", terminated.ExitCode, terminated.Reason, terminated.Message)
+				}
+			}
+			for _, status := range pod.Status.ContainerStatuses {
+				if status.Name == "collect" && status.State.Running != nil {
+					return pod.Name, nil
+				}
+			}
+			if pod.Status.Phase == corev1.PodFailed {
+				return "", fmt.Errorf("generator pod failed: %s", pod.Status.Message)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("generator timed out: %w", ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
@@ -0,0 +1,316 @@
+package k8stool
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/google/uuid"
+	"github.com/spf13/viper"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+	"k8s.io/client-go/dynamic"
+	"k8s.io/client-go/kubernetes"
+)
+
+const (
+	HTTPRoutingModeIngress   = "ingress"
+	HTTPRoutingModeHTTPRoute = "httproute"
+)
+
+// 路由对象按端口创建，用这个标签找到一个 Pod 的所有路由
+const httpRoutePodLabel = "a1ctf-pod"
+
+// 路由对应的 Service 端口名
+const httpRoutePortAnnotation = "a1ctf.port-name"
+
+var httpRouteGVR = schema.GroupVersionResource{
+	Group:    "gateway.networking.k8s.io",
+	Version:  "v1",
+	Resource: "httproutes",
+}
+
+var dynamicClient dynamic.Interface
+
+// HTTPRoutingEnabled 是否为标记为 http 的端口创建子域名路由
+func HTTPRoutingEnabled() bool {
+	return viper.GetBool("k8s.http-routing.enabled") && viper.GetString("k8s.http-routing.domain") != ""
+}
+
+func httpRoutingMode() string {
+	if viper.GetString("k8s.http-routing.mode") == HTTPRoutingModeHTTPRoute {
+		return HTTPRoutingModeHTTPRoute
+	}
+	return HTTPRoutingModeIngress
+}
+
+// HTTPRoutingScheme 选手访问路由使用的协议
+func HTTPRoutingScheme() string {
+	if viper.GetBool("k8s.http-routing.https") {
+		return "https"
+	}
+	return "http"
+}
+
+func getDynamicClient() (dynamic.Interface, error) {
+	if dynamicClient != nil {
+		return dynamicClient, nil
+	}
+
+	if _, err := GetClient(); err != nil {
+		return nil, err
+	}
+
+	client, err := dynamic.NewForConfig(GetClientConfig())
+	if err != nil {
+		return nil, fmt.Errorf("error creating dynamic client: %v", err)
+	}
+
+	dynamicClient = client
+	return client, nil
+}
+
+// 使用子域名路由的端口
+func isHTTPRoutedPort(port PortName) bool {
+	return port.HTTP && HTTPRoutingEnabled()
+}
+
+// 每个端口一个随机子域名，名字为 <pod>-<service 端口名>
+func createHTTPRoutes(clientset *kubernetes.Clientset, podInfo *PodInfo) error {
+	domain := strings.TrimPrefix(viper.GetString("k8s.http-routing.domain"), ".")
+
+	for c_index, c := range podInfo.Containers {
+		for _, port := range c.ExposePorts {
+			if !isHTTPRoutedPort(port) {
+				continue
+			}
+
+			portName := fmt.Sprintf("%d-%s", c_index, port.Name)
+			meta := metav1.ObjectMeta{
+				Name: fmt.Sprintf("%s-%s", podInfo.Name, portName),
+				Labels: map[string]string{
+					httpRoutePodLabel: podInfo.Name,
+				},
+				Annotations: map[string]string{
+					httpRoutePortAnnotation: portName,
+				},
+			}
+			for key, value := range podInfo.Labels {
+				meta.Labels[key] = value
+			}
+			host := fmt.Sprintf("%s.%s", uuid.NewString(), domain)
+
+			var err error
+			if httpRoutingMode() == HTTPRoutingModeHTTPRoute {
+				err = createHTTPRoute(meta, host, podInfo.Name, port.Port)
+			} else {
+				err = createIngress(clientset, meta, host, podInfo.Name, portName)
+			}
+			if err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+func createIngress(clientset *kubernetes.Clientset, meta metav1.ObjectMeta, host string, serviceName string, portName string) error {
+	namespace := "a1ctf-challenges"
+
+	for key, value := range viper.GetStringMapString("k8s.http-routing.annotations") {
+		meta.Annotations[key] = value
+	}
+
+	pathType := networkingv1.PathTypePrefix
+	ingress := &networkingv1.Ingress{
+		ObjectMeta: meta,
+		Spec: networkingv1.IngressSpec{
+			Rules: []networkingv1.IngressRule{
+				{
+					Host: host,
+					IngressRuleValue: networkingv1.IngressRuleValue{
+						HTTP: &networkingv1.HTTPIngressRuleValue{
+							Paths: []networkingv1.HTTPIngressPath{
+								{
+									Path:     "/",
+									PathType: &pathType,
+									Backend: networkingv1.IngressBackend{
+										Service: &networkingv1.IngressServiceBackend{
+											Name: serviceName,
+											Port: networkingv1.ServiceBackendPort{Name: portName},
+										},
+									},
+								},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	if className := viper.GetString("k8s.http-routing.ingress-class-name"); className != "" {
+		ingress.Spec.IngressClassName = &className
+	}
+
+	// 通配符证书，所有子域名共用
+	if secretName := viper.GetString("k8s.http-routing.tls-secret-name"); secretName != "" {
+		ingress.Spec.TLS = []networkingv1.IngressTLS{
+			{
+				Hosts:      []string{host},
+				SecretName: secretName,
+			},
+		}
+	}
+
+	_, err := clientset.NetworkingV1().Ingresses(namespace).Create(context.Background(), ingress, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("error creating ingress: %v", err)
+	}
+
+	return nil
+}
+
+// Gateway API 的类型不在 client-go 里，使用 dynamic client 创建
+// TLS 由 Gateway 的监听器配置
+func createHTTPRoute(meta metav1.ObjectMeta, host string, serviceName string, port int32) error {
+	namespace := "a1ctf-challenges"
+
+	client, err := getDynamicClient()
+	if err != nil {
+		return err
+	}
+
+	parentRef := map[string]interface{}{
+		"name": viper.GetString("k8s.http-routing.gateway-name"),
+	}
+	if gatewayNamespace := viper.GetString("k8s.http-routing.gateway-namespace"); gatewayNamespace != "" {
+		parentRef["namespace"] = gatewayNamespace
+	}
+	if sectionName := viper.GetString("k8s.http-routing.gateway-section-name"); sectionName != "" {
+		parentRef["sectionName"] = sectionName
+	}
+
+	labels := make(map[string]interface{}, len(meta.Labels))
+	for key, value := range meta.Labels {
+		labels[key] = value
+	}
+	annotations := make(map[string]interface{}, len(meta.Annotations))
+	for key, value := range meta.Annotations {
+		annotations[key] = value
+	}
+
+	route := &unstructured.Unstructured{
+		Object: map[string]interface{}{
+			"apiVersion": "gateway.networking.k8s.io/v1",
+			"kind":       "HTTPRoute",
+			"metadata": map[string]interface{}{
+				"name":        meta.Name,
+				"labels":      labels,
+				"annotations": annotations,
+			},
+			"spec": map[string]interface{}{
+				"parentRefs": []interface{}{parentRef},
+				"hostnames":  []interface{}{host},
+				"rules": []interface{}{
+					map[string]interface{}{
+						"backendRefs": []interface{}{
+							map[string]interface{}{
+								"name": serviceName,
+								"port": int64(port),
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+
+	_, err = client.Resource(httpRouteGVR).Namespace(namespace).Create(context.Background(), route, metav1.CreateOptions{})
+	if err != nil {
+		return fmt.Errorf("error creating http route: %v", err)
+	}
+
+	return nil
+}
+
+// 读取已经创建的路由，返回 service 端口名到访问地址的映射
+func getHTTPRouteHosts(clientset *kubernetes.Clientset, podName string) (map[string]string, error) {
+	namespace := "a1ctf-challenges"
+	result := make(map[string]string)
+	listOptions := metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", httpRoutePodLabel, podName)}
+
+	if httpRoutingMode() == HTTPRoutingModeHTTPRoute {
+		client, err := getDynamicClient()
+		if err != nil {
+			return nil, err
+		}
+
+		routes, err := client.Resource(httpRouteGVR).Namespace(namespace).List(context.Background(), listOptions)
+		if err != nil {
+			return nil, fmt.Errorf("error listing http routes: %v", err)
+		}
+
+		for _, route := range routes.Items {
+			hostnames, _, _ := unstructured.NestedStringSlice(route.Object, "spec", "hostnames")
+			if len(hostnames) > 0 {
+				result[route.GetAnnotations()[httpRoutePortAnnotation]] = hostnames[0]
+			}
+		}
+
+		return result, nil
+	}
+
+	ingresses, err := clientset.NetworkingV1().Ingresses(namespace).List(context.Background(), listOptions)
+	if err != nil {
+		return nil, fmt.Errorf("error listing ingresses: %v", err)
+	}
+
+	for _, ingress := range ingresses.Items {
+		if len(ingress.Spec.Rules) > 0 {
+			result[ingress.Annotations[httpRoutePortAnnotation]] = ingress.Spec.Rules[0].Host
+		}
+	}
+
+	return result, nil
+}
+
+// 为使用子域名路由的端口填上访问地址
+func fillHTTPRouteURLs(clientset *kubernetes.Clientset, podName string, ports *PodPorts) (*PodPorts, error) {
+	if !HTTPRoutingEnabled() {
+		return ports, nil
+	}
+
+	hosts, err := getHTTPRouteHosts(clientset, podName)
+	if err != nil {
+		return nil, err
+	}
+
+	for idx := range *ports {
+		if host, ok := hosts[(*ports)[idx].Name]; ok {
+			(*ports)[idx].URL = fmt.Sprintf("%s://%s", HTTPRoutingScheme(), host)
+		}
+	}
+
+	return ports, nil
+}
+
+// 忽略错误，和 Pod 的其他组件一样尽力删除
+func deleteHTTPRoutes(clientset *kubernetes.Clientset, podName string) {
+	namespace := "a1ctf-challenges"
+	listOptions := metav1.ListOptions{LabelSelector: fmt.Sprintf("%s=%s", httpRoutePodLabel, podName)}
+
+	if httpRoutingMode() == HTTPRoutingModeHTTPRoute {
+		client, err := getDynamicClient()
+		if err != nil {
+			return
+		}
+		_ = client.Resource(httpRouteGVR).Namespace(namespace).DeleteCollection(context.Background(), metav1.DeleteOptions{}, listOptions)
+		return
+	}
+
+	_ = clientset.NetworkingV1().Ingresses(namespace).DeleteCollection(context.Background(), metav1.DeleteOptions{}, listOptions)
+}
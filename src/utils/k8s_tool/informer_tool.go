@@ -0,0 +1,190 @@
+package k8stool
+
+import (
+	"context"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/spf13/viper"
+	corev1 "k8s.io/api/core/v1"
+	networkingv1 "k8s.io/api/networking/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/informers"
+	corelisters "k8s.io/client-go/listers/core/v1"
+	networkinglisters "k8s.io/client-go/listers/networking/v1"
+	"k8s.io/client-go/tools/cache"
+)
+
+// 按 team_hash 和 ingame_id 标签建立的索引，对应一个题目容器
+const ContainerIndex = "container"
+
+var (
+	podInformer         cache.SharedIndexInformer
+	podLister           corelisters.PodLister
+	serviceLister       corelisters.ServiceLister
+	networkPolicyLister networkinglisters.NetworkPolicyLister
+	// 缓存同步完成后才能读取
+	informersReady atomic.Bool
+)
+
+// ContainerKey 题目容器在索引和工作队列里的 key
+func ContainerKey(inGameID int64, teamHash string) string {
+	return fmt.Sprintf("%d/%s", inGameID, teamHash)
+}
+
+func containerIndexFunc(obj interface{}) ([]string, error) {
+	pod, ok := obj.(*corev1.Pod)
+	if !ok {
+		return nil, nil
+	}
+
+	teamHash, exists1 := pod.Labels["team_hash"]
+	inGameID, exists2 := pod.Labels["ingame_id"]
+	if !exists1 || !exists2 {
+		return nil, nil
+	}
+
+	return []string{inGameID + "/" + teamHash}, nil
+}
+
+// StartInformers 监听题目命名空间里的 Pod、Service 和 NetworkPolicy，替代轮询 ListPods
+// handler 收到 Pod 的变化，缓存同步完成后返回
+func StartInformers(ctx context.Context, handler cache.ResourceEventHandler) error {
+	clientset, err := GetClient()
+	if err != nil {
+		return err
+	}
+	namespace := "a1ctf-challenges"
+
+	resync := viper.GetDuration("k8s.informer-resync")
+	if resync <= 0 {
+		resync = 30 * time.Second
+	}
+
+	factory := informers.NewSharedInformerFactoryWithOptions(clientset, resync, informers.WithNamespace(namespace))
+
+	pods := factory.Core().V1().Pods()
+	if err := pods.Informer().AddIndexers(cache.Indexers{ContainerIndex: containerIndexFunc}); err != nil {
+		return fmt.Errorf("error adding pod indexer: %v", err)
+	}
+	if _, err := pods.Informer().AddEventHandler(handler); err != nil {
+		return fmt.Errorf("error adding pod event handler: %v", err)
+	}
+
+	services := factory.Core().V1().Services()
+	networkPolicies := factory.Networking().V1().NetworkPolicies()
+
+	// 需要在 Start 之前创建
+	services.Informer()
+	networkPolicies.Informer()
+
+	factory.Start(ctx.Done())
+
+	for informerType, synced := range factory.WaitForCacheSync(ctx.Done()) {
+		if !synced {
+			return fmt.Errorf("failed to sync informer cache for %v", informerType)
+		}
+	}
+
+	podInformer = pods.Informer()
+	podLister = pods.Lister()
+	serviceLister = services.Lister()
+	networkPolicyLister = networkPolicies.Lister()
+	informersReady.Store(true)
+
+	return nil
+}
+
+// CachedContainerPod 从本地缓存里按标签找到题目容器的 Pod
+func CachedContainerPod(inGameID int64, teamHash string) (*corev1.Pod, error) {
+	if !informersReady.Load() {
+		return nil, fmt.Errorf("informers are not started")
+	}
+
+	objs, err := podInformer.GetIndexer().ByIndex(ContainerIndex, ContainerKey(inGameID, teamHash))
+	if err != nil {
+		return nil, err
+	}
+	if len(objs) == 0 {
+		return nil, nil
+	}
+
+	return objs[0].(*corev1.Pod), nil
+}
+
+// CachedPods 本地缓存里的所有 Pod
+func CachedPods() ([]*corev1.Pod, error) {
+	if !informersReady.Load() {
+		return nil, fmt.Errorf("informers are not started")
+	}
+	return podLister.List(labels.Everything())
+}
+
+// CachedServices 本地缓存里的所有 Service
+func CachedServices() ([]*corev1.Service, error) {
+	if !informersReady.Load() {
+		return nil, fmt.Errorf("informers are not started")
+	}
+	return serviceLister.List(labels.Everything())
+}
+
+// CachedNetworkPolicies 本地缓存里的所有 NetworkPolicy
+func CachedNetworkPolicies() ([]*networkingv1.NetworkPolicy, error) {
+	if !informersReady.Load() {
+		return nil, fmt.Errorf("informers are not started")
+	}
+	return networkPolicyLister.List(labels.Everything())
+}
+
+// 按缓存里的 Service 释放手动分配的端口
+func releaseServicePorts(name string) {
+	if !informersReady.Load() || !manualPortAssignment() {
+		return
+	}
+
+	if service, err := serviceLister.Services("a1ctf-challenges").Get(name); err == nil {
+		for _, port := range service.Spec.Ports {
+			for _, allocator := range NodePortMap {
+				allocator.Release(int(port.NodePort))
+			}
+		}
+	}
+}
+
+// DeleteOrphan 删除数据库里已经没有对应容器的 Pod、Service 和 NetworkPolicy，释放手动分配的端口
+func DeleteOrphan(name string) error {
+	releaseServicePorts(name)
+
+	return forceDeletePod(name)
+}
+
+// CachedChallengeObjects 缓存里的所有 Pod、Service 和 NetworkPolicy，题目容器的三个对象同名
+func CachedChallengeObjects() ([]metav1.Object, error) {
+	pods, err := CachedPods()
+	if err != nil {
+		return nil, err
+	}
+	services, err := CachedServices()
+	if err != nil {
+		return nil, err
+	}
+	networkPolicies, err := CachedNetworkPolicies()
+	if err != nil {
+		return nil, err
+	}
+
+	result := make([]metav1.Object, 0, len(pods)+len(services)+len(networkPolicies))
+	for _, pod := range pods {
+		result = append(result, pod)
+	}
+	for _, service := range services {
+		result = append(result, service)
+	}
+	for _, networkPolicy := range networkPolicies {
+		result = append(result, networkPolicy)
+	}
+
+	return result, nil
+}
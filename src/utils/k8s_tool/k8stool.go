@@ -70,6 +70,21 @@ func InitNodePortRangeMap() {
 type PortName struct {
 	Name string `json:"name" validate:"required,portname" label:"PortName" message:"Port name must be a DNS_LABEL"`
 	Port int32  `json:"port" validate:"min=1,max=65535" label:"Port" message:"Port must be between 1 and 65535"`
+	// 开启 k8s.http-routing 后通过随机子域名访问，而不是 NodePort
+	HTTP bool `json:"http"`
+}
+
+// FlagConfig flag 的传入方式，flag 保存在和 Pod 同名的 Secret 里，不会出现在 Pod 的定义中
+type FlagConfig struct {
+	// 以文件挂载的路径，为空时不挂载
+	Path string `json:"path"`
+	// 文件的属主，设置后由 init 容器复制一份并修改属主
+	UID *int64 `json:"uid"`
+	GID *int64 `json:"gid"`
+	// 八进制的文件权限，默认 0444
+	Mode string `json:"mode"`
+	// 不再通过 A1CTF_FLAG 环境变量传入
+	DisableEnv bool `json:"disable_env"`
 }
 
 type A1Container struct {
@@ -81,6 +96,13 @@ type A1Container struct {
 	CPULimit     int64           `json:"cpu_limit" validate:"min=0" label:"CPULimit" message:"CPU limit must be greater than 0"`
 	MemoryLimit  int64           `json:"memory_limit" validate:"min=0" label:"MemoryLimit" message:"Memory limit must be greater than 0"`
 	StorageLimit int64           `json:"storage_limit" validate:"min=0" label:"StorageLimit" message:"Storage limit must be greater than 0"`
+	FlagConfig   *FlagConfig     `json:"flag_config,omitempty" validate:"-"`
+	// init 容器按顺序运行完成后才启动其他容器，不能暴露端口
+	Init            bool             `json:"init"`
+	Volumes         []VolumeMount    `json:"volumes,omitempty" validate:"-"`
+	ReadinessProbe  *Probe           `json:"readiness_probe,omitempty" validate:"-"`
+	SecurityContext *SecurityContext `json:"security_context,omitempty" validate:"-"`
+	EgressAllow     []EgressRule     `json:"egress_allow,omitempty" validate:"-"`
 }
 
 // 自定义验证函数 - 验证DNS标签格式
@@ -118,6 +140,17 @@ func ValidContainerConfig(containers []A1Container) error {
 				return fmt.Errorf("validation error: %v", err)
 			}
 		}
+
+		if err := validFlagConfig(container.FlagConfig); err != nil {
+			return fmt.Errorf("container %s: %v", container.Name, err)
+		}
+		if err := validContainerSpec(&container); err != nil {
+			return fmt.Errorf("container %s: %v", container.Name, err)
+		}
+	}
+
+	if len(containers) > 0 && FirstMainContainer(containers) == nil {
+		return errors.New("at least one container must not be an init container")
 	}
 	return nil
 }
@@ -137,13 +170,16 @@ func (e *A1Containers) Scan(value interface{}) error {
 }
 
 type PodInfo struct {
-	Name       string
-	TeamHash   string
-	Labels     map[string]string
-	Containers []A1Container
-	Flag       string
-	AllowWAN   bool
-	AllowDNS   bool
+	Name     string
+	TeamHash string
+	Labels   map[string]string
+	// Service 和 NetworkPolicy 选择 Pod 使用的标签，为空时使用 Labels
+	// 预热的 Pod 认领后会修改标签，只用不变的那部分选择
+	SelectorLabels map[string]string
+	Containers     []A1Container
+	Flag           string
+	AllowWAN       bool
+	AllowDNS       bool
 }
 
 func GetClient() (*kubernetes.Clientset, error) {
@@ -199,6 +235,13 @@ func IsIPv6(addr string) bool {
 	return ip.To4() == nil
 }
 
+func (p *PodInfo) selector() map[string]string {
+	if len(p.SelectorLabels) > 0 {
+		return p.SelectorLabels
+	}
+	return p.Labels
+}
+
 func CreatePod(podInfo *PodInfo) error {
 	clientset, err := GetClient()
 	if err != nil {
@@ -206,9 +249,23 @@ func CreatePod(podInfo *PodInfo) error {
 	}
 	namespace := "a1ctf-challenges"
 
+	fastVal := false
+
+	pod := &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   podInfo.Name,
+			Labels: podInfo.Labels,
+		},
+		Spec: corev1.PodSpec{
+			EnableServiceLinks: &fastVal,
+		},
+	}
+
 	// 构造 Pod 中的容器列表
 	var containers []corev1.Container
-	for _, c := range podInfo.Containers {
+	var initContainers []corev1.Container
+	pod.Spec.Volumes = podVolumes(podInfo.Containers)
+	for index, c := range podInfo.Containers {
 		containerName := c.Name
 		container := corev1.Container{
 			Name:  containerName,
@@ -222,11 +279,10 @@ func CreatePod(podInfo *PodInfo) error {
 			container.Env = c.Env
 		}
 
-		// add the flag env
-		container.Env = append(container.Env, corev1.EnvVar{
-			Name:  "A1CTF_FLAG",
-			Value: podInfo.Flag,
-		})
+		// flag 从 Secret 读取，预热的 Pod 没有 flag
+		if podInfo.Flag != "" {
+			mountFlag(&pod.Spec, &container, index, podInfo.Name, c.FlagConfig)
+		}
 
 		if len(c.ExposePorts) > 0 {
 			var containerPorts []corev1.ContainerPort
@@ -258,21 +314,17 @@ func CreatePod(podInfo *PodInfo) error {
 			Requests: requests,
 		}
 
-		containers = append(containers, container)
-	}
-
-	fastVal := false
+		applyContainerSpec(&container, &c)
 
-	pod := &corev1.Pod{
-		ObjectMeta: metav1.ObjectMeta{
-			Name:   podInfo.Name,
-			Labels: podInfo.Labels,
-		},
-		Spec: corev1.PodSpec{
-			Containers:         containers,
-			EnableServiceLinks: &fastVal,
-		},
+		if c.Init {
+			initContainers = append(initContainers, container)
+		} else {
+			containers = append(containers, container)
+		}
 	}
+	// 复制 flag 的 init 容器在前面
+	pod.Spec.InitContainers = append(pod.Spec.InitContainers, initContainers...)
+	pod.Spec.Containers = containers
 
 	if viper.GetBool("k8s.custom-dns-server.enabled") {
 		pod.Spec.DNSPolicy = corev1.DNSNone
@@ -289,13 +341,23 @@ func CreatePod(podInfo *PodInfo) error {
 		pod.Spec.ImagePullSecrets = secrets
 	}
 
+	if podInfo.Flag != "" {
+		if err := createFlagSecret(clientset, podInfo); err != nil {
+			return err
+		}
+	}
+
 	// 创建 Pod
-	_, err = clientset.CoreV1().Pods(namespace).Create(context.Background(), pod, metav1.CreateOptions{})
+	createdPod, err := clientset.CoreV1().Pods(namespace).Create(context.Background(), pod, metav1.CreateOptions{})
 	if err != nil {
 		return fmt.Errorf("error creating pod: %v", err)
 	}
 
-	if !viper.GetBool("k8s.manual-port-assignments.enabled") {
+	if podInfo.Flag != "" {
+		adoptFlagSecret(clientset, createdPod)
+	}
+
+	if !manualPortAssignment() {
 		// 构造 Service 的端口配置
 		var servicePorts []corev1.ServicePort
 		for c_index, c := range podInfo.Containers {
@@ -317,8 +379,8 @@ func CreatePod(podInfo *PodInfo) error {
 					Name: podInfo.Name,
 				},
 				Spec: corev1.ServiceSpec{
-					Type:     corev1.ServiceTypeNodePort,
-					Selector: podInfo.Labels,
+					Type:     serviceType(),
+					Selector: podInfo.selector(),
 					Ports:    servicePorts,
 				},
 			}
@@ -330,7 +392,14 @@ func CreatePod(podInfo *PodInfo) error {
 		}
 	}
 
+	if HTTPRoutingEnabled() {
+		if err := createHTTPRoutes(clientset, podInfo); err != nil {
+			return err
+		}
+	}
+
 	allowedPorts := []networkingv1.NetworkPolicyPort{}
+	httpPorts := []networkingv1.NetworkPolicyPort{}
 	for _, c := range podInfo.Containers {
 		for _, port := range c.ExposePorts {
 			allowedPorts = append(allowedPorts, networkingv1.NetworkPolicyPort{
@@ -341,6 +410,11 @@ func CreatePod(podInfo *PodInfo) error {
 				// }(),
 				Port: &intstr.IntOrString{IntVal: port.Port},
 			})
+			if isHTTPRoutedPort(port) {
+				httpPorts = append(httpPorts, networkingv1.NetworkPolicyPort{
+					Port: &intstr.IntOrString{IntVal: port.Port},
+				})
+			}
 		}
 	}
 
@@ -352,7 +426,7 @@ func CreatePod(podInfo *PodInfo) error {
 			},
 			Spec: networkingv1.NetworkPolicySpec{
 				PodSelector: metav1.LabelSelector{
-					MatchLabels: podInfo.Labels,
+					MatchLabels: podInfo.selector(),
 				},
 				PolicyTypes: []networkingv1.PolicyType{
 					networkingv1.PolicyTypeIngress,
@@ -378,6 +452,24 @@ func CreatePod(podInfo *PodInfo) error {
 			},
 		}
 
+		// Ingress 控制器或 Gateway 在集群内部，需要单独放行
+		if len(httpPorts) > 0 {
+			if controllerNamespace := viper.GetString("k8s.http-routing.controller-namespace"); controllerNamespace != "" {
+				networkPolicy.Spec.Ingress = append(networkPolicy.Spec.Ingress, networkingv1.NetworkPolicyIngressRule{
+					From: []networkingv1.NetworkPolicyPeer{
+						{
+							NamespaceSelector: &metav1.LabelSelector{
+								MatchLabels: map[string]string{
+									"kubernetes.io/metadata.name": controllerNamespace,
+								},
+							},
+						},
+					},
+					Ports: httpPorts,
+				})
+			}
+		}
+
 		if podInfo.AllowDNS {
 			dnsEgressRule := networkingv1.NetworkPolicyEgressRule{
 				To: []networkingv1.NetworkPolicyPeer{
@@ -438,6 +530,9 @@ func CreatePod(podInfo *PodInfo) error {
 			networkPolicy.Spec.Egress = append(networkPolicy.Spec.Egress, dnsEgressRule)
 		}
 
+		// 容器配置的出站白名单
+		networkPolicy.Spec.Egress = append(networkPolicy.Spec.Egress, egressAllowRules(podInfo.Containers)...)
+
 		_, err = clientset.NetworkingV1().NetworkPolicies(namespace).Create(context.Background(), networkPolicy, metav1.CreateOptions{})
 		if err != nil {
 			return fmt.Errorf("error creating network policy: %v", err)
@@ -452,6 +547,28 @@ type PodPort struct {
 	Port     int32  `json:"port"`
 	NodePort int32  `json:"node_port"`
 	NodeName string `json:"node_name"`
+	// 通过子域名路由访问的端口，形如 https://<uuid>.chal.example.com
+	URL string `json:"url,omitempty"`
+	// 集群内的 Pod 地址，TCP 网关在集群内运行时直接连接
+	PodIP string `json:"pod_ip,omitempty"`
+	// 开启 TCP 网关时 Service 只有集群内地址，网关连接 ClusterIP 和 Port
+	ClusterIP string `json:"cluster_ip,omitempty"`
+}
+
+// 开启 TCP 网关时选手只通过网关访问，不再分配 NodePort
+func gatewayEnabled() bool {
+	return viper.GetBool("tcp-gateway.enabled")
+}
+
+func manualPortAssignment() bool {
+	return viper.GetBool("k8s.manual-port-assignments.enabled") && !gatewayEnabled()
+}
+
+func serviceType() corev1.ServiceType {
+	if gatewayEnabled() {
+		return corev1.ServiceTypeClusterIP
+	}
+	return corev1.ServiceTypeNodePort
 }
 
 type PodPorts []PodPort
@@ -474,7 +591,7 @@ func GetPodPorts(podInfo *PodInfo) (*PodPorts, error) {
 	nodeName := pod.Spec.NodeName
 
 	// 获取对应 Service 信息
-	if !viper.GetBool("k8s.manual-port-assignments.enabled") {
+	if !manualPortAssignment() {
 		service, err := clientset.CoreV1().Services(namespace).Get(context.Background(), podInfo.Name, metav1.GetOptions{})
 		if err != nil {
 			return nil, fmt.Errorf("error getting service: %v", err)
@@ -483,14 +600,16 @@ func GetPodPorts(podInfo *PodInfo) (*PodPorts, error) {
 		result := make(PodPorts, 0)
 		for _, port := range service.Spec.Ports {
 			result = append(result, PodPort{
-				Name:     port.Name,
-				Port:     port.Port,
-				NodePort: port.NodePort,
-				NodeName: nodeName,
+				Name:      port.Name,
+				Port:      port.Port,
+				NodePort:  port.NodePort,
+				NodeName:  nodeName,
+				PodIP:     pod.Status.PodIP,
+				ClusterIP: service.Spec.ClusterIP,
 			})
 		}
 
-		return &result, nil
+		return fillHTTPRouteURLs(clientset, podInfo.Name, &result)
 	} else {
 		portAlloc, exists := NodePortMap[nodeName]
 		if !exists {
@@ -503,6 +622,22 @@ func GetPodPorts(podInfo *PodInfo) (*PodPorts, error) {
 			if len(c.ExposePorts) > 0 {
 				for _, port := range c.ExposePorts {
 
+					// 子域名路由的端口不占用手动分配的端口
+					if isHTTPRoutedPort(port) {
+						servicePorts = append(servicePorts, corev1.ServicePort{
+							Name:       fmt.Sprintf("%d-%s", c_index, port.Name),
+							Port:       port.Port,
+							TargetPort: intstr.FromInt(int(port.Port)),
+						})
+						result = append(result, PodPort{
+							Name:     fmt.Sprintf("%d-%s", c_index, port.Name),
+							Port:     port.Port,
+							NodeName: nodeName,
+							PodIP:    pod.Status.PodIP,
+						})
+						continue
+					}
+
 					availablePort, err := portAlloc.Get()
 					if err != nil {
 						return nil, err
@@ -521,6 +656,7 @@ func GetPodPorts(podInfo *PodInfo) (*PodPorts, error) {
 						Port:     port.Port,
 						NodePort: int32(availablePort),
 						NodeName: nodeName,
+						PodIP:    pod.Status.PodIP,
 					})
 				}
 			}
@@ -533,7 +669,7 @@ func GetPodPorts(podInfo *PodInfo) (*PodPorts, error) {
 				},
 				Spec: corev1.ServiceSpec{
 					Type:     corev1.ServiceTypeNodePort,
-					Selector: podInfo.Labels,
+					Selector: podInfo.selector(),
 					Ports:    servicePorts,
 				},
 			}
@@ -544,7 +680,7 @@ func GetPodPorts(podInfo *PodInfo) (*PodPorts, error) {
 			}
 		}
 
-		return &result, nil
+		return fillHTTPRouteURLs(clientset, podInfo.Name, &result)
 	}
 }
 
@@ -554,6 +690,8 @@ func DeletePod(podInfo *PodInfo, ports []int32) error {
 			allocator.Release(int(port))
 		}
 	}
+	// 通过网关暴露的端口不在 ports 里，按 Service 释放
+	releaseServicePorts(podInfo.Name)
 
 	return forceDeletePod(podInfo.Name)
 }
@@ -587,6 +725,14 @@ func forceDeletePod(podName string) error {
 	// 	return fmt.Errorf("error deleting network policy: %v", err)
 	// }
 
+	// 删除子域名路由
+	if HTTPRoutingEnabled() {
+		deleteHTTPRoutes(clientset, podName)
+	}
+
+	// 删除保存 flag 的 Secret
+	_ = clientset.CoreV1().Secrets(namespace).Delete(context.Background(), podName, metav1.DeleteOptions{})
+
 	return nil
 }
 
@@ -0,0 +1,58 @@
+package k8stool
+
+import (
+	"context"
+	"fmt"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/apis/meta/v1/unstructured"
+	"k8s.io/apimachinery/pkg/runtime/schema"
+)
+
+// metrics-server 提供的 Pod 资源占用，不在 client-go 里，使用 dynamic client 读取
+var podMetricsGVR = schema.GroupVersionResource{
+	Group:    "metrics.k8s.io",
+	Version:  "v1beta1",
+	Resource: "pods",
+}
+
+// GetPodCPUUsage Pod 所有容器最近一次采样的 CPU 占用之和，单位 millicore
+func GetPodCPUUsage(podName string) (int64, error) {
+	namespace := "a1ctf-challenges"
+
+	client, err := getDynamicClient()
+	if err != nil {
+		return 0, err
+	}
+
+	metrics, err := client.Resource(podMetricsGVR).Namespace(namespace).Get(context.Background(), podName, metav1.GetOptions{})
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			return 0, fmt.Errorf("metrics of pod %s not found", podName)
+		}
+		return 0, fmt.Errorf("error getting pod metrics: %v", err)
+	}
+
+	containers, _, _ := unstructured.NestedSlice(metrics.Object, "containers")
+
+	var usage int64
+	for _, item := range containers {
+		container, ok := item.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		cpu, _, _ := unstructured.NestedString(container, "usage", "cpu")
+		if cpu == "" {
+			continue
+		}
+		quantity, err := resource.ParseQuantity(cpu)
+		if err != nil {
+			return 0, fmt.Errorf("invalid cpu usage %s: %v", cpu, err)
+		}
+		usage += quantity.MilliValue()
+	}
+
+	return usage, nil
+}
@@ -0,0 +1,51 @@
+package k8stool
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/remotecommand"
+)
+
+// ExecInPod 在容器里执行一条命令，stdin 可以为空，返回标准输出
+func ExecInPod(ctx context.Context, podName string, containerName string, command []string, stdin io.Reader) (string, error) {
+	clientset, err := GetClient()
+	if err != nil {
+		return "", err
+	}
+	namespace := "a1ctf-challenges"
+
+	req := clientset.CoreV1().RESTClient().Post().
+		Resource("pods").
+		Name(podName).
+		Namespace(namespace).
+		SubResource("exec").
+		VersionedParams(&corev1.PodExecOptions{
+			Container: containerName,
+			Command:   command,
+			Stdin:     stdin != nil,
+			Stdout:    true,
+			Stderr:    true,
+		}, scheme.ParameterCodec)
+
+	exec, err := remotecommand.NewSPDYExecutor(GetClientConfig(), "POST", req.URL())
+	if err != nil {
+		return "", fmt.Errorf("error creating executor: %v", err)
+	}
+
+	var stdout, stderr bytes.Buffer
+	err = exec.StreamWithContext(ctx, remotecommand.StreamOptions{
+		Stdin:  stdin,
+		Stdout: &stdout,
+		Stderr: &stderr,
+	})
+	if err != nil {
+		return stdout.String(), fmt.Errorf("error executing %v in pod %s: %v: %s", command, podName, err, stderr.String())
+	}
+
+	return stdout.String(), nil
+}
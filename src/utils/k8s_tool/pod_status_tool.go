@@ -159,8 +159,24 @@ func handlePendingPod(pod *corev1.Pod) (PodStatusDecision, error) {
 		}
 	}
 
+	// init 容器失败后会不断重启，Pod 一直是 Pending
+	for _, containerStatus := range pod.Status.InitContainerStatuses {
+		if containerStatus.State.Waiting != nil && containerStatus.State.Waiting.Reason == "CrashLoopBackOff" {
+			return PodStatusDecision{
+				Status:         CustomPodFailed,
+				ShouldContinue: false,
+				ShouldReport:   true,
+				Message: fmt.Sprintf("Init container %s keeps failing: %s",
+					containerStatus.Name, containerStatus.State.Waiting.Message),
+			}, nil
+		}
+	}
+
 	// 检查容器状态
-	for _, containerStatus := range pod.Status.ContainerStatuses {
+	statuses := make([]corev1.ContainerStatus, 0, len(pod.Status.InitContainerStatuses)+len(pod.Status.ContainerStatuses))
+	statuses = append(statuses, pod.Status.InitContainerStatuses...)
+	statuses = append(statuses, pod.Status.ContainerStatuses...)
+	for _, containerStatus := range statuses {
 		if containerStatus.State.Waiting != nil {
 			switch containerStatus.State.Waiting.Reason {
 			case "ErrImagePull", "ImagePullBackOff":
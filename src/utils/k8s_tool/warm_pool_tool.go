@@ -0,0 +1,253 @@
+package k8stool
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/spf13/viper"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+)
+
+const (
+	// 预热 Pod 的名字，Service 和 NetworkPolicy 用这个标签选择 Pod，认领后不变
+	WarmPodLabel = "a1ctf-warm-pod"
+	// 所属的预热池，值为 <ingame_id>-<容器配置的哈希>，认领时删除
+	WarmPoolLabel = "a1ctf-warm-pool"
+	// 预拉取镜像的 DaemonSet，值为 ingame_id
+	PrewarmLabel = "a1ctf-prewarm"
+)
+
+// IsPodReady Pod 在运行并且所有容器都已就绪
+func IsPodReady(pod *corev1.Pod) bool {
+	if pod.DeletionTimestamp != nil || pod.Status.Phase != corev1.PodRunning {
+		return false
+	}
+	if len(pod.Status.ContainerStatuses) == 0 {
+		return false
+	}
+	for _, status := range pod.Status.ContainerStatuses {
+		if !status.Ready {
+			return false
+		}
+	}
+	return true
+}
+
+// CachedWarmPoolPods 本地缓存里还没有被认领的预热 Pod，按预热池分组
+func CachedWarmPoolPods() (map[string][]*corev1.Pod, error) {
+	if !informersReady.Load() {
+		return nil, fmt.Errorf("informers are not started")
+	}
+
+	selector, err := labels.Parse(WarmPoolLabel)
+	if err != nil {
+		return nil, err
+	}
+
+	pods, err := podLister.List(selector)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]*corev1.Pod)
+	for _, pod := range pods {
+		pool := pod.Labels[WarmPoolLabel]
+		result[pool] = append(result[pool], pod)
+	}
+
+	return result, nil
+}
+
+// ClaimWarmPod 从预热池里认领一个就绪的 Pod，换成队伍的标签，没有可用的 Pod 时返回空字符串
+// 更新时带上 resourceVersion，多个实例同时认领同一个 Pod 时只有一个成功
+func ClaimWarmPod(pool string, teamLabels map[string]string) (string, error) {
+	clientset, err := GetClient()
+	if err != nil {
+		return "", err
+	}
+	namespace := "a1ctf-challenges"
+
+	pools, err := CachedWarmPoolPods()
+	if err != nil {
+		return "", err
+	}
+
+	return ClaimReadyPod(pools[pool], teamLabels, func(pod *corev1.Pod) error {
+		_, err := clientset.CoreV1().Pods(namespace).Update(context.Background(), pod, metav1.UpdateOptions{})
+		return err
+	})
+}
+
+// ClaimReadyPod 按创建时间从早到晚认领 pods 里就绪的 Pod，去掉预热池标签并换成队伍的标签
+// pods 可能是过期的缓存，update 需要按 resourceVersion 更新，返回冲突或者不存在时换下一个 Pod
+func ClaimReadyPod(pods []*corev1.Pod, teamLabels map[string]string, update func(pod *corev1.Pod) error) (string, error) {
+	candidates := make([]*corev1.Pod, 0, len(pods))
+	for _, pod := range pods {
+		if IsPodReady(pod) {
+			candidates = append(candidates, pod)
+		}
+	}
+
+	// 先认领最早创建的 Pod
+	sort.Slice(candidates, func(i, j int) bool {
+		return candidates[i].CreationTimestamp.Before(&candidates[j].CreationTimestamp)
+	})
+
+	for _, candidate := range candidates {
+		pod := candidate.DeepCopy()
+		delete(pod.Labels, WarmPoolLabel)
+		for key, value := range teamLabels {
+			pod.Labels[key] = value
+		}
+
+		if err := update(pod); err != nil {
+			if apierrors.IsConflict(err) || apierrors.IsNotFound(err) {
+				continue
+			}
+			return "", fmt.Errorf("error claiming warm pod: %v", err)
+		}
+
+		return pod.Name, nil
+	}
+
+	return "", nil
+}
+
+// ApplyPrewarmDaemonSet 在所有节点上拉取题目的镜像
+// 每个镜像一个 init 容器，执行从 helper 镜像复制出来的 busybox，题目镜像里不需要有 shell
+func ApplyPrewarmDaemonSet(name string, pool string, images []string) error {
+	clientset, err := GetClient()
+	if err != nil {
+		return err
+	}
+	namespace := "a1ctf-challenges"
+
+	helperImage := viper.GetString("k8s.image-prewarm.helper-image")
+	if helperImage == "" {
+		helperImage = "busybox:1.36"
+	}
+	pauseImage := viper.GetString("k8s.image-prewarm.pause-image")
+	if pauseImage == "" {
+		pauseImage = "registry.k8s.io/pause:3.10"
+	}
+
+	limits := corev1.ResourceList{
+		corev1.ResourceCPU:    *resource.NewMilliQuantity(50, resource.DecimalSI),
+		corev1.ResourceMemory: *resource.NewQuantity(32*1024*1024, resource.BinarySI),
+	}
+	volumeMounts := []corev1.VolumeMount{{Name: "prewarm", MountPath: "/a1ctf-prewarm"}}
+
+	initContainers := []corev1.Container{
+		{
+			Name:            "helper",
+			Image:           helperImage,
+			Command:         []string{"cp", "/bin/busybox", "/a1ctf-prewarm/true"},
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			VolumeMounts:    volumeMounts,
+			Resources:       corev1.ResourceRequirements{Limits: limits},
+		},
+	}
+	for index, image := range images {
+		initContainers = append(initContainers, corev1.Container{
+			Name:            fmt.Sprintf("image-%d", index),
+			Image:           image,
+			Command:         []string{"/a1ctf-prewarm/true"},
+			ImagePullPolicy: corev1.PullIfNotPresent,
+			VolumeMounts:    volumeMounts,
+			Resources:       corev1.ResourceRequirements{Limits: limits},
+		})
+	}
+
+	podLabels := map[string]string{PrewarmLabel: pool, "a1ctf-prewarm-name": name}
+	daemonSet := &appsv1.DaemonSet{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   name,
+			Labels: map[string]string{PrewarmLabel: pool},
+		},
+		Spec: appsv1.DaemonSetSpec{
+			Selector: &metav1.LabelSelector{MatchLabels: podLabels},
+			Template: corev1.PodTemplateSpec{
+				ObjectMeta: metav1.ObjectMeta{Labels: podLabels},
+				Spec: corev1.PodSpec{
+					InitContainers: initContainers,
+					Containers: []corev1.Container{
+						{
+							Name:      "pause",
+							Image:     pauseImage,
+							Resources: corev1.ResourceRequirements{Limits: limits},
+						},
+					},
+					Volumes: []corev1.Volume{
+						{Name: "prewarm", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+					},
+					// 题目节点上可能有污点，全部容忍
+					Tolerations: []corev1.Toleration{{Operator: corev1.TolerationOpExists}},
+				},
+			},
+		},
+	}
+
+	for _, secretName := range viper.GetStringSlice("k8s.pull-secret-names") {
+		daemonSet.Spec.Template.Spec.ImagePullSecrets = append(daemonSet.Spec.Template.Spec.ImagePullSecrets, corev1.LocalObjectReference{Name: secretName})
+	}
+
+	existing, err := clientset.AppsV1().DaemonSets(namespace).Get(context.Background(), name, metav1.GetOptions{})
+	if err != nil {
+		if !apierrors.IsNotFound(err) {
+			return fmt.Errorf("error getting prewarm daemonset: %v", err)
+		}
+		if _, err := clientset.AppsV1().DaemonSets(namespace).Create(context.Background(), daemonSet, metav1.CreateOptions{}); err != nil {
+			return fmt.Errorf("error creating prewarm daemonset: %v", err)
+		}
+		return nil
+	}
+
+	existing.Spec.Template = daemonSet.Spec.Template
+	if _, err := clientset.AppsV1().DaemonSets(namespace).Update(context.Background(), existing, metav1.UpdateOptions{}); err != nil {
+		return fmt.Errorf("error updating prewarm daemonset: %v", err)
+	}
+
+	return nil
+}
+
+// ListPrewarmDaemonSets 所有预拉取镜像的 DaemonSet，返回名字到 ingame_id 的映射
+func ListPrewarmDaemonSets() (map[string]string, error) {
+	clientset, err := GetClient()
+	if err != nil {
+		return nil, err
+	}
+	namespace := "a1ctf-challenges"
+
+	daemonSets, err := clientset.AppsV1().DaemonSets(namespace).List(context.Background(), metav1.ListOptions{LabelSelector: PrewarmLabel})
+	if err != nil {
+		return nil, fmt.Errorf("error listing prewarm daemonsets: %v", err)
+	}
+
+	result := make(map[string]string, len(daemonSets.Items))
+	for _, daemonSet := range daemonSets.Items {
+		result[daemonSet.Name] = daemonSet.Labels[PrewarmLabel]
+	}
+
+	return result, nil
+}
+
+func DeletePrewarmDaemonSet(name string) error {
+	clientset, err := GetClient()
+	if err != nil {
+		return err
+	}
+	namespace := "a1ctf-challenges"
+
+	err = clientset.AppsV1().DaemonSets(namespace).Delete(context.Background(), name, metav1.DeleteOptions{})
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("error deleting prewarm daemonset: %v", err)
+	}
+
+	return nil
+}
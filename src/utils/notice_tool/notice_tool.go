@@ -44,3 +44,32 @@ func AnnounceNotice(notice models.Notice) {
 		}
 	}
 }
+
+// AnnounceJudgeResult 只推送给提交的队伍
+func AnnounceJudgeResult(judge models.Judge) {
+	if judge.TeamID == 0 {
+		return
+	}
+
+	msg, _ := sonic.Marshal(map[string]interface{}{
+		"type": "JudgeResult",
+		"message": map[string]interface{}{
+			"judge_id":     judge.JudgeID,
+			"challenge_id": judge.ChallengeID,
+			"ingame_id":    judge.IngameID,
+			"judge_status": judge.JudgeStatus,
+			"judge_result": judge.JudgeResult,
+		},
+	})
+
+	// 按推送时的队伍成员找连接，连接之后才加入队伍的成员也能收到
+	var team models.Team
+	if err := dbtool.DB().Select("team_id", "team_members").Where("team_id = ?", judge.TeamID).First(&team).Error; err != nil {
+		zaphelper.Logger.Error("Failed to load team for judge result", zap.Error(err), zap.Int64("team_id", judge.TeamID))
+		return
+	}
+
+	for _, session := range dbtool.UserSessions(judge.GameID, team.TeamMembers) {
+		session.Write(msg)
+	}
+}
@@ -2,12 +2,12 @@ package ristretto_tool
 
 import (
 	"a1ctf/src/db/models"
+	"a1ctf/src/modules/scoring"
 	dbtool "a1ctf/src/utils/db_tool"
 	"a1ctf/src/webmodels"
 	"errors"
 	"fmt"
 	"log"
-	"math"
 	"math/rand"
 	"sort"
 	"time"
@@ -304,6 +304,111 @@ func CoverToLowCost(timelines []webmodels.TimeLineItem) []webmodels.TimeLineItem
 	return lowCostList
 }
 
+func bloodRewardReason(rank int32) string {
+	switch rank {
+	case 1:
+		return "First Blood Reward"
+	case 2:
+		return "Second Blood Reward"
+	case 3:
+		return "Third Blood Reward"
+	default:
+		return fmt.Sprintf("Rank %d Reward", rank)
+	}
+}
+
+// BuildTeamScoreItems 根据加载好的解题、分数修正和额外分数填充 teamDataMap 里每个队伍的分数、罚时和解题明细，
+// 总分和积分引擎使用同一套计算。solves 需要按解题时间升序排列
+func BuildTeamScoreItems(game *models.Game, teamDataMap map[int64]webmodels.TeamScoreItem, solves []models.Solve, gameChallengeMap map[int64]*models.GameChallenge, adjustments []models.ScoreAdjustment, adScoreMap map[int64]*webmodels.TeamADScoreItem, kothScoreMap map[int64]float64) {
+	// 计算每道题的首杀时间
+	firstSolveTime := make(map[int64]time.Time) // challengeID -> 首杀时间
+	for _, solve := range solves {
+		if _, exists := firstSolveTime[solve.ChallengeID]; !exists {
+			firstSolveTime[solve.ChallengeID] = solve.SolveTime
+		}
+	}
+
+	teamSolves := make(map[int64][]models.Solve)
+	for _, solve := range solves {
+		teamSolves[solve.TeamID] = append(teamSolves[solve.TeamID], solve)
+	}
+
+	teamAdjustments := make(map[int64][]models.ScoreAdjustment)
+	for _, adjustment := range adjustments {
+		teamAdjustments[adjustment.TeamID] = append(teamAdjustments[adjustment.TeamID], adjustment)
+	}
+
+	for teamID, teamData := range teamDataMap {
+		adjustmentSum := float64(0)
+		for _, adjustment := range teamAdjustments[teamID] {
+			adjustmentSum += adjustment.ScoreChange
+		}
+
+		extra := kothScoreMap[teamID]
+		if adScore, ok := adScoreMap[teamID]; ok {
+			extra += adScore.AttackScore + adScore.DefenseScore + adScore.SLAScore
+			teamData.ADScore = adScore
+		}
+		teamData.KothScore = kothScoreMap[teamID]
+
+		teamScore := scoring.CalculateTeamScore(game, gameChallengeMap, teamSolves[teamID], adjustmentSum, extra)
+
+		for _, item := range teamScore.Solves {
+			solve := item.Solve
+
+			// 计算罚时（解题时间 - 首杀时间，单位：秒）
+			penalty := int64(0)
+			if firstTime, ok := firstSolveTime[solve.ChallengeID]; ok {
+				penalty = int64(solve.SolveTime.Sub(firstTime).Seconds())
+			}
+
+			if item.BloodReward > 0 {
+				// 往前端添加血奖的加分记录
+				teamData.ScoreAdjustments = append(teamData.ScoreAdjustments, webmodels.TeamScoreAdjustmentItem{
+					AdjustmentID:   -1,
+					AdjustmentType: string(models.AdjustmentTypeReward),
+					ScoreChange:    item.BloodReward,
+					Reason:         fmt.Sprintf("%s for %s", bloodRewardReason(solve.Rank), solve.Challenge.Name),
+					CreatedAt:      solve.SolveTime,
+				})
+			}
+
+			teamData.Penalty += penalty
+
+			// 插入解题记录
+			teamData.SolvedChallenges = append(teamData.SolvedChallenges, webmodels.TeamSolveItem{
+				ChallengeID:   solve.ChallengeID,
+				Score:         item.ChallengeScore + item.BloodReward,
+				Solver:        solve.Solver.Username,
+				Rank:          int64(solve.Rank),
+				SolveTime:     solve.SolveTime,
+				BloodReward:   item.BloodReward,
+				ChallengeName: solve.Challenge.Name,
+			})
+
+			// 更新最后解题时间
+			if teamData.LastSolveTime < solve.SolveTime.UnixMilli() {
+				teamData.LastSolveTime = solve.SolveTime.UnixMilli()
+			}
+		}
+
+		// 添加分数修正到队伍的分数修正列表
+		for _, adjustment := range teamAdjustments[teamID] {
+			teamData.ScoreAdjustments = append(teamData.ScoreAdjustments, webmodels.TeamScoreAdjustmentItem{
+				AdjustmentID:   adjustment.AdjustmentID,
+				AdjustmentType: string(adjustment.AdjustmentType),
+				ScoreChange:    adjustment.ScoreChange,
+				Reason:         adjustment.Reason,
+				CreatedAt:      adjustment.CreatedAt,
+			})
+		}
+
+		teamData.Score = teamScore.Total
+		teamDataMap[teamID] = teamData
+	}
+
+}
+
 func CalculateGameScoreBoard(gameID int64) (*webmodels.CachedGameScoreBoardData, error) {
 	var cachedData webmodels.CachedGameScoreBoardData
 
@@ -332,7 +437,6 @@ func CalculateGameScoreBoard(gameID int64) (*webmodels.CachedGameScoreBoardData,
 	if err := dbtool.DB().Where(`game_id = ? 
 	AND solve_time >= ? 
 	AND solve_time <= ?`, gameID, game.StartTime, game.EndTime).
-		Preload("GameChallenge").
 		Preload("Solver").
 		Preload("Challenge").
 		Preload("Game").
@@ -344,14 +448,6 @@ func CalculateGameScoreBoard(gameID int64) (*webmodels.CachedGameScoreBoardData,
 
 	solves = filterValidSolves(solves)
 
-	// 计算每道题的首杀时间
-	firstSolveTime := make(map[int64]time.Time) // challengeID -> 首杀时间
-	for _, solve := range solves {
-		if _, exists := firstSolveTime[solve.ChallengeID]; !exists {
-			firstSolveTime[solve.ChallengeID] = solve.SolveTime
-		}
-	}
-
 	// 计算每个队伍的总分和罚时
 	teamDataMap := make(map[int64]webmodels.TeamScoreItem)
 
@@ -396,120 +492,73 @@ func CalculateGameScoreBoard(gameID int64) (*webmodels.CachedGameScoreBoardData,
 		}
 	}
 
-	// 计算每个队伍的分数和罚时
-	for _, solve := range solves {
-		if !solve.GameChallenge.Visible {
-			continue
-		}
-
-		if teamData, exists := teamDataMap[solve.TeamID]; exists {
-			// 计算罚时（解题时间 - 首杀时间，单位：秒）
-			penalty := int64(0)
-			if firstTime, ok := firstSolveTime[solve.ChallengeID]; ok {
-				penalty = int64(solve.SolveTime.Sub(firstTime).Seconds())
-			}
-
-			challengeScore := solve.GameChallenge.CurScore
-			rewardScore := 0.0
-
-			// 这里计算分数了，处理一下三血
-			if solve.GameChallenge.BloodRewardEnabled && solve.Rank <= 3 {
-
-				var rewardReason string
-				// 三血对于的奖励分数比例是否开启
-				var rankRewardEnabled bool = false
-
-				switch solve.Rank {
-				case 3:
-					rewardScore = float64(solve.Game.ThirdBloodReward) * solve.GameChallenge.CurScore / 100
-					rewardReason = "Third Blood Reward"
-					if solve.Game.ThirdBloodReward != 0 {
-						rankRewardEnabled = true
-					}
-				case 2:
-					rewardScore = float64(solve.Game.SecondBloodReward) * solve.GameChallenge.CurScore / 100
-					rewardReason = "Second Blood Reward"
-					if solve.Game.SecondBloodReward != 0 {
-						rankRewardEnabled = true
-					}
-				case 1:
-					rewardScore = float64(solve.Game.FirstBloodReward) * solve.GameChallenge.CurScore / 100
-					rewardReason = "First Blood Reward"
-					if solve.Game.FirstBloodReward != 0 {
-						rankRewardEnabled = true
-					}
-				}
-
-				if rankRewardEnabled {
-					rewardScore = math.Max(math.Floor(rewardScore), 1)
-
-					rewardReason = fmt.Sprintf("%s for %s", rewardReason, solve.Challenge.Name)
-
-					adjustment := webmodels.TeamScoreAdjustmentItem{
-						AdjustmentID:   -1,
-						AdjustmentType: string(models.AdjustmentTypeReward),
-						ScoreChange:    rewardScore,
-						Reason:         rewardReason,
-						CreatedAt:      solve.SolveTime,
-					}
+	// 题目的当前分数和可见性
+	var gameChallenges []models.GameChallenge
+	if err := dbtool.DB().Where("game_id = ?", gameID).Find(&gameChallenges).Error; err != nil {
+		return nil, errors.New("failed to load game challenges")
+	}
 
-					challengeScore += rewardScore
+	gameChallengeMap := make(map[int64]*models.GameChallenge, len(gameChallenges))
+	for idx := range gameChallenges {
+		gameChallengeMap[gameChallenges[idx].ChallengeID] = &gameChallenges[idx]
+	}
 
-					// 往前端添加三血的加分记录
-					teamData.ScoreAdjustments = append(teamData.ScoreAdjustments, adjustment)
-				}
-			}
+	// 获取分数修正
+	var adjustments []models.ScoreAdjustment
+	if err := dbtool.DB().Where("game_id = ?", gameID).Find(&adjustments).Error; err != nil {
+		return nil, errors.New("failed to load score adjustments")
+	}
 
-			teamData.Score += challengeScore
-			teamData.Penalty += penalty
+	// 攻防模式每轮结算的分数
+	adScoreMap := make(map[int64]*webmodels.TeamADScoreItem)
+	if game.GameMode == models.GameModeAttackDefense {
+		type roundScoreRow struct {
+			TeamID       int64   `gorm:"column:team_id"`
+			AttackScore  float64 `gorm:"column:attack_score"`
+			DefenseScore float64 `gorm:"column:defense_score"`
+			SLAScore     float64 `gorm:"column:sla_score"`
+		}
 
-			// 插入解题记录
-			teamData.SolvedChallenges = append(teamData.SolvedChallenges, webmodels.TeamSolveItem{
-				ChallengeID:   solve.ChallengeID,
-				Score:         challengeScore,
-				Solver:        solve.Solver.Username,
-				Rank:          int64(solve.Rank),
-				SolveTime:     solve.SolveTime,
-				BloodReward:   rewardScore,
-				ChallengeName: solve.Challenge.Name,
-			})
+		var roundScores []roundScoreRow
+		if err := dbtool.DB().Model(&models.ADRoundScore{}).
+			Select("team_id, SUM(attack_score) AS attack_score, SUM(defense_score) AS defense_score, SUM(sla_score) AS sla_score").
+			Where("game_id = ?", gameID).
+			Group("team_id").
+			Scan(&roundScores).Error; err != nil {
+			return nil, errors.New("failed to load attack-defense scores")
+		}
 
-			// 更新最后解题时间
-			if teamData.LastSolveTime < solve.SolveTime.UnixMilli() {
-				teamData.LastSolveTime = solve.SolveTime.UnixMilli()
+		for _, roundScore := range roundScores {
+			adScoreMap[roundScore.TeamID] = &webmodels.TeamADScoreItem{
+				AttackScore:  roundScore.AttackScore,
+				DefenseScore: roundScore.DefenseScore,
+				SLAScore:     roundScore.SLAScore,
 			}
-
-			teamDataMap[solve.TeamID] = teamData
 		}
 	}
 
-	// 获取并应用分数修正
-	var adjustments []models.ScoreAdjustment
-	if err := dbtool.DB().Where("game_id = ?", gameID).Find(&adjustments).Error; err != nil {
-		return nil, errors.New("failed to load score adjustments")
+	// KOTH 题目占领靶机的分数
+	type kothScoreRow struct {
+		TeamID int64   `gorm:"column:team_id"`
+		Points float64 `gorm:"column:points"`
 	}
 
-	// 应用分数修正到队伍数据
-	for _, adjustment := range adjustments {
-		if teamData, exists := teamDataMap[adjustment.TeamID]; exists {
-			// 应用修正
-			teamData.Score += adjustment.ScoreChange
+	var kothScores []kothScoreRow
+	if err := dbtool.DB().Model(&models.KothTick{}).
+		Select("team_id, SUM(points) AS points").
+		Where("game_id = ? AND team_id IS NOT NULL", gameID).
+		Group("team_id").
+		Scan(&kothScores).Error; err != nil {
+		return nil, errors.New("failed to load king of the hill scores")
+	}
 
-			// 添加分数修正到队伍的分数修正列表
-			if teamData.ScoreAdjustments == nil {
-				teamData.ScoreAdjustments = make([]webmodels.TeamScoreAdjustmentItem, 0)
-			}
-			teamData.ScoreAdjustments = append(teamData.ScoreAdjustments, webmodels.TeamScoreAdjustmentItem{
-				AdjustmentID:   adjustment.AdjustmentID,
-				AdjustmentType: string(adjustment.AdjustmentType),
-				ScoreChange:    adjustment.ScoreChange,
-				Reason:         adjustment.Reason,
-				CreatedAt:      adjustment.CreatedAt,
-			})
-			teamDataMap[adjustment.TeamID] = teamData
-		}
+	kothScoreMap := make(map[int64]float64, len(kothScores))
+	for _, kothScore := range kothScores {
+		kothScoreMap[kothScore.TeamID] = kothScore.Points
 	}
 
+	BuildTeamScoreItems(&game, teamDataMap, solves, gameChallengeMap, adjustments, adScoreMap, kothScoreMap)
+
 	// 转换为切片并排序
 	teamRankings := make([]webmodels.TeamScoreItem, 0, len(teamDataMap))
 	for _, teamData := range teamDataMap {
@@ -562,6 +611,8 @@ func CalculateGameScoreBoard(gameID int64) (*webmodels.CachedGameScoreBoardData,
 			ScoreAdjustments: teamData.ScoreAdjustments,
 			GroupID:          teamData.GroupID,
 			GroupName:        teamData.GroupName,
+			ADScore:          teamData.ADScore,
+			KothScore:        teamData.KothScore,
 		}
 		finalScoreBoardMap[teamData.TeamID] = tmp
 		processedTeamRankings = append(processedTeamRankings, tmp)
@@ -587,6 +638,8 @@ func CalculateGameScoreBoard(gameID int64) (*webmodels.CachedGameScoreBoardData,
 			ScoreAdjustments: teamData.ScoreAdjustments,
 			GroupID:          teamData.GroupID,
 			GroupName:        teamData.GroupName,
+			ADScore:          teamData.ADScore,
+			KothScore:        teamData.KothScore,
 		})
 		// 防止队伍数量少于 10报错
 		idx += 1
@@ -886,6 +939,7 @@ func CachedGameSimpleChallenges(gameID int64) ([]webmodels.UserSimpleGameChallen
 				Category:      gc.Challenge.Category,
 				Visible:       gc.Visible,
 				BelongStage:   gc.BelongStage,
+				ChallengeKind: gc.ChallengeKind,
 			})
 		}
 
@@ -1196,7 +1250,7 @@ func CachedAllTeamFlags(gameID int64, challengeID int64) (map[int64]*models.Team
 
 	obj, err := GetOrCacheSingleFlight(fmt.Sprintf("all_team_flags_%d_%d", gameID, challengeID), func() (interface{}, error) {
 		var flags []models.TeamFlag
-		if err := dbtool.DB().Where("game_id = ? AND challenge_id = ?", gameID, challengeID).Find(&flags).Error; err != nil {
+		if err := dbtool.DB().Where("game_id = ? AND challenge_id = ? AND round = 0", gameID, challengeID).Find(&flags).Error; err != nil {
 			return nil, errors.New("failed to query team flags")
 		}
 
@@ -12,6 +12,12 @@ type UserAttachmentConfig struct {
 	AttachURL    *string               `json:"attach_url,omitempty"`
 	AttachHash   *string               `json:"attach_hash,omitempty"`
 	DownloadHash *string               `json:"download_hash,omitempty"`
+	// 动态附件的生成状态，还没有请求生成时为空
+	GenerateStatus *models.TeamAttachmentStatus `json:"generate_status,omitempty"`
+}
+
+type UserGenerateAttachmentPayload struct {
+	AttachName string `json:"attach_name" binding:"required"`
 }
 
 type UserCreateTeamPayload struct {
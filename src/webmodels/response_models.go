@@ -62,6 +62,7 @@ type UserSimpleGameChallenge struct {
 	Category      models.ChallengeCategory `json:"category"`
 	Visible       bool                     `json:"visible"`
 	BelongStage   *string                  `json:"belong_stage"`
+	ChallengeKind models.GameChallengeKind `json:"challenge_kind"`
 }
 
 type ExposePortInfo struct {
@@ -85,6 +86,30 @@ type UserDetailGameChallenge struct {
 	ContainerExpireTime *time.Time                    `json:"container_expiretime"`
 	Containers          []ExposePortInfo              `json:"containers"`
 	Visible             bool                          `json:"visible"`
+	ChallengeKind       models.GameChallengeKind      `json:"challenge_kind"`
+	Koth                *UserKothStatus               `json:"koth,omitempty"`
+	// 选手容器的有效期策略，静态容器和没有容器的题目为空
+	ContainerLifetime    *UserContainerLifetime `json:"container_lifetime,omitempty"`
+	ContainerExtendCount int64                  `json:"container_extend_count"`
+}
+
+// UserContainerLifetime 容器的有效期策略，单位秒，为 0 的上限表示不限制
+type UserContainerLifetime struct {
+	InitialTTL     int64 `json:"initial_ttl"`
+	ExtendDuration int64 `json:"extend_duration"`
+	ExtendWindow   int64 `json:"extend_window"`
+	MaxExtensions  int64 `json:"max_extensions"`
+	MaxLifetime    int64 `json:"max_lifetime"`
+	IdleTimeout    int64 `json:"idle_timeout"`
+}
+
+type UserKothStatus struct {
+	TickInterval   int64      `json:"tick_interval"`
+	PointsPerTick  float64    `json:"points_per_tick"`
+	HolderTeamID   *int64     `json:"holder_team_id"`
+	HolderTeamName *string    `json:"holder_team_name"`
+	LastTickTime   *time.Time `json:"last_tick_time"`
+	TeamPoints     float64    `json:"team_points"`
 }
 
 type GameNotice struct {
@@ -206,6 +231,16 @@ type TeamScoreItem struct {
 	SolvedChallenges []TeamSolveItem           `json:"solved_challenges"`
 	ScoreAdjustments []TeamScoreAdjustmentItem `json:"score_adjustments"`
 	LastSolveTime    int64                     `json:"last_solve_time"`
+	// 攻防模式各项得分
+	ADScore *TeamADScoreItem `json:"ad_score,omitempty"`
+	// KOTH 题目占领得分
+	KothScore float64 `json:"koth_score,omitempty"`
+}
+
+type TeamADScoreItem struct {
+	AttackScore  float64 `json:"attack_score"`
+	DefenseScore float64 `json:"defense_score"`
+	SLAScore     float64 `json:"sla_score"`
 }
 
 type CachedGameScoreBoardData struct {